@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"sigs.k8s.io/yaml"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+// Role decides which calls of the api a key can make, each role can make the calls of the lower roles
+type Role string
+
+const (
+	// list and get jobs, scenarios and workers
+	ROLE_VIEWER Role = "viewer"
+	// start, stop, restart, schedule and patch jobs
+	ROLE_OPERATOR Role = "operator"
+	// create, clone and delete jobs and run scenarios
+	ROLE_ADMIN Role = "admin"
+)
+
+var roleLevels = map[Role]int{
+	ROLE_VIEWER:   1,
+	ROLE_OPERATOR: 2,
+	ROLE_ADMIN:    3,
+}
+
+// API_KEYS_ENV is the environment variable holding keys as a comma separated list of name:role:key
+const API_KEYS_ENV = "CHAMELEON_API_KEYS"
+
+// API_KEY_HEADER is the header a key can be sent with, instead of a bearer token
+const API_KEY_HEADER = "X-Api-Key"
+
+// the context keys of the authenticated key
+const (
+	CONTEXT_PRINCIPAL = "principal"
+	CONTEXT_ROLE      = "role"
+)
+
+type Key struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+	Role Role   `json:"role"`
+}
+
+type KeysConfiguration struct {
+	Keys []Key `json:"keys"`
+}
+
+func (k Key) validate() error {
+	if k.Key == "" {
+		return fmt.Errorf("key %s is empty", k.Name)
+	}
+	if _, ok := roleLevels[k.Role]; !ok {
+		return fmt.Errorf("invalid role %s of key %s", k.Role, k.Name)
+	}
+	return nil
+}
+
+// LoadKeys reads the keys from a yaml or json file
+func LoadKeys(file string) ([]Key, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	config := KeysConfiguration{}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("invalid keys file %s : %w", file, err)
+	}
+	return config.Keys, nil
+}
+
+// ParseKeys reads keys from a comma separated list of name:role:key
+func ParseKeys(value string) ([]Key, error) {
+	keys := make([]Key, 0)
+	for index, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		parts := strings.SplitN(item, ":", 3)
+		if len(parts) != 3 {
+			// the item is not in the error, it can be a secret
+			return nil, fmt.Errorf("invalid key %d, it has to be name:role:key", index)
+		}
+		keys = append(keys, Key{Name: parts[0], Role: Role(parts[1]), Key: parts[2]})
+	}
+	return keys, nil
+}
+
+// Authenticator checks the key of each call against the role required by the route, all calls are
+// allowed when it has no key
+type Authenticator struct {
+	keys []Key
+}
+
+func NewAuthenticator(keys []Key) (*Authenticator, error) {
+	names := make(map[string]struct{}, len(keys))
+	for index, key := range keys {
+		if key.Name == "" {
+			keys[index].Name = fmt.Sprintf("key-%d", index)
+		}
+		if err := keys[index].validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := names[keys[index].Name]; ok {
+			return nil, fmt.Errorf("key %s is defined twice", keys[index].Name)
+		}
+		names[keys[index].Name] = struct{}{}
+	}
+	return &Authenticator{keys: keys}, nil
+}
+
+func (a *Authenticator) Enabled() bool {
+	return len(a.keys) > 0
+}
+
+// authenticate returns the key matching the bearer token or the api key header of the request
+func (a *Authenticator) authenticate(r *http.Request) *Key {
+	token := r.Header.Get(API_KEY_HEADER)
+	if header := r.Header.Get("Authorization"); token == "" && strings.HasPrefix(header, "Bearer ") {
+		token = strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+	}
+	if token == "" {
+		return nil
+	}
+
+	var found *Key
+	for index := range a.keys {
+		// every key is compared so the time taken does not tell which key matched
+		if subtle.ConstantTimeCompare([]byte(a.keys[index].Key), []byte(token)) == 1 {
+			found = &a.keys[index]
+		}
+	}
+	return found
+}
+
+// Require rejects the calls without a key, with 401, or with a key of a lower role, with 403
+func (a *Authenticator) Require(role Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !a.Enabled() {
+			c.Next()
+			return
+		}
+
+		key := a.authenticate(c.Request)
+		if key == nil {
+			c.Header("WWW-Authenticate", "Bearer")
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "missing or invalid api key"})
+			return
+		}
+
+		c.Set(CONTEXT_PRINCIPAL, key.Name)
+		c.Set(CONTEXT_ROLE, string(key.Role))
+		if roleLevels[key.Role] < roleLevels[role] {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("role %s is required", role)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// Audit logs the calls changing the state of the server, with the key making them and their outcome
+func Audit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		method := c.Request.Method
+		if method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		c.Next()
+
+		log.Logger().WithFields(logrus.Fields{
+			"audit":     true,
+			"principal": c.GetString(CONTEXT_PRINCIPAL),
+			"role":      c.GetString(CONTEXT_ROLE),
+			"method":    method,
+			"path":      c.Request.URL.Path,
+			"route":     c.FullPath(),
+			"status":    c.Writer.Status(),
+			"clientIP":  c.ClientIP(),
+		}).Info("audit")
+	}
+}
@@ -0,0 +1,55 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+// RegisterRequest is sent by a worker to register itself to a coordinator
+type RegisterRequest struct {
+	Id      string `json:"id,omitempty"`
+	Address string `json:"address"`
+}
+
+// RunAgent registers the worker to the coordinator every heartbeat interval until the context is done,
+// the worker is then unregistered, the api key needs the operator role on the coordinator
+func RunAgent(ctx context.Context, coordinator string, apiKey string, worker RegisterRequest) {
+	c := newClient(&http.Client{}, apiKey)
+	workersUrl := strings.TrimSuffix(coordinator, "/") + "/api/workers"
+
+	register := func() {
+		registerCtx, cancel := context.WithTimeout(ctx, REQUEST_TIMEOUT)
+		defer cancel()
+		if err := c.request(registerCtx, http.MethodPost, workersUrl, worker, nil); err != nil && ctx.Err() == nil {
+			log.Logger().WithError(err).Warnf("failed to register to coordinator %s", coordinator)
+		}
+	}
+
+	log.Logger().Infof("register worker %s to coordinator %s", worker.Address, coordinator)
+	register()
+	ticker := time.NewTicker(HEARTBEAT_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			id := worker.Id
+			if id == "" {
+				id = WorkerId(worker.Address)
+			}
+			unregisterCtx, cancel := context.WithTimeout(context.Background(), REQUEST_TIMEOUT)
+			defer cancel()
+			if err := c.request(unregisterCtx, http.MethodDelete, fmt.Sprintf("%s/%s", workersUrl, url.PathEscape(id)), nil, nil); err != nil {
+				log.Logger().WithError(err).Warnf("failed to unregister from coordinator %s", coordinator)
+			}
+			return
+		case <-ticker.C:
+			register()
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+)
+
+// client calls the job api of the workers
+type client struct {
+	http *http.Client
+	// sent as bearer token when it is set
+	apiKey string
+}
+
+func newClient(httpClient *http.Client, apiKey string) *client {
+	return &client{http: httpClient, apiKey: apiKey}
+}
+
+// request sends the payload as json and decodes the response into result when it is not nil
+func (c *client) request(ctx context.Context, method string, url string, payload interface{}, result interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if res.StatusCode > 299 || res.StatusCode < 200 {
+		return fmt.Errorf("request failed with status code %d, response body %s", res.StatusCode, resBody)
+	}
+
+	if result == nil {
+		return nil
+	}
+	return json.Unmarshal(resBody, result)
+}
+
+func jobsUrl(address string) string {
+	return strings.TrimSuffix(address, "/") + "/api/jobs"
+}
+
+// createTimeout is the timeout of a create call, a worker responds once the services of the part are ready
+func createTimeout(config job.JobConfiguration) time.Duration {
+	if config.Readiness == nil {
+		return REQUEST_TIMEOUT
+	}
+
+	readiness := config.Readiness.Timeout
+	if readiness == 0 {
+		readiness = job.DEFAULT_READINESS_TIMEOUT
+	}
+	return time.Duration(readiness)*time.Second + REQUEST_TIMEOUT
+}
+
+// createJob creates and starts a job on the worker, it returns the id of the job
+func (c *client) createJob(ctx context.Context, worker *Worker, config job.JobConfiguration) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, createTimeout(config))
+	defer cancel()
+
+	response := struct {
+		Id string `json:"id"`
+	}{}
+	if err := c.request(ctx, http.MethodPost, jobsUrl(worker.Address), config, &response); err != nil {
+		return "", err
+	}
+	return response.Id, nil
+}
+
+func (c *client) getJob(ctx context.Context, worker *Worker, id string) (*job.RemoteState, error) {
+	ctx, cancel := context.WithTimeout(ctx, REQUEST_TIMEOUT)
+	defer cancel()
+
+	response := struct {
+		Status job.JobStatus `json:"status"`
+		Stats  *job.Stats    `json:"stats"`
+	}{}
+	if err := c.request(ctx, http.MethodGet, fmt.Sprintf("%s/%s", jobsUrl(worker.Address), id), nil, &response); err != nil {
+		return nil, err
+	}
+	return &job.RemoteState{Worker: worker.Id, JobId: id, Status: response.Status, Stats: response.Stats}, nil
+}
+
+func (c *client) stopJob(ctx context.Context, worker *Worker, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, STOP_TIMEOUT)
+	defer cancel()
+	return c.request(ctx, http.MethodPost, fmt.Sprintf("%s/%s/stop", jobsUrl(worker.Address), id), nil, nil)
+}
+
+// remoteJob is the part of a distributed job run by a worker
+type remoteJob struct {
+	client *client
+	worker *Worker
+	id     string
+}
+
+func (r *remoteJob) Worker() string {
+	return r.worker.Id
+}
+
+func (r *remoteJob) Poll(ctx context.Context) (*job.RemoteState, error) {
+	return r.client.getJob(ctx, r.worker, r.id)
+}
+
+func (r *remoteJob) Stop(ctx context.Context) error {
+	return r.client.stopJob(ctx, r.worker, r.id)
+}
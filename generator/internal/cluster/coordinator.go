@@ -0,0 +1,82 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+// REQUEST_TIMEOUT is the timeout of the calls to the workers which return without waiting for a job,
+// creating or stopping a part waits longer, see createTimeout and STOP_TIMEOUT
+const REQUEST_TIMEOUT = 10 * time.Second
+
+// STOP_TIMEOUT is the timeout of a stop call, a worker responds once its part is drained
+const STOP_TIMEOUT = job.DRAIN_TIMEOUT + REQUEST_TIMEOUT
+
+// Coordinator dispatches the parts of the distributed jobs to the registered workers
+type Coordinator struct {
+	registry *Registry
+	client   *client
+}
+
+// NewCoordinator creates a coordinator calling the workers with the api key, it needs the admin role on the workers
+func NewCoordinator(registry *Registry, apiKey string) *Coordinator {
+	return &Coordinator{
+		registry: registry,
+		// each call has a deadline matching what the worker does before it responds
+		client: newClient(&http.Client{}, apiKey),
+	}
+}
+
+// Dispatch splits the job into one part per worker and creates the parts on the workers, the parts already
+// created are stopped when one of them fails
+func (c *Coordinator) Dispatch(ctx context.Context, config job.JobConfiguration) ([]job.Remote, error) {
+	workers := c.registry.Alive()
+	count := len(workers)
+	if count == 0 {
+		return nil, fmt.Errorf("no worker is available")
+	}
+
+	distribution := config.Distribution
+	if distribution.Workers > 0 {
+		if distribution.Workers > count {
+			return nil, fmt.Errorf("%d workers are required but only %d are available", distribution.Workers, count)
+		}
+		count = distribution.Workers
+	} else if (distribution.Strategy == "" || distribution.Strategy == job.PARTITION_STRATEGY_CONCURRENCY) &&
+		count > config.Source.Concurrency {
+		// each worker runs at least one stream
+		count = config.Source.Concurrency
+	}
+
+	remotes := make([]job.Remote, 0, count)
+	for index, worker := range workers[:count] {
+		part, err := job.Partition(config, index, count)
+		if err != nil {
+			c.stop(remotes)
+			return nil, err
+		}
+		part.Name = fmt.Sprintf("%s-%d", config.Name, index)
+
+		id, err := c.client.createJob(ctx, worker, part)
+		if err != nil {
+			c.stop(remotes)
+			return nil, fmt.Errorf("failed to create part %d on worker %s : %w", index, worker.Id, err)
+		}
+		log.Logger().Infof("part %d of job %s is created on worker %s as job %s", index, config.Name, worker.Id, id)
+		remotes = append(remotes, &remoteJob{client: c.client, worker: worker, id: id})
+	}
+	return remotes, nil
+}
+
+func (c *Coordinator) stop(remotes []job.Remote) {
+	for _, remote := range remotes {
+		if err := remote.Stop(context.Background()); err != nil {
+			log.Logger().WithError(err).Warnf("failed to stop part on worker %s", remote.Worker())
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package cluster
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HEARTBEAT_INTERVAL is how often a worker registers itself again to the coordinator
+const HEARTBEAT_INTERVAL = 5 * time.Second
+
+// WORKER_TTL is how long a worker is available after its last heartbeat
+const WORKER_TTL = 3 * HEARTBEAT_INTERVAL
+
+// Worker is a generator server running the parts of the distributed jobs of a coordinator
+type Worker struct {
+	Id           string    `json:"id"`
+	Address      string    `json:"address"`
+	RegisteredAt time.Time `json:"registered_at"`
+	LastSeen     time.Time `json:"last_seen"`
+	Alive        bool      `json:"alive"`
+}
+
+// Registry keeps the workers registered to a coordinator
+type Registry struct {
+	workers map[string]*Worker
+	ttl     time.Duration
+	lock    sync.Mutex
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		workers: make(map[string]*Worker),
+		ttl:     WORKER_TTL,
+	}
+}
+
+// WorkerId returns the default id of a worker, its address without the scheme
+func WorkerId(address string) string {
+	if index := strings.Index(address, "://"); index >= 0 {
+		address = address[index+3:]
+	}
+	return strings.TrimSuffix(address, "/")
+}
+
+// Register adds a worker or refreshes its heartbeat, the id of the worker defaults to its address
+func (r *Registry) Register(id string, address string) (*Worker, error) {
+	if address == "" {
+		return nil, fmt.Errorf("worker address is required")
+	}
+	if id == "" {
+		id = WorkerId(address)
+	}
+
+	now := time.Now().UTC()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	worker, ok := r.workers[id]
+	if !ok {
+		worker = &Worker{Id: id, RegisteredAt: now}
+		r.workers[id] = worker
+	}
+	worker.Address = address
+	worker.LastSeen = now
+
+	result := *worker
+	result.Alive = true
+	return &result, nil
+}
+
+func (r *Registry) Unregister(id string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.workers[id]; !ok {
+		return fmt.Errorf("%s worker does not exist", id)
+	}
+	delete(r.workers, id)
+	return nil
+}
+
+// List returns all the registered workers sorted by id
+func (r *Registry) List() []*Worker {
+	now := time.Now()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	workers := make([]*Worker, 0, len(r.workers))
+	for _, worker := range r.workers {
+		result := *worker
+		result.Alive = now.Sub(worker.LastSeen) <= r.ttl
+		workers = append(workers, &result)
+	}
+	sort.Slice(workers, func(i, j int) bool {
+		return workers[i].Id < workers[j].Id
+	})
+	return workers
+}
+
+// Alive returns the workers whose last heartbeat is within the ttl, sorted by id
+func (r *Registry) Alive() []*Worker {
+	alive := make([]*Worker, 0)
+	for _, worker := range r.List() {
+		if worker.Alive {
+			alive = append(alive, worker)
+		}
+	}
+	return alive
+}
@@ -0,0 +1,128 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"sigs.k8s.io/yaml"
+
+	"github.com/timeplus-io/chameleon/generator/internal/templates"
+)
+
+var newOptions = struct {
+	template string
+	output   string
+	values   []string
+	list     bool
+}{}
+
+var newCmd = &cobra.Command{
+	Use:   "new",
+	Short: "write a job configuration file from a template",
+	Long: `write a job configuration file from a builtin template or a template of the template directory,
+for example: generator new --template iot --set rate=5000 --set duration=60 -o iot.yaml`,
+	Args: cobra.NoArgs,
+	RunE: runNew,
+}
+
+func init() {
+	newCmd.Flags().StringVarP(&newOptions.template, "template", "t", "", "name of the template")
+	newCmd.Flags().StringVarP(&newOptions.output, "output", "o", "", "the yaml or json file to write, default to <template>.yaml")
+	newCmd.Flags().StringArrayVar(&newOptions.values, "set", nil, "the value of a parameter as name=value, the value is parsed as yaml")
+	newCmd.Flags().BoolVarP(&newOptions.list, "list", "l", false, "list the templates and their parameters")
+	rootCmd.AddCommand(newCmd)
+}
+
+func runNew(cmd *cobra.Command, _ []string) error {
+	catalogue, err := templates.NewCatalogue(viper.GetString("template-dir"))
+	if err != nil {
+		return err
+	}
+
+	if newOptions.list {
+		printTemplates(cmd, catalogue)
+		return nil
+	}
+
+	if newOptions.template == "" {
+		return fmt.Errorf("--template is required, use --list to list the templates")
+	}
+	t, err := catalogue.Get(newOptions.template)
+	if err != nil {
+		return err
+	}
+
+	values, err := parseValues(newOptions.values)
+	if err != nil {
+		return err
+	}
+
+	// the job is checked before the rendered configuration is written
+	if _, err := t.Instantiate(values); err != nil {
+		return err
+	}
+	config, err := t.Render(values)
+	if err != nil {
+		return err
+	}
+
+	output := newOptions.output
+	if output == "" {
+		output = t.Name + ".yaml"
+	}
+
+	var data []byte
+	switch filepath.Ext(output) {
+	case ".json":
+		data, err = json.MarshalIndent(config, "", "  ")
+	case ".yaml", ".yml":
+		data, err = yaml.Marshal(config)
+	default:
+		return fmt.Errorf("output has to be a json or yaml file")
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(output, data, 0644); err != nil {
+		return err
+	}
+	cmd.Printf("job configuration of template %s is written to %s\n", t.Name, output)
+	return nil
+}
+
+// parseValues reads the name=value parameters, the values are parsed as yaml so numbers and lists keep their type
+func parseValues(items []string) (map[string]interface{}, error) {
+	values := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		name, raw, ok := strings.Cut(item, "=")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid parameter %s, it has to be name=value", item)
+		}
+
+		var value interface{}
+		if err := yaml.Unmarshal([]byte(raw), &value); err != nil {
+			return nil, fmt.Errorf("invalid value of parameter %s : %w", name, err)
+		}
+		values[name] = value
+	}
+	return values, nil
+}
+
+func printTemplates(cmd *cobra.Command, catalogue *templates.Catalogue) {
+	for _, t := range catalogue.List() {
+		cmd.Printf("%s (%s)\n  %s\n", t.Name, t.Origin, t.Description)
+		for _, parameter := range t.AllParameters() {
+			if parameter.Default != nil {
+				cmd.Printf("  --set %s=...  %s, default %v\n", parameter.Name, parameter.Description, parameter.Default)
+			} else {
+				cmd.Printf("  --set %s=...  %s\n", parameter.Name, parameter.Description)
+			}
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/observer"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+)
+
+var pluginsCmd = &cobra.Command{
+	Use:   "plugins",
+	Short: "list the registered sinks and observers with their properties",
+	Args:  cobra.NoArgs,
+	RunE:  runPlugins,
+}
+
+func init() {
+	rootCmd.AddCommand(pluginsCmd)
+}
+
+func runPlugins(cmd *cobra.Command, _ []string) error {
+	log.ToStderr()
+	server.InitPlugins()
+
+	out := cmd.OutOrStdout()
+	fmt.Fprintln(out, "sinks:")
+	for _, name := range sorted(sink.ListRegisteredSinkTypes()) {
+		fmt.Fprintf(out, "  %s\n", name)
+		properties, _ := sink.GetProperties(name)
+		printProperties(out, properties)
+	}
+	fmt.Fprintln(out, "observers:")
+	for _, name := range sorted(observer.ListRegisteredSinkTypes()) {
+		fmt.Fprintf(out, "  %s\n", name)
+		properties, _ := observer.GetProperties(name)
+		printProperties(out, properties)
+	}
+	return nil
+}
+
+func sorted(names []string) []string {
+	sort.Strings(names)
+	return names
+}
+
+// printProperties prints a line per property with its type, default, flags and description
+func printProperties(out io.Writer, properties schema.Properties) {
+	writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	for _, property := range properties {
+		attributes := make([]string, 0)
+		if property.Default != nil {
+			attributes = append(attributes, fmt.Sprintf("default %v", property.Default))
+		}
+		if len(property.Enum) > 0 {
+			attributes = append(attributes, "one of "+strings.Join(property.Enum, "|"))
+		}
+		if property.Required {
+			attributes = append(attributes, "required")
+		}
+		if property.Secret {
+			attributes = append(attributes, "secret")
+		}
+		fmt.Fprintf(writer, "    %s\t%s\t%s\t%s\n", property.Name, property.Type, strings.Join(attributes, ", "), property.Description)
+	}
+	writer.Flush()
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+)
+
+var previewOptions = struct {
+	count int
+}{}
+
+var previewCmd = &cobra.Command{
+	Use:   "preview <job.yaml>",
+	Short: "print sample events of a job",
+	Long: `print sample events generated by the source of a job configuration file, one json object per line,
+nothing is written to the sinks`,
+	Args: cobra.ExactArgs(1),
+	RunE: runPreview,
+}
+
+func init() {
+	previewCmd.Flags().IntVarP(&previewOptions.count, "count", "n", 10, "number of events to print")
+	rootCmd.AddCommand(previewCmd)
+}
+
+func runPreview(cmd *cobra.Command, args []string) error {
+	log.ToStderr()
+	server.InitPlugins()
+	if previewOptions.count <= 0 {
+		return fmt.Errorf("count has to be positive")
+	}
+
+	config, err := loadJob(args[0])
+	if err != nil {
+		return err
+	}
+	generator, err := source.NewGenarator(config.Source)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	generator.Start(ctx)
+	defer generator.Stop()
+
+	fields := generator.GetFields()
+	printed := 0
+	for printed < previewOptions.count {
+		events := generator.Read()
+		if len(events) == 0 {
+			// the source is finished
+			break
+		}
+		for _, event := range events {
+			line, err := eventJSON(event, fields)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), line)
+			if printed++; printed == previewOptions.count {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// eventJSON encodes the event with its values in the order of the fields, the values which are not
+// a field come last by name
+func eventJSON(event common.Event, fields []common.Field) (string, error) {
+	keys := make([]string, 0, len(event))
+	seen := make(map[string]struct{}, len(event))
+	for _, field := range fields {
+		if _, ok := event[field.Name]; ok {
+			keys = append(keys, field.Name)
+			seen[field.Name] = struct{}{}
+		}
+	}
+	others := make([]string, 0)
+	for key := range event {
+		if _, ok := seen[key]; !ok {
+			others = append(others, key)
+		}
+	}
+	sort.Strings(others)
+	keys = append(keys, others...)
+
+	buffer := bytes.Buffer{}
+	buffer.WriteByte('{')
+	for index, key := range keys {
+		if index > 0 {
+			buffer.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return "", err
+		}
+		value, err := json.Marshal(event[key])
+		if err != nil {
+			return "", err
+		}
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+	return buffer.String(), nil
+}
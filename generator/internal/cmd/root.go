@@ -20,15 +20,19 @@ var cfgFile string
 var rootCmd = &cobra.Command{
 	Use:   "generator",
 	Short: "generating stream data",
-	Long:  ``,
-	RunE:  server.Run,
+	Long: `generating stream data, run a job with generator run, or serve the api with generator serve.
+the flags -S, -f and --scenario-file of the root command are kept for the existing scripts`,
+	RunE:          server.Run,
+	SilenceUsage:  true,
+	SilenceErrors: true,
 }
 
 func Execute() {
-	config.Conf.ApplyToCobra(rootCmd)
+	// the flags are shared by all the commands
+	config.Conf.ApplyToCobraPersistent(rootCmd)
 
 	for arg := range config.Conf {
-		viper.BindPFlag(arg, rootCmd.Flags().Lookup(arg))
+		viper.BindPFlag(arg, rootCmd.PersistentFlags().Lookup(arg))
 	}
 
 	viper.WriteConfig()
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+)
+
+var runOptions = struct {
+	progress time.Duration
+	output   string
+}{}
+
+var runCmd = &cobra.Command{
+	Use:   "run <job.yaml>...",
+	Short: "run jobs until they finish",
+	Long: `run the jobs of the configuration files in parallel, until they time out, their source completes or
+the process is interrupted, and print their progress. exit with an error when a job fails or rows
+cannot be written`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runJobs,
+}
+
+func init() {
+	runCmd.Flags().DurationVar(&runOptions.progress, "progress", 5*time.Second, "interval between two progress lines, 0 to only print the summary")
+	runCmd.Flags().StringVar(&runOptions.output, "output", "", "directory where the result of each job is written as json, with the series of the observer metrics")
+	rootCmd.AddCommand(runCmd)
+}
+
+func runJobs(cmd *cobra.Command, files []string) error {
+	log.ToStderr()
+	server.InitPlugins()
+
+	// all the files are checked before any job runs
+	configs := make([]*job.JobConfiguration, len(files))
+	for index, file := range files {
+		config, err := loadJob(file)
+		if err != nil {
+			return fmt.Errorf("invalid job %s : %w", file, err)
+		}
+		if config.Distribution != nil {
+			return fmt.Errorf("invalid job %s : distributed job has to be created on a coordinator", file)
+		}
+		if config.Readiness == nil {
+			config.Readiness = server.ServiceReadiness()
+		}
+		configs[index] = config
+	}
+
+	jobs := make([]*job.Job, 0, len(configs))
+	for index, config := range configs {
+		j, err := job.NewRunJob(*config)
+		if err != nil {
+			for _, created := range jobs {
+				created.Stop()
+			}
+			return fmt.Errorf("failed to create job %s : %w", files[index], err)
+		}
+		jobs = append(jobs, j)
+	}
+
+	done := make(chan struct{})
+	waiter := sync.WaitGroup{}
+	for _, j := range jobs {
+		waiter.Add(1)
+		go func(j *job.Job) {
+			defer waiter.Done()
+			j.RunToEnd()
+		}(j)
+	}
+	go func() {
+		waiter.Wait()
+		close(done)
+	}()
+
+	// an interrupted run stops the jobs, their sinks are flushed before the summary
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	defer signal.Stop(quit)
+
+	telemetries := make([]*job.Telemetry, len(jobs))
+	for index, j := range jobs {
+		telemetries[index] = job.NewTelemetry(j)
+	}
+
+	var ticks <-chan time.Time
+	if runOptions.progress > 0 {
+		ticker := time.NewTicker(runOptions.progress)
+		defer ticker.Stop()
+		ticks = ticker.C
+	}
+
+	for running := true; running; {
+		select {
+		case <-ticks:
+			for index, j := range jobs {
+				fmt.Fprintln(cmd.OutOrStdout(), progressLine(j.Name, telemetries[index].Next()))
+			}
+		case <-quit:
+			fmt.Fprintln(cmd.OutOrStdout(), "interrupted, stopping the jobs")
+			for _, j := range jobs {
+				go j.Stop()
+			}
+		case <-done:
+			running = false
+		}
+	}
+
+	failed := 0
+	for _, j := range jobs {
+		run := j.CurrentRun()
+		fmt.Fprintln(cmd.OutOrStdout(), summaryLine(j.Name, run))
+		if run.Status == job.STATUS_FAILED || run.Stats.FailedWrite > 0 {
+			failed++
+		}
+	}
+	if runOptions.output != "" {
+		for _, j := range jobs {
+			if err := writeResult(runOptions.output, j.Result()); err != nil {
+				return err
+			}
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d jobs failed", failed, len(jobs))
+	}
+	return nil
+}
+
+// writeResult writes the result of a run as <job name>_<run id>.json in the directory
+func writeResult(dir string, result *job.RunRecord) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%s_%s.json", result.JobName, result.Id))
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		return fmt.Errorf("failed to write the result of job %s : %w", result.JobName, err)
+	}
+	return nil
+}
+
+func progressLine(name string, snapshot *job.Snapshot) string {
+	line := fmt.Sprintf("%s %s generated %d (%.0f/s)", name, snapshot.Status, snapshot.Generated, snapshot.GeneratedEps)
+	sinks := make([]string, len(snapshot.Sinks))
+	for index, s := range snapshot.Sinks {
+		sinks[index] = fmt.Sprintf("%s written %d (%.0f/s) failed %d", s.Type, s.SuccessWrite, s.WriteRate, s.FailedWrite)
+	}
+	if len(sinks) > 0 {
+		line += ", " + strings.Join(sinks, ", ")
+	}
+	return line
+}
+
+func summaryLine(name string, run *job.Run) string {
+	duration := time.Duration(0)
+	if run.StartedAt != nil && run.StoppedAt != nil {
+		duration = run.StoppedAt.Sub(*run.StartedAt).Round(time.Millisecond)
+	}
+	line := fmt.Sprintf("%s %s in %s, generated %d, written %d, failed %d",
+		name, run.Status, duration, run.Stats.Generated, run.Stats.SuccessWrite, run.Stats.FailedWrite)
+	if run.Stats.BytesWritten != nil {
+		line += fmt.Sprintf(", %d bytes", *run.Stats.BytesWritten)
+	}
+	return line
+}
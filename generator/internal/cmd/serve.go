@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "start the api server",
+	Long: `start the api server, jobs are created and followed through the REST api,
+for example: generator serve -p 3000 --job-store file`,
+	Args: cobra.NoArgs,
+	RunE: server.Serve,
+}
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+)
+
+var validateCmd = &cobra.Command{
+	Use:   "validate <job.yaml>...",
+	Short: "check job configuration files",
+	Long: `check the source, the sinks and observers, the schedule and the distribution of job configuration files,
+the targets of the sinks and observers are not reached. exit with an error when a file is invalid`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runValidate,
+}
+
+func init() {
+	rootCmd.AddCommand(validateCmd)
+}
+
+func runValidate(cmd *cobra.Command, files []string) error {
+	log.ToStderr()
+	server.InitPlugins()
+
+	invalid := 0
+	for _, file := range files {
+		if _, err := loadJob(file); err != nil {
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", file, err)
+			invalid++
+			continue
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s: ok\n", file)
+	}
+
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d files are invalid", invalid, len(files))
+	}
+	return nil
+}
+
+// loadJob reads and validates a job configuration file
+func loadJob(file string) (*job.JobConfiguration, error) {
+	config, err := job.LoadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
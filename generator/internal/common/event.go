@@ -29,6 +29,15 @@ func (e Event) GetHeader() []string {
 	return header
 }
 
+// Size returns the length of the json encoding of the event in bytes
+func (e Event) Size() int {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
 func (e Event) GetRow(header []string) []interface{} {
 	row := make([]interface{}, len(header))
 	for i, h := range header {
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// FILE_REFERENCE_PREFIX marks a reference to the content of a file, like ${file:/run/secrets/password}
+const FILE_REFERENCE_PREFIX = "file:"
+
+// matches $${...}, kept as ${...}, and ${...}
+var referenceRe = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+
+// Interpolate replaces the references in a string:
+// ${NAME} is the environment variable NAME, it is an error when NAME is not set,
+// ${NAME:-default} is the environment variable NAME, or default when NAME is not set or empty,
+// ${file:/path} is the content of the file without its trailing new line, and $${...} is kept as ${...}
+func Interpolate(value string) (string, error) {
+	var failure error
+	result := referenceRe.ReplaceAllStringFunc(value, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+
+		resolved, err := resolve(ref[2 : len(ref)-1])
+		if err != nil && failure == nil {
+			failure = err
+		}
+		return resolved
+	})
+	if failure != nil {
+		return "", failure
+	}
+	return result, nil
+}
+
+func resolve(reference string) (string, error) {
+	if path, ok := strings.CutPrefix(reference, FILE_REFERENCE_PREFIX); ok {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s : %w", path, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+
+	name, defaultValue, hasDefault := strings.Cut(reference, ":-")
+	if name == "" {
+		return "", fmt.Errorf("invalid reference ${%s}", reference)
+	}
+	if value, ok := os.LookupEnv(name); ok && (value != "" || !hasDefault) {
+		return value, nil
+	}
+	if hasDefault {
+		return defaultValue, nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set", name)
+}
+
+// InterpolateAll replaces the references in all the strings of a decoded json or yaml document, the maps and
+// lists are changed in place
+func InterpolateAll(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return Interpolate(v)
+	case map[string]interface{}:
+		for key, item := range v {
+			interpolated, err := InterpolateAll(item)
+			if err != nil {
+				return nil, fmt.Errorf("%s : %w", key, err)
+			}
+			v[key] = interpolated
+		}
+		return v, nil
+	case []interface{}:
+		for index, item := range v {
+			interpolated, err := InterpolateAll(item)
+			if err != nil {
+				return nil, fmt.Errorf("%d : %w", index, err)
+			}
+			v[index] = interpolated
+		}
+		return v, nil
+	}
+	return value, nil
+}
@@ -0,0 +1,10 @@
+package common
+
+import "context"
+
+// ReadinessProbe is implemented by the sinks and observers which can check that their service is up, a job
+// with readiness waits for them before it creates its streams and generates events
+type ReadinessProbe interface {
+	// Ready returns an error as long as the service cannot be used
+	Ready(ctx context.Context) error
+}
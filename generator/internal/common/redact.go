@@ -0,0 +1,54 @@
+package common
+
+import (
+	"strings"
+)
+
+// REDACTED replaces the value of a secret when a configuration is returned by the api or logged
+const REDACTED = "******"
+
+// the parts of the property names holding secrets, compared in lower case without _ and -
+var secretKeyParts = []string{"password", "passwd", "secret", "token", "apikey", "privatekey", "credential"}
+
+// IsSecretKey tells whether a property holds a secret, like password, sasl_password, apiKey or hec_token
+func IsSecretKey(key string) bool {
+	normalized := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, part := range secretKeyParts {
+		if strings.Contains(normalized, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactProperties returns a copy of the properties where the values of the secrets are replaced,
+// also in the nested maps and lists
+func RedactProperties(properties map[string]interface{}) map[string]interface{} {
+	if properties == nil {
+		return nil
+	}
+
+	result := make(map[string]interface{}, len(properties))
+	for key, value := range properties {
+		if IsSecretKey(key) && value != nil && value != "" {
+			result[key] = REDACTED
+			continue
+		}
+		result[key] = redactValue(value)
+	}
+	return result
+}
+
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return RedactProperties(v)
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for index, item := range v {
+			result[index] = redactValue(item)
+		}
+		return result
+	}
+	return value
+}
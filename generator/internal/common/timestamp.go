@@ -0,0 +1,205 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+type TimestampEncoding string
+
+const (
+	TIMESTAMP_ENCODING_TIME           TimestampEncoding = "time"
+	TIMESTAMP_ENCODING_LAYOUT         TimestampEncoding = "layout"
+	TIMESTAMP_ENCODING_RFC3339        TimestampEncoding = "rfc3339"
+	TIMESTAMP_ENCODING_RFC3339NANO    TimestampEncoding = "rfc3339nano"
+	TIMESTAMP_ENCODING_EPOCH_S        TimestampEncoding = "epoch_s"
+	TIMESTAMP_ENCODING_EPOCH_MS       TimestampEncoding = "epoch_ms"
+	TIMESTAMP_ENCODING_EPOCH_US       TimestampEncoding = "epoch_us"
+	TIMESTAMP_ENCODING_EPOCH_NS       TimestampEncoding = "epoch_ns"
+	TIMESTAMP_ENCODING_EPOCH_S_FLOAT  TimestampEncoding = "epoch_s_float"
+	TIMESTAMP_ENCODING_EPOCH_MS_FLOAT TimestampEncoding = "epoch_ms_float"
+	TIMESTAMP_ENCODING_EPOCH_US_FLOAT TimestampEncoding = "epoch_us_float"
+	TIMESTAMP_ENCODING_EPOCH_NS_FLOAT TimestampEncoding = "epoch_ns_float"
+)
+
+var epochUnits = map[TimestampEncoding]time.Duration{
+	TIMESTAMP_ENCODING_EPOCH_S:        time.Second,
+	TIMESTAMP_ENCODING_EPOCH_MS:       time.Millisecond,
+	TIMESTAMP_ENCODING_EPOCH_US:       time.Microsecond,
+	TIMESTAMP_ENCODING_EPOCH_NS:       time.Nanosecond,
+	TIMESTAMP_ENCODING_EPOCH_S_FLOAT:  time.Second,
+	TIMESTAMP_ENCODING_EPOCH_MS_FLOAT: time.Millisecond,
+	TIMESTAMP_ENCODING_EPOCH_US_FLOAT: time.Microsecond,
+	TIMESTAMP_ENCODING_EPOCH_NS_FLOAT: time.Nanosecond,
+}
+
+var precisions = map[string]time.Duration{
+	"s":  time.Second,
+	"ms": time.Millisecond,
+	"us": time.Microsecond,
+	"ns": time.Nanosecond,
+}
+
+// TimestampCodec encodes a time into one of the supported timestamp encodings and parses it back,
+// it is shared by the generator and the observers so both sides agree on the representation
+type TimestampCodec struct {
+	Encoding  TimestampEncoding
+	Layout    string
+	Location  *time.Location
+	Precision time.Duration
+}
+
+// NewTimestampCodec validates the encoding, layout, time zone and precision. an empty precision
+// defaults to the unit of epoch encodings, nanoseconds for rfc3339nano and milliseconds otherwise
+func NewTimestampCodec(encoding TimestampEncoding, layout string, locale string, precision string) (*TimestampCodec, error) {
+	codec := &TimestampCodec{
+		Encoding: encoding,
+		Layout:   layout,
+		Location: time.UTC,
+	}
+
+	switch encoding {
+	case TIMESTAMP_ENCODING_TIME, TIMESTAMP_ENCODING_RFC3339:
+		codec.Precision = time.Millisecond
+	case TIMESTAMP_ENCODING_RFC3339NANO:
+		codec.Precision = time.Nanosecond
+	case TIMESTAMP_ENCODING_LAYOUT:
+		if layout == "" {
+			return nil, fmt.Errorf("timestamp encoding %s requires a layout", encoding)
+		}
+		codec.Precision = time.Millisecond
+	default:
+		unit, ok := epochUnits[encoding]
+		if !ok {
+			return nil, fmt.Errorf("unsupported timestamp encoding %s", encoding)
+		}
+		codec.Precision = unit
+		if codec.IsFloat() {
+			codec.Precision = time.Nanosecond
+		}
+	}
+
+	if precision != "" {
+		p, ok := precisions[precision]
+		if !ok {
+			return nil, fmt.Errorf("unsupported timestamp precision %s, support s|ms|us|ns", precision)
+		}
+		codec.Precision = p
+	}
+
+	if locale != "" {
+		location, err := time.LoadLocation(locale)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp locale %s : %w", locale, err)
+		}
+		codec.Location = location
+	}
+
+	return codec, nil
+}
+
+// IsEpoch tells whether the encoding is a number since unix epoch
+func (c *TimestampCodec) IsEpoch() bool {
+	_, ok := epochUnits[c.Encoding]
+	return ok
+}
+
+// IsFloat tells whether the encoding is a floating point number since unix epoch
+func (c *TimestampCodec) IsFloat() bool {
+	switch c.Encoding {
+	case TIMESTAMP_ENCODING_EPOCH_S_FLOAT, TIMESTAMP_ENCODING_EPOCH_MS_FLOAT,
+		TIMESTAMP_ENCODING_EPOCH_US_FLOAT, TIMESTAMP_ENCODING_EPOCH_NS_FLOAT:
+		return true
+	}
+	return false
+}
+
+func (c *TimestampCodec) Encode(t time.Time) interface{} {
+	t = t.Truncate(c.Precision)
+
+	switch c.Encoding {
+	case TIMESTAMP_ENCODING_TIME:
+		return t.In(c.Location)
+	case TIMESTAMP_ENCODING_LAYOUT:
+		return t.In(c.Location).Format(c.Layout)
+	case TIMESTAMP_ENCODING_RFC3339:
+		return t.In(c.Location).Format(time.RFC3339)
+	case TIMESTAMP_ENCODING_RFC3339NANO:
+		return t.In(c.Location).Format(time.RFC3339Nano)
+	}
+
+	unit := epochUnits[c.Encoding]
+	if c.IsFloat() {
+		return float64(t.UnixNano()) / float64(unit)
+	}
+	return t.UnixNano() / int64(unit)
+}
+
+func (c *TimestampCodec) Decode(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		return c.decodeString(v)
+	case float64:
+		return c.fromEpochFloat(v)
+	case float32:
+		return c.fromEpochFloat(float64(v))
+	case int64:
+		return c.fromEpochInt(v)
+	case int:
+		return c.fromEpochInt(int64(v))
+	case int32:
+		return c.fromEpochInt(int64(v))
+	case uint64:
+		return c.fromEpochInt(int64(v))
+	case uint32:
+		return c.fromEpochInt(int64(v))
+	case json.Number:
+		return c.decodeString(v.String())
+	case nil:
+		return time.Time{}, fmt.Errorf("timestamp is missing")
+	}
+	return time.Time{}, fmt.Errorf("unsupported timestamp value %v of type %T", value, value)
+}
+
+func (c *TimestampCodec) decodeString(value string) (time.Time, error) {
+	if c.IsEpoch() {
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return c.fromEpochInt(i)
+		}
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("failed to parse epoch timestamp %s : %w", value, err)
+		}
+		return c.fromEpochFloat(f)
+	}
+
+	switch c.Encoding {
+	case TIMESTAMP_ENCODING_TIME, TIMESTAMP_ENCODING_RFC3339, TIMESTAMP_ENCODING_RFC3339NANO:
+		// time.Time is serialized as RFC3339Nano, which parses the RFC3339 layout with or without fractional seconds
+		return time.Parse(time.RFC3339Nano, value)
+	case TIMESTAMP_ENCODING_LAYOUT:
+		return time.ParseInLocation(c.Layout, value, c.Location)
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %s as timestamp encoding %s", value, c.Encoding)
+}
+
+func (c *TimestampCodec) fromEpochInt(value int64) (time.Time, error) {
+	unit, ok := epochUnits[c.Encoding]
+	if !ok {
+		return time.Time{}, fmt.Errorf("cannot parse number %d as timestamp encoding %s", value, c.Encoding)
+	}
+	return time.Unix(0, value*int64(unit)), nil
+}
+
+func (c *TimestampCodec) fromEpochFloat(value float64) (time.Time, error) {
+	unit, ok := epochUnits[c.Encoding]
+	if !ok {
+		return time.Time{}, fmt.Errorf("cannot parse number %f as timestamp encoding %s", value, c.Encoding)
+	}
+	return time.Unix(0, int64(math.Round(value*float64(unit)))), nil
+}
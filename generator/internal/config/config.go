@@ -26,17 +26,49 @@ var Conf = config.Map{
 	},
 	"wait-service-ready": &config.Bool{
 		Default: false,
-		Usage:   "wait 10s for service ready",
+		Usage:   "probe the services of the sinks and observers of the jobs without readiness until they are ready",
 	},
 	"wait-service-time": &config.String{
 		Default: "10s",
-		Usage:   "wait service to be ready, default 10s",
+		Usage:   "how long the services are waited for with wait-service-ready",
+	},
+	"job-store": &config.String{
+		Default: "memory",
+		Usage:   "where the jobs are kept, support memory|file",
+	},
+	"job-store-path": &config.String{
+		Default: "generator.db",
+		Usage:   "path of the job store database file when job-store is file",
+	},
+	"job-resume-policy": &config.String{
+		Default: "fail",
+		Usage:   "what to do with jobs interrupted by a restart, support fail|resume",
+	},
+	"coordinator": &config.Bool{
+		Default: false,
+		Usage:   "whether the server is a coordinator running the distributed jobs on its registered workers",
+	},
+	"coordinator-address": &config.String{
+		Default: "",
+		Usage:   "url of the coordinator the server registers to as a worker, like http://localhost:3000",
+	},
+	"worker-address": &config.String{
+		Default: "",
+		Usage:   "url the coordinator reaches the worker on, default to http://localhost:<server-port>",
+	},
+	"template-dir": &config.String{
+		Default: "",
+		Usage:   "a directory of yaml or json job templates, added to the builtin templates",
 	},
 	"test-config-file": &config.String{
 		Default:   "",
 		Usage:     "a json configuration file of test target",
 		Shorthand: "f",
 	},
+	"scenario-file": &config.String{
+		Default: "",
+		Usage:   "a json or yaml scenario file to run, exit with an error when the scenario fails",
+	},
 	"log-level": &config.String{
 		Default: "info",
 		Usage:   "level of log, support panic|fatal|error|warn|info|debug|trace",
@@ -49,9 +81,37 @@ var Conf = config.Map{
 		Default: "generator.log",
 		Usage:   "log file path, default to generator.log. panic will be log to a separated .panic file under the same folder",
 	},
+	"metrics-report": &config.String{
+		Default: "none",
+		Usage:   "also write the values of each observer metric to <observer>_<metric>_report_<timestamp>.csv when the observer saves them, support none|csv",
+	},
+	"metrics-report-dir": &config.String{
+		Default: "",
+		Usage:   "directory of the csv reports with metrics-report csv, default to the working directory",
+	},
+	"metrics-max-points": &config.Int{
+		Default: 100000,
+		Usage:   "the maximum number of values kept in memory of each observer metric, the oldest ones are dropped",
+	},
 	"allow-origin": &config.String{
-		Default: "*",
-		Usage:   "access control allow origin",
+		Default: "",
+		Usage:   "comma separated origins allowed to call the api from a browser, * for any origin, none by default",
+	},
+	"auth-keys-file": &config.String{
+		Default: "",
+		Usage:   "a yaml or json file of the api keys and their roles, the api requires a key once keys are configured here or in CHAMELEON_API_KEYS",
+	},
+	"cluster-api-key": &config.String{
+		Default: "",
+		Usage:   "api key sent by the coordinator to the workers and by the workers to the coordinator",
+	},
+	"tls-cert-file": &config.String{
+		Default: "",
+		Usage:   "certificate file to serve the api over https, with tls-key-file",
+	},
+	"tls-key-file": &config.String{
+		Default: "",
+		Usage:   "private key file to serve the api over https, with tls-cert-file",
 	},
 	"max-idle-conns": &config.Int{
 		Default: 10,
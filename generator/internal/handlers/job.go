@@ -1,7 +1,10 @@
 package handlers
 
 import (
+	"fmt"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/timeplus-io/chameleon/generator/internal/job"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
@@ -9,6 +12,17 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// the names of the server sent events of a job telemetry stream
+const (
+	EVENT_SNAPSHOT = "snapshot"
+	EVENT_STATUS   = "status"
+)
+
+const DEFAULT_EVENTS_INTERVAL = "1s"
+
+// MIN_EVENTS_INTERVAL bounds how often the snapshots of a job are sent
+const MIN_EVENTS_INTERVAL = 100 * time.Millisecond
+
 type JobHandler struct {
 	manager *job.JobManager
 }
@@ -17,12 +31,13 @@ type JobResponse struct {
 	Id     string               `json:"id"`
 	Name   string               `json:"name"`
 	Status job.JobStatus        `json:"status"`
+	RunId  string               `json:"run_id"`
 	Config job.JobConfiguration `json:"config"`
 }
 
-func NewJobHandler() *JobHandler {
+func NewJobHandler(manager *job.JobManager) *JobHandler {
 	return &JobHandler{
-		manager: job.NewJobManager(),
+		manager: manager,
 	}
 }
 
@@ -35,23 +50,24 @@ func NewJobHandler() *JobHandler {
 // @Param config body job.JobConfiguration true "job configuration"
 // @Success 201 {object} JobResponse
 // @Failure 400
-// @Failure 500
 // @Router /jobs [post]
 func (h *JobHandler) CreateJob(c *gin.Context) {
 	config := job.JobConfiguration{}
 
 	if c.ShouldBind(&config) == nil {
-		log.Logger().Infof("create job with config %v", config)
+		log.Logger().Infof("create job with config %v", config.Redacted())
 
 		if job, err := h.manager.CreateJob(config); err != nil {
-			c.Status(http.StatusInternalServerError)
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		} else {
 			h.manager.StartJob(job.Id) // start job immediatly
+			run := job.CurrentRun()
 			response := JobResponse{
 				Id:     job.Id,
 				Name:   job.Name,
-				Status: job.Status,
-				Config: job.Config,
+				Status: run.Status,
+				RunId:  run.Id,
+				Config: job.Config.Redacted(),
 			}
 			c.JSON(http.StatusCreated, response)
 		}
@@ -97,6 +113,45 @@ func (h *JobHandler) GetJob(c *gin.Context) {
 	}
 }
 
+// PatchJob godoc
+// @Summary change a running job.
+// @Description change the batch size, interval, rate or concurrency of the source of a running job, and enable or disable its sinks and observers, the change is recorded as an annotation of the job.
+// @Tags job
+// @Accept json
+// @Produce json
+// @Param id path string true "job id"
+// @Param patch body job.JobPatch true "changes"
+// @Success 200 {object} job.Annotation
+// @Failure 400
+// @Failure 404
+// @Failure 409
+// @Router /jobs/{id} [patch]
+func (h *JobHandler) PatchJob(c *gin.Context) {
+	id := c.Param("id")
+	j, err := h.manager.GetJob(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	patch := job.JobPatch{}
+	if err := c.ShouldBindJSON(&patch); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if status := j.CurrentRun().Status; status != job.STATUS_RUNNING {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s job is %s, only a running job can be patched", id, status)})
+		return
+	}
+
+	if annotation, err := h.manager.PatchJob(id, patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, annotation)
+	}
+}
+
 // DeleteJob godoc
 // @Summary delete job by id.
 // @Description delete job by id.
@@ -153,3 +208,156 @@ func (h *JobHandler) StopJob(c *gin.Context) {
 		c.Status(http.StatusNoContent)
 	}
 }
+
+// RestartJob godoc
+// @Summary restart a job.
+// @Description start a new run of a stopped job, rebuilding source, sinks and observers from its configuration.
+// @Tags job
+// @Accept json
+// @Produce json
+// @Param id path string true "job id"
+// @Success 204
+// @Failure 404
+// @Failure 409
+// @Router /jobs/{id}/restart [post]
+func (h *JobHandler) RestartJob(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.manager.GetJob(id); err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	if err := h.manager.RestartJob(id); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// CloneJob godoc
+// @Summary clone a job.
+// @Description create and start a new job from the configuration of a job, with optional overrides merged into the configuration.
+// @Tags job
+// @Accept json
+// @Produce json
+// @Param id path string true "job id"
+// @Param overrides body object false "configuration overrides"
+// @Success 201 {object} JobResponse
+// @Failure 400
+// @Failure 404
+// @Router /jobs/{id}/clone [post]
+func (h *JobHandler) CloneJob(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.manager.GetJob(id); err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	overrides := make(map[string]interface{})
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&overrides); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	job, err := h.manager.CloneJob(id, overrides)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.manager.StartJob(job.Id) // start job immediatly
+	run := job.CurrentRun()
+	response := JobResponse{
+		Id:     job.Id,
+		Name:   job.Name,
+		Status: run.Status,
+		RunId:  run.Id,
+		Config: job.Config.Redacted(),
+	}
+	c.JSON(http.StatusCreated, response)
+}
+
+// ScheduleJob godoc
+// @Summary schedule a job.
+// @Description set the schedule of a job which is not running and activate it.
+// @Tags job
+// @Accept json
+// @Produce json
+// @Param id path string true "job id"
+// @Param schedule body job.Schedule true "schedule"
+// @Success 204
+// @Failure 400
+// @Failure 404
+// @Router /jobs/{id}/schedule [post]
+func (h *JobHandler) ScheduleJob(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.manager.GetJob(id); err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	schedule := job.Schedule{}
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.manager.ScheduleJob(id, schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// JobEvents godoc
+// @Summary stream the telemetry of a job.
+// @Description stream the snapshots of a job every interval and its status transitions as server sent events.
+// @Tags job
+// @Produce text/event-stream
+// @Param id path string true "job id"
+// @Param interval query string false "interval between snapshots, like 1s or 500ms, at least 100ms"
+// @Success 200 {object} job.Snapshot
+// @Failure 400
+// @Failure 404
+// @Router /jobs/{id}/events [get]
+func (h *JobHandler) JobEvents(c *gin.Context) {
+	id := c.Param("id")
+	j, err := h.manager.GetJob(id)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	interval, err := time.ParseDuration(c.DefaultQuery("interval", DEFAULT_EVENTS_INTERVAL))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid interval"})
+		return
+	}
+	if interval < MIN_EVENTS_INTERVAL {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("interval cannot be less than %s", MIN_EVENTS_INTERVAL)})
+		return
+	}
+
+	transitions, unsubscribe := j.Subscribe()
+	defer unsubscribe()
+	telemetry := job.NewTelemetry(j)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.SSEvent(EVENT_SNAPSHOT, telemetry.Next())
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case transition := <-transitions:
+			c.SSEvent(EVENT_STATUS, transition)
+		case <-ticker.C:
+			c.SSEvent(EVENT_SNAPSHOT, telemetry.Next())
+		}
+		return true
+	})
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timeplus-io/chameleon/generator/internal/observer"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+)
+
+type PluginHandler struct{}
+
+// PluginsResponse is the json schema of the properties of each registered sink and observer
+type PluginsResponse struct {
+	Sinks     map[string]map[string]interface{} `json:"sinks"`
+	Observers map[string]map[string]interface{} `json:"observers"`
+}
+
+func NewPluginHandler() *PluginHandler {
+	return &PluginHandler{}
+}
+
+// ListPlugin godoc
+// @Summary list all sinks and observers.
+// @Description list the registered sinks and observers with the json schema of their properties.
+// @Tags plugin
+// @Accept json
+// @Produce json
+// @Success 200 {object} PluginsResponse
+// @Router /plugins [get]
+func (h *PluginHandler) ListPlugin(c *gin.Context) {
+	result := PluginsResponse{
+		Sinks:     make(map[string]map[string]interface{}),
+		Observers: make(map[string]map[string]interface{}),
+	}
+	for _, name := range sink.ListRegisteredSinkTypes() {
+		properties, _ := sink.GetProperties(name)
+		result.Sinks[name] = properties.JSONSchema()
+	}
+	for _, name := range observer.ListRegisteredSinkTypes() {
+		properties, _ := observer.GetProperties(name)
+		result.Observers[name] = properties.JSONSchema()
+	}
+	c.JSON(http.StatusOK, result)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+// the formats of an exported run
+const (
+	EXPORT_FORMAT_JSON = "json"
+	EXPORT_FORMAT_CSV  = "csv"
+)
+
+type RunHandler struct {
+	manager *job.JobManager
+}
+
+func NewRunHandler(manager *job.JobManager) *RunHandler {
+	return &RunHandler{
+		manager: manager,
+	}
+}
+
+// ListRun godoc
+// @Summary list all archived runs.
+// @Description list the finished runs of all jobs, the latest first, with the summaries of the observer metrics but without their series.
+// @Tags run
+// @Accept json
+// @Produce json
+// @Success 200 {array} job.RunRecord
+// @Failure 500
+// @Router /runs [get]
+func (h *RunHandler) ListRun(c *gin.Context) {
+	runs, err := h.manager.ListRuns()
+	if err != nil {
+		log.Logger().WithError(err).Error("failed to list runs")
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GetRun godoc
+// @Summary get archived run by id.
+// @Description get the configuration, stats and observer metrics of a finished run.
+// @Tags run
+// @Accept json
+// @Produce json
+// @Param id path string true "run id"
+// @Success 200 {object} job.RunRecord
+// @Failure 404
+// @Router /runs/{id} [get]
+func (h *RunHandler) GetRun(c *gin.Context) {
+	if run, err := h.manager.GetRun(c.Param("id")); err != nil {
+		c.Status(http.StatusNotFound)
+	} else {
+		c.JSON(http.StatusOK, run)
+	}
+}
+
+// ExportRun godoc
+// @Summary export archived run.
+// @Description download a finished run as a json file, or the series of its observer metrics as a csv file.
+// @Tags run
+// @Produce json
+// @Produce text/csv
+// @Param id path string true "run id"
+// @Param format query string false "json or csv, default to json"
+// @Success 200
+// @Failure 400
+// @Failure 404
+// @Router /runs/{id}/export [get]
+func (h *RunHandler) ExportRun(c *gin.Context) {
+	format := c.DefaultQuery("format", EXPORT_FORMAT_JSON)
+	if format != EXPORT_FORMAT_JSON && format != EXPORT_FORMAT_CSV {
+		c.String(http.StatusBadRequest, "unsupported format %s", format)
+		return
+	}
+
+	run, err := h.manager.GetRun(c.Param("id"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	fileName := fmt.Sprintf("%s_%s.%s", run.JobName, run.Id, format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	if format == EXPORT_FORMAT_JSON {
+		c.JSON(http.StatusOK, run)
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Status(http.StatusOK)
+	if err := run.WriteCSV(c.Writer); err != nil {
+		log.Logger().WithError(err).Errorf("failed to export run %s", run.Id)
+	}
+}
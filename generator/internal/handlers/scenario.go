@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/scenario"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ScenarioHandler struct {
+	manager *scenario.ScenarioManager
+}
+
+func NewScenarioHandler(manager *scenario.ScenarioManager) *ScenarioHandler {
+	return &ScenarioHandler{
+		manager: manager,
+	}
+}
+
+// RunScenario godoc
+// @Summary Run a scenario.
+// @Description validate a scenario and run its stages in background.
+// @Tags scenario
+// @Accept json
+// @Produce json
+// @Param config body scenario.Configuration true "scenario configuration"
+// @Success 201 {object} scenario.ScenarioRun
+// @Failure 400
+// @Router /scenarios [post]
+func (h *ScenarioHandler) RunScenario(c *gin.Context) {
+	config := scenario.Configuration{}
+	if err := c.ShouldBindJSON(&config); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	log.Logger().Infof("run scenario %s with %d stages", config.Name, len(config.Stages))
+	run, err := h.manager.RunScenario(config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, run)
+}
+
+// ListScenario godoc
+// @Summary list all scenario runs.
+// @Description list all scenario runs.
+// @Tags scenario
+// @Accept json
+// @Produce json
+// @Success 200 {array} scenario.ScenarioRun
+// @Router /scenarios [get]
+func (h *ScenarioHandler) ListScenario(c *gin.Context) {
+	c.JSON(http.StatusOK, h.manager.ListScenario())
+}
+
+// GetScenario godoc
+// @Summary get scenario run by id.
+// @Description get scenario run by id, with the result of each stage.
+// @Tags scenario
+// @Accept json
+// @Produce json
+// @Param id path string true "scenario run id"
+// @Success 200 {object} scenario.ScenarioRun
+// @Failure 404
+// @Router /scenarios/{id} [get]
+func (h *ScenarioHandler) GetScenario(c *gin.Context) {
+	id := c.Param("id")
+	if run, err := h.manager.GetScenario(id); err != nil {
+		c.Status(http.StatusNotFound)
+	} else {
+		c.JSON(http.StatusOK, run)
+	}
+}
+
+// StopScenario godoc
+// @Summary stop a scenario run.
+// @Description cancel the stages in progress, the stages marked as always still run.
+// @Tags scenario
+// @Accept json
+// @Produce json
+// @Param id path string true "scenario run id"
+// @Success 204
+// @Failure 404
+// @Router /scenarios/{id}/stop [post]
+func (h *ScenarioHandler) StopScenario(c *gin.Context) {
+	id := c.Param("id")
+	if err := h.manager.StopScenario(id); err != nil {
+		c.Status(http.StatusNotFound)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/templates"
+)
+
+type TemplateHandler struct {
+	catalogue *templates.Catalogue
+	manager   *job.JobManager
+}
+
+type TemplateResponse struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Origin      string                 `json:"origin"`
+	Parameters  []templates.Parameter  `json:"parameters"`
+	Job         map[string]interface{} `json:"job"`
+}
+
+// TemplateJobRequest sets the parameters of the job created from a template
+type TemplateJobRequest struct {
+	Parameters map[string]interface{} `json:"parameters"`
+}
+
+func NewTemplateHandler(catalogue *templates.Catalogue, manager *job.JobManager) *TemplateHandler {
+	return &TemplateHandler{
+		catalogue: catalogue,
+		manager:   manager,
+	}
+}
+
+func newTemplateResponse(t *templates.Template) TemplateResponse {
+	return TemplateResponse{
+		Name:        t.Name,
+		Description: t.Description,
+		Origin:      t.Origin,
+		Parameters:  t.AllParameters(),
+		Job:         t.Job,
+	}
+}
+
+// ListTemplate godoc
+// @Summary list all templates.
+// @Description list the builtin templates and the templates of the template directory, with their parameters.
+// @Tags template
+// @Accept json
+// @Produce json
+// @Success 200 {array} TemplateResponse
+// @Router /templates [get]
+func (h *TemplateHandler) ListTemplate(c *gin.Context) {
+	list := h.catalogue.List()
+	result := make([]TemplateResponse, len(list))
+	for index, t := range list {
+		result[index] = newTemplateResponse(t)
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// GetTemplate godoc
+// @Summary get template by name.
+// @Description get template by name.
+// @Tags template
+// @Accept json
+// @Produce json
+// @Param name path string true "template name"
+// @Success 200 {object} TemplateResponse
+// @Failure 404
+// @Router /templates/{name} [get]
+func (h *TemplateHandler) GetTemplate(c *gin.Context) {
+	if t, err := h.catalogue.Get(c.Param("name")); err != nil {
+		c.Status(http.StatusNotFound)
+	} else {
+		c.JSON(http.StatusOK, newTemplateResponse(t))
+	}
+}
+
+// CreateJobFromTemplate godoc
+// @Summary create a job from a template.
+// @Description create and start a job from a template, with the values of its parameters.
+// @Tags template
+// @Accept json
+// @Produce json
+// @Param name path string true "template name"
+// @Param request body TemplateJobRequest false "parameters"
+// @Success 201 {object} JobResponse
+// @Failure 400
+// @Failure 404
+// @Router /templates/{name}/jobs [post]
+func (h *TemplateHandler) CreateJobFromTemplate(c *gin.Context) {
+	t, err := h.catalogue.Get(c.Param("name"))
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	request := TemplateJobRequest{}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+	}
+
+	config, err := t.Instantiate(request.Parameters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Logger().Infof("create job from template %s", t.Name)
+	j, err := h.manager.CreateJob(config)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.manager.StartJob(j.Id) // start job immediatly
+	run := j.CurrentRun()
+	response := JobResponse{
+		Id:     j.Id,
+		Name:   j.Name,
+		Status: run.Status,
+		RunId:  run.Id,
+		Config: j.Config.Redacted(),
+	}
+	c.JSON(http.StatusCreated, response)
+}
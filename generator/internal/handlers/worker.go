@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/timeplus-io/chameleon/generator/internal/cluster"
+)
+
+type WorkerHandler struct {
+	registry *cluster.Registry
+}
+
+func NewWorkerHandler(registry *cluster.Registry) *WorkerHandler {
+	return &WorkerHandler{
+		registry: registry,
+	}
+}
+
+// RegisterWorker godoc
+// @Summary register a worker.
+// @Description register a worker to the coordinator or refresh its heartbeat.
+// @Tags worker
+// @Accept json
+// @Produce json
+// @Param worker body cluster.RegisterRequest true "worker"
+// @Success 200 {object} cluster.Worker
+// @Failure 400
+// @Router /workers [post]
+func (h *WorkerHandler) RegisterWorker(c *gin.Context) {
+	request := cluster.RegisterRequest{}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if worker, err := h.registry.Register(request.Id, request.Address); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	} else {
+		c.JSON(http.StatusOK, worker)
+	}
+}
+
+// ListWorker godoc
+// @Summary list all workers.
+// @Description list the workers registered to the coordinator.
+// @Tags worker
+// @Accept json
+// @Produce json
+// @Success 200 {array} cluster.Worker
+// @Router /workers [get]
+func (h *WorkerHandler) ListWorker(c *gin.Context) {
+	c.JSON(http.StatusOK, h.registry.List())
+}
+
+// UnregisterWorker godoc
+// @Summary unregister a worker.
+// @Description remove a worker from the coordinator.
+// @Tags worker
+// @Accept json
+// @Produce json
+// @Param id path string true "worker id"
+// @Success 204
+// @Failure 404
+// @Router /workers/{id} [delete]
+func (h *WorkerHandler) UnregisterWorker(c *gin.Context) {
+	if err := h.registry.Unregister(c.Param("id")); err != nil {
+		c.Status(http.StatusNotFound)
+	} else {
+		c.Status(http.StatusNoContent)
+	}
+}
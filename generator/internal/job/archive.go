@@ -0,0 +1,167 @@
+package job
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/metrics"
+	"github.com/timeplus-io/chameleon/generator/internal/observer"
+)
+
+// RunRecord is the archived result of a finished run, it is kept when its job is deleted
+type RunRecord struct {
+	Id      string    `json:"id"`
+	JobId   string    `json:"job_id"`
+	JobName string    `json:"job_name"`
+	Status  JobStatus `json:"status"`
+	// the configuration of the run with its secrets redacted
+	Config    JobConfiguration  `json:"config"`
+	StartedAt *time.Time        `json:"started_at,omitempty"`
+	StoppedAt *time.Time        `json:"stopped_at,omitempty"`
+	Stats     *Stats            `json:"stats,omitempty"`
+	Observers []*ObserverResult `json:"observers,omitempty"`
+}
+
+// ObserverResult holds the metrics observed by an observer during a run
+type ObserverResult struct {
+	Type    string                   `json:"type"`
+	Metrics map[string]*MetricResult `json:"metrics"`
+}
+
+type MetricResult struct {
+	Summary metrics.Summary `json:"summary"`
+	Series  []metrics.Point `json:"series,omitempty"`
+}
+
+// Brief returns a copy of the record without the metric series, only their summaries are kept
+func (r *RunRecord) Brief() *RunRecord {
+	result := *r
+	result.Observers = make([]*ObserverResult, len(r.Observers))
+	for index, ob := range r.Observers {
+		brief := &ObserverResult{Type: ob.Type, Metrics: make(map[string]*MetricResult, len(ob.Metrics))}
+		for name, metric := range ob.Metrics {
+			brief.Metrics[name] = &MetricResult{Summary: metric.Summary}
+		}
+		result.Observers[index] = brief
+	}
+	return &result
+}
+
+// WriteCSV writes the metric series of the observers as csv, one row per observed value
+func (r *RunRecord) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"observer", "type", "metric", "time", "value"}); err != nil {
+		return err
+	}
+	for index, ob := range r.Observers {
+		names := make([]string, 0, len(ob.Metrics))
+		for name := range ob.Metrics {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			for _, point := range ob.Metrics[name].Series {
+				row := []string{
+					strconv.Itoa(index),
+					ob.Type,
+					name,
+					strconv.FormatInt(point.Time, 10),
+					strconv.FormatFloat(point.Value, 'f', -1, 64),
+				}
+				if err := writer.Write(row); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// observerResults collects the metrics of the observers once they are stopped, the observers are in the order
+// of their configurations
+func observerResults(observers []observer.Observer, configs []observer.Configuration) []*ObserverResult {
+	results := make([]*ObserverResult, len(observers))
+	for index, ob := range observers {
+		result := &ObserverResult{Type: configs[index].Type, Metrics: make(map[string]*MetricResult)}
+		for name, points := range ob.Series() {
+			result.Metrics[name] = &MetricResult{Summary: metrics.Summarize(points), Series: points}
+		}
+		results[index] = result
+	}
+	return results
+}
+
+// Result returns the record of the current run, with the metrics of the observers once the run is stopped
+func (j *Job) Result() *RunRecord {
+	run := j.CurrentRun()
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return &RunRecord{
+		Id:        run.Id,
+		JobId:     j.Id,
+		JobName:   j.Name,
+		Status:    run.Status,
+		Config:    j.Config.Redacted(),
+		StartedAt: run.StartedAt,
+		StoppedAt: run.StoppedAt,
+		Stats:     run.Stats,
+		Observers: j.results,
+	}
+}
+
+// archive saves the finished run in the run archive of the job store, the run is the job itself or one of
+// its scheduled runs
+func (j *Job) archive(run *Job) {
+	if j.store == nil {
+		return
+	}
+
+	record := run.Result()
+	record.JobId, record.JobName = j.Id, j.Name
+	if err := j.store.SaveRun(record); err != nil {
+		log.Logger().WithError(err).Errorf("failed to archive run %s of job %s", record.Id, j.Id)
+	}
+}
+
+// ListRuns returns the archived runs, the latest first, without their metric series
+func (m *JobManager) ListRuns() ([]*RunRecord, error) {
+	records, err := m.store.ListRuns()
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(records, func(i, k int) bool {
+		return startTime(records[i]).After(startTime(records[k]))
+	})
+	result := make([]*RunRecord, len(records))
+	for index, record := range records {
+		result[index] = record.Brief()
+	}
+	return result, nil
+}
+
+func (m *JobManager) GetRun(id string) (*RunRecord, error) {
+	record, err := m.store.GetRun(id)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, fmt.Errorf("%s run does not exist", id)
+	}
+	return record, nil
+}
+
+func startTime(record *RunRecord) time.Time {
+	if record.StartedAt == nil {
+		return time.Time{}
+	}
+	return *record.StartedAt
+}
@@ -0,0 +1,138 @@
+package job
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/timeplus-io/chameleon/generator/internal/observer"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+)
+
+const METRICS_NAMESPACE = "chameleon"
+
+// WRITE_LATENCY_BUCKETS are the bounds in seconds of the write latency histograms
+var WRITE_LATENCY_BUCKETS = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var (
+	jobLabels      = []string{"job_id", "job_name"}
+	sinkLabels     = append(append([]string{}, jobLabels...), "sink", "sink_type")
+	observerLabels = append(append([]string{}, jobLabels...), "observer", "observer_type", "metric")
+)
+
+func newDesc(name string, help string, labels []string) *prometheus.Desc {
+	return prometheus.NewDesc(prometheus.BuildFQName(METRICS_NAMESPACE, "", name), help, labels, nil)
+}
+
+// JobCollector exposes the stats of the jobs of a job manager as prometheus metrics, they are read from the
+// jobs on each scrape and are reset when a job is restarted
+type JobCollector struct {
+	manager *JobManager
+
+	status         *prometheus.Desc
+	generated      *prometheus.Desc
+	successWrite   *prometheus.Desc
+	failedWrite    *prometheus.Desc
+	bytesWritten   *prometheus.Desc
+	batches        *prometheus.Desc
+	errors         *prometheus.Desc
+	retries        *prometheus.Desc
+	deadLetterRows *prometheus.Desc
+	droppedBatches *prometheus.Desc
+	queueDepth     *prometheus.Desc
+	lag            *prometheus.Desc
+	writeLatency   *prometheus.Desc
+	observerMetric *prometheus.Desc
+}
+
+func NewJobCollector(manager *JobManager) *JobCollector {
+	return &JobCollector{
+		manager: manager,
+
+		status:         newDesc("job_status", "Current status of the job, 1 for the status label of the job.", append(append([]string{}, jobLabels...), "status")),
+		generated:      newDesc("job_generated_rows_total", "Rows generated by the source of the job.", jobLabels),
+		successWrite:   newDesc("sink_rows_written_total", "Rows written to the sink.", sinkLabels),
+		failedWrite:    newDesc("sink_rows_failed_total", "Rows which could not be written to the sink.", sinkLabels),
+		bytesWritten:   newDesc("sink_bytes_written_total", "Size of the rows written to the sink.", sinkLabels),
+		batches:        newDesc("sink_batches_total", "Batches written or failed.", sinkLabels),
+		errors:         newDesc("sink_write_errors_total", "Writes returning an error, including the retried ones.", sinkLabels),
+		retries:        newDesc("sink_write_retries_total", "Retried writes.", sinkLabels),
+		deadLetterRows: newDesc("sink_dead_letter_rows_total", "Rows sent to the dead letter destination.", sinkLabels),
+		droppedBatches: newDesc("sink_dropped_batches_total", "Batches dropped by the queue of the sink.", sinkLabels),
+		queueDepth:     newDesc("sink_queue_depth", "Batches waiting in the queue of the sink.", sinkLabels),
+		lag:            newDesc("sink_lag_seconds", "Time the last written batch spent between its generation and its write.", sinkLabels),
+		writeLatency:   newDesc("sink_write_duration_seconds", "Latency of the writes to the sink.", sinkLabels),
+		observerMetric: newDesc("observer_metric", "Latest value of a metric reported by an observer, like latency, throughput or availability.", observerLabels),
+	}
+}
+
+func (c *JobCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		c.status, c.generated, c.successWrite, c.failedWrite, c.bytesWritten, c.batches, c.errors, c.retries,
+		c.deadLetterRows, c.droppedBatches, c.queueDepth, c.lag, c.writeLatency, c.observerMetric,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *JobCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, j := range c.manager.ListJob() {
+		c.collectJob(ch, j)
+	}
+}
+
+func (c *JobCollector) collectJob(ch chan<- prometheus.Metric, j *Job) {
+	j.lock.Lock()
+	labels := []string{j.Id, j.Name}
+	status := j.Status
+	stats := copyStats(j.Stats)
+	sinks := j.sinks
+	observers := j.observers
+	observerConfigs := j.Config.Observers
+	j.lock.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(c.status, prometheus.GaugeValue, 1, append(labels, string(status))...)
+	ch <- prometheus.MustNewConstMetric(c.generated, prometheus.CounterValue, float64(stats.Generated), labels...)
+
+	for index, sinkStats := range stats.Sinks {
+		sinkValues := append(append([]string{}, labels...), strconv.Itoa(index), sinkStats.Type)
+		counter := func(desc *prometheus.Desc, value float64) {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value, sinkValues...)
+		}
+		counter(c.successWrite, float64(sinkStats.SuccessWrite))
+		counter(c.failedWrite, float64(sinkStats.FailedWrite))
+		if sinkStats.BytesWritten != nil {
+			counter(c.bytesWritten, float64(*sinkStats.BytesWritten))
+		}
+		counter(c.batches, float64(sinkStats.Batches))
+		counter(c.errors, float64(sinkStats.Errors))
+		counter(c.retries, float64(sinkStats.Retries))
+		counter(c.deadLetterRows, float64(sinkStats.DeadLetterRows))
+		counter(c.droppedBatches, float64(sinkStats.DroppedBatches))
+		ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(sinkStats.QueueDepth), sinkValues...)
+		ch <- prometheus.MustNewConstMetric(c.lag, prometheus.GaugeValue, float64(sinkStats.LagMs)/1000, sinkValues...)
+
+		if index < len(sinks) {
+			if retrySink, ok := sinks[index].(*sink.RetrySink); ok {
+				count, sum, buckets := retrySink.Latency().Cumulative(WRITE_LATENCY_BUCKETS)
+				ch <- prometheus.MustNewConstHistogram(c.writeLatency, count, sum, buckets, sinkValues...)
+			}
+		}
+	}
+
+	c.collectObservers(ch, labels, observerConfigs, observers)
+}
+
+func (c *JobCollector) collectObservers(ch chan<- prometheus.Metric, labels []string, configs []observer.Configuration, observers []observer.Observer) {
+	for index, ob := range observers {
+		observerType := ""
+		if index < len(configs) {
+			observerType = configs[index].Type
+		}
+
+		for name, value := range ob.Measurements() {
+			observerValues := append(append([]string{}, labels...), strconv.Itoa(index), observerType, name)
+			ch <- prometheus.MustNewConstMetric(c.observerMetric, prometheus.GaugeValue, value, observerValues...)
+		}
+	}
+}
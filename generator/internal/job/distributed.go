@@ -0,0 +1,436 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+)
+
+// PartitionStrategy decides how a distributed job is split across workers
+type PartitionStrategy string
+
+const (
+	// each worker runs a share of the concurrent streams of the source
+	PARTITION_STRATEGY_CONCURRENCY PartitionStrategy = "concurrency"
+	// each worker runs all the streams, with its own range of the values of the key field
+	PARTITION_STRATEGY_KEY_RANGE PartitionStrategy = "key_range"
+)
+
+// POLL_INTERVAL is how often the coordinator reads the status and stats of the parts of a distributed job
+const POLL_INTERVAL = time.Second
+
+// UNREACHABLE_TIMEOUT is how long a part can fail to be polled before it is considered failed
+const UNREACHABLE_TIMEOUT = 30 * time.Second
+
+// Distribution splits a job across the workers registered to a coordinator
+type Distribution struct {
+	// number of workers running the job, default to all the available workers
+	Workers  int               `json:"workers,omitempty"`
+	Strategy PartitionStrategy `json:"strategy,omitempty"`
+	// the int field whose limit is split between the workers with the key_range strategy
+	KeyField string `json:"key_field,omitempty"`
+}
+
+func (d *Distribution) Validate(config JobConfiguration) error {
+	if d.Workers < 0 {
+		return fmt.Errorf("workers cannot be negative")
+	}
+	if config.Schedule != nil {
+		return fmt.Errorf("distributed job cannot be scheduled")
+	}
+
+	switch d.Strategy {
+	case "", PARTITION_STRATEGY_CONCURRENCY:
+		if d.Workers > config.Source.Concurrency {
+			return fmt.Errorf("concurency %d is less than the number of workers %d", config.Source.Concurrency, d.Workers)
+		}
+	case PARTITION_STRATEGY_KEY_RANGE:
+		if _, _, err := keyRange(config.Source, d.KeyField); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("invalid partition strategy %s", d.Strategy)
+	}
+
+	// the parts are only generated by the workers, check the source can be created
+	part, err := Partition(config, 0, 1)
+	if err != nil {
+		return err
+	}
+	if _, err := source.NewGenarator(part.Source); err != nil {
+		return fmt.Errorf("invalid source : %w", err)
+	}
+	return nil
+}
+
+// Partition returns the configuration of the part of a distributed job run by the worker with the given index,
+// the rate of the source is shared between the workers, the seed of each worker is derived from the seed
+// of the source and the values of the sequence fields are split into one range per worker
+func Partition(config JobConfiguration, index int, count int) (JobConfiguration, error) {
+	if config.Distribution == nil {
+		return config, fmt.Errorf("job is not distributed")
+	}
+	if count <= 0 || index < 0 || index >= count {
+		return config, fmt.Errorf("invalid part %d of %d", index, count)
+	}
+
+	distribution := config.Distribution
+	part := config
+	part.Distribution = nil
+	part.Source.Fields = append([]source.Field{}, config.Source.Fields...)
+	src := &part.Source
+
+	switch distribution.Strategy {
+	case "", PARTITION_STRATEGY_CONCURRENCY:
+		concurrency := config.Source.Concurrency
+		if concurrency < count {
+			return config, fmt.Errorf("concurency %d is less than the number of workers %d", concurrency, count)
+		}
+		// the first workers run one more stream when the streams cannot be evenly split
+		first := split(int64(concurrency), index, count)
+		last := split(int64(concurrency), index+1, count)
+		src.Concurrency = int(last - first)
+		rate := int64(config.Source.Rate)
+		src.Rate = int(rate*last/int64(concurrency) - rate*first/int64(concurrency))
+	case PARTITION_STRATEGY_KEY_RANGE:
+		fieldIndex, limits, err := keyRange(config.Source, distribution.KeyField)
+		if err != nil {
+			return config, err
+		}
+		values := limits[1] - limits[0] + 1
+		if values < int64(count) {
+			return config, fmt.Errorf("key field %s has less values than the number of workers %d", distribution.KeyField, count)
+		}
+		low := limits[0] + split(values, index, count)
+		high := limits[0] + split(values, index+1, count) - 1
+		src.Fields[fieldIndex].Limit = []interface{}{float64(low), float64(high)}
+		src.Rate = int(split(int64(config.Source.Rate), index+1, count) - split(int64(config.Source.Rate), index, count))
+	default:
+		return config, fmt.Errorf("invalid partition strategy %s", distribution.Strategy)
+	}
+
+	if config.Source.Rate > 0 && src.Rate == 0 {
+		return config, fmt.Errorf("rate %d is too low to be shared by %d workers", config.Source.Rate, count)
+	}
+
+	for fieldIndex, f := range src.Fields {
+		if f.Type != source.FIELDTYPE_SEQUENCE {
+			continue
+		}
+		start, end, err := sequenceRange(f)
+		if err != nil {
+			return config, err
+		}
+		size := uint64(end-start) / uint64(count)
+		if size == 0 {
+			return config, fmt.Errorf("sequence field %s has less values than the number of workers %d", f.Name, count)
+		}
+		partStart := start + int64(size*uint64(index))
+		partEnd := partStart + int64(size)
+		if index == count-1 {
+			partEnd = end
+		}
+		src.Fields[fieldIndex].Limit = []interface{}{float64(partStart), float64(partEnd)}
+	}
+
+	if src.Seed != 0 {
+		src.Seed += int64(index)
+	}
+	return part, nil
+}
+
+// split returns the start of the part with the given index when total is split into count parts, the first
+// parts are one larger when total cannot be evenly split
+func split(total int64, index int, count int) int64 {
+	return (total*int64(index) + int64(count) - 1) / int64(count)
+}
+
+func keyRange(config source.Configuration, name string) (int, []int64, error) {
+	for index, f := range config.Fields {
+		if f.Name != name {
+			continue
+		}
+		if f.Type != source.FIELDTYPE_INT || len(f.Limit) != 2 {
+			return 0, nil, fmt.Errorf("key field %s has to be an int field with a limit", name)
+		}
+		low, lowOk := f.Limit[0].(float64)
+		high, highOk := f.Limit[1].(float64)
+		if !lowOk || !highOk || high < low {
+			return 0, nil, fmt.Errorf("invalid limit of key field %s", name)
+		}
+		return index, []int64{int64(low), int64(high)}, nil
+	}
+	return 0, nil, fmt.Errorf("key field %s does not exist", name)
+}
+
+func sequenceRange(f source.Field) (int64, int64, error) {
+	start, end := int64(0), int64(math.MaxInt64)
+	for index, limit := range f.Limit {
+		value, ok := limit.(float64)
+		if !ok {
+			return 0, 0, fmt.Errorf("invalid limit of sequence field %s", f.Name)
+		}
+		if index == 0 {
+			start = int64(value)
+		} else if index == 1 {
+			end = int64(value)
+		}
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("invalid limit of sequence field %s", f.Name)
+	}
+	return start, end, nil
+}
+
+// RemoteState is the status and the stats of the part of a distributed job run by a worker
+type RemoteState struct {
+	Worker string    `json:"worker"`
+	JobId  string    `json:"job_id,omitempty"`
+	Status JobStatus `json:"status"`
+	Stats  *Stats    `json:"stats,omitempty"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// Remote is the part of a distributed job run by a worker
+type Remote interface {
+	// Worker returns the id of the worker running the part
+	Worker() string
+	Poll(ctx context.Context) (*RemoteState, error)
+	// Stop returns once the part is drained, the remote bounds the wait when ctx has no deadline
+	Stop(ctx context.Context) error
+}
+
+// Dispatcher splits a distributed job and starts its parts on the workers
+type Dispatcher interface {
+	Dispatch(ctx context.Context, config JobConfiguration) ([]Remote, error)
+}
+
+// newDistributedJob creates a job whose parts are run by the workers of the dispatcher
+func newDistributedJob(config JobConfiguration, dispatcher Dispatcher) (*Job, error) {
+	if dispatcher == nil {
+		return nil, fmt.Errorf("distributed job has to be created on a coordinator")
+	}
+	if err := config.Distribution.Validate(config); err != nil {
+		return nil, fmt.Errorf("invalid distribution : %w", err)
+	}
+
+	job := newJob(config.Name, nil, nil, nil, config.Timeout, config)
+	job.Stats = newStats(config, len(config.Sinks))
+	job.dispatcher = dispatcher
+	return job, nil
+}
+
+// startDistributed starts the parts of the job on the workers, the job is finished once all parts are
+func (j *Job) startDistributed() {
+	ctx, cancel := context.WithCancel(context.Background())
+	waiter := &sync.WaitGroup{}
+	waiter.Add(1)
+
+	j.lock.Lock()
+	if j.stopped != nil {
+		j.lock.Unlock()
+		cancel()
+		log.Logger().Warnf("job %s is stopped before it is started", j.Id)
+		return
+	}
+	stopped := make(chan struct{})
+	config := j.Config
+	j.jobWaiter = waiter
+	j.cancel = cancel
+	j.stopped = stopped
+	j.lock.Unlock()
+
+	remotes, err := j.dispatcher.Dispatch(ctx, config)
+	if err != nil {
+		log.Logger().WithError(err).Errorf("failed to dispatch job %s", j.Id)
+		j.lock.Lock()
+		j.cancel = nil
+		j.lock.Unlock()
+		cancel()
+		j.setStatus(STATUS_FAILED)
+		waiter.Done()
+		close(stopped)
+		return
+	}
+
+	states := make([]*RemoteState, len(remotes))
+	for index, remote := range remotes {
+		states[index] = &RemoteState{Worker: remote.Worker(), Status: STATUS_INIT}
+	}
+	j.lock.Lock()
+	j.Workers = states
+	j.lock.Unlock()
+
+	j.setStatus(STATUS_RUNNING)
+	go j.watch(ctx, remotes, waiter, stopped)
+}
+
+// watch polls the parts of the job until they are all finished or the job is stopped
+func (j *Job) watch(ctx context.Context, remotes []Remote, waiter *sync.WaitGroup, stopped chan struct{}) {
+	defer close(stopped)
+	defer waiter.Done()
+
+	lastSeen := make([]time.Time, len(remotes))
+	for index := range lastSeen {
+		lastSeen[index] = time.Now()
+	}
+
+	ticker := time.NewTicker(POLL_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// the remote applies the deadline of the stop, which waits for the part to drain
+			for _, remote := range remotes {
+				if err := remote.Stop(context.Background()); err != nil {
+					log.Logger().WithError(err).Warnf("failed to stop part of job %s on worker %s", j.Id, remote.Worker())
+				}
+			}
+			j.poll(remotes, lastSeen)
+			j.setStatus(STATUS_STOPPED)
+			j.archive(j)
+
+			stats := j.CurrentRun().Stats
+			log.Logger().Infof("job %s stopped, generated %d, written to all sinks %d, failed %d, bytes written %s",
+				j.Id, stats.Generated, stats.SuccessWrite, stats.FailedWrite, formatBytes(stats.BytesWritten))
+			return
+		case <-ticker.C:
+			if status, finished := j.poll(remotes, lastSeen); finished {
+				j.lock.Lock()
+				j.cancel = nil
+				j.lock.Unlock()
+				j.setStatus(status)
+				j.archive(j)
+				return
+			}
+		}
+	}
+}
+
+// Parts returns the state of the parts of a distributed job, in the order of the workers running them
+func (j *Job) Parts() []*RemoteState {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return append([]*RemoteState{}, j.Workers...)
+}
+
+// poll reads the state of each part and aggregates their stats, it returns the status of the job once
+// all parts are finished
+func (j *Job) poll(remotes []Remote, lastSeen []time.Time) (JobStatus, bool) {
+	j.lock.Lock()
+	states := make([]*RemoteState, len(j.Workers))
+	copy(states, j.Workers)
+	j.lock.Unlock()
+
+	for index, remote := range remotes {
+		ctx, cancel := context.WithTimeout(context.Background(), POLL_INTERVAL*5)
+		state, err := remote.Poll(ctx)
+		cancel()
+		if err == nil {
+			states[index] = state
+			lastSeen[index] = time.Now()
+			continue
+		}
+
+		previous := *states[index]
+		previous.Error = err.Error()
+		if time.Since(lastSeen[index]) > UNREACHABLE_TIMEOUT {
+			previous.Status = STATUS_FAILED
+		}
+		states[index] = &previous
+	}
+
+	finished, failed := true, false
+	for _, state := range states {
+		switch state.Status {
+		case STATUS_STOPPED:
+		case STATUS_FAILED:
+			failed = true
+		default:
+			finished = false
+		}
+	}
+
+	j.lock.Lock()
+	j.Workers = states
+	j.Stats = aggregateStats(j.Config, states)
+	j.lock.Unlock()
+
+	if failed {
+		return STATUS_FAILED, finished
+	}
+	return STATUS_STOPPED, finished
+}
+
+// addBytes adds the bytes of a part to a byte counter, either of them is nil when the bytes are not measured
+func addBytes(total *int64, bytes *int64) {
+	if total != nil && bytes != nil {
+		*total += *bytes
+	}
+}
+
+// aggregateStats sums the stats of the parts, the lag and the write latency of a sink are the highest of its parts
+func aggregateStats(config JobConfiguration, states []*RemoteState) *Stats {
+	stats := newStats(config, len(config.Sinks))
+	for _, state := range states {
+		if state.Stats == nil {
+			continue
+		}
+
+		stats.Generated += state.Stats.Generated
+		stats.SuccessWrite += state.Stats.SuccessWrite
+		stats.FailedWrite += state.Stats.FailedWrite
+		addBytes(stats.BytesWritten, state.Stats.BytesWritten)
+		for index, partStats := range state.Stats.Sinks {
+			if index >= len(stats.Sinks) {
+				break
+			}
+
+			sinkStats := stats.Sinks[index]
+			sinkStats.SuccessWrite += partStats.SuccessWrite
+			sinkStats.FailedWrite += partStats.FailedWrite
+			addBytes(sinkStats.BytesWritten, partStats.BytesWritten)
+			sinkStats.QueueDepth += partStats.QueueDepth
+			sinkStats.DroppedBatches += partStats.DroppedBatches
+			sinkStats.Batches += partStats.Batches
+			sinkStats.Errors += partStats.Errors
+			sinkStats.Retries += partStats.Retries
+			sinkStats.DeadLetterRows += partStats.DeadLetterRows
+			if partStats.LagMs > sinkStats.LagMs {
+				sinkStats.LagMs = partStats.LagMs
+			}
+			sinkStats.WriteLatency = sinkStats.WriteLatency.Merge(partStats.WriteLatency)
+		}
+	}
+	return stats
+}
+
+// stopDistributed stops the parts of the job and waits for their final stats
+func (j *Job) stopDistributed() {
+	j.lock.Lock()
+	cancel, stopped := j.cancel, j.stopped
+	j.cancel = nil
+	if stopped == nil {
+		// never started, nothing runs on the workers
+		stopped = make(chan struct{})
+		close(stopped)
+		j.stopped = stopped
+		j.lock.Unlock()
+		j.setStatus(STATUS_STOPPED)
+		return
+	}
+	j.lock.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	<-stopped
+	if status := j.status(); status != STATUS_STOPPED && status != STATUS_FAILED {
+		j.setStatus(STATUS_STOPPED)
+	}
+}
@@ -3,10 +3,13 @@ package job
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -29,48 +32,218 @@ const (
 	STATUS_RUNNING JobStatus = "running"
 	STATUS_STOPPED JobStatus = "stopped"
 	STATUS_FAILED  JobStatus = "failed"
+	// a job with a schedule, waiting for its next run
+	STATUS_SCHEDULED JobStatus = "scheduled"
 )
 
+// DRAIN_TIMEOUT bounds how long a stopping job waits for the batches in flight and for the sinks to flush
+const DRAIN_TIMEOUT = 30 * time.Second
+
+// Stats holds the write statistics of a run, each generated row is counted once whatever the number of sinks
 type Stats struct {
+	// number of rows generated by the source
+	Generated int `json:"generated"`
+	// rows written by all the enabled sinks
 	SuccessWrite int `json:"success_write"`
-	FailedWrite  int `json:"failed_write"`
+	// rows which at least one sink could not write
+	FailedWrite int `json:"failed_write"`
+	// size of the written rows as json, nil when the source does not measure its events
+	BytesWritten *int64       `json:"bytes_written"`
+	Sinks        []*SinkStats `json:"sinks,omitempty"`
+}
+
+// SinkStats holds the write statistics of one sink, in the same order as the sinks of the job configuration
+type SinkStats struct {
+	Type         string `json:"type"`
+	SuccessWrite int    `json:"success_write"`
+	FailedWrite  int    `json:"failed_write"`
+	BytesWritten *int64 `json:"bytes_written"`
+	// number of batches waiting in the queue of the sink
+	QueueDepth int `json:"queue_depth"`
+	// time in ms the last written batch spent in the queue
+	LagMs          int64 `json:"lag_ms"`
+	DroppedBatches int   `json:"dropped_batches"`
+	// number of batches written or failed
+	Batches int `json:"batches"`
+	// number of Write calls returning an error, a retried batch can fail more than once
+	Errors  int `json:"errors"`
+	Retries int `json:"retries"`
+	// rows sent to the dead letter destination after all retries failed
+	DeadLetterRows int `json:"dead_letter_rows"`
+	// latency of the Write calls of the sink
+	WriteLatency sink.LatencySummary `json:"write_latency"`
+}
+
+// Run keeps the outcome of a previous run of a job
+type Run struct {
+	Id        string     `json:"id"`
+	Status    JobStatus  `json:"status"`
+	StartedAt *time.Time `json:"started_at,omitempty"`
+	StoppedAt *time.Time `json:"stopped_at,omitempty"`
+	Stats     *Stats     `json:"stats,omitempty"`
 }
 
 type Job struct {
-	Id     string           `json:"id"`
-	Name   string           `json:"name"`
-	Status JobStatus        `json:"status"`
-	Config JobConfiguration `json:"config"`
-	Stats  *Stats           `json:"stats,omitempty"`
+	Id        string             `json:"id"`
+	Name      string             `json:"name"`
+	Status    JobStatus          `json:"status"`
+	Config    JobConfiguration   `json:"config"`
+	RunId     string             `json:"run_id"`
+	Stats     *Stats             `json:"stats,omitempty"`
+	CreatedAt time.Time          `json:"created_at"`
+	StartedAt *time.Time         `json:"started_at,omitempty"`
+	StoppedAt *time.Time         `json:"stopped_at,omitempty"`
+	History   []StatusTransition `json:"history,omitempty"`
+	Runs      []*Run             `json:"runs,omitempty"`
+	// only set for jobs with a schedule
+	ScheduleState *ScheduleState `json:"schedule_state,omitempty"`
+	// the parts of a distributed job run by the workers
+	Workers []*RemoteState `json:"workers,omitempty"`
+	// the changes made to the job while it runs
+	Annotations []*Annotation `json:"annotations,omitempty"`
 
 	source    source.Source
 	sinks     []sink.Sink
 	observers []observer.Observer
-	jobWaiter sync.WaitGroup
+	jobWaiter *sync.WaitGroup
 	timeout   int
 	lock      sync.Mutex
+	store     JobStore
+	scheduler *scheduler
+
+	// the queues of the sinks of the current run
+	queues []*sink.Queue
+	// the context of the source and observers of the current run, cancelled by cancel
+	ctx    context.Context
+	cancel context.CancelFunc
+	// aborts the sink writes of the current run, only used when draining times out
+	abort context.CancelFunc
+	// closed once the current run is stopped and its sinks and observers are released
+	stopped chan struct{}
+	// the telemetry streams following the status transitions of the job
+	subscribers map[chan StatusTransition]struct{}
+	// starts the parts of a distributed job on the workers
+	dispatcher Dispatcher
+	// serializes the patches of the job
+	patchLock sync.Mutex
+	// the metrics of the observers of the current run, collected once it is stopped
+	results []*ObserverResult
 }
 
+// LoadConfig reads a json or yaml job configuration file, the references to environment variables and files
+// in its string values are replaced, see common.Interpolate
 func LoadConfig(file string) (*JobConfiguration, error) {
 	dat, err := os.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
 
+	if !strings.HasSuffix(file, ".json") && !strings.HasSuffix(file, ".yaml") && !strings.HasSuffix(file, ".yml") {
+		return nil, fmt.Errorf("configuration has to be json or yaml")
+	}
+
+	// json is valid yaml, both are read as a generic document to be interpolated
+	var document interface{}
+	if err := yaml.Unmarshal(dat, &document); err != nil {
+		return nil, err
+	}
+	payload, err := interpolateConfig(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to interpolate %s : %w", file, err)
+	}
+	return payload, nil
+}
+
+// interpolateConfig replaces the references of a decoded job document, the configuration remembers the
+// reference of each replaced value, so the job store keeps the references instead of the secrets
+func interpolateConfig(document interface{}) (*JobConfiguration, error) {
+	dat, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	var original interface{}
+	if err := json.Unmarshal(dat, &original); err != nil {
+		return nil, err
+	}
+
+	if document, err = common.InterpolateAll(document); err != nil {
+		return nil, err
+	}
+	if dat, err = json.Marshal(document); err != nil {
+		return nil, err
+	}
 	var payload JobConfiguration
-	if strings.HasSuffix(file, ".json") {
-		json.NewDecoder(bytes.NewBuffer(dat)).Decode(&payload)
-		return &payload, nil
-	} else if strings.HasSuffix(file, ".yaml") || strings.HasSuffix(file, ".yml") {
-		err := yaml.Unmarshal(dat, &payload)
-		if err != nil {
-			return nil, err
+	if err := json.NewDecoder(bytes.NewBuffer(dat)).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	payload.references = make(map[string]string)
+	collectReferences(original, document, payload.references)
+	return &payload, nil
+}
+
+// collectReferences maps each string of the interpolated document to the string it was interpolated from
+func collectReferences(original interface{}, interpolated interface{}, references map[string]string) {
+	switch v := interpolated.(type) {
+	case string:
+		if raw, ok := original.(string); ok && raw != v {
+			references[v] = raw
+		}
+	case map[string]interface{}:
+		if raw, ok := original.(map[string]interface{}); ok {
+			for key, item := range v {
+				collectReferences(raw[key], item, references)
+			}
 		}
-		return &payload, nil
+	case []interface{}:
+		if raw, ok := original.([]interface{}); ok && len(raw) == len(v) {
+			for index, item := range v {
+				collectReferences(raw[index], item, references)
+			}
+		}
+	}
+}
+
+// uninterpolated returns the configuration with the interpolated values replaced by their references
+func (c JobConfiguration) uninterpolated() (JobConfiguration, error) {
+	if len(c.references) == 0 {
+		return c, nil
 	}
 
-	return nil, fmt.Errorf("configuration has to be json or yaml")
+	dat, err := json.Marshal(c)
+	if err != nil {
+		return c, err
+	}
+	var document interface{}
+	if err := json.Unmarshal(dat, &document); err != nil {
+		return c, err
+	}
+	if dat, err = json.Marshal(replaceStrings(document, c.references)); err != nil {
+		return c, err
+	}
+	var result JobConfiguration
+	if err := json.Unmarshal(dat, &result); err != nil {
+		return c, err
+	}
+	return result, nil
+}
 
+func replaceStrings(value interface{}, replacements map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		if replacement, ok := replacements[v]; ok {
+			return replacement
+		}
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = replaceStrings(item, replacements)
+		}
+	case []interface{}:
+		for index, item := range v {
+			v[index] = replaceStrings(item, replacements)
+		}
+	}
+	return value
 }
 
 func SaveConfig(config JobConfiguration, file string) error {
@@ -97,67 +270,505 @@ func NewJobFromFile(file string) (*Job, error) {
 		return nil, err
 	}
 
-	log.Logger().Infof("using configuration : %v", jobConfig)
+	log.Logger().Infof("using configuration : %v", jobConfig.Redacted())
 	return NewJob(*jobConfig)
 }
 
 func NewJob(config JobConfiguration) (*Job, error) {
+	if config.Distribution != nil {
+		return nil, fmt.Errorf("distributed job has to be created on a coordinator")
+	}
+	// unknown and mistyped properties of the sinks and observers are rejected here instead of being ignored
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Schedule != nil {
+		// a scheduled job only records its runs, each run creates its own source, sinks and observers
+		return newJob(config.Name, nil, nil, nil, config.Timeout, config), nil
+	}
+	// a service which is not ready is returned as an error, it does not stop the server
+	return NewRunJob(config)
+}
+
+func createComponents(config JobConfiguration) (source.Source, []sink.Sink, []observer.Observer, error) {
 	source, err := source.NewGenarator(config.Source)
 	if err != nil {
-		return nil, err
+		return nil, nil, nil, err
 	}
 
 	sinks := make([]sink.Sink, len(config.Sinks))
 	for index, sinkConfig := range config.Sinks {
-		if sink, err := sink.CreateSink(sinkConfig); err != nil {
+		created, err := sink.CreateSink(sinkConfig)
+		if err != nil {
 			log.Logger().WithError(err).Errorf("failed to create sink")
-			return nil, err
-		} else {
-			sinks[index] = sink
+			closeComponents(sinks[:index], nil)
+			return nil, nil, nil, err
+		}
+
+		// failed writes are retried and sent to the dead letter destination of the sink
+		if sinks[index], err = sink.NewRetrySink(created, sinkConfig); err != nil {
+			closeComponents(append(sinks[:index], created), nil)
+			return nil, nil, nil, fmt.Errorf("invalid sink %d : %w", index, err)
 		}
 	}
-	obs := make([]observer.Observer, 0)
 
-	for _, obConfig := range config.Observers {
+	// the observers stay in the order of the configuration, their index is used to disable them and to report their results
+	obs := make([]observer.Observer, len(config.Observers))
+	for index, obConfig := range config.Observers {
 		ob, err := observer.CreateObserver(obConfig)
 		if err != nil {
-			log.Logger().WithError(err).Warnf("failed to create observer %s", obConfig.Type)
-		} else {
-			obs = append(obs, ob)
+			log.Logger().WithError(err).Errorf("failed to create observer %s", obConfig.Type)
+			closeComponents(sinks, obs[:index])
+			return nil, nil, nil, fmt.Errorf("invalid observer %d : %w", index, err)
+		}
+		obs[index] = ob
+	}
+
+	return source, sinks, obs, nil
+}
+
+// closeComponents closes the sinks and observers of a job which is never started
+func closeComponents(sinks []sink.Sink, obs []observer.Observer) {
+	for index, s := range sinks {
+		if err := s.Close(); err != nil {
+			log.Logger().WithError(err).Errorf("failed to close sink %d", index)
+		}
+	}
+	for index, ob := range obs {
+		if err := ob.Close(); err != nil {
+			log.Logger().WithError(err).Errorf("failed to close observer %d", index)
 		}
 	}
+}
 
-	return CreateJob(config.Name, source, sinks, obs, config.Timeout, config), nil
+// CreateJob creates a job from its components, a service which is not ready or a sink failing to initialize
+// is returned as an error
+func CreateJob(name string, source source.Source, sinks []sink.Sink, obs []observer.Observer, timeout int, config JobConfiguration) (*Job, error) {
+	job := newJob(name, source, sinks, obs, timeout, config)
+	if err := job.initSinks(); err != nil {
+		log.Logger().WithError(err).Errorf("failed to initialize sink")
+		// the objects of the sinks initialized before the failure are dropped as after a failed run
+		ctx, cancel := context.WithTimeout(context.Background(), DRAIN_TIMEOUT)
+		defer cancel()
+		job.cleanup(ctx, sinks, false)
+		closeComponents(sinks, obs)
+		return nil, err
+	}
+
+	return job, nil
 }
 
-func CreateJob(name string, source source.Source, sinks []sink.Sink, obs []observer.Observer, timeout int, config JobConfiguration) *Job {
+// NewRunJob creates a job running once, like the runs of a scheduled job
+func NewRunJob(config JobConfiguration) (*Job, error) {
+	if config.Distribution != nil {
+		return nil, fmt.Errorf("distributed job has to be created on a coordinator")
+	}
+	source, sinks, obs, err := createComponents(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return CreateJob(config.Name, source, sinks, obs, config.Timeout, config)
+}
+
+// RunJob runs a job created from the configuration until it times out, its source completes
+// or the context is done, and returns the outcome of the run
+func RunJob(ctx context.Context, config JobConfiguration) (*Run, error) {
+	run, err := NewRunJob(config)
+	if err != nil {
+		return nil, err
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			run.Stop()
+		case <-done:
+		}
+	}()
+
+	run.RunToEnd()
+	return run.CurrentRun(), ctx.Err()
+}
+
+func newJob(name string, source source.Source, sinks []sink.Sink, obs []observer.Observer, timeout int, config JobConfiguration) *Job {
 	id := uuid.New().String()
+	now := time.Now().UTC()
 	job := &Job{
 		Id:        id,
 		Name:      name,
 		Status:    STATUS_INIT,
+		RunId:     uuid.New().String(),
+		CreatedAt: now,
+		History:   []StatusTransition{{Status: STATUS_INIT, Time: now}},
 		source:    source,
 		sinks:     sinks,
 		observers: obs,
 		timeout:   timeout,
+		jobWaiter: &sync.WaitGroup{},
 		Config:    config,
 		lock:      sync.Mutex{},
-		Stats: &Stats{
-			SuccessWrite: 0,
-			FailedWrite:  0,
-		},
+		Stats:     newStats(config, len(sinks)),
+	}
+
+	if config.Schedule != nil {
+		job.ScheduleState = &ScheduleState{}
+	}
+
+	return job
+}
+
+// measuredBytes returns a byte counter when the events of the source are measured, nil otherwise
+func measuredBytes(config JobConfiguration) *int64 {
+	// measuring an event encodes it, so the bytes are only counted when the payload size is under test
+	if config.Source.PayloadSize == nil {
+		return nil
+	}
+	return new(int64)
+}
+
+// formatBytes formats a byte counter of the stats
+func formatBytes(bytes *int64) string {
+	if bytes == nil {
+		return "not measured"
+	}
+	return strconv.FormatInt(*bytes, 10)
+}
+
+func newStats(config JobConfiguration, sinkCount int) *Stats {
+	sinkStats := make([]*SinkStats, sinkCount)
+	for index := range sinkStats {
+		sinkStats[index] = &SinkStats{BytesWritten: measuredBytes(config)}
+		if index < len(config.Sinks) {
+			sinkStats[index].Type = config.Sinks[index].Type
+		}
 	}
 
-	// initialize all sinks with fields defineid in source
-	fields := job.source.GetFields()
-	for _, sink := range job.sinks {
-		err := sink.Init(name, fields) // todo : check init status here
+	return &Stats{
+		SuccessWrite: 0,
+		FailedWrite:  0,
+		BytesWritten: measuredBytes(config),
+		Sinks:        sinkStats,
+	}
+}
+
+func copyStats(stats *Stats) *Stats {
+	result := *stats
+	result.BytesWritten = copyBytes(stats.BytesWritten)
+	result.Sinks = make([]*SinkStats, len(stats.Sinks))
+	for index, sinkStats := range stats.Sinks {
+		s := *sinkStats
+		s.BytesWritten = copyBytes(sinkStats.BytesWritten)
+		result.Sinks[index] = &s
+	}
+	return &result
+}
+
+// copyBytes copies a byte counter, so the copied stats do not change with the run
+func copyBytes(bytes *int64) *int64 {
+	if bytes == nil {
+		return nil
+	}
+	value := *bytes
+	return &value
+}
+
+// restoreJob recreates a job loaded from the job store, its source, sinks and observers
+// are only created when the job is started again
+func restoreJob(record *JobRecord, store JobStore) *Job {
+	stats := record.Stats
+	if stats == nil {
+		stats = &Stats{}
+	}
+
+	if record.Interpolated {
+		var document interface{}
+		dat, err := json.Marshal(record.Config)
+		if err == nil {
+			err = json.Unmarshal(dat, &document)
+		}
+		var config *JobConfiguration
+		if err == nil {
+			config, err = interpolateConfig(document)
+		}
 		if err != nil {
-			log.Logger().WithError(err).Fatalf("failed to initialize sink")
+			// the job fails to start until the references can be resolved again
+			log.Logger().WithError(err).Errorf("failed to interpolate the configuration of job %s", record.Id)
+		} else {
+			record.Config = *config
 		}
 	}
 
-	return job
+	return &Job{
+		Id:        record.Id,
+		Name:      record.Name,
+		Status:    record.Status,
+		Config:    record.Config,
+		RunId:     record.RunId,
+		Stats:     stats,
+		CreatedAt: record.CreatedAt,
+		StartedAt: record.StartedAt,
+		StoppedAt: record.StoppedAt,
+		History:   record.History,
+		Runs:      record.Runs,
+		timeout:   record.Config.Timeout,
+		jobWaiter: &sync.WaitGroup{},
+		lock:      sync.Mutex{},
+		store:     store,
+
+		Annotations:   record.Annotations,
+		ScheduleState: record.ScheduleState,
+	}
+}
+
+// initialize all sinks with fields defineid in source, once the sinks and observers are ready when the job
+// has readiness
+func (j *Job) initSinks() error {
+	if j.Config.Readiness != nil {
+		if err := waitReady(context.Background(), *j.Config.Readiness, j.readinessTargets()); err != nil {
+			return err
+		}
+	}
+
+	fields := j.source.GetFields()
+	for index, s := range j.sinks {
+		if cleanup := j.Config.Sinks[index].Cleanup; cleanup != nil && cleanup.DropExisting {
+			if cleaner, ok := s.(sink.Cleaner); ok {
+				if err := cleaner.DropExisting(context.Background(), j.Name); err != nil {
+					return fmt.Errorf("failed to drop the existing object of sink %d : %w", index, err)
+				}
+			}
+		}
+		if err := s.Init(context.Background(), j.Name, fields); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare creates the source, sinks and observers of a restored job
+func (j *Job) prepare() error {
+	if j.Config.Distribution != nil {
+		return j.checkDispatcher()
+	}
+	if j.source != nil {
+		return nil
+	}
+
+	source, sinks, obs, err := createComponents(j.Config)
+	if err != nil {
+		return err
+	}
+
+	j.source = source
+	j.sinks = sinks
+	j.observers = obs
+	if len(j.Stats.Sinks) != len(sinks) {
+		j.Stats = newStats(j.Config, len(sinks))
+	}
+	return j.initSinks()
+}
+
+// reset rebuilds the source, sinks and observers from the job configuration and starts a new run,
+// the stats of the current run are archived in the run history
+func (j *Job) reset() error {
+	var source source.Source
+	var sinks []sink.Sink
+	var obs []observer.Observer
+	if j.Config.Distribution != nil {
+		// the components of a distributed job are created by the workers
+		if err := j.checkDispatcher(); err != nil {
+			return err
+		}
+	} else {
+		var err error
+		if source, sinks, obs, err = createComponents(j.Config); err != nil {
+			return err
+		}
+	}
+
+	j.lock.Lock()
+	if j.stopped == nil && j.source != nil {
+		// the previous components were never started, so never released
+		go j.release(j.sinks, j.observers, true)
+	}
+	j.stopped = nil
+	if j.StartedAt != nil {
+		j.Runs = append(j.Runs, &Run{
+			Id:        j.RunId,
+			Status:    j.Status,
+			StartedAt: j.StartedAt,
+			StoppedAt: j.StoppedAt,
+			Stats:     copyStats(j.Stats),
+		})
+	}
+	j.RunId = uuid.New().String()
+	j.Stats = newStats(j.Config, len(j.Config.Sinks))
+	j.results = nil
+	j.Workers = nil
+	j.StartedAt = nil
+	j.StoppedAt = nil
+	j.source = source
+	j.sinks = sinks
+	j.observers = obs
+	j.lock.Unlock()
+
+	if j.Config.Distribution == nil {
+		if err := j.initSinks(); err != nil {
+			return err
+		}
+	}
+
+	j.setStatus(STATUS_INIT)
+	return nil
+}
+
+// checkDispatcher fails when a distributed job is restored on a server which is not a coordinator
+func (j *Job) checkDispatcher() error {
+	if j.dispatcher == nil {
+		return fmt.Errorf("distributed job %s has to be run on a coordinator", j.Id)
+	}
+	return nil
+}
+
+// CurrentRun returns the outcome of the current run
+func (j *Job) CurrentRun() *Run {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return &Run{
+		Id:        j.RunId,
+		Status:    j.Status,
+		StartedAt: j.StartedAt,
+		StoppedAt: j.StoppedAt,
+		Stats:     copyStats(j.Stats),
+	}
+}
+
+// finishRun records a finished scheduled run in the run history
+// PreviousRuns returns the outcome of the previous runs of the job, the oldest first
+func (j *Job) PreviousRuns() []*Run {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return append([]*Run{}, j.Runs...)
+}
+
+func (j *Job) finishRun(run *Run) {
+	j.lock.Lock()
+	j.Runs = append(j.Runs, run)
+	j.Stats = run.Stats
+	if j.ScheduleState != nil {
+		j.ScheduleState.LastRunStatus = run.Status
+	}
+	j.lock.Unlock()
+
+	j.persist()
+}
+
+// Scheduling returns a copy of the scheduling information of a scheduled job, nil for the other jobs
+func (j *Job) Scheduling() *ScheduleState {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.ScheduleState == nil {
+		return nil
+	}
+	state := *j.ScheduleState
+	return &state
+}
+
+func (j *Job) updateScheduleState(update func(state *ScheduleState)) {
+	j.lock.Lock()
+	if j.ScheduleState == nil {
+		j.ScheduleState = &ScheduleState{}
+	}
+	update(j.ScheduleState)
+	j.lock.Unlock()
+}
+
+func (j *Job) setStatus(status JobStatus) {
+	now := time.Now().UTC()
+	j.lock.Lock()
+	j.Status = status
+	transition := StatusTransition{Status: status, Time: now}
+	j.History = append(j.History, transition)
+	switch status {
+	case STATUS_RUNNING:
+		j.StartedAt = &now
+	case STATUS_STOPPED, STATUS_FAILED:
+		j.StoppedAt = &now
+	}
+	for subscriber := range j.subscribers {
+		select {
+		case subscriber <- transition:
+		default:
+			// a slow subscriber misses the transition, it still gets the status in the next snapshot
+		}
+	}
+	j.lock.Unlock()
+
+	j.persist()
+}
+
+// record returns a snapshot of the job for the job store
+func (j *Job) record() *JobRecord {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	// the secrets read from the environment or files are stored as their references
+	config, err := j.Config.uninterpolated()
+	if err != nil {
+		log.Logger().WithError(err).Errorf("failed to store the references of job %s, its secrets are not stored", j.Id)
+		config = j.Config.Redacted()
+	}
+	record := &JobRecord{
+		Id:           j.Id,
+		Name:         j.Name,
+		Status:       j.Status,
+		Config:       config,
+		Interpolated: len(j.Config.references) > 0,
+		RunId:        j.RunId,
+		Stats:        copyStats(j.Stats),
+		CreatedAt:    j.CreatedAt,
+		StartedAt:    j.StartedAt,
+		StoppedAt:    j.StoppedAt,
+		History:      append([]StatusTransition{}, j.History...),
+		Runs:         append([]*Run{}, j.Runs...),
+
+		Annotations: append([]*Annotation{}, j.Annotations...),
+	}
+	if j.ScheduleState != nil {
+		state := *j.ScheduleState
+		record.ScheduleState = &state
+	}
+	return record
+}
+
+func (j *Job) persist() {
+	if j.store == nil {
+		return
+	}
+
+	if err := j.store.Save(j.record()); err != nil {
+		log.Logger().WithError(err).Errorf("failed to persist job %s", j.Id)
+	}
+}
+
+// MarshalJSON encodes the job with the secrets of its configuration redacted, under the job lock as the job
+// may be running
+func (j *Job) MarshalJSON() ([]byte, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	type plainJob Job
+	return json.Marshal(struct {
+		*plainJob
+		Config JobConfiguration `json:"config"`
+	}{
+		plainJob: (*plainJob)(j),
+		Config:   j.Config.Redacted(),
+	})
 }
 
 func (j *Job) ID() string {
@@ -165,23 +776,69 @@ func (j *Job) ID() string {
 }
 
 func (j *Job) Start() {
+	if j.Config.Distribution != nil {
+		j.startDistributed()
+		return
+	}
+
 	startTime := time.Now()
-	j.source.Start()
+	streams := j.source.GetStreams()
+	// each run has its own waiter, streams of a previous run may still be draining
+	waiter := &sync.WaitGroup{}
+	waiter.Add(len(streams))
 
-	for _, ob := range j.observers {
+	j.lock.Lock()
+	if j.stopped != nil {
+		// stopped before it is started, its sinks are already closed
+		j.lock.Unlock()
+		log.Logger().Warnf("job %s is stopped before it is started", j.Id)
+		return
+	}
+	// the stats and sinks of this run, a restart replaces them on the job
+	stats := j.Stats
+	sinks := j.sinks
+	runId := j.RunId
+	measure := stats.BytesWritten != nil
+	queues, err := j.createQueues(sinks, stats, len(streams))
+	if err != nil {
+		j.lock.Unlock()
+		log.Logger().WithError(err).Errorf("failed to start job %s", j.Id)
+		j.setStatus(STATUS_FAILED)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	writeCtx, abort := context.WithCancel(context.Background())
+	j.jobWaiter = waiter
+	j.queues = queues
+	j.ctx = ctx
+	j.cancel = cancel
+	j.abort = abort
+	j.stopped = make(chan struct{})
+	observers := j.observers
+	j.lock.Unlock()
+
+	for _, queue := range queues {
+		queue.Start(writeCtx)
+	}
+	j.source.Start(ctx)
+
+	for index, ob := range observers {
+		if j.observerDisabled(index) {
+			log.Logger().Infof("observer %d is disabled", index)
+			continue
+		}
 		log.Logger().Info("start observer")
-		go ob.Observe() // start observer go routine
+		if err := ob.Observe(ctx); err != nil {
+			log.Logger().WithError(err).Warnf("failed to start observer")
+		}
 	}
 
-	j.Status = STATUS_RUNNING
+	j.setStatus(STATUS_RUNNING)
 
-	streams := j.source.GetStreams()
 	log.Logger().Infof("get %d stream from source", len(streams))
 
 	if len(streams) > 0 {
-		j.jobWaiter = sync.WaitGroup{}
-		j.jobWaiter.Add(len(streams))
-
 		for i, stream := range streams {
 			time.Sleep(time.Duration(rand.Intn(100)) * time.Microsecond)
 			log.Logger().Infof("start stream %d ", i)
@@ -195,25 +852,39 @@ func (j *Job) Start() {
 
 					header := events[0].GetHeader()
 					data := make([][]interface{}, len(events))
+					size := int64(0)
 					for index, event := range events {
-						row := event.GetRow(header)
-						data[index] = row
+						data[index] = event.GetRow(header)
+						if measure {
+							size += int64(event.Size())
+						}
 					}
 
-					for _, sink := range j.sinks {
-						if err := sink.Write(header, data, i); err != nil {
-							log.Logger().Errorf("failed to write event : %v ", err)
-							j.lock.Lock()
-							j.Stats.FailedWrite += len(data)
-							j.lock.Unlock()
-						} else {
-							j.lock.Lock()
-							j.Stats.SuccessWrite += len(data)
-							j.lock.Unlock()
+					// each sink has its own queue, a slow sink does not hold the others back
+					j.lock.Lock()
+					sinkConfigs := j.Config.Sinks
+					j.lock.Unlock()
+					enabled := make([]*sink.Queue, 0, len(queues))
+					for sinkIndex, queue := range queues {
+						if sinkIndex < len(sinkConfigs) && sinkConfigs[sinkIndex].Disabled {
+							continue
 						}
+						enabled = append(enabled, queue)
+					}
+					// the rows are counted once in the stats of the run, when all the sinks are done with them
+					delivery := sink.NewDelivery(len(enabled))
+					for _, queue := range enabled {
+						queue.Push((&sink.Batch{Headers: header, Rows: data, Index: i, Size: size}).WithDelivery(delivery))
 					}
+
+					j.lock.Lock()
+					stats.Generated += len(data)
+					for sinkIndex, queue := range queues {
+						updateQueueStats(stats.Sinks[sinkIndex], queue)
+					}
+					j.lock.Unlock()
 				}
-				j.jobWaiter.Done()
+				waiter.Done()
 			}(i, stream)
 		}
 	} else {
@@ -224,6 +895,9 @@ func (j *Job) Start() {
 		log.Logger().Infof("wait for timeout %d", j.timeout)
 		for {
 			time.Sleep(1 * time.Second)
+			if !j.isRunning(runId) {
+				break // stopped or restarted meanwhile
+			}
 			if -time.Until(startTime).Seconds() > float64(j.timeout) {
 				log.Logger().Infof("timeout and exit data generating")
 				j.Stop() // stop the job on timeout
@@ -233,7 +907,11 @@ func (j *Job) Start() {
 		log.Logger().Infof("timeout")
 	}
 
-	for _, ob := range j.observers {
+	// an observer enabled again by a patch is a new instance
+	j.lock.Lock()
+	observers = j.observers
+	j.lock.Unlock()
+	for _, ob := range observers {
 		log.Logger().Infof("wait observer finish")
 		ob.Wait()
 		log.Logger().Infof("observer finished")
@@ -242,18 +920,282 @@ func (j *Job) Start() {
 	log.Logger().Infof("job finished")
 }
 
+// createQueues creates the queue of each sink, the written batches are counted in the stats of the run
+func (j *Job) createQueues(sinks []sink.Sink, stats *Stats, streamCount int) ([]*sink.Queue, error) {
+	queues := make([]*sink.Queue, len(sinks))
+	for index, s := range sinks {
+		config := sink.QueueConfiguration{}
+		if index < len(j.Config.Sinks) && j.Config.Sinks[index].Queue != nil {
+			config = *j.Config.Sinks[index].Queue
+		}
+
+		sinkIndex := index
+		queue, err := sink.NewQueue(s, config, streamCount, func(batch *sink.Batch, err error) {
+			j.lock.Lock()
+			defer j.lock.Unlock()
+
+			sinkStats := stats.Sinks[sinkIndex]
+			dropped := errors.Is(err, sink.ErrDropped)
+			if dropped {
+				// the rows of a dropped batch are failed, the batch is never written
+				sinkStats.FailedWrite += len(batch.Rows)
+			} else if err != nil {
+				log.Logger().Errorf("failed to write event : %v ", err)
+				sinkStats.FailedWrite += len(batch.Rows)
+			} else {
+				sinkStats.SuccessWrite += len(batch.Rows)
+				if sinkStats.BytesWritten != nil {
+					*sinkStats.BytesWritten += batch.Size
+				}
+			}
+			if done, failed := batch.Delivered(err); done && failed {
+				stats.FailedWrite += len(batch.Rows)
+			} else if done {
+				stats.SuccessWrite += len(batch.Rows)
+				if stats.BytesWritten != nil {
+					*stats.BytesWritten += batch.Size
+				}
+			}
+			updateQueueStats(sinkStats, queues[sinkIndex])
+			if dropped {
+				return
+			}
+			sinkStats.Batches++
+			sinkStats.LagMs = batch.Dequeued.Sub(batch.Enqueued).Milliseconds()
+
+			writeStats := s.GetStats()
+			sinkStats.Errors = writeStats.Errors
+			sinkStats.Retries = writeStats.Retries
+			sinkStats.DeadLetterRows = writeStats.DeadLetterRows
+			sinkStats.WriteLatency = writeStats.Latency
+		})
+		if err != nil {
+			return nil, fmt.Errorf("invalid queue of sink %d : %w", index, err)
+		}
+		queues[index] = queue
+	}
+	return queues, nil
+}
+
+func updateQueueStats(stats *SinkStats, queue *sink.Queue) {
+	stats.QueueDepth = queue.Depth()
+	stats.DroppedBatches = queue.Dropped()
+}
+
+// RunToEnd starts the job and waits until it is finished
+func (j *Job) RunToEnd() {
+	j.Start() // blocks until the job times out
+	if j.timeout == 0 {
+		// no timeout, the job lasts until the source completes or it is stopped
+		j.jobWaiter.Wait()
+		if j.isRunning(j.RunId) {
+			j.Stop()
+		}
+	}
+	j.Wait()
+}
+
+func (j *Job) status() JobStatus {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.Status
+}
+
+func (j *Job) configuration() JobConfiguration {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.Config
+}
+
+// takeScheduler detaches the scheduler of the job, it returns nil when the job is not scheduled
+func (j *Job) takeScheduler() *scheduler {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	scheduler := j.scheduler
+	j.scheduler = nil
+	return scheduler
+}
+
+func (j *Job) isRunning(runId string) bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.Status == STATUS_RUNNING && j.RunId == runId
+}
+
 func (j *Job) Wait() {
 	j.jobWaiter.Wait()
 
-	for _, ob := range j.observers {
+	j.lock.Lock()
+	observers := j.observers
+	j.lock.Unlock()
+	for _, ob := range observers {
 		ob.Wait()
 	}
 }
 
+// Stop stops generating and waits for the batches in flight to be written, then flushes and closes the
+// sinks so the rows buffered by asynchronous producers are not lost, and stops the observers
 func (j *Job) Stop() {
-	j.source.Stop()
-	for _, ob := range j.observers {
-		ob.Stop()
+	if j.Config.Distribution != nil {
+		j.stopDistributed()
+		return
+	}
+
+	j.lock.Lock()
+	cancel, abort, stopped := j.cancel, j.abort, j.stopped
+	waiter, sinks, observers, queues := j.jobWaiter, j.sinks, j.observers, j.queues
+	j.cancel = nil
+	if stopped == nil {
+		// the job is never started, only its sinks and observers have to be released
+		stopped = make(chan struct{})
+		close(stopped)
+		j.stopped = stopped
+		j.lock.Unlock()
+
+		j.release(sinks, observers, j.succeeded())
+		j.setStatus(STATUS_STOPPED)
+		return
+	}
+	j.lock.Unlock()
+
+	if cancel == nil {
+		// the run is already stopped or being stopped by another call
+		<-stopped
+		if j.status() != STATUS_STOPPED {
+			j.setStatus(STATUS_STOPPED)
+		}
+		return
+	}
+	defer close(stopped)
+
+	cancel()
+	deadline := time.Now().Add(DRAIN_TIMEOUT)
+	drained := waitTimeout(waiter, DRAIN_TIMEOUT)
+
+	// write the batches left in the queues of the sinks
+	queueWaiter := sync.WaitGroup{}
+	queueDrained := make([]bool, len(queues))
+	queueWaiter.Add(len(queues))
+	for index, queue := range queues {
+		go func(index int, queue *sink.Queue) {
+			defer queueWaiter.Done()
+			queueDrained[index] = queue.Close(time.Until(deadline))
+		}(index, queue)
+	}
+	queueWaiter.Wait()
+	for _, ok := range queueDrained {
+		drained = drained && ok
+	}
+
+	if !drained {
+		log.Logger().Warnf("job %s is not drained in %s, abort the pending writes", j.Id, DRAIN_TIMEOUT)
+	}
+	abort()
+	// the sinks are released once no worker is writing to them anymore
+	for _, queue := range queues {
+		queue.Wait()
+	}
+
+	j.release(sinks, observers, j.succeeded())
+	j.lock.Lock()
+	j.results = observerResults(observers, j.Config.Observers)
+	j.lock.Unlock()
+	j.setStatus(STATUS_STOPPED)
+	j.archive(j)
+
+	stats := j.CurrentRun().Stats
+	log.Logger().Infof("job %s stopped, generated %d, written to all sinks %d, failed %d, bytes written %s",
+		j.Id, stats.Generated, stats.SuccessWrite, stats.FailedWrite, formatBytes(stats.BytesWritten))
+	for index, sinkStats := range stats.Sinks {
+		latency := sinkStats.WriteLatency
+		log.Logger().Infof("sink %d (%s) of job %s, rows %d, bytes %s, batches %d, errors %d, retries %d, "+
+			"write latency p50 %.2fms p90 %.2fms p99 %.2fms max %.2fms",
+			index, sinkStats.Type, j.Id, sinkStats.SuccessWrite, formatBytes(sinkStats.BytesWritten), sinkStats.Batches,
+			sinkStats.Errors, sinkStats.Retries, latency.P50, latency.P90, latency.P99, latency.Max)
+	}
+}
+
+// release flushes the sinks, stops and closes the observers, drops the objects of the sinks according to
+// their cleanup policy, then closes the sinks
+func (j *Job) release(sinks []sink.Sink, observers []observer.Observer, succeeded bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), DRAIN_TIMEOUT)
+	defer cancel()
+
+	for index, sink := range sinks {
+		if err := sink.Flush(ctx); err != nil {
+			log.Logger().WithError(err).Errorf("failed to flush sink %d of job %s", index, j.Id)
+		}
+	}
+
+	// the observers are stopped before the objects they observe are dropped
+	for index, ob := range observers {
+		// a disabled observer is not observing
+		if !j.observerDisabled(index) {
+			ob.Stop()
+		}
+		if err := ob.Close(); err != nil {
+			log.Logger().WithError(err).Errorf("failed to close observer of job %s", j.Id)
+		}
+	}
+
+	j.cleanup(ctx, sinks, succeeded)
+
+	for index, sink := range sinks {
+		if err := sink.Close(); err != nil {
+			log.Logger().WithError(err).Errorf("failed to close sink %d of job %s", index, j.Id)
+		}
+	}
+}
+
+// cleanup drops the objects created by the sinks whose cleanup policy applies to the run
+func (j *Job) cleanup(ctx context.Context, sinks []sink.Sink, succeeded bool) {
+	j.lock.Lock()
+	configs := j.Config.Sinks
+	j.lock.Unlock()
+
+	for index, s := range sinks {
+		if index >= len(configs) || configs[index].Cleanup == nil || !configs[index].Cleanup.Drop(succeeded) {
+			continue
+		}
+		cleaner, ok := s.(sink.Cleaner)
+		if !ok {
+			continue
+		}
+		if err := cleaner.Cleanup(ctx); err != nil {
+			log.Logger().WithError(err).Errorf("failed to clean up sink %d (%s) of job %s", index, configs[index].Type, j.Id)
+			continue
+		}
+		log.Logger().Infof("sink %d (%s) of job %s is cleaned up", index, configs[index].Type, j.Id)
+	}
+}
+
+// succeeded tells whether the current run is not failed and wrote all its rows
+func (j *Job) succeeded() bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return j.Status != STATUS_FAILED && j.Stats.FailedWrite == 0
+}
+
+func (j *Job) observerDisabled(index int) bool {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return index < len(j.Config.Observers) && j.Config.Observers[index].Disabled
+}
+
+// waitTimeout waits for the wait group, it returns false when the timeout is reached first
+func waitTimeout(waiter *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		waiter.Wait()
+		close(done)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
 	}
-	j.Status = STATUS_STOPPED
 }
@@ -1,9 +1,11 @@
 package job
 
 import (
+	"encoding/json"
 	"fmt"
 	"sync"
 
+	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/source"
@@ -15,24 +17,230 @@ type JobConfiguration struct {
 	Sinks     []sink.Configuration     `json:"sinks,omitempty"`
 	Observers []observer.Configuration `json:"observer,omitempty"`
 	Timeout   int                      `json:"timeout,omitempty"`
+	Schedule  *Schedule                `json:"schedule,omitempty"`
+	// splits the job across the workers of the coordinator
+	Distribution *Distribution `json:"distribution,omitempty"`
+	// waits for the services of the sinks and observers before the sinks are initialized
+	Readiness *ReadinessConfiguration `json:"readiness,omitempty"`
+
+	// the references of the values interpolated from a job file, by value
+	references map[string]string
+}
+
+// Validate checks the configuration without creating the sinks and observers, so no target is reached
+func (c JobConfiguration) Validate() error {
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+	if _, err := source.NewGenarator(c.Source); err != nil {
+		return fmt.Errorf("invalid source : %w", err)
+	}
+
+	for index, sinkConfig := range c.Sinks {
+		if err := validateSinkProperties(sinkConfig); err != nil {
+			return fmt.Errorf("sink %d : %w", index, err)
+		}
+		if sinkConfig.DeadLetter != nil && sinkConfig.DeadLetter.Sink != nil {
+			if err := validateSinkProperties(*sinkConfig.DeadLetter.Sink); err != nil {
+				return fmt.Errorf("dead letter sink of sink %d : %w", index, err)
+			}
+		}
+		if sinkConfig.Queue != nil {
+			if err := sinkConfig.Queue.Validate(); err != nil {
+				return fmt.Errorf("invalid queue of sink %d : %w", index, err)
+			}
+		}
+		if sinkConfig.Retry != nil {
+			if err := sinkConfig.Retry.Validate(); err != nil {
+				return fmt.Errorf("invalid retry of sink %d : %w", index, err)
+			}
+		}
+		if sinkConfig.Cleanup != nil {
+			if err := sinkConfig.Cleanup.Validate(); err != nil {
+				return fmt.Errorf("invalid cleanup of sink %d : %w", index, err)
+			}
+		}
+	}
+	for index, obConfig := range c.Observers {
+		properties, exist := observer.GetProperties(obConfig.Type)
+		if !exist {
+			return fmt.Errorf("observer %d : the observer %s does not exist", index, obConfig.Type)
+		}
+		if _, err := properties.Parse(obConfig.Properties); err != nil {
+			return fmt.Errorf("observer %d : invalid properties of %s : %w", index, obConfig.Type, err)
+		}
+	}
+
+	if c.Schedule != nil {
+		if _, err := c.Schedule.parse(); err != nil {
+			return fmt.Errorf("invalid schedule : %w", err)
+		}
+	}
+	if c.Readiness != nil {
+		if err := c.Readiness.Validate(); err != nil {
+			return fmt.Errorf("invalid readiness : %w", err)
+		}
+	}
+	if c.Distribution != nil {
+		if err := c.Distribution.Validate(c); err != nil {
+			return fmt.Errorf("invalid distribution : %w", err)
+		}
+	}
+	return nil
+}
+
+func validateSinkProperties(config sink.Configuration) error {
+	properties, exist := sink.GetProperties(config.Type)
+	if !exist {
+		return fmt.Errorf("the sink %s does not exist", config.Type)
+	}
+	if _, err := properties.Parse(config.Properties); err != nil {
+		return fmt.Errorf("invalid properties of %s : %w", config.Type, err)
+	}
+	return nil
 }
 
+// Redacted returns a copy of the configuration where the secrets of the sinks and observers are replaced, to be
+// returned by the api or logged
+func (c JobConfiguration) Redacted() JobConfiguration {
+	result := c
+	result.Sinks = make([]sink.Configuration, len(c.Sinks))
+	for index, sinkConfig := range c.Sinks {
+		result.Sinks[index] = redactSink(sinkConfig)
+	}
+	result.Observers = make([]observer.Configuration, len(c.Observers))
+	for index, obConfig := range c.Observers {
+		properties, _ := observer.GetProperties(obConfig.Type)
+		obConfig.Properties = properties.Redact(obConfig.Properties)
+		result.Observers[index] = obConfig
+	}
+	return result
+}
+
+func redactSink(config sink.Configuration) sink.Configuration {
+	properties, _ := sink.GetProperties(config.Type)
+	config.Properties = properties.Redact(config.Properties)
+	if config.DeadLetter != nil && config.DeadLetter.Sink != nil {
+		deadLetterSink := redactSink(*config.DeadLetter.Sink)
+		config.DeadLetter = &sink.DeadLetterConfiguration{File: config.DeadLetter.File, Sink: &deadLetterSink}
+	}
+	return config
+}
+
+// ResumePolicy decides what happens to jobs that were running when the server stopped
+type ResumePolicy string
+
+const (
+	RESUME_POLICY_FAIL   ResumePolicy = "fail"
+	RESUME_POLICY_RESUME ResumePolicy = "resume"
+)
+
 type JobManager struct {
-	jobs sync.Map
+	jobs       sync.Map
+	store      JobStore
+	dispatcher Dispatcher
 }
 
 func NewJobManager() *JobManager {
 	return &JobManager{
-		jobs: sync.Map{},
+		jobs:  sync.Map{},
+		store: NewMemoryJobStore(),
+	}
+}
+
+// NewJobManagerWithStore creates a job manager and restores the jobs kept in the store
+func NewJobManagerWithStore(store JobStore, policy ResumePolicy) (*JobManager, error) {
+	switch policy {
+	case "", RESUME_POLICY_FAIL, RESUME_POLICY_RESUME:
+	default:
+		return nil, fmt.Errorf("invalid resume policy %s", policy)
+	}
+
+	records, err := store.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load jobs : %w", err)
 	}
+
+	m := &JobManager{
+		jobs:  sync.Map{},
+		store: store,
+	}
+
+	for _, record := range records {
+		job := restoreJob(record, store)
+		m.jobs.Store(job.ID(), job)
+
+		if job.Status == STATUS_SCHEDULED {
+			// runs in progress are lost, the schedule itself is always activated again
+			if err := m.startSchedule(job); err != nil {
+				log.Logger().WithError(err).Errorf("failed to schedule job %s", job.Id)
+				job.setStatus(STATUS_FAILED)
+			}
+			continue
+		}
+
+		if job.Status != STATUS_RUNNING {
+			continue
+		}
+
+		if job.Config.Distribution != nil {
+			// the parts run by the workers are not tracked anymore
+			log.Logger().Warnf("distributed job %s was interrupted, mark it as failed", job.Id)
+			job.setStatus(STATUS_FAILED)
+			continue
+		}
+
+		if policy != RESUME_POLICY_RESUME {
+			log.Logger().Warnf("job %s was interrupted, mark it as failed", job.Id)
+			job.setStatus(STATUS_FAILED)
+			continue
+		}
+
+		if err := job.prepare(); err != nil {
+			log.Logger().WithError(err).Errorf("failed to resume job %s", job.Id)
+			job.setStatus(STATUS_FAILED)
+			continue
+		}
+
+		log.Logger().Infof("resume job %s", job.Id)
+		go job.Start()
+	}
+
+	return m, nil
+}
+
+func (m *JobManager) addJob(job *Job) {
+	job.store = m.store
+	m.jobs.Store(job.ID(), job)
+	job.persist()
+}
+
+// SetDispatcher makes the manager a coordinator, its distributed jobs are run by the workers of the dispatcher
+func (m *JobManager) SetDispatcher(dispatcher Dispatcher) {
+	m.dispatcher = dispatcher
+	m.jobs.Range(func(key, value interface{}) bool {
+		if job := value.(*Job); job.Config.Distribution != nil {
+			job.dispatcher = dispatcher
+		}
+		return true
+	})
 }
 
 func (m *JobManager) CreateJob(config JobConfiguration) (*Job, error) {
+	if config.Distribution != nil {
+		job, err := newDistributedJob(config, m.dispatcher)
+		if err != nil {
+			return nil, err
+		}
+		m.addJob(job)
+		return job, nil
+	}
+
 	job, err := NewJob(config)
 	if err != nil {
 		return nil, err
 	}
-	m.jobs.Store(job.ID(), job)
+	m.addJob(job)
 	return job, nil
 }
 
@@ -41,7 +249,7 @@ func (m *JobManager) CreateJobFromFile(file string) (*Job, error) {
 	if err != nil {
 		return nil, err
 	}
-	m.jobs.Store(job.ID(), job)
+	m.addJob(job)
 	return job, nil
 }
 
@@ -64,12 +272,17 @@ func (m *JobManager) GetJob(id string) (*Job, error) {
 }
 
 func (m *JobManager) DeleteJob(id string) error {
-	_, ok := m.jobs.Load(id)
+	job, ok := m.jobs.Load(id)
 	if !ok {
 		return fmt.Errorf("%s job does not exist", id)
 	}
+	if scheduler := job.(*Job).takeScheduler(); scheduler != nil {
+		scheduler.stop()
+	}
+	// release the connections of the job, a running job is drained first
+	job.(*Job).Stop()
 	m.jobs.Delete(id)
-	return nil
+	return m.store.Delete(id)
 }
 
 func (m *JobManager) StartJob(id string) error {
@@ -79,23 +292,187 @@ func (m *JobManager) StartJob(id string) error {
 	}
 
 	jobObject := job.(*Job)
-	if jobObject.Status == STATUS_RUNNING {
+	switch jobObject.status() {
+	case STATUS_RUNNING:
 		return fmt.Errorf("%s job is running", id)
+	case STATUS_SCHEDULED:
+		return fmt.Errorf("%s job is scheduled", id)
+	case STATUS_STOPPED:
+		return fmt.Errorf("%s job is stopped, restart it to rerun", id)
+	case STATUS_FAILED:
+		return fmt.Errorf("%s job is failed, restart it to rerun", id)
 	}
-	if jobObject.Status == STATUS_STOPPED {
-		return fmt.Errorf("%s job is stopped, cannot rerun", id)
+
+	if jobObject.configuration().Schedule != nil {
+		return m.startSchedule(jobObject)
+	}
+
+	// jobs restored from the job store create their source and sinks on start
+	if err := jobObject.prepare(); err != nil {
+		return err
 	}
 
 	go jobObject.Start()
 	return nil
 }
 
+// RestartJob starts a new run of a job which is not running, with source, sinks and observers
+// created again from its configuration
+func (m *JobManager) RestartJob(id string) error {
+	job, ok := m.jobs.Load(id)
+	if !ok {
+		return fmt.Errorf("%s job does not exist", id)
+	}
+
+	jobObject := job.(*Job)
+	if status := jobObject.status(); status == STATUS_RUNNING || status == STATUS_SCHEDULED {
+		return fmt.Errorf("%s job is %s, stop it before restart", id, status)
+	}
+
+	if jobObject.configuration().Schedule != nil {
+		return m.startSchedule(jobObject)
+	}
+
+	if err := jobObject.reset(); err != nil {
+		return err
+	}
+
+	go jobObject.Start()
+	return nil
+}
+
+// CloneJob creates a new job from the configuration of an existing job, the overrides are merged
+// into the configuration, nested objects are merged and other values replaced
+func (m *JobManager) CloneJob(id string, overrides map[string]interface{}) (*Job, error) {
+	job, ok := m.jobs.Load(id)
+	if !ok {
+		return nil, fmt.Errorf("%s job does not exist", id)
+	}
+
+	config, err := mergeConfiguration(job.(*Job).configuration(), overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.CreateJob(config)
+}
+
+func mergeConfiguration(config JobConfiguration, overrides map[string]interface{}) (JobConfiguration, error) {
+	if len(overrides) == 0 {
+		return config, nil
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return config, err
+	}
+
+	base := make(map[string]interface{})
+	if err := json.Unmarshal(data, &base); err != nil {
+		return config, err
+	}
+
+	if data, err = json.Marshal(mergeMap(base, overrides)); err != nil {
+		return config, err
+	}
+
+	result := JobConfiguration{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return config, fmt.Errorf("invalid overrides : %w", err)
+	}
+	// the interpolated values kept from the config are still stored as their references
+	result.references = config.references
+	return result, nil
+}
+
+func mergeMap(base map[string]interface{}, overrides map[string]interface{}) map[string]interface{} {
+	for key, value := range overrides {
+		baseValue, baseIsMap := base[key].(map[string]interface{})
+		overrideValue, overrideIsMap := value.(map[string]interface{})
+		if baseIsMap && overrideIsMap {
+			base[key] = mergeMap(baseValue, overrideValue)
+		} else {
+			base[key] = value
+		}
+	}
+	return base
+}
+
 func (m *JobManager) StopJob(id string) error {
 	job, ok := m.jobs.Load(id)
 	if !ok {
 		return fmt.Errorf("%s job does not exist", id)
 	}
-	job.(*Job).Stop()
+
+	jobObject := job.(*Job)
+	if scheduler := jobObject.takeScheduler(); scheduler != nil {
+		// stop the schedule and the runs in progress
+		scheduler.stop()
+	}
+
+	jobObject.Stop()
+	return nil
+}
+
+// PatchJob changes a running job, see JobPatch
+func (m *JobManager) PatchJob(id string, patch JobPatch) (*Annotation, error) {
+	job, ok := m.jobs.Load(id)
+	if !ok {
+		return nil, fmt.Errorf("%s job does not exist", id)
+	}
+	return job.(*Job).Patch(patch)
+}
+
+// ScheduleJob sets the schedule of a job which is not running and activates it
+func (m *JobManager) ScheduleJob(id string, schedule Schedule) error {
+	job, ok := m.jobs.Load(id)
+	if !ok {
+		return fmt.Errorf("%s job does not exist", id)
+	}
+
+	if _, err := schedule.parse(); err != nil {
+		return fmt.Errorf("invalid schedule : %w", err)
+	}
+
+	jobObject := job.(*Job)
+	jobObject.lock.Lock()
+	if status := jobObject.Status; status == STATUS_RUNNING || status == STATUS_SCHEDULED {
+		jobObject.lock.Unlock()
+		return fmt.Errorf("%s job is %s, stop it before changing the schedule", id, status)
+	}
+	if jobObject.Config.Distribution != nil {
+		jobObject.lock.Unlock()
+		return fmt.Errorf("%s job is distributed and cannot be scheduled", id)
+	}
+	jobObject.Config.Schedule = &schedule
+	// the runs create their own components, the ones of the job are not used anymore
+	unused := jobObject.stopped == nil && jobObject.source != nil
+	sinks, observers := jobObject.sinks, jobObject.observers
+	jobObject.source, jobObject.sinks, jobObject.observers = nil, nil, nil
+	jobObject.lock.Unlock()
+
+	if unused {
+		go jobObject.release(sinks, observers, true)
+	}
+	return m.startSchedule(jobObject)
+}
+
+func (m *JobManager) startSchedule(job *Job) error {
+	job.lock.Lock()
+	if job.scheduler != nil {
+		job.lock.Unlock()
+		return fmt.Errorf("%s job is already scheduled", job.Id)
+	}
+	scheduler, err := newScheduler(job)
+	if err != nil {
+		job.lock.Unlock()
+		return err
+	}
+	job.scheduler = scheduler
+	job.lock.Unlock()
+
+	job.setStatus(STATUS_SCHEDULED)
+	scheduler.start()
 	return nil
 }
 
@@ -0,0 +1,193 @@
+package job
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/observer"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+)
+
+// JobPatch changes the source settings of a running job, and enables or disables its sinks and observers
+type JobPatch struct {
+	Source    *source.Settings `json:"source,omitempty"`
+	Sinks     []ComponentPatch `json:"sinks,omitempty"`
+	Observers []ComponentPatch `json:"observers,omitempty"`
+}
+
+// ComponentPatch enables or disables the sink or observer with the given index in the job configuration
+type ComponentPatch struct {
+	Index   int  `json:"index"`
+	Enabled bool `json:"enabled"`
+}
+
+// Annotation records a change of a running job, so the metrics of the job can be related to it
+type Annotation struct {
+	Time    time.Time `json:"time"`
+	RunId   string    `json:"run_id"`
+	Message string    `json:"message"`
+	Patch   JobPatch  `json:"patch"`
+}
+
+func (p JobPatch) validate(config JobConfiguration) error {
+	if p.Source == nil && len(p.Sinks) == 0 && len(p.Observers) == 0 {
+		return fmt.Errorf("patch is empty")
+	}
+	if p.Source != nil {
+		if err := p.Source.Validate(); err != nil {
+			return fmt.Errorf("invalid source settings : %w", err)
+		}
+	}
+	for _, component := range p.Sinks {
+		if component.Index < 0 || component.Index >= len(config.Sinks) {
+			return fmt.Errorf("sink %d does not exist", component.Index)
+		}
+	}
+	patched := make(map[int]bool)
+	for _, component := range p.Observers {
+		if component.Index < 0 || component.Index >= len(config.Observers) {
+			return fmt.Errorf("observer %d does not exist", component.Index)
+		}
+		if patched[component.Index] {
+			return fmt.Errorf("observer %d is patched more than once", component.Index)
+		}
+		patched[component.Index] = true
+	}
+	return nil
+}
+
+// Patch applies the changes to the running job and to its configuration, the change is recorded as an
+// annotation of the job
+func (j *Job) Patch(patch JobPatch) (*Annotation, error) {
+	j.patchLock.Lock()
+	defer j.patchLock.Unlock()
+
+	j.lock.Lock()
+	status, config, runId, src, observers, ctx := j.Status, j.Config, j.RunId, j.source, j.observers, j.ctx
+	j.lock.Unlock()
+
+	if config.Distribution != nil {
+		return nil, fmt.Errorf("distributed job cannot be patched")
+	}
+	if status != STATUS_RUNNING {
+		return nil, fmt.Errorf("%s job is %s, only a running job can be patched", j.Id, status)
+	}
+	if err := patch.validate(config); err != nil {
+		return nil, err
+	}
+
+	// a stopped observer cannot observe again, the enabled observers are created again from their
+	// configuration before anything is changed, so a failure leaves the job as it is
+	created := make(map[int]observer.Observer)
+	for _, component := range patch.Observers {
+		obConfig := config.Observers[component.Index]
+		if !component.Enabled || !obConfig.Disabled {
+			continue
+		}
+		ob, err := observer.CreateObserver(obConfig)
+		if err != nil {
+			closeComponents(nil, observerValues(created))
+			return nil, fmt.Errorf("failed to create observer %d : %w", component.Index, err)
+		}
+		created[component.Index] = ob
+	}
+
+	changes := make([]string, 0)
+	if settings := patch.Source; settings != nil {
+		if err := src.Update(*settings); err != nil {
+			closeComponents(nil, observerValues(created))
+			return nil, fmt.Errorf("failed to update source : %w", err)
+		}
+		changes = append(changes, applySettings(&config.Source, *settings)...)
+	}
+
+	sinks := append([]sink.Configuration{}, config.Sinks...)
+	for _, component := range patch.Sinks {
+		sinkConfig := &sinks[component.Index]
+		if sinkConfig.Disabled != component.Enabled {
+			continue
+		}
+		sinkConfig.Disabled = !component.Enabled
+		changes = append(changes, fmt.Sprintf("sink %d (%s) %s", component.Index, sinkConfig.Type, enabledText(component.Enabled)))
+	}
+	config.Sinks = sinks
+
+	obConfigs := append([]observer.Configuration{}, config.Observers...)
+	observers = append([]observer.Observer{}, observers...)
+	replaced := make([]observer.Observer, 0, len(created))
+	for _, component := range patch.Observers {
+		obConfig := &obConfigs[component.Index]
+		if obConfig.Disabled != component.Enabled {
+			continue
+		}
+		obConfig.Disabled = !component.Enabled
+
+		if component.Enabled {
+			replaced = append(replaced, observers[component.Index])
+			observers[component.Index] = created[component.Index]
+			if err := observers[component.Index].Observe(ctx); err != nil {
+				log.Logger().WithError(err).Warnf("failed to start observer %d of job %s", component.Index, j.Id)
+			}
+		} else {
+			observers[component.Index].Stop()
+		}
+		changes = append(changes, fmt.Sprintf("observer %d (%s) %s", component.Index, obConfig.Type, enabledText(component.Enabled)))
+	}
+	config.Observers = obConfigs
+
+	annotation := &Annotation{
+		Time:    time.Now().UTC(),
+		RunId:   runId,
+		Message: strings.Join(changes, ", "),
+		Patch:   patch,
+	}
+	if annotation.Message == "" {
+		annotation.Message = "no change"
+	}
+
+	j.lock.Lock()
+	j.Config = config
+	j.observers = observers
+	j.Annotations = append(j.Annotations, annotation)
+	j.lock.Unlock()
+	// the replaced observers are stopped or never started
+	closeComponents(nil, replaced)
+
+	log.Logger().Infof("job %s is patched : %s", j.Id, annotation.Message)
+	j.persist()
+	return annotation, nil
+}
+
+// applySettings sets the settings on the source configuration, it returns the description of each change
+func applySettings(c *source.Configuration, settings source.Settings) []string {
+	changes := make([]string, 0)
+	change := func(name string, value *int, current *int) {
+		if value != nil && *value != *current {
+			changes = append(changes, fmt.Sprintf("%s %d -> %d", name, *current, *value))
+			*current = *value
+		}
+	}
+	change("batch_size", settings.BatchSize, &c.BatchSize)
+	change("interval", settings.Interval, &c.Interval)
+	change("rate", settings.Rate, &c.Rate)
+	change("concurency", settings.Concurrency, &c.Concurrency)
+	return changes
+}
+
+func observerValues(observers map[int]observer.Observer) []observer.Observer {
+	values := make([]observer.Observer, 0, len(observers))
+	for _, ob := range observers {
+		values = append(values, ob)
+	}
+	return values
+}
+
+func enabledText(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
@@ -0,0 +1,130 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+const (
+	DEFAULT_READINESS_TIMEOUT         = 60
+	DEFAULT_READINESS_INITIAL_BACKOFF = 500
+	DEFAULT_READINESS_MAX_BACKOFF     = 5000
+)
+
+// ReadinessConfiguration makes a job wait for the services of its sinks and observers before the sinks are
+// initialized, the sinks and observers without readiness probe are ready
+type ReadinessConfiguration struct {
+	// how long the services are waited for in seconds
+	Timeout int `json:"timeout,omitempty"`
+	// backoff before the second probe in ms, it doubles after each failed probe up to max_backoff
+	InitialBackoff int `json:"initial_backoff,omitempty"`
+	MaxBackoff     int `json:"max_backoff,omitempty"`
+}
+
+func (c *ReadinessConfiguration) Validate() error {
+	if c.Timeout < 0 || c.InitialBackoff < 0 || c.MaxBackoff < 0 {
+		return fmt.Errorf("timeout, initial_backoff and max_backoff cannot be negative")
+	}
+	return nil
+}
+
+func (c ReadinessConfiguration) withDefaults() ReadinessConfiguration {
+	if c.Timeout == 0 {
+		c.Timeout = DEFAULT_READINESS_TIMEOUT
+	}
+	if c.InitialBackoff == 0 {
+		c.InitialBackoff = DEFAULT_READINESS_INITIAL_BACKOFF
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = DEFAULT_READINESS_MAX_BACKOFF
+	}
+	return c
+}
+
+// readinessTarget is a sink or an observer of the job with a readiness probe
+type readinessTarget struct {
+	name  string
+	probe common.ReadinessProbe
+}
+
+// readinessTargets returns the sinks and observers of the current run which have a readiness probe
+func (j *Job) readinessTargets() []readinessTarget {
+	targets := make([]readinessTarget, 0)
+	for index, s := range j.sinks {
+		if probe, ok := s.(common.ReadinessProbe); ok {
+			name := fmt.Sprintf("sink %d (%s)", index, j.Config.Sinks[index].Type)
+			targets = append(targets, readinessTarget{name: name, probe: probe})
+		}
+	}
+	for index, ob := range j.observers {
+		if probe, ok := ob.(common.ReadinessProbe); ok {
+			name := fmt.Sprintf("observer %d (%s)", index, j.Config.Observers[index].Type)
+			targets = append(targets, readinessTarget{name: name, probe: probe})
+		}
+	}
+	return targets
+}
+
+// waitReady probes the targets until they are all ready, the error names each target still not ready when the
+// timeout expires with the error of its last probe
+func waitReady(ctx context.Context, config ReadinessConfiguration, targets []readinessTarget) error {
+	config = config.withDefaults()
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.Timeout)*time.Second)
+	defer cancel()
+
+	errs := make([]error, len(targets))
+	waiter := sync.WaitGroup{}
+	for index, target := range targets {
+		waiter.Add(1)
+		go func(index int, target readinessTarget) {
+			defer waiter.Done()
+			errs[index] = probeUntilReady(ctx, config, target)
+		}(index, target)
+	}
+	waiter.Wait()
+
+	failures := make([]string, 0)
+	for index, err := range errs {
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s : %s", targets[index].name, err))
+		}
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("not ready after %ds, %s", config.Timeout, strings.Join(failures, "; "))
+	}
+	return nil
+}
+
+func probeUntilReady(ctx context.Context, config ReadinessConfiguration, target readinessTarget) error {
+	backoff := time.Duration(config.InitialBackoff) * time.Millisecond
+	maxBackoff := time.Duration(config.MaxBackoff) * time.Millisecond
+
+	var lastErr error
+	for probes := 1; ; probes++ {
+		err := target.probe.Ready(ctx)
+		if err == nil {
+			log.Logger().Infof("%s is ready after %d probes", target.name, probes)
+			return nil
+		}
+		// a probe cut by the timeout says less than the probe before it
+		if lastErr == nil || ctx.Err() == nil {
+			lastErr = err
+		}
+		log.Logger().WithError(err).Debugf("%s is not ready, probe again in %s", target.name, backoff)
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d probes failed, the last one with %w", probes, lastErr)
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
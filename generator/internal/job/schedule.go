@@ -0,0 +1,282 @@
+package job
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/robfig/cron/v3"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+// OverlapPolicy decides what happens when a scheduled run is due while another run is in progress
+type OverlapPolicy string
+
+const (
+	OVERLAP_SKIP     OverlapPolicy = "skip"
+	OVERLAP_QUEUE    OverlapPolicy = "queue"
+	OVERLAP_PARALLEL OverlapPolicy = "parallel"
+)
+
+type Schedule struct {
+	// a standard 5 fields cron expression or a descriptor like @daily, exclusive with interval
+	Cron string `json:"cron,omitempty"`
+	// fixed interval between two runs in seconds
+	Interval int `json:"interval,omitempty"`
+	// a random delay up to jitter seconds added to each run
+	Jitter int `json:"jitter,omitempty"`
+	// how long a run lasts in seconds, default to the timeout of the job, or until the source completes
+	Duration          int           `json:"duration,omitempty"`
+	Overlap           OverlapPolicy `json:"overlap,omitempty"`
+	MaxConcurrentRuns int           `json:"max_concurrent_runs,omitempty"`
+}
+
+// ScheduleState is the scheduling information of a scheduled job
+type ScheduleState struct {
+	NextRun       *time.Time `json:"next_run,omitempty"`
+	LastRun       *time.Time `json:"last_run,omitempty"`
+	LastRunId     string     `json:"last_run_id,omitempty"`
+	LastRunStatus JobStatus  `json:"last_run_status,omitempty"`
+	ActiveRuns    int        `json:"active_runs"`
+	QueuedRuns    int        `json:"queued_runs"`
+	SkippedRuns   int        `json:"skipped_runs"`
+}
+
+func (s *Schedule) parse() (cron.Schedule, error) {
+	if s.Cron != "" && s.Interval != 0 {
+		return nil, fmt.Errorf("cron and interval cannot be both set")
+	}
+	if s.Jitter < 0 || s.Duration < 0 || s.MaxConcurrentRuns < 0 {
+		return nil, fmt.Errorf("jitter, duration and max_concurrent_runs cannot be negative")
+	}
+
+	switch s.Overlap {
+	case "", OVERLAP_SKIP, OVERLAP_QUEUE, OVERLAP_PARALLEL:
+	default:
+		return nil, fmt.Errorf("invalid overlap policy %s", s.Overlap)
+	}
+
+	if s.Cron != "" {
+		schedule, err := cron.ParseStandard(s.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression %s : %w", s.Cron, err)
+		}
+		return schedule, nil
+	}
+
+	if s.Interval <= 0 {
+		return nil, fmt.Errorf("either cron or a positive interval is required")
+	}
+	return cron.Every(time.Duration(s.Interval) * time.Second), nil
+}
+
+func (s *Schedule) overlap() OverlapPolicy {
+	if s.Overlap == "" {
+		return OVERLAP_SKIP
+	}
+	return s.Overlap
+}
+
+func (s *Schedule) maxConcurrentRuns() int {
+	if s.MaxConcurrentRuns == 0 {
+		return 1
+	}
+	return s.MaxConcurrentRuns
+}
+
+// scheduler starts the runs of a scheduled job, each run is a separated job instance created
+// from the job configuration, its result is recorded in the run history of the scheduled job
+type scheduler struct {
+	job      *Job
+	config   Schedule
+	schedule cron.Schedule
+	runs     map[string]*Job
+	// the runs being created, they count as active runs
+	pending  int
+	stopCh   chan struct{}
+	lock     sync.Mutex
+	stopped  bool
+	runWaits sync.WaitGroup
+}
+
+func newScheduler(job *Job) (*scheduler, error) {
+	schedule, err := job.Config.Schedule.parse()
+	if err != nil {
+		return nil, err
+	}
+
+	return &scheduler{
+		job:      job,
+		config:   *job.Config.Schedule,
+		schedule: schedule,
+		runs:     make(map[string]*Job),
+		stopCh:   make(chan struct{}),
+	}, nil
+}
+
+func (s *scheduler) start() {
+	go s.loop()
+}
+
+func (s *scheduler) loop() {
+	for {
+		next := s.schedule.Next(time.Now())
+		if s.config.Jitter > 0 {
+			next = next.Add(time.Duration(rand.Int63n(int64(s.config.Jitter) * int64(time.Second))))
+		}
+		s.job.updateScheduleState(func(state *ScheduleState) {
+			state.NextRun = &next
+		})
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-timer.C:
+			s.trigger()
+		case <-s.stopCh:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+func (s *scheduler) trigger() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.stopped {
+		return
+	}
+
+	active := len(s.runs) + s.pending
+	if active > 0 {
+		switch s.config.overlap() {
+		case OVERLAP_SKIP:
+			s.skip("a run is in progress")
+			return
+		case OVERLAP_QUEUE:
+			// at most one run waits for the run in progress
+			s.job.updateScheduleState(func(state *ScheduleState) {
+				if state.QueuedRuns == 0 {
+					state.QueuedRuns = 1
+				} else {
+					state.SkippedRuns++
+				}
+			})
+			return
+		case OVERLAP_PARALLEL:
+			if active >= s.config.maxConcurrentRuns() {
+				s.skip("max concurrent runs reached")
+				return
+			}
+		}
+	}
+
+	s.launch()
+}
+
+func (s *scheduler) skip(reason string) {
+	log.Logger().Infof("skip scheduled run of job %s, %s", s.job.Id, reason)
+	s.job.updateScheduleState(func(state *ScheduleState) {
+		state.SkippedRuns++
+	})
+}
+
+// launch starts a new run, it must be called with the lock held. The run is created in its own
+// goroutine, as creating it probes and initializes the sinks
+func (s *scheduler) launch() {
+	s.pending++
+	s.runWaits.Add(1)
+	go s.create()
+}
+
+func (s *scheduler) create() {
+	run, err := NewRunJob(s.job.configuration())
+
+	s.lock.Lock()
+	s.pending--
+	if err != nil {
+		s.lock.Unlock()
+		defer s.runWaits.Done()
+		log.Logger().WithError(err).Errorf("failed to create scheduled run of job %s", s.job.Id)
+		now := time.Now().UTC()
+		s.job.finishRun(&Run{Id: uuid.New().String(), Status: STATUS_FAILED, StartedAt: &now, StoppedAt: &now})
+		return
+	}
+	if s.stopped {
+		s.lock.Unlock()
+		defer s.runWaits.Done()
+		run.Stop()
+		return
+	}
+
+	if s.config.Duration > 0 {
+		run.timeout = s.config.Duration
+	}
+
+	s.runs[run.RunId] = run
+	now := time.Now().UTC()
+	s.job.updateScheduleState(func(state *ScheduleState) {
+		state.LastRun = &now
+		state.LastRunId = run.RunId
+		state.ActiveRuns = len(s.runs)
+	})
+	s.lock.Unlock()
+
+	log.Logger().Infof("start scheduled run %s of job %s", run.RunId, s.job.Id)
+	s.execute(run)
+}
+
+func (s *scheduler) execute(run *Job) {
+	defer s.runWaits.Done()
+
+	run.RunToEnd()
+	// recording the run writes to the store, it is done without the lock so the other runs can start or stop
+	s.job.finishRun(run.CurrentRun())
+	s.job.archive(run)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	delete(s.runs, run.RunId)
+
+	queued := false
+	s.job.updateScheduleState(func(state *ScheduleState) {
+		state.ActiveRuns = len(s.runs)
+		if state.QueuedRuns > 0 && !s.stopped {
+			state.QueuedRuns--
+			queued = true
+		}
+	})
+
+	if queued {
+		s.launch()
+	}
+}
+
+// stop stops the schedule and all runs in progress
+func (s *scheduler) stop() {
+	s.lock.Lock()
+	if s.stopped {
+		s.lock.Unlock()
+		return
+	}
+	s.stopped = true
+	close(s.stopCh)
+	runs := make([]*Job, 0, len(s.runs))
+	for _, run := range s.runs {
+		runs = append(runs, run)
+	}
+	s.lock.Unlock()
+
+	// stopping a run waits for its sinks to drain, the runs are stopped without the lock
+	for _, run := range runs {
+		run.Stop()
+	}
+	s.runWaits.Wait()
+	s.job.updateScheduleState(func(state *ScheduleState) {
+		state.NextRun = nil
+		state.QueuedRuns = 0
+	})
+}
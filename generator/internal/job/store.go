@@ -0,0 +1,231 @@
+package job
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	JOB_STORE_MEMORY = "memory"
+	JOB_STORE_FILE   = "file"
+)
+
+var (
+	jobBucket = []byte("jobs")
+	runBucket = []byte("runs")
+)
+
+type StatusTransition struct {
+	Status JobStatus `json:"status"`
+	Time   time.Time `json:"time"`
+}
+
+// JobRecord is what the job store keeps for a job, enough to restore it after a restart
+type JobRecord struct {
+	Id     string           `json:"id"`
+	Name   string           `json:"name"`
+	Status JobStatus        `json:"status"`
+	Config JobConfiguration `json:"config"`
+	// the config holds references to environment variables and files, resolved again when the job is restored
+	Interpolated bool               `json:"interpolated,omitempty"`
+	RunId        string             `json:"run_id"`
+	Stats        *Stats             `json:"stats,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+	StartedAt    *time.Time         `json:"started_at,omitempty"`
+	StoppedAt    *time.Time         `json:"stopped_at,omitempty"`
+	History      []StatusTransition `json:"history,omitempty"`
+	Runs         []*Run             `json:"runs,omitempty"`
+
+	Annotations []*Annotation `json:"annotations,omitempty"`
+
+	ScheduleState *ScheduleState `json:"schedule_state,omitempty"`
+}
+
+type JobStore interface {
+	Save(record *JobRecord) error
+	Delete(id string) error
+	List() ([]*JobRecord, error)
+	// SaveRun archives a finished run, the archived runs are kept when their job is deleted
+	SaveRun(record *RunRecord) error
+	ListRuns() ([]*RunRecord, error)
+	// GetRun returns nil when the run is not archived
+	GetRun(id string) (*RunRecord, error)
+	Close() error
+}
+
+func NewJobStore(storeType string, path string) (JobStore, error) {
+	switch storeType {
+	case "", JOB_STORE_MEMORY:
+		return NewMemoryJobStore(), nil
+	case JOB_STORE_FILE:
+		return NewFileJobStore(path)
+	}
+	return nil, fmt.Errorf("the job store %s doesnot exist", storeType)
+}
+
+// MemoryJobStore keeps nothing across restarts, it is used when persistence is not required
+type MemoryJobStore struct {
+	records sync.Map
+	runs    sync.Map
+}
+
+func NewMemoryJobStore() *MemoryJobStore {
+	return &MemoryJobStore{
+		records: sync.Map{},
+		runs:    sync.Map{},
+	}
+}
+
+func (s *MemoryJobStore) Save(record *JobRecord) error {
+	s.records.Store(record.Id, record)
+	return nil
+}
+
+func (s *MemoryJobStore) Delete(id string) error {
+	s.records.Delete(id)
+	return nil
+}
+
+func (s *MemoryJobStore) List() ([]*JobRecord, error) {
+	result := make([]*JobRecord, 0)
+	s.records.Range(func(key, value interface{}) bool {
+		result = append(result, value.(*JobRecord))
+		return true
+	})
+	return result, nil
+}
+
+func (s *MemoryJobStore) SaveRun(record *RunRecord) error {
+	s.runs.Store(record.Id, record)
+	return nil
+}
+
+func (s *MemoryJobStore) ListRuns() ([]*RunRecord, error) {
+	result := make([]*RunRecord, 0)
+	s.runs.Range(func(key, value interface{}) bool {
+		result = append(result, value.(*RunRecord))
+		return true
+	})
+	return result, nil
+}
+
+func (s *MemoryJobStore) GetRun(id string) (*RunRecord, error) {
+	if record, ok := s.runs.Load(id); ok {
+		return record.(*RunRecord), nil
+	}
+	return nil, nil
+}
+
+func (s *MemoryJobStore) Close() error {
+	return nil
+}
+
+// FileJobStore persists job records as json in an embedded bbolt database file
+type FileJobStore struct {
+	db *bolt.DB
+}
+
+func NewFileJobStore(path string) (*FileJobStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open job store %s : %w", path, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{jobBucket, runBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &FileJobStore{
+		db: db,
+	}, nil
+}
+
+func (s *FileJobStore) Save(record *JobRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobBucket).Put([]byte(record.Id), value)
+	})
+}
+
+func (s *FileJobStore) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobBucket).Delete([]byte(id))
+	})
+}
+
+func (s *FileJobStore) List() ([]*JobRecord, error) {
+	result := make([]*JobRecord, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobBucket).ForEach(func(k, v []byte) error {
+			var record JobRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to decode job %s : %w", string(k), err)
+			}
+			result = append(result, &record)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (s *FileJobStore) SaveRun(record *RunRecord) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(runBucket).Put([]byte(record.Id), value)
+	})
+}
+
+func (s *FileJobStore) ListRuns() ([]*RunRecord, error) {
+	result := make([]*RunRecord, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(runBucket).ForEach(func(k, v []byte) error {
+			var record RunRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to decode run %s : %w", string(k), err)
+			}
+			result = append(result, &record)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (s *FileJobStore) GetRun(id string) (*RunRecord, error) {
+	var record *RunRecord
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(runBucket).Get([]byte(id))
+		if value == nil {
+			return nil
+		}
+		record = &RunRecord{}
+		if err := json.Unmarshal(value, record); err != nil {
+			return fmt.Errorf("failed to decode run %s : %w", id, err)
+		}
+		return nil
+	})
+	return record, err
+}
+
+func (s *FileJobStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,127 @@
+package job
+
+import (
+	"time"
+)
+
+// SUBSCRIBER_BUFFER is the number of status transitions kept for a subscriber which does not read them yet
+const SUBSCRIBER_BUFFER = 16
+
+// Snapshot is the state of a running job at a point in time, rates are computed since the previous snapshot
+type Snapshot struct {
+	Time   time.Time `json:"time"`
+	RunId  string    `json:"run_id"`
+	Status JobStatus `json:"status"`
+	// rows generated by the source and generated rows per second
+	Generated    int             `json:"generated"`
+	GeneratedEps float64         `json:"generated_eps"`
+	Sinks        []*SinkSnapshot `json:"sinks"`
+	// latest measurements of each observer, in the order of the job configuration
+	Observers []map[string]float64 `json:"observers"`
+	// the changes of the run since the previous snapshot
+	Annotations []*Annotation `json:"annotations,omitempty"`
+}
+
+type SinkSnapshot struct {
+	SinkStats
+	// rows written per second
+	WriteRate float64 `json:"write_rate"`
+}
+
+// Subscribe returns a channel receiving the status transitions of the job, the returned function
+// stops the subscription
+func (j *Job) Subscribe() (<-chan StatusTransition, func()) {
+	transitions := make(chan StatusTransition, SUBSCRIBER_BUFFER)
+
+	j.lock.Lock()
+	if j.subscribers == nil {
+		j.subscribers = make(map[chan StatusTransition]struct{})
+	}
+	j.subscribers[transitions] = struct{}{}
+	j.lock.Unlock()
+
+	return transitions, func() {
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		delete(j.subscribers, transitions)
+	}
+}
+
+// Telemetry takes the snapshots of a job for one telemetry stream
+type Telemetry struct {
+	job      *Job
+	previous *Snapshot
+	// number of annotations of the job already in a snapshot
+	annotations int
+}
+
+func NewTelemetry(job *Job) *Telemetry {
+	return &Telemetry{job: job}
+}
+
+// Next takes a snapshot of the job, the first snapshot of a run has the rates since the run started
+func (t *Telemetry) Next() *Snapshot {
+	j := t.job
+	now := time.Now().UTC()
+
+	j.lock.Lock()
+	stats := copyStats(j.Stats)
+	snapshot := &Snapshot{
+		Time:      now,
+		RunId:     j.RunId,
+		Status:    j.Status,
+		Generated: stats.Generated,
+		Sinks:     make([]*SinkSnapshot, len(stats.Sinks)),
+	}
+	startedAt := j.StartedAt
+	observers := j.observers
+	for _, annotation := range j.Annotations[t.annotations:] {
+		if annotation.RunId == j.RunId {
+			snapshot.Annotations = append(snapshot.Annotations, annotation)
+		}
+	}
+	t.annotations = len(j.Annotations)
+	j.lock.Unlock()
+
+	// rates are based on the previous snapshot of the same run
+	previous := t.previous
+	if previous != nil && previous.RunId != snapshot.RunId {
+		previous = nil
+	}
+
+	var elapsed float64
+	if previous != nil {
+		elapsed = now.Sub(previous.Time).Seconds()
+	} else if startedAt != nil {
+		elapsed = now.Sub(*startedAt).Seconds()
+	}
+
+	for index, sinkStats := range stats.Sinks {
+		written := sinkStats.SuccessWrite
+		if previous != nil && index < len(previous.Sinks) {
+			written -= previous.Sinks[index].SuccessWrite
+		}
+		snapshot.Sinks[index] = &SinkSnapshot{SinkStats: *sinkStats, WriteRate: rate(written, elapsed)}
+	}
+
+	generated := snapshot.Generated
+	if previous != nil {
+		generated -= previous.Generated
+	}
+	snapshot.GeneratedEps = rate(generated, elapsed)
+
+	snapshot.Observers = make([]map[string]float64, len(observers))
+	for index, ob := range observers {
+		snapshot.Observers[index] = ob.Measurements()
+	}
+
+	t.previous = snapshot
+	return snapshot
+}
+
+func rate(count int, seconds float64) float64 {
+	if seconds <= 0 {
+		return 0
+	}
+	return float64(count) / seconds
+}
@@ -19,6 +19,8 @@ var timeFormat = "2006-01-02 15:04:05.000 -0700"
 var (
 	logger       *logrus.Logger
 	accessLogger *logrus.Logger
+	// the log file written along the console, nil without log file
+	logFile io.Writer
 )
 
 func init() {
@@ -66,26 +68,27 @@ func initProduction() {
 
 	var level logrus.Level
 	if l, err := logrus.ParseLevel(viper.GetString("log-level")); err != nil {
-		fmt.Printf("failed to parse log level: %s\n", err)
+		fmt.Fprintf(os.Stderr, "failed to parse log level: %s\n", err)
 		level = logrus.InfoLevel
 	} else {
 		level = l
 	}
-	fmt.Printf("logger initialization with level %d\n", level)
+	fmt.Fprintf(os.Stderr, "logger initialization with level %d\n", level)
 	logger.SetLevel(level)
 	accessLogger.SetLevel(level)
 
 	logPath := viper.GetString("log-file-path")
 	var writer io.Writer = os.Stdout
 	if len(logPath) > 0 {
-		fmt.Printf("logger initialization with file %s\n", logPath)
-		writer = io.MultiWriter(os.Stdout, &lumberjack.Logger{
+		fmt.Fprintf(os.Stderr, "logger initialization with file %s\n", logPath)
+		logFile = &lumberjack.Logger{
 			Filename:   logPath,
 			MaxSize:    100, // megabytes
 			MaxBackups: 5,
 			MaxAge:     10,   //days
 			Compress:   true, // disabled by default
-		})
+		}
+		writer = io.MultiWriter(os.Stdout, logFile)
 
 		if file, err := os.OpenFile(logPath+".panic", os.O_CREATE|os.O_WRONLY, 0666); err != nil {
 			fmt.Println("failed to log panic into file")
@@ -98,7 +101,7 @@ func initProduction() {
 	accessLogPath := viper.GetString("access-log-file-path")
 	writer = os.Stdout
 	if len(accessLogPath) > 0 {
-		fmt.Printf("access log logger initialization with file %s\n", accessLogPath)
+		fmt.Fprintf(os.Stderr, "access log logger initialization with file %s\n", accessLogPath)
 		writer = &lumberjack.Logger{
 			Filename:   accessLogPath,
 			MaxSize:    100, // megabytes
@@ -110,6 +113,15 @@ func initProduction() {
 	accessLogger.SetOutput(writer)
 }
 
+// ToStderr writes the logs to stderr instead of stdout, so the output of a command can be piped
+func ToStderr() {
+	var writer io.Writer = os.Stderr
+	if logFile != nil {
+		writer = io.MultiWriter(os.Stderr, logFile)
+	}
+	Logger().SetOutput(writer)
+}
+
 func Logger() *logrus.Logger {
 	if logger == nil {
 		Init(false)
@@ -1,10 +1,133 @@
 package metrics
 
-type MetricRecord struct {
-	value float64
-	time  int64
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+// Point is a value observed of a metric, time is in ms
+type Point struct {
+	Time  int64   `json:"time"`
+	Value float64 `json:"value"`
+}
+
+// Summary sums up the values observed of a metric during a run
+type Summary struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+// Summarize returns the summary of the points, the percentiles are the nearest ranks
+func Summarize(points []Point) Summary {
+	if len(points) == 0 {
+		return Summary{}
+	}
+
+	values := make([]float64, len(points))
+	sum := 0.0
+	for index, point := range points {
+		values[index] = point.Value
+		sum += point.Value
+	}
+	sort.Float64s(values)
+
+	percentile := func(p float64) float64 {
+		rank := int(math.Ceil(p / 100 * float64(len(values))))
+		if rank < 1 {
+			rank = 1
+		}
+		return values[rank-1]
+	}
+	return Summary{
+		Count: len(values),
+		Min:   values[0],
+		Max:   values[len(values)-1],
+		Mean:  sum / float64(len(values)),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+	}
+}
+
+const DEFAULT_MAX_POINTS = 100000
+
+// recorder keeps the values observed of each metric in memory, it is embedded by the managers.
+// a series keeps its last metrics-max-points values only, so a long run does not exhaust the memory
+type recorder struct {
+	series    map[string][]Point
+	maxPoints int
+	capped    map[string]bool
+	lock      sync.Mutex
+}
+
+func (r *recorder) init() {
+	if r.series != nil {
+		return
+	}
+	r.series = make(map[string][]Point)
+	r.capped = make(map[string]bool)
+	r.maxPoints = viper.GetInt("metrics-max-points")
+	if r.maxPoints <= 0 {
+		r.maxPoints = DEFAULT_MAX_POINTS
+	}
+}
+
+func (r *recorder) add(name string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.init()
+	if _, ok := r.series[name]; ok {
+		log.Logger().Errorf("metric %s already exist", name)
+		return
+	}
+	r.series[name] = make([]Point, 0)
+}
+
+func (r *recorder) record(name string, value float64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.init()
+	points := r.series[name]
+	if len(points) >= r.maxPoints {
+		// the oldest values are dropped, append moves the kept ones to a new array once the current one is full
+		points = points[len(points)-r.maxPoints+1:]
+		if !r.capped[name] {
+			r.capped[name] = true
+			log.Logger().Warnf("metric %s has more than %d values, the oldest ones are dropped", name, r.maxPoints)
+		}
+	}
+	r.series[name] = append(points, Point{Time: time.Now().UnixMilli(), Value: value})
+}
+
+func (r *recorder) Latest() map[string]float64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	result := make(map[string]float64, len(r.series))
+	for name, points := range r.series {
+		if len(points) > 0 {
+			result[name] = points[len(points)-1].Value
+		}
+	}
+	return result
 }
 
-type Metric struct {
-	records []MetricRecord
+func (r *recorder) Series() map[string][]Point {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	result := make(map[string][]Point, len(r.series))
+	for name, points := range r.series {
+		result[name] = append([]Point{}, points...)
+	}
+	return result
 }
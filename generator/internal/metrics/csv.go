@@ -4,76 +4,65 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"sync"
+	"path/filepath"
 	"time"
 
+	"github.com/spf13/viper"
+
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 )
 
+// CSVManager keeps the observed values in memory and writes them to a report file of each metric on save,
+// the values are archived with the run of the job too
 type CSVManager struct {
-	metrics sync.Map
+	recorder
+	dir string
+}
+
+func NewCSVMetricManager(dir string) *CSVManager {
+	return &CSVManager{dir: dir}
 }
 
-func NewCSVMetricManager() *CSVManager {
-	return &CSVManager{
-		metrics: sync.Map{},
+// NewLocalMetricManager returns the manager of an observer without a metric store, according to metrics-report
+func NewLocalMetricManager() Metrics {
+	if viper.GetString("metrics-report") == "csv" {
+		return NewCSVMetricManager(viper.GetString("metrics-report-dir"))
 	}
+	return NewMemoryMetricManager()
 }
 
 func (m *CSVManager) Add(name string) {
-	if _, ok := m.metrics.Load(name); !ok {
-		metric := NewMetric()
-		m.metrics.Store(name, metric)
-	} else {
-		log.Logger().Errorf("metric %s already exist", name)
-	}
+	m.add(name)
 }
 
 func (m *CSVManager) Observe(name string, value float64, tags map[string]interface{}) {
-	go func() {
-		if metric, ok := m.metrics.Load(name); !ok {
-			log.Logger().Errorf("metric %s doesnot exist", name)
-		} else {
-			record := MetricRecord{
-				value: value,
-				time:  time.Now().UnixMilli(),
-			}
-			metric.(*Metric).records = append(metric.(*Metric).records, record)
-		}
-	}()
+	m.record(name, value)
 }
 
 func (m *CSVManager) Save(namesapce string) {
-	log.Logger().Infof("save result to fle")
-	m.metrics.Range(func(key, value interface{}) bool {
-		metricName := key.(string)
-		metric := value.(*Metric)
-		m.save(namesapce, metricName, metric)
-		return true
-	})
+	log.Logger().Infof("save result to file")
+	for name, points := range m.Series() {
+		if err := m.save(namesapce, name, points); err != nil {
+			log.Logger().WithError(err).Errorf("failed to write the report of metric %s", name)
+		}
+	}
 }
 
-func (m *CSVManager) save(namesapce string, name string, metric *Metric) {
+func (m *CSVManager) save(namesapce string, name string, points []Point) error {
 	ts := time.Now().Unix()
-	filePath := fmt.Sprintf("%s_%s_report_%d.csv", namesapce, name, ts)
+	filePath := filepath.Join(m.dir, fmt.Sprintf("%s_%s_report_%d.csv", namesapce, name, ts))
 	file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-
 	if err != nil {
-		log.Logger().Fatalf("failed creating file: %s", err)
+		return err
 	}
+	defer file.Close()
 
 	datawriter := bufio.NewWriter(file)
-
-	header := fmt.Sprintf("time,%s", name)
-	datawriter.WriteString(header + "\n")
-
-	for _, record := range metric.records {
-		row := fmt.Sprintf("%d,%f", record.time, record.value)
-		datawriter.WriteString(row + "\n")
+	fmt.Fprintf(datawriter, "time,%s\n", name)
+	for _, point := range points {
+		fmt.Fprintf(datawriter, "%d,%f\n", point.Time, point.Value)
 	}
-
-	datawriter.Flush()
-	file.Close()
+	return datawriter.Flush()
 }
 
 func (m *CSVManager) Flush() {
@@ -3,6 +3,11 @@ package metrics
 type Metrics interface {
 	Add(name string)
 	Observe(name string, value float64, tags map[string]interface{})
+	// Save sends the values not sent yet to the metric store, the values are kept in memory
 	Save(namespace string)
 	Flush()
+	// Latest returns the last observed value of each metric
+	Latest() map[string]float64
+	// Series returns the values observed of each metric, in the order they were observed
+	Series() map[string][]Point
 }
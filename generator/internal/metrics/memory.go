@@ -0,0 +1,24 @@
+package metrics
+
+// MemoryManager keeps the observed values in memory only, they are archived with the run of the job
+type MemoryManager struct {
+	recorder
+}
+
+func NewMemoryMetricManager() *MemoryManager {
+	return &MemoryManager{}
+}
+
+func (m *MemoryManager) Add(name string) {
+	m.add(name)
+}
+
+func (m *MemoryManager) Observe(name string, value float64, tags map[string]interface{}) {
+	m.record(name, value)
+}
+
+func (m *MemoryManager) Save(namesapce string) {
+}
+
+func (m *MemoryManager) Flush() {
+}
@@ -1,12 +1,27 @@
 package observer
 
+import (
+	"context"
+
+	"github.com/timeplus-io/chameleon/generator/internal/metrics"
+)
+
 type Observer interface {
-	Observe() error
+	// Observe starts observing, observing stops when the context is done or Stop is called
+	Observe(ctx context.Context) error
 	Stop()
 	Wait()
+	// Close releases the connections of the observer
+	Close() error
+	// Measurements returns the latest value of each metric observed, like latency, throughput or availability
+	Measurements() map[string]float64
+	// Series returns the values observed of each metric during the run, to be archived with the run
+	Series() map[string][]metrics.Point
 }
 
 type Configuration struct {
 	Type       string                 `json:"type"`
 	Properties map[string]interface{} `json:"properties"`
+	// a disabled observer is created but does not observe, until it is enabled
+	Disabled bool `json:"disabled,omitempty"`
 }
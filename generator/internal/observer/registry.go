@@ -2,6 +2,8 @@ package observer
 
 import (
 	"fmt"
+
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 )
 
 type ObserverConstructor func(properties map[string]interface{}) (Observer, error)
@@ -9,6 +11,8 @@ type ObserverConstructor func(properties map[string]interface{}) (Observer, erro
 type ObRegItem struct {
 	Name        string
 	Constructor ObserverConstructor
+	// the properties of the observer, any property is accepted without schema
+	Properties schema.Properties
 }
 
 var (
@@ -46,3 +50,9 @@ func ListRegisteredSinkTypes() []string {
 	}
 	return keys
 }
+
+// GetProperties returns the schema of the properties of the observer, it is false when the observer does not exist
+func GetProperties(name string) (schema.Properties, bool) {
+	item, exist := obRegistry[name]
+	return item.Properties, exist
+}
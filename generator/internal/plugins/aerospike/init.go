@@ -9,9 +9,10 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        AEROSPIKE_SINK_TYPE,
 		Constructor: NewAeroSpikeSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", AEROSPIKE_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", AEROSPIKE_SINK_TYPE)
 }
 
 func Init() {
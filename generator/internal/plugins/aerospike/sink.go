@@ -1,10 +1,12 @@
 package aerospike
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/utils"
 
@@ -21,26 +23,23 @@ type AeroSpikeSink struct {
 	failedwrite  int
 }
 
-func NewAeroSpikeSink(properties map[string]interface{}) (sink.Sink, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var sinkProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 3000, Description: "port of the server"},
+	{Name: "namespace", Type: schema.TYPE_STRING, Default: "test", Description: "namespace of the records"},
+	{Name: "set", Type: schema.TYPE_STRING, Default: "default", Description: "set of the records"},
+}
 
-	port, err := utils.GetIntWithDefault(properties, "port", 3000)
+func NewAeroSpikeSink(properties map[string]interface{}) (sink.Sink, error) {
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	namespace, err := utils.GetWithDefault(properties, "namespace", "test")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	set, err := utils.GetWithDefault(properties, "set", "default")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	port := values.Int("port")
+	namespace := values.String("namespace")
+	set := values.String("set")
 
 	if client, err := aero.NewClient(host, port); err != nil {
 		return nil, err
@@ -55,11 +54,13 @@ func NewAeroSpikeSink(properties map[string]interface{}) (sink.Sink, error) {
 	}
 }
 
-func (s *AeroSpikeSink) Init(name string, fields []common.Field) error {
+func (s *AeroSpikeSink) Init(ctx context.Context, name string, fields []common.Field) error {
 	return nil
 }
 
-func (s *AeroSpikeSink) Write(headers []string, rows [][]interface{}, index int) error {
+func (s *AeroSpikeSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
+	// the rows are written one by one, the first error is returned once all of them are tried
+	var writeErr error
 	events := common.ToEvents(headers, rows)
 	for _, event := range events {
 		key := utils.RandStringBytes(8)
@@ -67,18 +68,33 @@ func (s *AeroSpikeSink) Write(headers []string, rows [][]interface{}, index int)
 		if aKey, err := aero.NewKey(s.namespace, s.set, key); err != nil {
 			log.Logger().Errorf("failed to create key %s", err)
 			s.failedwrite += 1
+			if writeErr == nil {
+				writeErr = sink.Permanent(err)
+			}
 			continue
 		} else {
 			bins := aero.BinMap(event)
 			if err = s.client.Put(nil, aKey, bins); err != nil {
 				log.Logger().Errorf("failed to write key %s", err)
 				s.failedwrite += 1
+				if writeErr == nil {
+					writeErr = err
+				}
 			} else {
 				s.successwrite += 1
 			}
 		}
 
 	}
+	return writeErr
+}
+
+func (s *AeroSpikeSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *AeroSpikeSink) Close() error {
+	s.client.Close()
 	return nil
 }
 
@@ -1,8 +1,11 @@
 package console
 
 import (
+	"context"
+
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 )
 
@@ -14,9 +17,10 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        CONSOLE_SINK_TYPE,
 		Constructor: NewConsoleSink,
+		Properties:  schema.Properties{},
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", CONSOLE_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", CONSOLE_SINK_TYPE)
 }
 
 func Init() {
@@ -28,12 +32,20 @@ func NewConsoleSink(properties map[string]interface{}) (sink.Sink, error) {
 	return &Console{}, nil
 }
 
-func (s *Console) Write(headers []string, rows [][]interface{}, index int) error {
+func (s *Console) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
 	log.Logger().Infof("Write one event to console %v:%v", headers, rows)
 	return nil
 }
 
-func (s *Console) Init(name string, fields []common.Field) error {
+func (s *Console) Init(ctx context.Context, name string, fields []common.Field) error {
+	return nil
+}
+
+func (s *Console) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *Console) Close() error {
 	return nil
 }
 
@@ -10,16 +10,18 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        DOLPINDB_SINK_TYPE,
 		Constructor: NewDolpinDBSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", DOLPINDB_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", DOLPINDB_SINK_TYPE)
 
 	obItem := observer.ObRegItem{
 		Name:        DOLPINEB_OB_TYPE,
 		Constructor: NewKDolpinDBObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", DOLPINEB_OB_TYPE)
+	log.Logger().Debugf("observer plugin %s has been registered", DOLPINEB_OB_TYPE)
 }
 
 func Init() {
@@ -12,7 +12,7 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 )
 
 const DOLPINEB_OB_TYPE = "dolpindb"
@@ -24,46 +24,38 @@ type DolpinDBObserver struct {
 	db api.DolphinDB
 
 	metric         string
-	isStopped      bool
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
 
-func NewKDolpinDBObserver(properties map[string]interface{}) (observer.Observer, error) {
-	address, err := utils.GetWithDefault(properties, "address", "localhost:8848")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	username, err := utils.GetWithDefault(properties, "username", "admin")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	password, err := utils.GetWithDefault(properties, "password", "123456")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	dbpath, err := utils.GetWithDefault(properties, "dbpath", "dfs://dbtest")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	tableName, err := utils.GetWithDefault(properties, "table", "test")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var observerProperties = schema.Properties{
+	{Name: "address", Type: schema.TYPE_STRING, Default: "localhost:8848", Description: "address of the server"},
+	{Name: "username", Type: schema.TYPE_STRING, Default: "admin", Description: "user name"},
+	{Name: "password", Type: schema.TYPE_STRING, Default: "123456", Secret: true, Description: "password of the user"},
+	{Name: "dbpath", Type: schema.TYPE_STRING, Default: "dfs://dbtest", Description: "path of the database"},
+	{Name: "table", Type: schema.TYPE_STRING, Default: "test", Description: "table to observe"},
+	{Name: "dbhandle", Type: schema.TYPE_STRING, Default: "test", Description: "name of the database handle"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency", "throughput", "availability"}, Description: "metric to observe"},
+	{Name: "metric_store_address", Type: schema.TYPE_STRING, Default: "http://localhost:8000", Description: "address of the timeplus server where the metrics are stored, no metric is stored when it is not set"},
+	{Name: "metric_store_apikey", Type: schema.TYPE_STRING, Secret: true, Description: "api key of the metric store"},
+	{Name: "metric_store_tenant", Type: schema.TYPE_STRING, Description: "tenant of the metric store"},
+}
 
-	dbhandle, err := utils.GetWithDefault(properties, "dbhandle", "test")
+func NewKDolpinDBObserver(properties map[string]interface{}) (observer.Observer, error) {
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	address := values.String("address")
+	username := values.String("username")
+	password := values.String("password")
+	dbpath := values.String("dbpath")
+	tableName := values.String("table")
+	dbhandle := values.String("dbhandle")
+	metric := values.String("metric")
 
 	db, err := api.NewDolphinDBClient(context.TODO(), address, nil)
 	if err != nil {
@@ -102,22 +94,11 @@ func NewKDolpinDBObserver(properties map[string]interface{}) (observer.Observer,
 
 	var metricsManager metrics.Metrics
 	if _, ok := properties["metric_store_address"]; !ok {
-		metricsManager = metrics.NewCSVMetricManager()
+		metricsManager = metrics.NewLocalMetricManager()
 	} else {
-		metricStoreAddress, err := utils.GetWithDefault(properties, "metric_store_address", "http://localhost:8000")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreAPIKey, err := utils.GetWithDefault(properties, "metric_store_apikey", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreTenant, err := utils.GetWithDefault(properties, "metric_store_tenant", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
+		metricStoreAddress := values.String("metric_store_address")
+		metricStoreAPIKey := values.String("metric_store_apikey")
+		metricStoreTenant := values.String("metric_store_tenant")
 
 		metricsManager = metrics.NewTimeplusMetricManager(metricStoreAddress, metricStoreTenant, metricStoreAPIKey)
 	}
@@ -127,7 +108,8 @@ func NewKDolpinDBObserver(properties map[string]interface{}) (observer.Observer,
 		dbpath:         dbpath,
 		tableName:      tableName,
 		metric:         metric,
-		isStopped:      false,
+		ctx:            context.Background(),
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 	}, nil
@@ -156,6 +138,10 @@ func (o *DolpinDBObserver) observeAvailability() error {
 
 	var preCount int32 = 0
 	for {
+		if o.ctx.Err() != nil {
+			break
+		}
+
 		tb, err := o.db.RunScript(fmt.Sprintf("select count(*) from loadTable('%s','%s')", o.dbpath, o.tableName))
 		if err != nil {
 			log.Logger().Errorf("failed to run script %s", err)
@@ -172,7 +158,10 @@ func (o *DolpinDBObserver) observeAvailability() error {
 			}
 		}
 
-		time.Sleep(2 * time.Second)
+		select {
+		case <-time.After(2 * time.Second):
+		case <-o.ctx.Done():
+		}
 	}
 
 	time.Sleep(100 * time.Millisecond)
@@ -181,7 +170,8 @@ func (o *DolpinDBObserver) observeAvailability() error {
 	return nil
 }
 
-func (o *DolpinDBObserver) Observe() error {
+func (o *DolpinDBObserver) Observe(ctx context.Context) error {
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("start observing")
 	if o.metric == "latency" {
 		go o.observeLatency()
@@ -200,8 +190,7 @@ func (o *DolpinDBObserver) Observe() error {
 
 func (o *DolpinDBObserver) Stop() {
 	log.Logger().Infof("call dolphinDB stop observing")
-	o.isStopped = true
-	log.Logger().Infof("set stopped")
+	o.cancel()
 	o.obWaiter.Wait()
 	log.Logger().Infof("stop observing")
 	o.metricsManager.Save("dolphinDB")
@@ -211,3 +200,15 @@ func (o *DolpinDBObserver) Stop() {
 func (o *DolpinDBObserver) Wait() {
 	o.obWaiter.Wait()
 }
+
+func (o *DolpinDBObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *DolpinDBObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *DolpinDBObserver) Close() error {
+	return o.db.Close()
+}
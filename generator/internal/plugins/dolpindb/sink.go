@@ -7,9 +7,9 @@ import (
 
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/source"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
 
 	"github.com/dolphindb/api-go/api"
 	"github.com/dolphindb/api-go/model"
@@ -25,46 +25,31 @@ type DolpinDBSink struct {
 	tableName string
 }
 
-func NewDolpinDBSink(properties map[string]interface{}) (sink.Sink, error) {
-	address, err := utils.GetWithDefault(properties, "address", "localhost:8848")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	username, err := utils.GetWithDefault(properties, "username", "admin")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	password, err := utils.GetWithDefault(properties, "password", "123456")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	dbpath, err := utils.GetWithDefault(properties, "dbpath", "dfs://dbtest")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	partitionType, err := utils.GetWithDefault(properties, "partitionType", "RANGE")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var sinkProperties = schema.Properties{
+	{Name: "address", Type: schema.TYPE_STRING, Default: "localhost:8848", Description: "address of the server"},
+	{Name: "username", Type: schema.TYPE_STRING, Default: "admin", Description: "user name"},
+	{Name: "password", Type: schema.TYPE_STRING, Default: "123456", Secret: true, Description: "password of the user"},
+	{Name: "dbpath", Type: schema.TYPE_STRING, Default: "dfs://dbtest", Description: "path of the database"},
+	{Name: "partitionType", Type: schema.TYPE_STRING, Default: "RANGE", Description: "partition type of the database"},
+	{Name: "partitionSchema", Type: schema.TYPE_STRING, Default: "0 50 100", Description: "partition schema of the database"},
+	{Name: "dbhandle", Type: schema.TYPE_STRING, Default: "test", Description: "name of the database handle"},
+	{Name: "engine", Type: schema.TYPE_STRING, Default: "OLAP", Description: "storage engine of the database"},
+}
 
-	partitionSchema, err := utils.GetWithDefault(properties, "partitionSchema", "0 50 100")
+func NewDolpinDBSink(properties map[string]interface{}) (sink.Sink, error) {
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	dbhandle, err := utils.GetWithDefault(properties, "dbhandle", "test")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	engine, err := utils.GetWithDefault(properties, "engine", "OLAP")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	address := values.String("address")
+	username := values.String("username")
+	password := values.String("password")
+	dbpath := values.String("dbpath")
+	partitionType := values.String("partitionType")
+	partitionSchema := values.String("partitionSchema")
+	dbhandle := values.String("dbhandle")
+	engine := values.String("engine")
 
 	db, err := api.NewDolphinDBClient(context.TODO(), address, nil)
 	if err != nil {
@@ -135,7 +120,7 @@ func convertType(sourceType string) string {
 	switch sourceType {
 	case string(source.FIELDTYPE_TIMESTAMP): // Should consider to support time.Time for timestamp type
 		return "STRING"
-	case string(source.FIELDTYPE_TIMESTAMP_INT):
+	case string(source.FIELDTYPE_TIMESTAMP_INT), string(source.FIELDTYPE_SEQUENCE):
 		return "LONG"
 	case string(source.FIELDTYPE_STRING):
 		return "STRING"
@@ -154,7 +139,7 @@ func convertType(sourceType string) string {
 	return "STRING"
 }
 
-func (s *DolpinDBSink) Init(name string, fields []common.Field) error {
+func (s *DolpinDBSink) Init(ctx context.Context, name string, fields []common.Field) error {
 	log.Logger().Infof("init dolpindb %s %v", name, fields)
 	colums := make([]common.Field, len(fields))
 	columsVal := make([]string, len(fields))
@@ -180,7 +165,7 @@ func (s *DolpinDBSink) Init(name string, fields []common.Field) error {
 	return nil
 }
 
-func (s *DolpinDBSink) Write(headers []string, rows [][]interface{}, index int) error {
+func (s *DolpinDBSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
 	log.Logger().Debugf("write dolpindb header:%v rows:%v %t index:%d", headers, rows, rows, index)
 
 	// order by headers
@@ -209,7 +194,7 @@ func (s *DolpinDBSink) Write(headers []string, rows [][]interface{}, index int)
 			datas, err := model.NewDataTypeListWithRaw(modelType, v)
 			if err != nil {
 				log.Logger().Errorf("create data type failed: %s", err)
-				return err
+				return sink.Permanent(err)
 			}
 			colValues[index] = model.NewVector(datas)
 		} else if col.Type == "INT" {
@@ -221,7 +206,7 @@ func (s *DolpinDBSink) Write(headers []string, rows [][]interface{}, index int)
 			datas, err := model.NewDataTypeListWithRaw(modelType, v)
 			if err != nil {
 				log.Logger().Errorf("create data type failed: %s", err)
-				return err
+				return sink.Permanent(err)
 			}
 			colValues[index] = model.NewVector(datas)
 		} else if col.Type == "DOUBLE" {
@@ -233,7 +218,7 @@ func (s *DolpinDBSink) Write(headers []string, rows [][]interface{}, index int)
 			datas, err := model.NewDataTypeListWithRaw(modelType, v)
 			if err != nil {
 				log.Logger().Errorf("create data type failed: %s", err)
-				return err
+				return sink.Permanent(err)
 			}
 			colValues[index] = model.NewVector(datas)
 		} else if col.Type == "BOOL" {
@@ -245,7 +230,7 @@ func (s *DolpinDBSink) Write(headers []string, rows [][]interface{}, index int)
 			datas, err := model.NewDataTypeListWithRaw(modelType, v)
 			if err != nil {
 				log.Logger().Errorf("create data type failed: %s", err)
-				return err
+				return sink.Permanent(err)
 			}
 			colValues[index] = model.NewVector(datas)
 		} else if col.Type == "TIMESTAMP" {
@@ -257,7 +242,7 @@ func (s *DolpinDBSink) Write(headers []string, rows [][]interface{}, index int)
 			datas, err := model.NewDataTypeListWithRaw(modelType, v)
 			if err != nil {
 				log.Logger().Errorf("create data type failed: %s", err)
-				return err
+				return sink.Permanent(err)
 			}
 			colValues[index] = model.NewVector(datas)
 		} else {
@@ -269,7 +254,7 @@ func (s *DolpinDBSink) Write(headers []string, rows [][]interface{}, index int)
 			datas, err := model.NewDataTypeListWithRaw(modelType, v)
 			if err != nil {
 				log.Logger().Errorf("create data type failed: %s", err)
-				return err
+				return sink.Permanent(err)
 			}
 			colValues[index] = model.NewVector(datas)
 		}
@@ -289,6 +274,14 @@ func (s *DolpinDBSink) Write(headers []string, rows [][]interface{}, index int)
 	return nil
 }
 
+func (s *DolpinDBSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *DolpinDBSink) Close() error {
+	return s.db.Close()
+}
+
 func (s *DolpinDBSink) GetStats() *sink.Stats {
 	return &sink.Stats{
 		SuccessWrite: 0,
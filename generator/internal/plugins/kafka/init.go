@@ -10,16 +10,18 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        KAFKA_SINK_TYPE,
 		Constructor: NewKafkaSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", KAFKA_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", KAFKA_SINK_TYPE)
 
 	obItem := observer.ObRegItem{
 		Name:        KAFKA_OB_TYPE,
 		Constructor: NewKafkaObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", KAFKA_OB_TYPE)
+	log.Logger().Debugf("observer plugin %s has been registered", KAFKA_OB_TYPE)
 }
 
 func Init() {
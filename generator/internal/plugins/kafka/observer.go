@@ -12,10 +12,11 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/twmb/franz-go/pkg/kadm"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/pkg/sasl/plain"
@@ -34,66 +35,56 @@ type KafkaObserver struct {
 	valueHit      int
 	timeColumn    string
 	timeFormat    string
+	timeCodec     *common.TimestampCodec
 	client        *kgo.Client
-	ctx           context.Context
 
 	metric         string
-	isStopped      bool
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
 
 type KafkaResult map[string]interface{}
 
-func NewKafkaObserver(properties map[string]interface{}) (observer.Observer, error) {
-	brokers, err := utils.GetWithDefault(properties, "brokers", "localhost:9092")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	enableTls, err := utils.GetBoolWithDefault(properties, "tls", false)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	topic, err := utils.GetWithDefault(properties, "topic", "test")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var observerProperties = schema.Properties{
+	{Name: "brokers", Type: schema.TYPE_STRING, Default: "localhost:9092", Description: "comma separated list of the brokers"},
+	{Name: "tls", Type: schema.TYPE_BOOLEAN, Default: false, Description: "connect with tls"},
+	{Name: "topic", Type: schema.TYPE_STRING, Default: "test", Description: "topic to consume"},
+	{Name: "sasl", Type: schema.TYPE_STRING, Default: KAFKA_SASL_TYPE_NONE, Enum: []string{KAFKA_SASL_TYPE_NONE, KAFKA_SASL_TYPE_PLAIN, KAFKA_SASL_TYPE_SCRAM}, Description: "sasl mechanism"},
+	{Name: "username", Type: schema.TYPE_STRING, Description: "user name"},
+	{Name: "password", Type: schema.TYPE_STRING, Secret: true, Description: "password of the user"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency", "throughput", "availability"}, Description: "metric to observe"},
+	{Name: "value_hit", Type: schema.TYPE_INTEGER, Default: 9, Description: "value of the field checked by the availability metric"},
+	{Name: "time_column", Type: schema.TYPE_STRING, Default: "time", Description: "column which holds the event time"},
+	{Name: "time_format", Type: schema.TYPE_STRING, Default: "2006-01-02 15:04:05.000000", Description: "go layout of the event time"},
+	{Name: "time_encoding", Type: schema.TYPE_STRING, Default: "layout", Description: "encoding of the event time"},
+	{Name: "time_locale", Type: schema.TYPE_STRING, Description: "time zone of the event time when the layout has none"},
+}
 
-	sasl, err := utils.GetWithDefault(properties, "sasl", KAFKA_SASL_TYPE_NONE)
+func NewKafkaObserver(properties map[string]interface{}) (observer.Observer, error) {
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	saslUsername, err := utils.GetWithDefault(properties, "username", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	saslPassword, err := utils.GetWithDefault(properties, "password", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	brokers := values.String("brokers")
+	enableTls := values.Bool("tls")
+	topic := values.String("topic")
+	sasl := values.String("sasl")
+	saslUsername := values.String("username")
+	saslPassword := values.String("password")
 
 	consumerGroup := fmt.Sprintf("my-group-%s", uuid.Must(uuid.NewRandom()).String())
 
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	valueHit, err := utils.GetIntWithDefault(properties, "value_hit", 9)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	timeColumn, err := utils.GetWithDefault(properties, "time_column", "time")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	metric := values.String("metric")
+	valueHit := values.Int("value_hit")
+	timeColumn := values.String("time_column")
+	timeFormat := values.String("time_format")
+	timeEncoding := values.String("time_encoding")
+	timeLocale := values.String("time_locale")
 
-	timeFormat, err := utils.GetWithDefault(properties, "time_format", "2006-01-02 15:04:05.000000")
+	timeCodec, err := common.NewTimestampCodec(common.TimestampEncoding(timeEncoding), timeFormat, timeLocale, "")
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
@@ -127,7 +118,7 @@ func NewKafkaObserver(properties map[string]interface{}) (observer.Observer, err
 		return nil, err
 	}
 
-	metricsManager := metrics.NewCSVMetricManager()
+	metricsManager := metrics.NewLocalMetricManager()
 
 	return &KafkaObserver{
 		brokers:        strings.Split(brokers, ","),
@@ -140,17 +131,23 @@ func NewKafkaObserver(properties map[string]interface{}) (observer.Observer, err
 		valueHit:       valueHit,
 		timeColumn:     timeColumn,
 		timeFormat:     timeFormat,
+		timeCodec:      timeCodec,
 		client:         client,
 		ctx:            context.Background(),
-		isStopped:      false,
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 	}, nil
 }
 
+// Ready sends a metadata request to the brokers
+func (o *KafkaObserver) Ready(ctx context.Context) error {
+	return o.client.Ping(ctx)
+}
+
 func (o *KafkaObserver) deleteGroup() error {
 	admClient := kadm.NewClient(o.client)
-	admClient.DeleteGroups(o.ctx, o.consumerGroup)
+	admClient.DeleteGroups(context.Background(), o.consumerGroup)
 	return nil
 }
 
@@ -160,12 +157,16 @@ func (o *KafkaObserver) observeLatency() error {
 	o.obWaiter.Add(1)
 
 	for {
-		if o.isStopped {
+		if o.ctx.Err() != nil {
 			log.Logger().Infof("stop kafka latency observing")
 			break
 		}
 
 		fetches := o.client.PollFetches(o.ctx)
+		if o.ctx.Err() != nil {
+			log.Logger().Infof("stop kafka latency observing")
+			break
+		}
 		if errs := fetches.Errors(); len(errs) > 0 {
 			panic(fmt.Sprint(errs))
 		}
@@ -179,7 +180,7 @@ func (o *KafkaObserver) observeLatency() error {
 
 			if recordResult["value"].(float64) == float64(o.valueHit) {
 				log.Logger().Infof("got one hit event %v", recordResult)
-				t, err := time.Parse(o.timeFormat, recordResult[o.timeColumn].(string))
+				t, err := o.timeCodec.Decode(recordResult[o.timeColumn])
 				if err != nil {
 					log.Logger().Errorf("failed to parse time column, %s", err)
 					continue
@@ -200,7 +201,7 @@ func (o *KafkaObserver) observeThroughput() error {
 	o.metricsManager.Add("throughput")
 	preOffset := int64(0)
 	for {
-		if o.isStopped {
+		if o.ctx.Err() != nil {
 			log.Logger().Infof("stop kafka throughput observing")
 			break
 		}
@@ -222,7 +223,11 @@ func (o *KafkaObserver) observeThroughput() error {
 			o.metricsManager.Observe("throughput", float64(throughput), nil)
 		}
 		preOffset = offset
-		time.Sleep(1 * time.Second)
+
+		select {
+		case <-time.After(1 * time.Second):
+		case <-o.ctx.Done():
+		}
 	}
 	return nil
 }
@@ -233,7 +238,8 @@ func (o *KafkaObserver) observeAvailability() error {
 	return nil
 }
 
-func (o *KafkaObserver) Observe() error {
+func (o *KafkaObserver) Observe(ctx context.Context) error {
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("start observing")
 	if o.metric == "latency" {
 		go o.observeLatency()
@@ -252,7 +258,7 @@ func (o *KafkaObserver) Observe() error {
 
 func (o *KafkaObserver) Stop() {
 	log.Logger().Infof("call Kafka stop observing")
-	o.isStopped = true
+	o.cancel()
 	o.obWaiter.Wait()
 	o.deleteGroup()
 	log.Logger().Infof("stop observing")
@@ -262,3 +268,16 @@ func (o *KafkaObserver) Stop() {
 func (o *KafkaObserver) Wait() {
 	o.obWaiter.Wait()
 }
+
+func (o *KafkaObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *KafkaObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *KafkaObserver) Close() error {
+	o.client.Close()
+	return nil
+}
@@ -0,0 +1,153 @@
+package kafka
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+)
+
+const (
+	KAFKA_FORMAT_JSON = "json"
+	KAFKA_FORMAT_OTLP = "otlp"
+)
+
+// OTLP span kind and status codes, see opentelemetry-proto trace.proto
+const (
+	otlpSpanKindServer  = 2
+	otlpStatusCodeOK    = 1
+	otlpStatusCodeError = 2
+)
+
+// the types below follow the OTLP/JSON encoding of ExportTraceServiceRequest
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpStatus struct {
+	Code int `json:"code"`
+}
+
+type otlpSpan struct {
+	TraceId           string     `json:"traceId"`
+	SpanId            string     `json:"spanId"`
+	ParentSpanId      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Status            otlpStatus `json:"status"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpTraces struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+var otlpTimeCodec, _ = common.NewTimestampCodec(common.TIMESTAMP_ENCODING_TIME, "", "", "ns")
+
+func otlpString(event common.Event, field string) string {
+	if v, ok := event[field].(string); ok {
+		return v
+	}
+	return ""
+}
+
+func otlpUnixNano(event common.Event, field string) (string, error) {
+	t, err := otlpTimeCodec.Decode(event[field])
+	if err != nil {
+		return "", fmt.Errorf("invalid %s : %w", field, err)
+	}
+	return strconv.FormatInt(t.UnixNano(), 10), nil
+}
+
+// toOTLPTraces groups the span events generated by the trace source mode by trace, and then by service
+func toOTLPTraces(events []common.Event) (map[string]*otlpTraces, []string, error) {
+	traces := make(map[string]*otlpTraces)
+	traceIds := make([]string, 0)
+	services := make(map[string]map[string]*otlpResourceSpans)
+
+	for _, event := range events {
+		traceId := otlpString(event, source.TRACE_FIELD_TRACE_ID)
+		if traceId == "" {
+			return nil, nil, fmt.Errorf("event %v is not a span", event)
+		}
+
+		start, err := otlpUnixNano(event, source.TRACE_FIELD_START_TIME)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		end, err := otlpUnixNano(event, source.TRACE_FIELD_END_TIME)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		statusCode := otlpStatusCodeOK
+		if otlpString(event, source.TRACE_FIELD_STATUS_CODE) == source.TRACE_STATUS_ERROR {
+			statusCode = otlpStatusCodeError
+		}
+
+		trace, ok := traces[traceId]
+		if !ok {
+			trace = &otlpTraces{ResourceSpans: make([]otlpResourceSpans, 0)}
+			traces[traceId] = trace
+			traceIds = append(traceIds, traceId)
+			services[traceId] = make(map[string]*otlpResourceSpans)
+		}
+
+		serviceName := otlpString(event, source.TRACE_FIELD_SERVICE_NAME)
+		resourceSpans, ok := services[traceId][serviceName]
+		if !ok {
+			resourceSpans = &otlpResourceSpans{
+				Resource: otlpResource{
+					Attributes: []otlpKeyValue{{Key: "service.name", Value: otlpAnyValue{StringValue: serviceName}}},
+				},
+				ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "chameleon"}, Spans: make([]otlpSpan, 0)}},
+			}
+			services[traceId][serviceName] = resourceSpans
+		}
+
+		resourceSpans.ScopeSpans[0].Spans = append(resourceSpans.ScopeSpans[0].Spans, otlpSpan{
+			TraceId:           traceId,
+			SpanId:            otlpString(event, source.TRACE_FIELD_SPAN_ID),
+			ParentSpanId:      otlpString(event, source.TRACE_FIELD_PARENT_SPAN_ID),
+			Name:              otlpString(event, source.TRACE_FIELD_OPERATION_NAME),
+			Kind:              otlpSpanKindServer,
+			StartTimeUnixNano: start,
+			EndTimeUnixNano:   end,
+			Status:            otlpStatus{Code: statusCode},
+		})
+	}
+
+	for _, traceId := range traceIds {
+		for _, resourceSpans := range services[traceId] {
+			traces[traceId].ResourceSpans = append(traces[traceId].ResourceSpans, *resourceSpans)
+		}
+	}
+
+	return traces, traceIds, nil
+}
@@ -4,21 +4,24 @@ import (
 	"context"
 	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
 	"net"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kerr"
 	"github.com/twmb/franz-go/pkg/kgo"
 	"github.com/twmb/franz-go/pkg/sasl/plain"
 	"github.com/twmb/franz-go/pkg/sasl/scram"
 
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
 )
 
 const KAFKA_SINK_TYPE = "kafka"
@@ -33,40 +36,44 @@ type KafkaSink struct {
 	saslUsername string
 	saslPassword string
 	createTopic  bool
+	format       string
 
 	client *kgo.Client
-	ctx    context.Context
-}
-
-func NewKafkaSink(properties map[string]interface{}) (sink.Sink, error) {
-	brokers, err := utils.GetWithDefault(properties, "brokers", "localhost:9092")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	// the topic did not exist before Init, so it is created by the sink
+	created bool
 
-	tls, err := utils.GetBoolWithDefault(properties, "tls", false)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	// the records are produced asynchronously, their failures are returned by the next Write or Flush
+	lock          sync.Mutex
+	produceErr    error
+	failedRecords int
+}
 
-	sasl, err := utils.GetWithDefault(properties, "sasl", KAFKA_SASL_TYPE_NONE)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var sinkProperties = schema.Properties{
+	{Name: "brokers", Type: schema.TYPE_STRING, Default: "localhost:9092", Description: "comma separated list of the brokers"},
+	{Name: "tls", Type: schema.TYPE_BOOLEAN, Default: false, Description: "connect with tls"},
+	{Name: "sasl", Type: schema.TYPE_STRING, Default: KAFKA_SASL_TYPE_NONE, Enum: []string{KAFKA_SASL_TYPE_NONE, KAFKA_SASL_TYPE_PLAIN, KAFKA_SASL_TYPE_SCRAM}, Description: "sasl mechanism"},
+	{Name: "username", Type: schema.TYPE_STRING, Description: "user name"},
+	{Name: "password", Type: schema.TYPE_STRING, Secret: true, Description: "password of the user"},
+	{Name: "create_topic", Type: schema.TYPE_BOOLEAN, Default: false, Description: "create the topic of each stream when it does not exist"},
+	{Name: "format", Type: schema.TYPE_STRING, Default: KAFKA_FORMAT_JSON, Enum: []string{KAFKA_FORMAT_JSON, KAFKA_FORMAT_OTLP}, Description: "encoding of the records"},
+}
 
-	saslUsername, err := utils.GetWithDefault(properties, "username", "")
+func NewKafkaSink(properties map[string]interface{}) (sink.Sink, error) {
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	saslPassword, err := utils.GetWithDefault(properties, "password", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	brokers := values.String("brokers")
+	tls := values.Bool("tls")
+	sasl := values.String("sasl")
+	saslUsername := values.String("username")
+	saslPassword := values.String("password")
+	createTopic := values.Bool("create_topic")
+	format := values.String("format")
 
-	createTopic, err := utils.GetBoolWithDefault(properties, "create_topic", false)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
+	if format != KAFKA_FORMAT_JSON && format != KAFKA_FORMAT_OTLP {
+		return nil, fmt.Errorf("invalid properties : unsupported format %s", format)
 	}
 
 	return &KafkaSink{
@@ -76,12 +83,11 @@ func NewKafkaSink(properties map[string]interface{}) (sink.Sink, error) {
 		saslUsername: saslUsername,
 		saslPassword: saslPassword,
 		createTopic:  createTopic,
-		ctx:          context.Background(),
+		format:       format,
 	}, nil
 }
 
-func (s *KafkaSink) Init(name string, fields []common.Field) error {
-	s.topic = name
+func (s *KafkaSink) options() []kgo.Opt {
 	opts := []kgo.Opt{
 		kgo.SeedBrokers(s.brokers...),
 		kgo.AllowAutoTopicCreation(),
@@ -103,16 +109,37 @@ func (s *KafkaSink) Init(name string, fields []common.Field) error {
 			Pass: s.saslPassword,
 		}.AsSha512Mechanism()))
 	}
+	return opts
+}
 
-	client, err := kgo.NewClient(opts...)
+// Ready sends a metadata request to the brokers
+func (s *KafkaSink) Ready(ctx context.Context) error {
+	client, err := kgo.NewClient(s.options()...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return client.Ping(ctx)
+}
+
+func (s *KafkaSink) Init(ctx context.Context, name string, fields []common.Field) error {
+	s.topic = name
+	client, err := kgo.NewClient(s.options()...)
 	if err != nil {
 		return err
 	}
 
 	s.client = client
+	admClient := kadm.NewClient(s.client)
+	// the topics are listed all together, as listing a missing topic may create it
+	if topics, err := admClient.ListTopics(ctx); err != nil {
+		log.Logger().WithError(err).Warnf("failed to list the topics, topic %s will not be cleaned up", s.topic)
+	} else {
+		s.created = !topics.Has(s.topic)
+	}
+
 	if s.createTopic {
-		admClient := kadm.NewClient(s.client)
-		if _, err := admClient.CreateTopics(s.ctx, 1, 3, nil, s.topic); err != nil {
+		if _, err := admClient.CreateTopics(ctx, 1, 3, nil, s.topic); err != nil {
 			return err
 		}
 	}
@@ -120,10 +147,41 @@ func (s *KafkaSink) Init(name string, fields []common.Field) error {
 	return nil
 }
 
-func (s *KafkaSink) cleanTopic() {
-	admClient := kadm.NewClient(s.client)
-	admClient.DeleteTopics(s.ctx, s.topic)
+// DropExisting deletes the topic before Init
+func (s *KafkaSink) DropExisting(ctx context.Context, name string) error {
+	client, err := kgo.NewClient(s.options()...)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return deleteTopic(ctx, kadm.NewClient(client), name)
+}
+
+// Cleanup deletes the topic when it did not exist before Init
+func (s *KafkaSink) Cleanup(ctx context.Context) error {
+	if !s.created || s.client == nil {
+		return nil
+	}
+	if err := deleteTopic(ctx, kadm.NewClient(s.client), s.topic); err != nil {
+		return err
+	}
 	s.client.PurgeTopicsFromClient(s.topic)
+	s.created = false
+	return nil
+}
+
+// deleteTopic deletes the topic, a missing topic is already deleted
+func deleteTopic(ctx context.Context, admClient *kadm.Client, topic string) error {
+	responses, err := admClient.DeleteTopics(ctx, topic)
+	if err != nil {
+		return err
+	}
+	for _, response := range responses {
+		if response.Err != nil && !errors.Is(response.Err, kerr.UnknownTopicOrPartition) {
+			return fmt.Errorf("failed to delete topic %s : %w", response.Topic, response.Err)
+		}
+	}
+	return nil
 }
 
 func randStringBytes(n int) string {
@@ -134,19 +192,106 @@ func randStringBytes(n int) string {
 	return string(b)
 }
 
-func (s *KafkaSink) Write(headers []string, rows [][]interface{}, index int) error {
+func (s *KafkaSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
+	// the batch is not produced when previous records failed, so a retry of the write produces it
+	if err := s.takeProduceErr(); err != nil {
+		return err
+	}
+
 	events := common.ToEvents(headers, rows)
-	for _, event := range events {
+	if s.format == KAFKA_FORMAT_OTLP {
+		return s.writeOTLP(ctx, events)
+	}
+
+	records := make([]*kgo.Record, len(events))
+	for index, event := range events {
 		log.Logger().Debugf("writing event to kafka topic %s, event %s", s.topic, fmt.Sprintf("%v", event))
-		eventValue, _ := json.Marshal(event)
+		eventValue, err := json.Marshal(event)
+		if err != nil {
+			return sink.Permanent(err)
+		}
 		key := []byte(randStringBytes(8))
-		record := &kgo.Record{Topic: s.topic, Value: eventValue, Key: key}
-		s.client.Produce(s.ctx, record, func(_ *kgo.Record, err error) {
-			if err != nil {
-				log.Logger().Errorf("record had a produce error: %s", err)
+		records[index] = &kgo.Record{Topic: s.topic, Value: eventValue, Key: key}
+	}
+	s.produce(ctx, records)
+	return nil
+}
+
+// produce sends the records without waiting for them to be acknowledged, the failed records are
+// collected by their promise
+func (s *KafkaSink) produce(ctx context.Context, records []*kgo.Record) {
+	for _, record := range records {
+		s.client.Produce(ctx, record, func(_ *kgo.Record, err error) {
+			if err == nil {
+				return
+			}
+
+			log.Logger().Errorf("record had a produce error: %s", err)
+			s.lock.Lock()
+			defer s.lock.Unlock()
+			s.failedRecords++
+			if s.produceErr == nil {
+				s.produceErr = err
 			}
 		})
 	}
+}
+
+// takeProduceErr returns the first error of the records failed since the last call, a non retriable
+// kafka error is permanent
+func (s *KafkaSink) takeProduceErr() error {
+	s.lock.Lock()
+	err, failed := s.produceErr, s.failedRecords
+	s.produceErr, s.failedRecords = nil, 0
+	s.lock.Unlock()
+	if err == nil {
+		return nil
+	}
+
+	err = fmt.Errorf("failed to produce %d records : %w", failed, err)
+	var kafkaErr *kerr.Error
+	if errors.As(err, &kafkaErr) && !kafkaErr.Retriable {
+		return sink.Permanent(err)
+	}
+	return err
+}
+
+// writeOTLP writes one OTLP/JSON traces message per trace, keyed by the trace id
+func (s *KafkaSink) writeOTLP(ctx context.Context, events []common.Event) error {
+	traces, traceIds, err := toOTLPTraces(events)
+	if err != nil {
+		return sink.Permanent(err)
+	}
+
+	records := make([]*kgo.Record, len(traceIds))
+	for index, traceId := range traceIds {
+		value, err := json.Marshal(traces[traceId])
+		if err != nil {
+			return sink.Permanent(err)
+		}
+
+		records[index] = &kgo.Record{Topic: s.topic, Value: value, Key: []byte(traceId)}
+	}
+	s.produce(ctx, records)
+	return nil
+}
+
+// Flush waits until the records produced are acknowledged by the brokers, and returns the error
+// of the records which failed
+func (s *KafkaSink) Flush(ctx context.Context) error {
+	if s.client == nil {
+		return nil
+	}
+	if err := s.client.Flush(ctx); err != nil {
+		return err
+	}
+	return s.takeProduceErr()
+}
+
+func (s *KafkaSink) Close() error {
+	if s.client != nil {
+		s.client.Close()
+	}
 	return nil
 }
 
@@ -10,16 +10,18 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        KDB_SINK_TYPE,
 		Constructor: NewKDBSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", KDB_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", KDB_SINK_TYPE)
 
 	obItem := observer.ObRegItem{
 		Name:        KDB_OB_TYPE,
 		Constructor: NewKDBObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", KDB_OB_TYPE)
+	log.Logger().Debugf("observer plugin %s has been registered", KDB_OB_TYPE)
 }
 
 func Init() {
@@ -1,6 +1,7 @@
 package kdb
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -10,7 +11,7 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 )
 
 const KDB_OB_TYPE = "kdb"
@@ -20,55 +21,46 @@ type KDBObserver struct {
 	query  string
 
 	metric         string
-	isStopped      bool
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
 
+var observerProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 5001, Description: "port of the server"},
+	{Name: "query", Type: schema.TYPE_STRING, Default: "count test", Description: "query run to observe the table"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency", "throughput", "availability"}, Description: "metric to observe"},
+	{Name: "metric_store_address", Type: schema.TYPE_STRING, Default: "http://localhost:8000", Description: "address of the timeplus server where the metrics are stored, no metric is stored when it is not set"},
+	{Name: "metric_store_apikey", Type: schema.TYPE_STRING, Secret: true, Description: "api key of the metric store"},
+	{Name: "metric_store_tenant", Type: schema.TYPE_STRING, Description: "tenant of the metric store"},
+}
+
 func NewKDBObserver(properties map[string]interface{}) (observer.Observer, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	port, err := utils.GetIntWithDefault(properties, "port", 5001)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	port := values.Int("port")
 
 	client, err := kdb.DialKDB(host, port, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect: %w", err)
 	}
 
-	query, err := utils.GetWithDefault(properties, "query", "count test")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	query := values.String("query")
+	metric := values.String("metric")
 
 	var metricsManager metrics.Metrics
 	if _, ok := properties["metric_store_address"]; !ok {
-		metricsManager = metrics.NewCSVMetricManager()
+		metricsManager = metrics.NewLocalMetricManager()
 	} else {
-		metricStoreAddress, err := utils.GetWithDefault(properties, "metric_store_address", "http://localhost:8000")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreAPIKey, err := utils.GetWithDefault(properties, "metric_store_apikey", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreTenant, err := utils.GetWithDefault(properties, "metric_store_tenant", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
+		metricStoreAddress := values.String("metric_store_address")
+		metricStoreAPIKey := values.String("metric_store_apikey")
+		metricStoreTenant := values.String("metric_store_tenant")
 
 		metricsManager = metrics.NewTimeplusMetricManager(metricStoreAddress, metricStoreTenant, metricStoreAPIKey)
 	}
@@ -77,7 +69,8 @@ func NewKDBObserver(properties map[string]interface{}) (observer.Observer, error
 		client:         client,
 		query:          query,
 		metric:         metric,
-		isStopped:      false,
+		ctx:            context.Background(),
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 	}, nil
@@ -104,6 +97,10 @@ func (o *KDBObserver) observeAvailability() error {
 	tag := map[string]interface{}{"targte": "kdb", "testId": id.String()}
 
 	for {
+		if o.ctx.Err() != nil {
+			break
+		}
+
 		res, err := o.client.Call(o.query)
 		if err != nil {
 			log.Logger().Errorf("query kdb failed: %s", err)
@@ -117,7 +114,10 @@ func (o *KDBObserver) observeAvailability() error {
 				preCount = count
 			}
 		}
-		time.Sleep(2 * time.Second)
+		select {
+		case <-time.After(2 * time.Second):
+		case <-o.ctx.Done():
+		}
 	}
 
 	time.Sleep(100 * time.Millisecond)
@@ -126,7 +126,8 @@ func (o *KDBObserver) observeAvailability() error {
 	return nil
 }
 
-func (o *KDBObserver) Observe() error {
+func (o *KDBObserver) Observe(ctx context.Context) error {
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("start observing")
 	if o.metric == "latency" {
 		go o.observeLatency()
@@ -145,7 +146,7 @@ func (o *KDBObserver) Observe() error {
 
 func (o *KDBObserver) Stop() {
 	log.Logger().Infof("call kdb stop observing")
-	o.isStopped = true
+	o.cancel()
 	o.obWaiter.Wait()
 	o.metricsManager.Save("kdb")
 	log.Logger().Infof("save observing completed")
@@ -154,3 +155,15 @@ func (o *KDBObserver) Stop() {
 func (o *KDBObserver) Wait() {
 	o.obWaiter.Wait()
 }
+
+func (o *KDBObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *KDBObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *KDBObserver) Close() error {
+	return o.client.Close()
+}
@@ -1,14 +1,15 @@
 package kdb
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/source"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
 
 	kdb "github.com/sv/kdbgo"
 )
@@ -24,16 +25,19 @@ type KDBSink struct {
 	tableName string
 }
 
+var sinkProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 5001, Description: "port of the server"},
+}
+
 func NewKDBSink(properties map[string]interface{}) (sink.Sink, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	port, err := utils.GetIntWithDefault(properties, "port", 5001)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	port := values.Int("port")
 
 	client, err := kdb.DialKDB(host, port, "")
 	if err != nil {
@@ -52,7 +56,7 @@ func convertType(sourceType string) string {
 	switch sourceType {
 	case string(source.FIELDTYPE_TIMESTAMP): // TODO: support using datetime instead of symbol for ingest
 		return "symbol"
-	case string(source.FIELDTYPE_TIMESTAMP_INT):
+	case string(source.FIELDTYPE_TIMESTAMP_INT), string(source.FIELDTYPE_SEQUENCE):
 		return "long"
 	case string(source.FIELDTYPE_STRING):
 		return "symbol"
@@ -71,7 +75,7 @@ func convertType(sourceType string) string {
 	return "symbol"
 }
 
-func (s *KDBSink) Init(name string, fields []common.Field) error {
+func (s *KDBSink) Init(ctx context.Context, name string, fields []common.Field) error {
 	log.Logger().Infof("init kdb %s %v", name, fields)
 	colums := make([]common.Field, len(fields))
 	columsVal := make([]string, len(fields))
@@ -98,7 +102,7 @@ func (s *KDBSink) Init(name string, fields []common.Field) error {
 	return nil
 }
 
-func (s *KDBSink) Write(headers []string, rows [][]interface{}, index int) error {
+func (s *KDBSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
 	log.Logger().Debugf("write kdb header:%v rows:%v index:%d", headers, rows, index)
 	// order by headers
 	values := make(map[string][]string)
@@ -142,6 +146,14 @@ func (s *KDBSink) Write(headers []string, rows [][]interface{}, index int) error
 	return nil
 }
 
+func (s *KDBSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *KDBSink) Close() error {
+	return s.client.Close()
+}
+
 func (s *KDBSink) GetStats() *sink.Stats {
 	return &sink.Stats{
 		SuccessWrite: 0,
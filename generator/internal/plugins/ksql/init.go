@@ -10,16 +10,18 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        KSQL_SINK_TYPE,
 		Constructor: NewKSQLSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", KSQL_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", KSQL_SINK_TYPE)
 
 	obItem := observer.ObRegItem{
 		Name:        KSQL_OB_TYPE,
 		Constructor: NewKSQLObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", KSQL_OB_TYPE)
+	log.Logger().Debugf("observer plugin %s has been registered", KSQL_OB_TYPE)
 }
 
 func Init() {
@@ -10,7 +10,7 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 )
 
 const KSQL_OB_TYPE = "ksql"
@@ -23,57 +23,44 @@ type KSQLObserver struct {
 	client     *ksqldb.Client
 
 	metric         string
-	isStopped      bool
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
 
 type KSQLResult map[string]interface{}
 
-func NewKSQLObserver(properties map[string]interface{}) (observer.Observer, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	port, err := utils.GetIntWithDefault(properties, "port", 8088)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var observerProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 8088, Description: "port of the server"},
+	{Name: "query", Type: schema.TYPE_STRING, Description: "query which reads the events written by the job"},
+	{Name: "time_format", Type: schema.TYPE_STRING, Default: "2006-01-02 15:04:05.000000", Description: "go layout of the event time"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency", "throughput", "availability"}, Description: "metric to observe"},
+	{Name: "metric_store_address", Type: schema.TYPE_STRING, Default: "http://localhost:8000", Description: "address of the timeplus server where the metrics are stored, no metric is stored when it is not set"},
+	{Name: "metric_store_apikey", Type: schema.TYPE_STRING, Secret: true, Description: "api key of the metric store"},
+	{Name: "metric_store_tenant", Type: schema.TYPE_STRING, Description: "tenant of the metric store"},
+}
 
-	query, err := utils.GetWithDefault(properties, "query", "")
+func NewKSQLObserver(properties map[string]interface{}) (observer.Observer, error) {
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	timeFormat, err := utils.GetWithDefault(properties, "time_format", "2006-01-02 15:04:05.000000")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	port := values.Int("port")
+	query := values.String("query")
+	timeFormat := values.String("time_format")
+	metric := values.String("metric")
 
 	var metricsManager metrics.Metrics
 	if _, ok := properties["metric_store_address"]; !ok {
-		metricsManager = metrics.NewEmptyMetricManager()
+		metricsManager = metrics.NewLocalMetricManager()
 	} else {
-		metricStoreAddress, err := utils.GetWithDefault(properties, "metric_store_address", "http://localhost:8000")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreAPIKey, err := utils.GetWithDefault(properties, "metric_store_apikey", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreTenant, err := utils.GetWithDefault(properties, "metric_store_tenant", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
+		metricStoreAddress := values.String("metric_store_address")
+		metricStoreAPIKey := values.String("metric_store_apikey")
+		metricStoreTenant := values.String("metric_store_tenant")
 
 		metricsManager = metrics.NewTimeplusMetricManager(metricStoreAddress, metricStoreTenant, metricStoreAPIKey)
 	}
@@ -88,7 +75,8 @@ func NewKSQLObserver(properties map[string]interface{}) (observer.Observer, erro
 		client:         client,
 		metric:         metric,
 		query:          query,
-		isStopped:      false,
+		ctx:            context.Background(),
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 	}, nil
@@ -109,7 +97,7 @@ func (o *KSQLObserver) observeLatency() error {
 		var TIME string
 		var VALUE float64
 		for row := range rc {
-			if o.isStopped {
+			if o.ctx.Err() != nil {
 				break
 			}
 			if row != nil {
@@ -127,8 +115,7 @@ func (o *KSQLObserver) observeLatency() error {
 		o.obWaiter.Done()
 	}()
 
-	ctx := context.Background()
-	e := o.client.Push(ctx, k, rc, hc)
+	e := o.client.Push(o.ctx, k, rc, hc)
 	if e != nil {
 		// handle the error better here, e.g. check for no rows returned
 		return fmt.Errorf("Error running Push request against ksqlDB:\n%v", e)
@@ -153,7 +140,7 @@ func (o *KSQLObserver) observeThroughput() error {
 		var count float64
 		var group string
 		for row := range rc {
-			if o.isStopped {
+			if o.ctx.Err() != nil {
 				break
 			}
 			if row != nil {
@@ -173,8 +160,7 @@ func (o *KSQLObserver) observeThroughput() error {
 		o.obWaiter.Done()
 	}()
 
-	ctx := context.Background()
-	e := o.client.Push(ctx, k, rc, hc)
+	e := o.client.Push(o.ctx, k, rc, hc)
 	if e != nil {
 		// handle the error better here, e.g. check for no rows returned
 		return fmt.Errorf("Error running Push request against ksqlDB:\n%v", e)
@@ -188,7 +174,8 @@ func (o *KSQLObserver) observeAvailability() error {
 	return nil
 }
 
-func (o *KSQLObserver) Observe() error {
+func (o *KSQLObserver) Observe(ctx context.Context) error {
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("start observing")
 	if o.metric == "latency" {
 		go o.observeLatency()
@@ -207,7 +194,7 @@ func (o *KSQLObserver) Observe() error {
 
 func (o *KSQLObserver) Stop() {
 	log.Logger().Infof("call ksql stop observing")
-	o.isStopped = true
+	o.cancel()
 	o.obWaiter.Wait()
 	log.Logger().Infof("stop observing")
 	o.metricsManager.Save("ksql")
@@ -216,3 +203,15 @@ func (o *KSQLObserver) Stop() {
 func (o *KSQLObserver) Wait() {
 	o.obWaiter.Wait()
 }
+
+func (o *KSQLObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *KSQLObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *KSQLObserver) Close() error {
+	return nil
+}
@@ -11,9 +11,9 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/plugins/kafka"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/source"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
 )
 
 const KSQL_SINK_TYPE = "ksql"
@@ -25,30 +25,26 @@ type KSQLSink struct {
 	brokers     string
 	stream      string
 	client      *ksqldb.Client
-	ctx         context.Context
 	brokerSink  sink.Sink
 }
 
-func NewKSQLSink(properties map[string]interface{}) (sink.Sink, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var sinkProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 8088, Description: "port of the server"},
+	{Name: "use_broker", Type: schema.TYPE_BOOLEAN, Default: false, Description: "write to the kafka brokers instead of the ksql rest api"},
+	{Name: "brokers", Type: schema.TYPE_STRING, Default: "localhost:9092", Description: "comma separated list of the kafka brokers, used with use_broker"},
+}
 
-	port, err := utils.GetIntWithDefault(properties, "port", 8088)
+func NewKSQLSink(properties map[string]interface{}) (sink.Sink, error) {
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	useBorker, err := utils.GetBoolWithDefault(properties, "use_broker", false)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	brokers, err := utils.GetWithDefault(properties, "brokers", "localhost:9092")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	port := values.Int("port")
+	useBorker := values.Bool("use_broker")
+	brokers := values.String("brokers")
 
 	url := fmt.Sprintf("http://%s:%d", host, port)
 	client := ksqldb.NewClient(url, "", "")
@@ -59,7 +55,6 @@ func NewKSQLSink(properties map[string]interface{}) (sink.Sink, error) {
 		usingBroker: useBorker,
 		brokers:     brokers,
 		client:      client,
-		ctx:         context.Background(),
 	}, nil
 }
 
@@ -67,7 +62,7 @@ func convertType(sourceType string) string {
 	switch sourceType {
 	case string(source.FIELDTYPE_TIMESTAMP):
 		return "STRING"
-	case string(source.FIELDTYPE_TIMESTAMP_INT):
+	case string(source.FIELDTYPE_TIMESTAMP_INT), string(source.FIELDTYPE_SEQUENCE):
 		return "bigint"
 	case string(source.FIELDTYPE_STRING):
 		return "STRING"
@@ -86,16 +81,18 @@ func convertType(sourceType string) string {
 	return "STRING"
 }
 
-func (s *KSQLSink) Init(name string, fields []common.Field) error {
+func (s *KSQLSink) Init(ctx context.Context, name string, fields []common.Field) error {
 	if s.usingBroker {
 		properties := map[string]interface{}{
 			"brokers": s.brokers,
 		}
-		if brokerSink, err := kafka.NewKafkaSink(properties); err != nil {
-			log.Logger().Errorf("failed to create broker sink : %s", err)
-		} else {
-			s.brokerSink = brokerSink
-			s.brokerSink.Init(name, fields)
+		brokerSink, err := kafka.NewKafkaSink(properties)
+		if err != nil {
+			return fmt.Errorf("failed to create broker sink : %w", err)
+		}
+		s.brokerSink = brokerSink
+		if err := s.brokerSink.Init(ctx, name, fields); err != nil {
+			return err
 		}
 	}
 
@@ -126,9 +123,9 @@ func (s *KSQLSink) Init(name string, fields []common.Field) error {
 	return nil
 }
 
-func (s *KSQLSink) Write(headers []string, rows [][]interface{}, index int) error {
+func (s *KSQLSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
 	if s.usingBroker {
-		return s.writeBroker(headers, rows, index)
+		return s.writeBroker(ctx, headers, rows, index)
 	}
 
 	return s.writeSQL(headers, rows, index)
@@ -164,8 +161,23 @@ func (s *KSQLSink) writeSQL(headers []string, rows [][]interface{}, index int) e
 	return nil
 }
 
-func (s *KSQLSink) writeBroker(headers []string, rows [][]interface{}, index int) error {
-	return s.brokerSink.Write(headers, rows, index)
+func (s *KSQLSink) writeBroker(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
+	return s.brokerSink.Write(ctx, headers, rows, index)
+}
+
+// Flush flushes the broker sink, inserts through ksql are synchronous
+func (s *KSQLSink) Flush(ctx context.Context) error {
+	if s.brokerSink != nil {
+		return s.brokerSink.Flush(ctx)
+	}
+	return nil
+}
+
+func (s *KSQLSink) Close() error {
+	if s.brokerSink != nil {
+		return s.brokerSink.Close()
+	}
+	return nil
 }
 
 func (s *KSQLSink) GetStats() *sink.Stats {
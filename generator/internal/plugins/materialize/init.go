@@ -4,22 +4,32 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/target"
 )
 
 func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        MATERIALIZE_SINK_TYPE,
 		Constructor: NewMaterializeSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", MATERIALIZE_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", MATERIALIZE_SINK_TYPE)
 
 	obItem := observer.ObRegItem{
 		Name:        MATERIALIZE_OB_TYPE,
 		Constructor: NewMaterializeObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", MATERIALIZE_OB_TYPE)
+	log.Logger().Debugf("observer plugin %s has been registered", MATERIALIZE_OB_TYPE)
+
+	targetItem := target.TargetRegItem{
+		Name:        MATERIALIZE_TARGET_TYPE,
+		Constructor: NewMaterializeTarget,
+	}
+	target.Register(targetItem)
+	log.Logger().Debugf("target plugin %s has been registered", MATERIALIZE_TARGET_TYPE)
 }
 
 func Init() {
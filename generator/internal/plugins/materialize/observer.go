@@ -13,7 +13,7 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 )
 
 const MATERIALIZE_OB_TYPE = "materialize"
@@ -30,81 +30,55 @@ type MaterializeObserver struct {
 	timeFormat string
 
 	conn           *pgx.Conn
-	isStopped      bool
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
 
-func NewMaterializeObserver(properties map[string]interface{}) (observer.Observer, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	port, err := utils.GetIntWithDefault(properties, "port", 6875)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	user, err := utils.GetWithDefault(properties, "user", "materialize")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	db, err := utils.GetWithDefault(properties, "db", "materialize")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	query, err := utils.GetWithDefault(properties, "query", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	view, err := utils.GetWithDefault(properties, "view", "mview")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var observerProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 6875, Description: "port of the server"},
+	{Name: "user", Type: schema.TYPE_STRING, Default: "materialize", Description: "user name"},
+	{Name: "db", Type: schema.TYPE_STRING, Default: "materialize", Description: "database"},
+	{Name: "query", Type: schema.TYPE_STRING, Description: "query which reads the events written by the job"},
+	{Name: "view", Type: schema.TYPE_STRING, Default: "mview", Description: "name of the materialized view created for the query"},
+	{Name: "time_format", Type: schema.TYPE_STRING, Default: "2006-01-02 15:04:05.000", Description: "go layout of the event time"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency", "throughput", "availability"}, Description: "metric to observe"},
+	{Name: "metric_store_address", Type: schema.TYPE_STRING, Default: "http://localhost:8000", Description: "address of the timeplus server where the metrics are stored, no metric is stored when it is not set"},
+	{Name: "metric_store_apikey", Type: schema.TYPE_STRING, Secret: true, Description: "api key of the metric store"},
+	{Name: "metric_store_tenant", Type: schema.TYPE_STRING, Description: "tenant of the metric store"},
+}
 
-	timeFormat, err := utils.GetWithDefault(properties, "time_format", "2006-01-02 15:04:05.000")
+func NewMaterializeObserver(properties map[string]interface{}) (observer.Observer, error) {
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	port := values.Int("port")
+	user := values.String("user")
+	db := values.String("db")
+	query := values.String("query")
+	view := values.String("view")
+	timeFormat := values.String("time_format")
+	metric := values.String("metric")
 
 	var metricsManager metrics.Metrics
 	if _, ok := properties["metric_store_address"]; !ok {
-		metricsManager = metrics.NewEmptyMetricManager()
+		metricsManager = metrics.NewLocalMetricManager()
 	} else {
-		metricStoreAddress, err := utils.GetWithDefault(properties, "metric_store_address", "http://localhost:8000")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreAPIKey, err := utils.GetWithDefault(properties, "metric_store_apikey", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreTenant, err := utils.GetWithDefault(properties, "metric_store_tenant", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
+		metricStoreAddress := values.String("metric_store_address")
+		metricStoreAPIKey := values.String("metric_store_apikey")
+		metricStoreTenant := values.String("metric_store_tenant")
 
 		metricsManager = metrics.NewTimeplusMetricManager(metricStoreAddress, metricStoreTenant, metricStoreAPIKey)
 	}
 
+	// the observer connects when it starts observing, so the server can be waited for with Ready
 	url := fmt.Sprintf("postgres://%s@%s:%d/%s", user, host, port, db)
 
-	conn, err := pgx.Connect(context.Background(), url)
-	if err != nil {
-		log.Logger().Fatal("failed to connect to materialize")
-	}
-
 	return &MaterializeObserver{
 		host:           host,
 		port:           port,
@@ -115,8 +89,8 @@ func NewMaterializeObserver(properties map[string]interface{}) (observer.Observe
 		view:           view,
 		metric:         metric,
 		timeFormat:     timeFormat,
-		conn:           conn,
-		isStopped:      false,
+		ctx:            context.Background(),
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 	}, nil
@@ -164,7 +138,7 @@ func (o *MaterializeObserver) observeLatency() error {
 	}
 
 	for {
-		if o.isStopped {
+		if o.ctx.Err() != nil {
 			break
 		}
 
@@ -232,7 +206,7 @@ func (o *MaterializeObserver) observeThroughput() error {
 	}
 
 	for {
-		if o.isStopped {
+		if o.ctx.Err() != nil {
 			break
 		}
 
@@ -272,12 +246,12 @@ func (o *MaterializeObserver) observeAvailability() error {
 	o.obWaiter.Add(1)
 
 	for {
-		if o.isStopped {
+		if o.ctx.Err() != nil {
 			log.Logger().Infof("stop neutron availability observing")
 			break
 		}
 
-		rows, err := o.conn.Query(context.Background(), o.query)
+		rows, err := o.conn.Query(o.ctx, o.query)
 		if err != nil {
 			log.Logger().Warnf("failed to run query %s", err)
 			continue
@@ -296,7 +270,10 @@ func (o *MaterializeObserver) observeAvailability() error {
 		}
 
 		// add observation here
-		time.Sleep(1 * time.Second)
+		select {
+		case <-time.After(1 * time.Second):
+		case <-o.ctx.Done():
+		}
 	}
 
 	log.Logger().Infof("stop observing availability")
@@ -304,7 +281,25 @@ func (o *MaterializeObserver) observeAvailability() error {
 	return nil
 }
 
-func (o *MaterializeObserver) Observe() error {
+// Ready connects to the server, the connection is closed once it succeeds
+func (o *MaterializeObserver) Ready(ctx context.Context) error {
+	conn, err := pgx.Connect(ctx, o.url)
+	if err != nil {
+		return err
+	}
+	return conn.Close(ctx)
+}
+
+func (o *MaterializeObserver) Observe(ctx context.Context) error {
+	if o.conn == nil {
+		conn, err := pgx.Connect(ctx, o.url)
+		if err != nil {
+			return fmt.Errorf("failed to connect to materialize : %w", err)
+		}
+		o.conn = conn
+	}
+
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("start observing")
 	if o.metric == "latency" {
 		go o.observeLatency()
@@ -320,7 +315,7 @@ func (o *MaterializeObserver) Observe() error {
 
 func (o *MaterializeObserver) Stop() {
 	log.Logger().Infof("call materialize stop observing")
-	o.isStopped = true
+	o.cancel()
 	o.obWaiter.Wait()
 	log.Logger().Infof("stop observing")
 	o.metricsManager.Save("materialize")
@@ -329,3 +324,18 @@ func (o *MaterializeObserver) Stop() {
 func (o *MaterializeObserver) Wait() {
 	o.obWaiter.Wait()
 }
+
+func (o *MaterializeObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *MaterializeObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *MaterializeObserver) Close() error {
+	if o.conn == nil {
+		return nil
+	}
+	return o.conn.Close(context.Background())
+}
@@ -2,16 +2,18 @@ package materialize
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/jackc/pgconn"
 	"github.com/jackc/pgx/v4"
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/source"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
 )
 
 const MATERIALIZE_SINK_TYPE = "materialize"
@@ -26,26 +28,23 @@ type MaterializeSink struct {
 	table string
 }
 
-func NewMaterializeSink(properties map[string]interface{}) (sink.Sink, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	port, err := utils.GetIntWithDefault(properties, "port", 6875)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var sinkProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 6875, Description: "port of the server"},
+	{Name: "user", Type: schema.TYPE_STRING, Default: "materialize", Description: "user name"},
+	{Name: "db", Type: schema.TYPE_STRING, Default: "materialize", Description: "database"},
+}
 
-	user, err := utils.GetWithDefault(properties, "user", "materialize")
+func NewMaterializeSink(properties map[string]interface{}) (sink.Sink, error) {
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	db, err := utils.GetWithDefault(properties, "db", "materialize")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	port := values.Int("port")
+	user := values.String("user")
+	db := values.String("db")
 
 	url := fmt.Sprintf("postgres://%s@%s:%d/%s", user, host, port, db)
 
@@ -58,20 +57,28 @@ func NewMaterializeSink(properties map[string]interface{}) (sink.Sink, error) {
 	}, nil
 }
 
-func (c *MaterializeSink) getConn() *pgx.Conn {
-	conn, err := pgx.Connect(context.Background(), c.url)
+func (c *MaterializeSink) getConn(ctx context.Context) (*pgx.Conn, error) {
+	conn, err := pgx.Connect(ctx, c.url)
 	if err != nil {
-		log.Logger().Fatal("failed to connect to materialize")
+		return nil, fmt.Errorf("failed to connect to materialize : %w", err)
 	}
-	return conn
+	return conn, nil
+}
 
+// Ready connects to the server, the connection is closed once it succeeds
+func (s *MaterializeSink) Ready(ctx context.Context) error {
+	conn, err := s.getConn(ctx)
+	if err != nil {
+		return err
+	}
+	return conn.Close(ctx)
 }
 
 func convertType(sourceType string) string {
 	switch sourceType {
 	case string(source.FIELDTYPE_TIMESTAMP):
 		return "timestamp"
-	case string(source.FIELDTYPE_TIMESTAMP_INT):
+	case string(source.FIELDTYPE_TIMESTAMP_INT), string(source.FIELDTYPE_SEQUENCE):
 		return "bigint"
 	case string(source.FIELDTYPE_STRING):
 		return "text"
@@ -90,8 +97,11 @@ func convertType(sourceType string) string {
 	return "text"
 }
 
-func (s *MaterializeSink) Init(name string, fields []common.Field) error {
-	conn := s.getConn()
+func (s *MaterializeSink) Init(ctx context.Context, name string, fields []common.Field) error {
+	conn, err := s.getConn(ctx)
+	if err != nil {
+		return err
+	}
 	defer conn.Close(context.Background())
 
 	s.table = name
@@ -105,15 +115,18 @@ func (s *MaterializeSink) Init(name string, fields []common.Field) error {
 	log.Logger().Debugf("create table with sql %s", createTableSql)
 
 	// todo: drop table if exist
-	if _, err := conn.Exec(context.Background(), createTableSql); err != nil {
+	if _, err := conn.Exec(ctx, createTableSql); err != nil {
 		log.Logger().Warnf("create table failed %s", err)
 		return err
 	}
 	return nil
 }
 
-func (s *MaterializeSink) Write(headers []string, rows [][]interface{}, index int) error {
-	conn := s.getConn() // todo : should share connection here?
+func (s *MaterializeSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
+	conn, err := s.getConn(ctx) // todo : should share connection here?
+	if err != nil {
+		return err
+	}
 	defer conn.Close(context.Background())
 
 	valueStrs := make([]string, len(rows))
@@ -138,14 +151,28 @@ func (s *MaterializeSink) Write(headers []string, rows [][]interface{}, index in
 
 	log.Logger().Debugf("insert data with sql %s", sql)
 
-	err := conn.BeginFunc(context.Background(), func(tx pgx.Tx) error {
-		_, err := tx.Exec(context.Background(), sql)
+	err = conn.BeginFunc(ctx, func(tx pgx.Tx) error {
+		_, err := tx.Exec(ctx, sql)
 		return err
 	})
 
 	if err != nil {
-		log.Logger().Error("failed to insert data to materialze", err)
+		err = fmt.Errorf("failed to insert data to materialize : %w", err)
+		// data exceptions and syntax errors fail again when retried
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "42")) {
+			return sink.Permanent(err)
+		}
 	}
+	return err
+}
+
+// Flush does nothing, each write opens and closes its own connection
+func (s *MaterializeSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *MaterializeSink) Close() error {
 	return nil
 }
 
@@ -0,0 +1,113 @@
+package materialize
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/timeplus-io/chameleon/generator/internal/target"
+	"github.com/timeplus-io/chameleon/generator/internal/utils"
+)
+
+const MATERIALIZE_TARGET_TYPE = "materialize"
+
+type MaterializeTarget struct {
+	url  string
+	conn *pgx.Conn
+	lock sync.Mutex
+}
+
+func NewMaterializeTarget(properties map[string]interface{}) (target.Target, error) {
+	host, err := utils.GetWithDefault(properties, "host", "localhost")
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties : %w", err)
+	}
+
+	port, err := utils.GetIntWithDefault(properties, "port", 6875)
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties : %w", err)
+	}
+
+	user, err := utils.GetWithDefault(properties, "user", "materialize")
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties : %w", err)
+	}
+
+	db, err := utils.GetWithDefault(properties, "db", "materialize")
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties : %w", err)
+	}
+
+	return &MaterializeTarget{
+		url: fmt.Sprintf("postgres://%s@%s:%d/%s", user, host, port, db),
+	}, nil
+}
+
+// getConn connects lazily, a pgx connection cannot be used concurrently so it is used under the lock
+func (t *MaterializeTarget) getConn(ctx context.Context) (*pgx.Conn, error) {
+	if t.conn == nil {
+		conn, err := pgx.Connect(ctx, t.url)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to materialize : %w", err)
+		}
+		t.conn = conn
+	}
+	return t.conn, nil
+}
+
+func (t *MaterializeTarget) Exec(ctx context.Context, statement string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	conn, err := t.getConn(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(ctx, statement)
+	return err
+}
+
+func (t *MaterializeTarget) Query(ctx context.Context, statement string) ([]map[string]interface{}, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	conn, err := t.getConn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := conn.Query(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	fields := rows.FieldDescriptions()
+	result := make([]map[string]interface{}, 0)
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return nil, err
+		}
+
+		row := make(map[string]interface{}, len(fields))
+		for i, field := range fields {
+			row[string(field.Name)] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+func (t *MaterializeTarget) Close() error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close(context.Background())
+}
@@ -88,6 +88,10 @@ func (e *Engine) Ping() error {
 	return e.connection.Ping()
 }
 
+func (e *Engine) PingContext(ctx context.Context) error {
+	return e.connection.PingContext(ctx)
+}
+
 func (e *Engine) ExecWithParams(sql string, params ...any) error {
 	log.Logger().Debugf("run exec %s", sql)
 	if _, err := e.connection.Exec(sql, params...); err != nil {
@@ -96,6 +100,18 @@ func (e *Engine) ExecWithParams(sql string, params ...any) error {
 	return nil
 }
 
+func (e *Engine) ExecContext(ctx context.Context, sql string, params ...any) error {
+	log.Logger().Debugf("run exec %s", sql)
+	if _, err := e.connection.ExecContext(ctx, sql, params...); err != nil {
+		return e.handleDriverError(err)
+	}
+	return nil
+}
+
+func (e *Engine) Close() error {
+	return e.connection.Close()
+}
+
 func Parse(err error) (int, string) {
 	var code int
 	var msg string
@@ -210,6 +226,11 @@ func (e *Engine) SyncQuery(sql string, timeout time.Duration, params ...any) ([]
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
+	return e.SyncQueryContext(ctx, sql, params...)
+}
+
+// SyncQueryContext is SyncQuery cancelled by the given context
+func (e *Engine) SyncQueryContext(ctx context.Context, sql string, params ...any) ([]Column, [][]any, error) {
 	rows, err := e.connection.QueryContext(ctx, sql, params...)
 	if err != nil {
 		return nil, nil, e.handleDriverError(err)
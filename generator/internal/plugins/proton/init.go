@@ -4,22 +4,32 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/target"
 )
 
 func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        ProtonSinkType,
 		Constructor: NewProtonSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", ProtonSinkType)
+	log.Logger().Debugf("sink plugin %s has been registered", ProtonSinkType)
 
 	obItem := observer.ObRegItem{
 		Name:        ProtonOBType,
 		Constructor: NewProtonObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", ProtonOBType)
+	log.Logger().Debugf("observer plugin %s has been registered", ProtonOBType)
+
+	targetItem := target.TargetRegItem{
+		Name:        ProtonTargetType,
+		Constructor: NewProtonTarget,
+	}
+	target.Register(targetItem)
+	log.Logger().Debugf("target plugin %s has been registered", ProtonTargetType)
 }
 
 func Init() {
@@ -6,10 +6,11 @@ import (
 	"sync"
 	"time"
 
+	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 
 	"github.com/google/uuid"
 )
@@ -21,6 +22,7 @@ type ProtonObserver struct {
 	query      string
 	timeColumn string
 	timeFormat string
+	timeCodec  *common.TimestampCodec
 	metric     string
 
 	bufferCount int
@@ -28,81 +30,73 @@ type ProtonObserver struct {
 
 	querySet []interface{}
 
-	isStopped      bool
-	cancel         func()
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
 
-func NewProtonObserver(properties map[string]interface{}) (observer.Observer, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var observerProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "username", Type: schema.TYPE_STRING, Default: "default", Description: "user name"},
+	{Name: "password", Type: schema.TYPE_STRING, Secret: true, Description: "password of the user"},
+	{Name: "query", Type: schema.TYPE_STRING, Description: "query which reads the events written by the job"},
+	{Name: "time_column", Type: schema.TYPE_STRING, Description: "column which holds the event time"},
+	{Name: "time_format", Type: schema.TYPE_STRING, Default: "2006-01-02T15:04:05.000Z", Description: "go layout of the event time"},
+	{Name: "time_encoding", Type: schema.TYPE_STRING, Default: "epoch_ms", Description: "encoding of the event time"},
+	{Name: "time_locale", Type: schema.TYPE_STRING, Description: "time zone of the event time when the layout has none"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency", "throughput", "availability", "queries"}, Description: "metric to observe"},
+	{Name: "buffer_count", Type: schema.TYPE_INTEGER, Default: 100, Description: "number of observations buffered before they are recorded"},
+	{Name: "buffer_time", Type: schema.TYPE_INTEGER, Default: 128, Description: "milliseconds observations are buffered before they are recorded"},
+	{Name: "querys", Type: schema.TYPE_ARRAY, Description: "queries run by the queries metric"},
+}
 
-	username, err := utils.GetWithDefault(properties, "username", "default")
+func NewProtonObserver(properties map[string]interface{}) (observer.Observer, error) {
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	password, err := utils.GetWithDefault(properties, "password", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	username := values.String("username")
+	password := values.String("password")
 
 	config := NewConfig(host, username, password)
 	engine := NewEngine(config)
 
-	query, err := utils.GetWithDefault(properties, "query", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	timeColumn, err := utils.GetWithDefault(properties, "time_column", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	timeFormat, err := utils.GetWithDefault(properties, "time_format", "2006-01-02T15:04:05.000Z")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	query := values.String("query")
+	timeColumn := values.String("time_column")
+	timeFormat := values.String("time_format")
+	timeEncoding := values.String("time_encoding")
+	timeLocale := values.String("time_locale")
 
-	bufferCount, err := utils.GetIntWithDefault(properties, "buffer_count", 100)
+	timeCodec, err := common.NewTimestampCodec(common.TimestampEncoding(timeEncoding), timeFormat, timeLocale, "")
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	bufferTime, err := utils.GetIntWithDefault(properties, "buffer_time", 128)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	metric := values.String("metric")
+	bufferCount := values.Int("buffer_count")
+	bufferTime := values.Int("buffer_time")
 
-	metricsManager := metrics.NewCSVMetricManager()
+	metricsManager := metrics.NewLocalMetricManager()
 
 	ob := &ProtonObserver{
 		server:         engine,
 		query:          query,
 		timeColumn:     timeColumn,
 		timeFormat:     timeFormat,
+		timeCodec:      timeCodec,
 		metric:         metric,
-		querySet:       nil,
-		isStopped:      false,
+		querySet:       values.List("querys"),
+		ctx:            context.Background(),
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 		bufferCount:    bufferCount,
 		bufferTime:     bufferTime,
 	}
 
-	if value, ok := properties["querys"]; ok {
-		ob.querySet = value.([]interface{})
-	}
-
 	return ob, nil
 }
 
@@ -111,11 +105,10 @@ func (o *ProtonObserver) observeLatency() error {
 	o.metricsManager.Add("latency")
 
 	id := uuid.NewString()
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx := o.ctx
 	header, resultStream, _, err := o.server.QueryStream(ctx, o.query, id)
 	if err != nil {
 		log.Logger().WithError(err).Errorf("failed to run query")
-		cancel()
 		return err
 	}
 
@@ -130,8 +123,11 @@ func (o *ProtonObserver) observeLatency() error {
 	}
 	resultStream.ForEach(func(v interface{}) {
 		event := v.(ResponseDataRow)
-		timestamp := event[timeIndex].(int64)
-		tm := time.UnixMilli(timestamp)
+		tm, err := o.timeCodec.Decode(event[timeIndex])
+		if err != nil {
+			log.Logger().Errorf("failed to parse time column, %s", err)
+			return
+		}
 		log.Logger().Infof("observe latency %v", time.Until(tm))
 		o.metricsManager.Observe("latency", -float64(time.Until(tm).Microseconds())/1000.0, nil)
 
@@ -142,7 +138,6 @@ func (o *ProtonObserver) observeLatency() error {
 	})
 
 	resultStream.Connect(ctx)
-	o.cancel = cancel
 	log.Logger().Infof("stop observing latecny")
 	o.obWaiter.Done()
 	return nil
@@ -153,11 +148,10 @@ func (o *ProtonObserver) observeThroughput() error {
 	o.metricsManager.Add("throughput")
 
 	id := uuid.NewString()
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx := o.ctx
 	_, resultStream, _, err := o.server.QueryStream(ctx, o.query, id)
 	if err != nil {
 		log.Logger().Errorf("failed to run query")
-		cancel()
 		return err
 	}
 
@@ -175,7 +169,6 @@ func (o *ProtonObserver) observeThroughput() error {
 	})
 
 	resultStream.Connect(ctx)
-	o.cancel = cancel
 	log.Logger().Infof("stop observing throughput")
 	o.obWaiter.Done()
 	return nil
@@ -191,7 +184,7 @@ func (o *ProtonObserver) runQuery(sql string) error {
 
 	id := uuid.NewString() // TODO : the API should return query Id
 	metricsName := "query"
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx := o.ctx
 	_, resultStream, _, err := o.server.QueryStream(ctx, sql, id)
 	if err != nil {
 		log.Logger().Errorf("failed to run query")
@@ -202,7 +195,6 @@ func (o *ProtonObserver) runQuery(sql string) error {
 			"id":    id,
 		}
 		o.metricsManager.Observe(metricsName, 1, tag)
-		cancel()
 		return err
 	}
 
@@ -241,7 +233,6 @@ func (o *ProtonObserver) runQuery(sql string) error {
 	})
 
 	resultStream.Connect(ctx)
-	o.cancel = cancel
 
 	time.Sleep(100 * time.Millisecond)
 	o.metricsManager.Flush()
@@ -265,7 +256,13 @@ func (o *ProtonObserver) observeQueries() error {
 	return nil
 }
 
-func (o *ProtonObserver) Observe() error {
+// Ready pings proton with the native protocol used by the queries
+func (o *ProtonObserver) Ready(ctx context.Context) error {
+	return o.server.PingContext(ctx)
+}
+
+func (o *ProtonObserver) Observe(ctx context.Context) error {
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("TimeplusObserver start observing")
 
 	if o.metric == "latency" {
@@ -282,7 +279,6 @@ func (o *ProtonObserver) Observe() error {
 
 func (o *ProtonObserver) Stop() {
 	log.Logger().Infof("call proton stop observing")
-	o.isStopped = true
 	o.cancel()
 	o.obWaiter.Wait()
 	log.Logger().Infof("stop observing")
@@ -292,3 +288,15 @@ func (o *ProtonObserver) Stop() {
 func (o *ProtonObserver) Wait() {
 	o.obWaiter.Wait()
 }
+
+func (o *ProtonObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *ProtonObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *ProtonObserver) Close() error {
+	return o.server.Close()
+}
@@ -2,6 +2,7 @@ package proton
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -245,6 +246,24 @@ func IsUnknownStreamError(err error) bool {
 	return false
 }
 
+// Ping calls the unversioned ping endpoint, it fails until proton accepts requests
+func (s *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/proton/%s", s.baseURL, PingPath), nil)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.restUser, s.restPassword)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrProtonDown, err.Error())
+	}
+	if err := UnmarshalErrorResponse(resp); err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
 func (s *Client) DeleteStream(name string) error {
 	url := s.buildVersionedURL(fmt.Sprintf("%s/%s", StreamsPath, name))
 
@@ -1,13 +1,14 @@
 package proton
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/source"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
 )
 
 const ProtonSinkType = "proton"
@@ -19,28 +20,27 @@ const DefaultLogStoreRetentionMS = 3600000 // 1 hour for default
 type ProtonSink struct {
 	client     *Client
 	streamName string
+	// the stream is created by Init, a stream which existed before is kept by Cleanup
+	created bool
 }
 
-func NewProtonSink(properties map[string]interface{}) (sink.Sink, error) {
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	port, err := utils.GetIntWithDefault(properties, "port", 8123)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var sinkProperties = schema.Properties{
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 8123, Description: "port of the server"},
+	{Name: "username", Type: schema.TYPE_STRING, Default: "default", Description: "user name"},
+	{Name: "password", Type: schema.TYPE_STRING, Secret: true, Description: "password of the user"},
+}
 
-	username, err := utils.GetWithDefault(properties, "username", "default")
+func NewProtonSink(properties map[string]interface{}) (sink.Sink, error) {
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	password, err := utils.GetWithDefault(properties, "password", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	host := values.String("host")
+	port := values.Int("port")
+	username := values.String("username")
+	password := values.String("password")
 
 	return &ProtonSink{
 		client: NewClient(host, port, username, password),
@@ -51,7 +51,7 @@ func convertType(sourceType string) string {
 	switch sourceType {
 	case string(source.FIELDTYPE_TIMESTAMP):
 		return "datetime64(3)"
-	case string(source.FIELDTYPE_TIMESTAMP_INT):
+	case string(source.FIELDTYPE_TIMESTAMP_INT), string(source.FIELDTYPE_SEQUENCE):
 		return "int64"
 	case string(source.FIELDTYPE_STRING):
 		return "string"
@@ -70,7 +70,7 @@ func convertType(sourceType string) string {
 	return "string"
 }
 
-func (s *ProtonSink) Init(name string, fields []common.Field) error {
+func (s *ProtonSink) Init(ctx context.Context, name string, fields []common.Field) error {
 	s.streamName = name
 
 	streamDef := StreamDef{
@@ -94,12 +94,38 @@ func (s *ProtonSink) Init(name string, fields []common.Field) error {
 		RetentionMS:    DefaultLogStoreRetentionMS,
 	}
 
-	// s.client.DeleteStream(streamDef.Name)
-	s.client.CreateStream(streamDef, StreamStorageConfig)
+	// the rows are written to the stream when it already exists
+	if err := s.client.CreateStream(streamDef, StreamStorageConfig); err != nil {
+		log.Logger().WithError(err).Warnf("failed to create stream %s", name)
+		return nil
+	}
+	s.created = true
+	return nil
+}
+
+// DropExisting deletes the stream before Init
+func (s *ProtonSink) DropExisting(ctx context.Context, name string) error {
+	return s.client.DeleteStream(name)
+}
+
+// Cleanup deletes the stream created by Init
+func (s *ProtonSink) Cleanup(ctx context.Context) error {
+	if !s.created {
+		return nil
+	}
+	if err := s.client.DeleteStream(s.streamName); err != nil {
+		return err
+	}
+	s.created = false
 	return nil
 }
 
-func (s *ProtonSink) Write(headers []string, rows [][]interface{}, index int) error {
+// Ready pings the rest api of proton
+func (s *ProtonSink) Ready(ctx context.Context) error {
+	return s.client.Ping(ctx)
+}
+
+func (s *ProtonSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
 	log.Logger().Debugf("Write one event to stream %s %v:%v", s.streamName, headers, rows)
 	ingestData := IngestData{
 		Columns: headers,
@@ -112,6 +138,14 @@ func (s *ProtonSink) Write(headers []string, rows [][]interface{}, index int) er
 	return nil
 }
 
+func (s *ProtonSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *ProtonSink) Close() error {
+	return nil
+}
+
 func (s *ProtonSink) GetStats() *sink.Stats {
 	return &sink.Stats{
 		SuccessWrite: 0,
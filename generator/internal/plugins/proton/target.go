@@ -0,0 +1,60 @@
+package proton
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/timeplus-io/chameleon/generator/internal/target"
+	"github.com/timeplus-io/chameleon/generator/internal/utils"
+)
+
+const ProtonTargetType = "proton"
+
+type ProtonTarget struct {
+	engine *Engine
+}
+
+func NewProtonTarget(properties map[string]interface{}) (target.Target, error) {
+	host, err := utils.GetWithDefault(properties, "host", "localhost")
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties : %w", err)
+	}
+
+	username, err := utils.GetWithDefault(properties, "username", "default")
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties : %w", err)
+	}
+
+	password, err := utils.GetWithDefault(properties, "password", "")
+	if err != nil {
+		return nil, fmt.Errorf("invalid properties : %w", err)
+	}
+
+	return &ProtonTarget{
+		engine: NewEngine(NewConfig(host, username, password)),
+	}, nil
+}
+
+func (t *ProtonTarget) Exec(ctx context.Context, statement string) error {
+	return t.engine.ExecContext(ctx, statement)
+}
+
+func (t *ProtonTarget) Query(ctx context.Context, statement string) ([]map[string]interface{}, error) {
+	header, data, err := t.engine.SyncQueryContext(ctx, statement)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]map[string]interface{}, len(data))
+	for index, row := range data {
+		result[index] = make(map[string]interface{}, len(header))
+		for i, column := range header {
+			result[index][column.Name] = row[i]
+		}
+	}
+	return result, nil
+}
+
+func (t *ProtonTarget) Close() error {
+	return t.engine.Close()
+}
@@ -10,16 +10,18 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        ROCKETMQ_SINK_TYPE,
 		Constructor: NewRocketMQSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", ROCKETMQ_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", ROCKETMQ_SINK_TYPE)
 
 	obItem := observer.ObRegItem{
 		Name:        ROCKETMQ_OB_TYPE,
 		Constructor: NewRocketMQObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", ROCKETMQ_OB_TYPE)
+	log.Logger().Debugf("observer plugin %s has been registered", ROCKETMQ_OB_TYPE)
 }
 
 func Init() {
@@ -14,7 +14,7 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 )
 
 const ROCKETMQ_OB_TYPE = "rocketmq"
@@ -24,22 +24,31 @@ type RocketMQObserver struct {
 	topic    string
 
 	metric         string
-	isStopped      bool
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
 
+var observerProperties = schema.Properties{
+	{Name: "brokers", Type: schema.TYPE_STRING, Default: "localhost:9876", Description: "comma separated list of the brokers"},
+	{Name: "topic", Type: schema.TYPE_STRING, Default: "test", Description: "topic to consume"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency"}, Description: "metric to observe"},
+	{Name: "metric_store_address", Type: schema.TYPE_STRING, Default: "http://localhost:8000", Description: "address of the timeplus server where the metrics are stored, no metric is stored when it is not set"},
+	{Name: "metric_store_apikey", Type: schema.TYPE_STRING, Secret: true, Description: "api key of the metric store"},
+	{Name: "metric_store_tenant", Type: schema.TYPE_STRING, Description: "tenant of the metric store"},
+}
+
 func NewRocketMQObserver(properties map[string]interface{}) (observer.Observer, error) {
-	brokers, err := utils.GetWithDefault(properties, "brokers", "localhost:9876")
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
+
+	brokers := values.String("brokers")
 	brokerAddress := strings.Split(brokers, ",")
 
-	topic, err := utils.GetWithDefault(properties, "topic", "test")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	topic := values.String("topic")
 
 	groupName := fmt.Sprintf("my-group-%s", uuid.Must(uuid.NewRandom()).String())
 
@@ -52,29 +61,15 @@ func NewRocketMQObserver(properties map[string]interface{}) (observer.Observer,
 		return nil, err
 	}
 
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	metric := values.String("metric")
 
 	var metricsManager metrics.Metrics
 	if _, ok := properties["metric_store_address"]; !ok {
-		metricsManager = metrics.NewEmptyMetricManager()
+		metricsManager = metrics.NewLocalMetricManager()
 	} else {
-		metricStoreAddress, err := utils.GetWithDefault(properties, "metric_store_address", "http://localhost:8000")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreAPIKey, err := utils.GetWithDefault(properties, "metric_store_apikey", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreTenant, err := utils.GetWithDefault(properties, "metric_store_tenant", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
+		metricStoreAddress := values.String("metric_store_address")
+		metricStoreAPIKey := values.String("metric_store_apikey")
+		metricStoreTenant := values.String("metric_store_tenant")
 
 		metricsManager = metrics.NewTimeplusMetricManager(metricStoreAddress, metricStoreTenant, metricStoreAPIKey)
 	}
@@ -83,7 +78,8 @@ func NewRocketMQObserver(properties map[string]interface{}) (observer.Observer,
 		consumer:       c,
 		topic:          topic,
 		metric:         metric,
-		isStopped:      false,
+		ctx:            context.Background(),
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 	}, nil
@@ -112,7 +108,10 @@ func (o *RocketMQObserver) observeLatency() error {
 		return err
 	}
 
-	time.Sleep(time.Hour)
+	select {
+	case <-time.After(time.Hour):
+	case <-o.ctx.Done():
+	}
 	err = o.consumer.Shutdown()
 	if err != nil {
 		log.Logger().WithError(err).Warnf("failed to shutdown")
@@ -120,7 +119,8 @@ func (o *RocketMQObserver) observeLatency() error {
 	return nil
 }
 
-func (o *RocketMQObserver) Observe() error {
+func (o *RocketMQObserver) Observe(ctx context.Context) error {
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("start observing")
 	if o.metric == "latency" {
 		go o.observeLatency()
@@ -131,7 +131,7 @@ func (o *RocketMQObserver) Observe() error {
 
 func (o *RocketMQObserver) Stop() {
 	log.Logger().Infof("call Kafka stop observing")
-	o.isStopped = true
+	o.cancel()
 	o.obWaiter.Wait()
 	log.Logger().Infof("stop observing")
 	o.metricsManager.Save(ROCKETMQ_OB_TYPE)
@@ -140,3 +140,15 @@ func (o *RocketMQObserver) Stop() {
 func (o *RocketMQObserver) Wait() {
 	o.obWaiter.Wait()
 }
+
+func (o *RocketMQObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *RocketMQObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *RocketMQObserver) Close() error {
+	return nil
+}
@@ -11,8 +11,8 @@ import (
 
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
 )
 
 const ROCKETMQ_SINK_TYPE = "rocketmq"
@@ -22,12 +22,18 @@ type RocketMQSink struct {
 	topic    string
 }
 
+var sinkProperties = schema.Properties{
+	{Name: "brokers", Type: schema.TYPE_STRING, Default: "localhost:9876", Description: "comma separated list of the brokers"},
+}
+
 func NewRocketMQSink(properties map[string]interface{}) (sink.Sink, error) {
-	brokers, err := utils.GetWithDefault(properties, "brokers", "localhost:9876")
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
+	brokers := values.String("brokers")
+
 	brokerAddress := strings.Split(brokers, ",")
 
 	p, err := rocketmq.NewProducer(
@@ -42,13 +48,12 @@ func NewRocketMQSink(properties map[string]interface{}) (sink.Sink, error) {
 	return &RocketMQSink{producer: p}, nil
 }
 
-func (s *RocketMQSink) Init(name string, fields []common.Field) error {
-	//TODO: there is no shut down for sink interface for now
+func (s *RocketMQSink) Init(ctx context.Context, name string, fields []common.Field) error {
 	s.topic = name
 	return s.producer.Start()
 }
 
-func (s *RocketMQSink) Write(headers []string, rows [][]interface{}, index int) error {
+func (s *RocketMQSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
 	events := common.ToEvents(headers, rows)
 	errs := make([]error, 0)
 	for _, event := range events {
@@ -57,7 +62,7 @@ func (s *RocketMQSink) Write(headers []string, rows [][]interface{}, index int)
 			Body:  []byte(event.String()),
 		}
 
-		_, err := s.producer.SendSync(context.Background(), msg)
+		_, err := s.producer.SendSync(ctx, msg)
 		if err != nil {
 			errs = append(errs, err)
 		}
@@ -70,6 +75,15 @@ func (s *RocketMQSink) Write(headers []string, rows [][]interface{}, index int)
 	return nil
 }
 
+// Flush does nothing, messages are sent synchronously
+func (s *RocketMQSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *RocketMQSink) Close() error {
+	return s.producer.Shutdown()
+}
+
 func (s *RocketMQSink) GetStats() *sink.Stats {
 	return &sink.Stats{
 		SuccessWrite: 0,
@@ -10,16 +10,18 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        SPLUNK_SINK_TYPE,
 		Constructor: NewSplunkSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", SPLUNK_SINK_TYPE)
+	log.Logger().Debugf("sink plugin %s has been registered", SPLUNK_SINK_TYPE)
 
 	obItem := observer.ObRegItem{
 		Name:        SPLUNK_OB_TYPE,
 		Constructor: NewSplunkObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", SPLUNK_OB_TYPE)
+	log.Logger().Debugf("observer plugin %s has been registered", SPLUNK_OB_TYPE)
 }
 
 func Init() {
@@ -3,6 +3,7 @@ package splunk
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -13,9 +14,11 @@ import (
 	"time"
 
 	"github.com/reactivex/rxgo/v2"
+	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/utils"
 )
 
@@ -31,7 +34,9 @@ type SplunkObserver struct {
 	metric         string
 	timeFormat     string
 	timeField      string
-	isStopped      bool
+	timeCodec      *common.TimestampCodec
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
@@ -45,65 +50,51 @@ type SplunkEvents struct {
 	Lastrow bool         `json:"lastrow,omitempty"`
 }
 
-func NewSplunkObserver(properties map[string]interface{}) (observer.Observer, error) {
-	search, err := utils.GetWithDefault(properties, "search", "search *")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	host, err := utils.GetWithDefault(properties, "host", "localhost")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	port, err := utils.GetIntWithDefault(properties, "host", 8089)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	username, err := utils.GetWithDefault(properties, "username", "admin")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	password, err := utils.GetWithDefault(properties, "password", "Password!")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var observerProperties = schema.Properties{
+	{Name: "search", Type: schema.TYPE_STRING, Default: "search *", Description: "search which reads the events written by the job"},
+	{Name: "host", Type: schema.TYPE_STRING, Default: "localhost", Description: "host of the server"},
+	{Name: "port", Type: schema.TYPE_INTEGER, Default: 8089, Description: "management port of the server"},
+	{Name: "username", Type: schema.TYPE_STRING, Default: "admin", Description: "user name"},
+	{Name: "password", Type: schema.TYPE_STRING, Default: "Password!", Secret: true, Description: "password of the user"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency", "throughput", "availability"}, Description: "metric to observe"},
+	{Name: "time_format", Type: schema.TYPE_STRING, Default: "2006-01-02 15:04:05.000000", Description: "go layout of the event time"},
+	{Name: "time_field", Type: schema.TYPE_STRING, Default: "time", Description: "field which holds the event time"},
+	{Name: "time_encoding", Type: schema.TYPE_STRING, Default: "layout", Description: "encoding of the event time"},
+	{Name: "time_locale", Type: schema.TYPE_STRING, Description: "time zone of the event time when the layout has none"},
+	{Name: "metric_store_address", Type: schema.TYPE_STRING, Default: "http://localhost:8000", Description: "address of the timeplus server where the metrics are stored, no metric is stored when it is not set"},
+	{Name: "metric_store_apikey", Type: schema.TYPE_STRING, Secret: true, Description: "api key of the metric store"},
+	{Name: "metric_store_tenant", Type: schema.TYPE_STRING, Description: "tenant of the metric store"},
+}
 
-	timeFormat, err := utils.GetWithDefault(properties, "time_format", "2006-01-02 15:04:05.000000")
+func NewSplunkObserver(properties map[string]interface{}) (observer.Observer, error) {
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	timeField, err := utils.GetWithDefault(properties, "time_field", "time")
+	search := values.String("search")
+	host := values.String("host")
+	port := values.Int("port")
+	username := values.String("username")
+	password := values.String("password")
+	metric := values.String("metric")
+	timeFormat := values.String("time_format")
+	timeField := values.String("time_field")
+	timeEncoding := values.String("time_encoding")
+	timeLocale := values.String("time_locale")
+
+	timeCodec, err := common.NewTimestampCodec(common.TimestampEncoding(timeEncoding), timeFormat, timeLocale, "")
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
 	var metricsManager metrics.Metrics
 	if _, ok := properties["metric_store_address"]; !ok {
-		metricsManager = metrics.NewEmptyMetricManager()
+		metricsManager = metrics.NewLocalMetricManager()
 	} else {
-		metricStoreAddress, err := utils.GetWithDefault(properties, "metric_store_address", "http://localhost:8000")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreAPIKey, err := utils.GetWithDefault(properties, "metric_store_apikey", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
-
-		metricStoreTenant, err := utils.GetWithDefault(properties, "metric_store_tenant", "")
-		if err != nil {
-			return nil, fmt.Errorf("invalid properties : %w", err)
-		}
+		metricStoreAddress := values.String("metric_store_address")
+		metricStoreAPIKey := values.String("metric_store_apikey")
+		metricStoreTenant := values.String("metric_store_tenant")
 
 		metricsManager = metrics.NewTimeplusMetricManager(metricStoreAddress, metricStoreTenant, metricStoreAPIKey)
 	}
@@ -121,7 +112,9 @@ func NewSplunkObserver(properties map[string]interface{}) (observer.Observer, er
 		metric:         metric,
 		timeFormat:     timeFormat,
 		timeField:      timeField,
-		isStopped:      false,
+		timeCodec:      timeCodec,
+		ctx:            context.Background(),
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 	}, nil
@@ -149,7 +142,7 @@ func (o *SplunkObserver) observeLatency() error {
 
 	o.obWaiter.Add(1)
 	for item := range stream.Observe() {
-		if o.isStopped {
+		if o.ctx.Err() != nil {
 			log.Logger().Infof("stop splunk observing")
 			break
 		}
@@ -159,9 +152,9 @@ func (o *SplunkObserver) observeLatency() error {
 		json.NewDecoder(bytes.NewBuffer([]byte(raw.(string)))).Decode(&rawEvent)
 		log.Logger().Debugf("get one search result raw : %v ", rawEvent)
 
-		eventTime := rawEvent[o.timeField].(string)
-		t, err := time.Parse(o.timeFormat, eventTime)
+		t, err := o.timeCodec.Decode(rawEvent[o.timeField])
 		if err != nil {
+			log.Logger().Debugf("failed to parse time field, %s", err)
 			continue
 		}
 		log.Logger().Infof("observe latency %v", time.Until(t))
@@ -196,7 +189,7 @@ func (o *SplunkObserver) observeThroughput() error {
 
 	o.obWaiter.Add(1)
 	for item := range stream.Observe() {
-		if o.isStopped {
+		if o.ctx.Err() != nil {
 			log.Logger().Infof("stop splunk observing")
 			break
 		}
@@ -236,7 +229,7 @@ func (o *SplunkObserver) observeAvailability() error {
 
 	o.obWaiter.Add(1)
 	for item := range stream.Observe() {
-		if o.isStopped {
+		if o.ctx.Err() != nil {
 			log.Logger().Infof("stop splunk availability observing")
 			break
 		}
@@ -252,7 +245,8 @@ func (o *SplunkObserver) observeAvailability() error {
 	return nil
 }
 
-func (o *SplunkObserver) Observe() error {
+func (o *SplunkObserver) Observe(ctx context.Context) error {
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("start observing")
 	if o.metric == "latency" {
 		go o.observeLatency()
@@ -271,7 +265,7 @@ func (o *SplunkObserver) Observe() error {
 
 func (o *SplunkObserver) Stop() {
 	log.Logger().Infof("call splunk stop observing")
-	o.isStopped = true
+	o.cancel()
 	o.obWaiter.Wait()
 	log.Logger().Infof("stop observing")
 	o.metricsManager.Save("splunk")
@@ -281,6 +275,19 @@ func (o *SplunkObserver) Wait() {
 	o.obWaiter.Wait()
 }
 
+func (o *SplunkObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *SplunkObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *SplunkObserver) Close() error {
+	o.client.CloseIdleConnections()
+	return nil
+}
+
 func HttpRequestStreamWithUser(method string, url string, payload *url.Values, client *http.Client, username string, password string) (rxgo.Observable, error) {
 	// note: this is specific for splunk search
 	var body io.Reader
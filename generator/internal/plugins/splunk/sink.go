@@ -2,19 +2,28 @@ package splunk
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/utils"
 )
 
 const SPLUNK_SINK_TYPE = "splunk"
 
+// HEC_SERVER_BUSY is the code returned by the HTTP event collector when it cannot accept events for now
+const HEC_SERVER_BUSY = 9
+
+// HEC_HEALTH_PATH answers 200 once the HTTP event collector accepts events
+const HEC_HEALTH_PATH = "/services/collector/health"
+
 type SplunkSink struct {
 	client     []*http.Client
 	hecAddress string
@@ -24,31 +33,25 @@ type SplunkSink struct {
 	index      string
 }
 
-func NewSplunkSink(properties map[string]interface{}) (sink.Sink, error) {
-	hecAddress, err := utils.GetWithDefault(properties, "hec_address", "https://localhost:8088/services/collector/event")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	hecToken, err := utils.GetWithDefault(properties, "hec_token", "abcd1234")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	source, err := utils.GetWithDefault(properties, "source", "my_source")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var sinkProperties = schema.Properties{
+	{Name: "hec_address", Type: schema.TYPE_STRING, Default: "https://localhost:8088/services/collector/event", Description: "address of the http event collector"},
+	{Name: "hec_token", Type: schema.TYPE_STRING, Default: "abcd1234", Secret: true, Description: "token of the http event collector"},
+	{Name: "source", Type: schema.TYPE_STRING, Default: "my_source", Description: "source of the events"},
+	{Name: "sourcetype", Type: schema.TYPE_STRING, Default: "my_source_type", Description: "source type of the events"},
+	{Name: "index", Type: schema.TYPE_STRING, Default: "main", Description: "index of the events"},
+}
 
-	sourcetype, err := utils.GetWithDefault(properties, "sourcetype", "my_source_type")
+func NewSplunkSink(properties map[string]interface{}) (sink.Sink, error) {
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	index, err := utils.GetWithDefault(properties, "index", "main")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	hecAddress := values.String("hec_address")
+	hecToken := values.String("hec_token")
+	source := values.String("source")
+	sourcetype := values.String("sourcetype")
+	index := values.String("index")
 
 	clients := make([]*http.Client, 64)
 	for i := 0; i < len(clients); i++ {
@@ -65,11 +68,35 @@ func NewSplunkSink(properties map[string]interface{}) (sink.Sink, error) {
 	}, nil
 }
 
-func (s *SplunkSink) Init(name string, fields []common.Field) error {
+func (s *SplunkSink) Init(ctx context.Context, name string, fields []common.Field) error {
 	return nil
 }
 
-func (s *SplunkSink) Write(headers []string, rows [][]interface{}, index int) error {
+// Ready calls the health endpoint of the HTTP event collector
+func (s *SplunkSink) Ready(ctx context.Context) error {
+	healthUrl, err := url.Parse(s.hecAddress)
+	if err != nil {
+		return err
+	}
+	healthUrl.Path = HEC_HEALTH_PATH
+	healthUrl.RawQuery = ""
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthUrl.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.client[0].Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http event collector is not healthy, status %s", resp.Status)
+	}
+	return nil
+}
+
+func (s *SplunkSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
 	client := s.client[index]
 	events := s.ToSplunkEvents(common.ToEvents(headers, rows))
 	log.Logger().Debugf("Write one event to splunk %v", events)
@@ -83,11 +110,17 @@ func (s *SplunkSink) Write(headers []string, rows [][]interface{}, index int) er
 	var queryResult map[string]interface{}
 	json.NewDecoder(bytes.NewBuffer(respBody)).Decode(&queryResult)
 	log.Logger().Debugf("the insert result is %d:%v", index, queryResult)
-	if queryResult["code"].(float64) != 0.0 {
-		log.Logger().Errorf("failed to insert data %v", queryResult)
+	code, ok := queryResult["code"].(float64)
+	if !ok || code == 0.0 {
+		return nil
 	}
 
-	return nil
+	err = fmt.Errorf("failed to insert data %v", queryResult)
+	// code 9 means the server is busy, the other codes are caused by the request
+	if code != HEC_SERVER_BUSY {
+		return sink.Permanent(err)
+	}
+	return err
 }
 
 func (s *SplunkSink) ToSplunkEvents(events []common.Event) []map[string]interface{} {
@@ -103,6 +136,17 @@ func (s *SplunkSink) ToSplunkEvents(events []common.Event) []map[string]interfac
 	return result
 }
 
+func (s *SplunkSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *SplunkSink) Close() error {
+	for _, client := range s.client {
+		client.CloseIdleConnections()
+	}
+	return nil
+}
+
 func (s *SplunkSink) GetStats() *sink.Stats {
 	return &sink.Stats{
 		SuccessWrite: 0,
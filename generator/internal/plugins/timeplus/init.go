@@ -10,16 +10,18 @@ func init() {
 	sinkItem := sink.SinkRegItem{
 		Name:        TimeplusSinkType,
 		Constructor: NewTimeplusSink,
+		Properties:  sinkProperties,
 	}
 	sink.Register(sinkItem)
-	log.Logger().Infof("sink plugin %s has been registered", TimeplusSinkType)
+	log.Logger().Debugf("sink plugin %s has been registered", TimeplusSinkType)
 
 	obItem := observer.ObRegItem{
 		Name:        TimeplusOBType,
 		Constructor: NewTimeplusObserver,
+		Properties:  observerProperties,
 	}
 	observer.Register(obItem)
-	log.Logger().Infof("observer plugin %s has been registered", TimeplusOBType)
+	log.Logger().Debugf("observer plugin %s has been registered", TimeplusOBType)
 }
 
 func Init() {
@@ -1,6 +1,7 @@
 package timeplus
 
 import (
+	"context"
 	"fmt"
 	"sync"
 	"time"
@@ -8,11 +9,12 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 
 	"github.com/timeplus-io/go-client/timeplus"
 
 	"github.com/google/uuid"
+	"github.com/reactivex/rxgo/v2"
 )
 
 const TimeplusOBType = "timeplus"
@@ -29,59 +31,42 @@ type TimeplusObserver struct {
 
 	querySet []interface{}
 
-	isStopped      bool
-	cancel         func()
+	ctx            context.Context
+	cancel         context.CancelFunc
 	obWaiter       sync.WaitGroup
 	metricsManager metrics.Metrics
 }
 
-func NewTimeplusObserver(properties map[string]interface{}) (observer.Observer, error) {
-	address, err := utils.GetWithDefault(properties, "address", "http://localhost:8000")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	apikey, err := utils.GetWithDefault(properties, "apikey", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	tenant, err := utils.GetWithDefault(properties, "tenant", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	query, err := utils.GetWithDefault(properties, "query", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	timeColumn, err := utils.GetWithDefault(properties, "time_column", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	timeFormat, err := utils.GetWithDefault(properties, "time_format", "2006-01-02T15:04:05.000Z")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	metric, err := utils.GetWithDefault(properties, "metric", "latency")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var observerProperties = schema.Properties{
+	{Name: "address", Type: schema.TYPE_STRING, Default: "http://localhost:8000", Description: "address of the timeplus server"},
+	{Name: "apikey", Type: schema.TYPE_STRING, Secret: true, Description: "api key"},
+	{Name: "tenant", Type: schema.TYPE_STRING, Description: "tenant of the workspace"},
+	{Name: "query", Type: schema.TYPE_STRING, Description: "query which reads the events written by the job"},
+	{Name: "time_column", Type: schema.TYPE_STRING, Description: "column which holds the event time"},
+	{Name: "time_format", Type: schema.TYPE_STRING, Default: "2006-01-02T15:04:05.000Z", Description: "go layout of the event time"},
+	{Name: "metric", Type: schema.TYPE_STRING, Default: "latency", Enum: []string{"latency", "throughput", "availability", "queries"}, Description: "metric to observe"},
+	{Name: "buffer_count", Type: schema.TYPE_INTEGER, Default: 100, Description: "number of observations buffered before they are recorded"},
+	{Name: "buffer_time", Type: schema.TYPE_INTEGER, Default: 128, Description: "milliseconds observations are buffered before they are recorded"},
+	{Name: "querys", Type: schema.TYPE_ARRAY, Description: "queries run by the queries metric"},
+}
 
-	bufferCount, err := utils.GetIntWithDefault(properties, "buffer_count", 100)
+func NewTimeplusObserver(properties map[string]interface{}) (observer.Observer, error) {
+	values, err := observerProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	bufferTime, err := utils.GetIntWithDefault(properties, "buffer_time", 128)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	address := values.String("address")
+	apikey := values.String("apikey")
+	tenant := values.String("tenant")
+	query := values.String("query")
+	timeColumn := values.String("time_column")
+	timeFormat := values.String("time_format")
+	metric := values.String("metric")
+	bufferCount := values.Int("buffer_count")
+	bufferTime := values.Int("buffer_time")
 
-	metricsManager := metrics.NewCSVMetricManager()
+	metricsManager := metrics.NewLocalMetricManager()
 
 	ob := &TimeplusObserver{
 		server:         timeplus.NewCient(address, tenant, apikey),
@@ -89,18 +74,15 @@ func NewTimeplusObserver(properties map[string]interface{}) (observer.Observer,
 		timeColumn:     timeColumn,
 		timeFormat:     timeFormat,
 		metric:         metric,
-		querySet:       nil,
-		isStopped:      false,
+		querySet:       values.List("querys"),
+		ctx:            context.Background(),
+		cancel:         func() {},
 		obWaiter:       sync.WaitGroup{},
 		metricsManager: metricsManager,
 		bufferCount:    bufferCount,
 		bufferTime:     bufferTime,
 	}
 
-	if value, ok := properties["querys"]; ok {
-		ob.querySet = value.([]interface{})
-	}
-
 	return ob, nil
 }
 
@@ -137,8 +119,7 @@ func (o *TimeplusObserver) observeLatency() error {
 		log.Logger().Debugf("query %s closed", o.query)
 	})
 
-	o.cancel = cancel
-	<-disposed
+	o.waitQuery(disposed, cancel)
 	log.Logger().Infof("stop observing latecny")
 	o.obWaiter.Done()
 	return nil
@@ -168,8 +149,7 @@ func (o *TimeplusObserver) observeThroughput() error {
 		log.Logger().Debugf("query %s closed", o.query)
 	})
 
-	o.cancel = cancel
-	<-disposed
+	o.waitQuery(disposed, cancel)
 	log.Logger().Infof("stop observing throughput")
 	o.obWaiter.Done()
 	return nil
@@ -202,8 +182,7 @@ func (o *TimeplusObserver) observeAvailability() error {
 		log.Logger().Debugf("query %s closed", o.query)
 	})
 
-	o.cancel = cancel
-	<-disposed
+	o.waitQuery(disposed, cancel)
 	log.Logger().Infof("stop observing availability")
 
 	return nil
@@ -262,14 +241,23 @@ func (o *TimeplusObserver) runQuery(sql string) error {
 		log.Logger().Infof("query %s closed", id)
 	})
 
-	o.cancel = cancel
-	<-disposed
+	o.waitQuery(disposed, cancel)
 
 	time.Sleep(100 * time.Millisecond)
 	o.metricsManager.Flush()
 	return nil
 }
 
+// waitQuery waits until the query stream is disposed, the query is cancelled when observing stops
+func (o *TimeplusObserver) waitQuery(disposed rxgo.Disposed, cancel func()) {
+	select {
+	case <-disposed:
+	case <-o.ctx.Done():
+		cancel()
+		<-disposed
+	}
+}
+
 func (o *TimeplusObserver) observeQueries() error {
 	metricsName := "query"
 	log.Logger().Info("start observing queries")
@@ -287,7 +275,8 @@ func (o *TimeplusObserver) observeQueries() error {
 	return nil
 }
 
-func (o *TimeplusObserver) Observe() error {
+func (o *TimeplusObserver) Observe(ctx context.Context) error {
+	o.ctx, o.cancel = context.WithCancel(ctx)
 	log.Logger().Infof("TimeplusObserver start observing")
 
 	if o.metric == "latency" {
@@ -304,8 +293,6 @@ func (o *TimeplusObserver) Observe() error {
 
 func (o *TimeplusObserver) Stop() {
 	log.Logger().Infof("call timeplus stop observing")
-	o.isStopped = true
-	log.Logger().Infof("set stopped")
 	o.cancel()
 	o.obWaiter.Wait()
 	log.Logger().Infof("stop observing")
@@ -315,3 +302,15 @@ func (o *TimeplusObserver) Stop() {
 func (o *TimeplusObserver) Wait() {
 	o.obWaiter.Wait()
 }
+
+func (o *TimeplusObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *TimeplusObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+func (o *TimeplusObserver) Close() error {
+	return nil
+}
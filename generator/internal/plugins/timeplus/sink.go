@@ -1,13 +1,14 @@
 package timeplus
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 	"github.com/timeplus-io/chameleon/generator/internal/sink"
 	"github.com/timeplus-io/chameleon/generator/internal/source"
-	"github.com/timeplus-io/chameleon/generator/internal/utils"
 
 	"github.com/timeplus-io/go-client/timeplus"
 	timeplusUtils "github.com/timeplus-io/go-client/utils"
@@ -22,48 +23,34 @@ const DefaultLogStoreRetentionMS = 1342177280
 type TimeplusSink struct {
 	server     *timeplus.TimeplusClient
 	streamName string
+	created    bool
 }
 
-func NewTimeplusSink(properties map[string]interface{}) (sink.Sink, error) {
-	address, err := utils.GetWithDefault(properties, "address", "http://localhost:8000")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	apikey, err := utils.GetWithDefault(properties, "apikey", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	tenant, err := utils.GetWithDefault(properties, "tenant", "")
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	insecureSkipVerify, err := utils.GetBoolWithDefault(properties, "insecureSkipVerify", true)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	maxIdleConns, err := utils.GetIntWithDefault(properties, "maxIdleConns", 100)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
-
-	maxConnsPerHost, err := utils.GetIntWithDefault(properties, "maxIdleConns", 100)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+var sinkProperties = schema.Properties{
+	{Name: "address", Type: schema.TYPE_STRING, Default: "http://localhost:8000", Description: "address of the timeplus server"},
+	{Name: "apikey", Type: schema.TYPE_STRING, Secret: true, Description: "api key"},
+	{Name: "tenant", Type: schema.TYPE_STRING, Description: "tenant of the workspace"},
+	{Name: "insecureSkipVerify", Type: schema.TYPE_BOOLEAN, Default: true, Description: "skip the verification of the server certificate"},
+	{Name: "maxIdleConns", Type: schema.TYPE_INTEGER, Default: 100, Description: "maximum number of idle http connections"},
+	{Name: "maxConnsPerHost", Type: schema.TYPE_INTEGER, Default: 100, Description: "maximum number of http connections"},
+	{Name: "maxIdleConnsPerHost", Type: schema.TYPE_INTEGER, Default: 100, Description: "maximum number of idle http connections"},
+	{Name: "http_timeout", Type: schema.TYPE_INTEGER, Default: 10, Description: "seconds before a http request times out"},
+}
 
-	maxIdleConnsPerHost, err := utils.GetIntWithDefault(properties, "maxIdleConnsPerHost", 100)
+func NewTimeplusSink(properties map[string]interface{}) (sink.Sink, error) {
+	values, err := sinkProperties.Parse(properties)
 	if err != nil {
 		return nil, fmt.Errorf("invalid properties : %w", err)
 	}
 
-	timeout, err := utils.GetIntWithDefault(properties, "http_timeout", 10)
-	if err != nil {
-		return nil, fmt.Errorf("invalid properties : %w", err)
-	}
+	address := values.String("address")
+	apikey := values.String("apikey")
+	tenant := values.String("tenant")
+	insecureSkipVerify := values.Bool("insecureSkipVerify")
+	maxIdleConns := values.Int("maxIdleConns")
+	maxConnsPerHost := values.Int("maxConnsPerHost")
+	maxIdleConnsPerHost := values.Int("maxIdleConnsPerHost")
+	timeout := values.Int("http_timeout")
 
 	config := timeplusUtils.NewHTTPClientConfig(insecureSkipVerify, maxIdleConns, maxConnsPerHost, maxIdleConnsPerHost, timeout)
 	return &TimeplusSink{
@@ -75,7 +62,7 @@ func convertType(sourceType string) string {
 	switch sourceType {
 	case string(source.FIELDTYPE_TIMESTAMP):
 		return "datetime64(3)"
-	case string(source.FIELDTYPE_TIMESTAMP_INT):
+	case string(source.FIELDTYPE_TIMESTAMP_INT), string(source.FIELDTYPE_SEQUENCE):
 		return "int64"
 	case string(source.FIELDTYPE_STRING):
 		return "string"
@@ -94,7 +81,7 @@ func convertType(sourceType string) string {
 	return "string"
 }
 
-func (s *TimeplusSink) Init(name string, fields []common.Field) error {
+func (s *TimeplusSink) Init(ctx context.Context, name string, fields []common.Field) error {
 	s.streamName = name
 
 	streamDef := timeplus.StreamDef{
@@ -116,10 +103,31 @@ func (s *TimeplusSink) Init(name string, fields []common.Field) error {
 	}
 
 	s.server.DeleteStream(streamDef.Name)
-	return s.server.CreateStream(streamDef)
+	if err := s.server.CreateStream(streamDef); err != nil {
+		return err
+	}
+	s.created = true
+	return nil
 }
 
-func (s *TimeplusSink) Write(headers []string, rows [][]interface{}, index int) error {
+// DropExisting does nothing, Init always drops the existing stream before creating it
+func (s *TimeplusSink) DropExisting(ctx context.Context, name string) error {
+	return nil
+}
+
+// Cleanup deletes the stream created by Init
+func (s *TimeplusSink) Cleanup(ctx context.Context) error {
+	if !s.created {
+		return nil
+	}
+	if err := s.server.DeleteStream(s.streamName); err != nil {
+		return err
+	}
+	s.created = false
+	return nil
+}
+
+func (s *TimeplusSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
 	log.Logger().Debugf("Write one event to stream %s %v:%v", s.streamName, headers, rows)
 	ingestData := &timeplus.IngestPayload{
 		Stream: s.streamName,
@@ -132,6 +140,14 @@ func (s *TimeplusSink) Write(headers []string, rows [][]interface{}, index int)
 	return s.server.InsertData(ingestData)
 }
 
+func (s *TimeplusSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *TimeplusSink) Close() error {
+	return nil
+}
+
 func (s *TimeplusSink) GetStats() *sink.Stats {
 	return &sink.Stats{
 		SuccessWrite: 0,
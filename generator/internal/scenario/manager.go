@@ -0,0 +1,55 @@
+package scenario
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+type ScenarioManager struct {
+	runs sync.Map
+}
+
+func NewScenarioManager() *ScenarioManager {
+	return &ScenarioManager{
+		runs: sync.Map{},
+	}
+}
+
+// RunScenario validates the scenario and runs it in background
+func (m *ScenarioManager) RunScenario(config Configuration) (*ScenarioRun, error) {
+	run, err := NewScenarioRun(config)
+	if err != nil {
+		return nil, err
+	}
+
+	m.runs.Store(run.Id, run)
+	go run.Run(context.Background())
+	return run, nil
+}
+
+func (m *ScenarioManager) ListScenario() []*ScenarioRun {
+	result := make([]*ScenarioRun, 0)
+	m.runs.Range(func(key, value interface{}) bool {
+		result = append(result, value.(*ScenarioRun))
+		return true
+	})
+	return result
+}
+
+func (m *ScenarioManager) GetScenario(id string) (*ScenarioRun, error) {
+	run, ok := m.runs.Load(id)
+	if !ok {
+		return nil, fmt.Errorf("%s scenario does not exist", id)
+	}
+	return run.(*ScenarioRun), nil
+}
+
+func (m *ScenarioManager) StopScenario(id string) error {
+	run, err := m.GetScenario(id)
+	if err != nil {
+		return err
+	}
+	run.Stop()
+	return nil
+}
@@ -0,0 +1,331 @@
+package scenario
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+	"github.com/timeplus-io/chameleon/generator/internal/target"
+)
+
+// ScenarioRun is one execution of a scenario, stages run in order and a failed stage skips the following
+// stages except the ones marked as always
+type ScenarioRun struct {
+	Id         string            `json:"id"`
+	Name       string            `json:"name"`
+	Status     Status            `json:"status"`
+	Config     Configuration     `json:"config"`
+	Variables  map[string]string `json:"variables"`
+	Stages     []*StageResult    `json:"stages"`
+	StartedAt  *time.Time        `json:"started_at,omitempty"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	lock   sync.Mutex
+}
+
+func NewScenarioRun(config Configuration) (*ScenarioRun, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	id := uuid.New().String()
+	variables := make(map[string]string, len(config.Variables)+2)
+	for key, value := range config.Variables {
+		variables[key] = value
+	}
+	variables["scenario.id"] = id
+	variables["scenario.name"] = config.Name
+
+	return &ScenarioRun{
+		Id:        id,
+		Name:      config.Name,
+		Status:    STATUS_PENDING,
+		Config:    config,
+		Variables: variables,
+		Stages:    newStageResults(config.Stages),
+		cancel:    func() {},
+		done:      make(chan struct{}),
+	}, nil
+}
+
+// Run runs all stages and returns the result of the scenario
+func (r *ScenarioRun) Run(ctx context.Context) Status {
+	defer close(r.done)
+
+	var cancel context.CancelFunc
+	if r.Config.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(r.Config.Timeout)*time.Second)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	now := time.Now().UTC()
+	r.lock.Lock()
+	r.cancel = cancel
+	r.Status = STATUS_RUNNING
+	r.StartedAt = &now
+	r.lock.Unlock()
+
+	failed := false
+	for index, stage := range r.Config.Stages {
+		result := r.Stages[index]
+		stageCtx := ctx
+		if stage.Always {
+			// teardown stages still run when the scenario is stopped or timed out
+			stageCtx = context.Background()
+		} else if failed || ctx.Err() != nil {
+			r.finish(result, STATUS_SKIPPED, nil)
+			continue
+		}
+
+		if err := r.runStage(stageCtx, stage, result); err != nil && !stage.ContinueOnError {
+			failed = true
+		}
+	}
+
+	status := STATUS_PASSED
+	if failed || ctx.Err() != nil {
+		status = STATUS_FAILED
+	}
+
+	finishedAt := time.Now().UTC()
+	r.lock.Lock()
+	r.Status = status
+	r.FinishedAt = &finishedAt
+	r.lock.Unlock()
+
+	log.Logger().Infof("scenario %s %s", r.Name, status)
+	return status
+}
+
+// Stop cancels the stages in progress, the stages marked as always still run
+func (r *ScenarioRun) Stop() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cancel()
+}
+
+// MarshalJSON returns the run with the secrets of its configuration redacted
+func (r *ScenarioRun) MarshalJSON() ([]byte, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	type plainRun ScenarioRun
+	return json.Marshal(struct {
+		*plainRun
+		Config Configuration `json:"config"`
+	}{
+		plainRun: (*plainRun)(r),
+		Config:   r.Config.Redacted(),
+	})
+}
+
+func (r *ScenarioRun) Wait() {
+	<-r.done
+}
+
+func (r *ScenarioRun) variables() map[string]string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	result := make(map[string]string, len(r.Variables))
+	for key, value := range r.Variables {
+		result[key] = value
+	}
+	return result
+}
+
+func (r *ScenarioRun) setVariable(name string, value interface{}) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.Variables[name] = fmt.Sprintf("%v", value)
+}
+
+func (r *ScenarioRun) finish(result *StageResult, status Status, err error) {
+	now := time.Now().UTC()
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	result.Status = status
+	result.FinishedAt = &now
+	if err != nil {
+		result.Error = err.Error()
+	}
+}
+
+func (r *ScenarioRun) runStage(ctx context.Context, stage Stage, result *StageResult) error {
+	log.Logger().Infof("run stage %s of scenario %s", stage.Name, r.Name)
+	now := time.Now().UTC()
+	r.lock.Lock()
+	result.Status = STATUS_RUNNING
+	result.StartedAt = &now
+	r.lock.Unlock()
+
+	if stage.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(stage.Timeout)*time.Second)
+		defer cancel()
+	}
+
+	var err error
+	switch stage.Type {
+	case STAGE_JOB:
+		err = r.runJob(ctx, stage, result)
+	case STAGE_SQL:
+		err = r.runSQL(ctx, stage)
+	case STAGE_WAIT:
+		err = r.runWait(ctx, stage)
+	case STAGE_SLEEP:
+		err = sleep(ctx, time.Duration(stage.Duration)*time.Millisecond)
+	case STAGE_PARALLEL:
+		err = r.runParallel(ctx, stage, result)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) {
+		err = fmt.Errorf("stage %s timed out", stage.Name)
+	}
+
+	if err != nil {
+		log.Logger().WithError(err).Warnf("stage %s of scenario %s failed", stage.Name, r.Name)
+		r.finish(result, STATUS_FAILED, err)
+		return err
+	}
+
+	r.finish(result, STATUS_PASSED, nil)
+	return nil
+}
+
+func (r *ScenarioRun) runJob(ctx context.Context, stage Stage, result *StageResult) error {
+	config, err := expandJob(*stage.Job, r.variables())
+	if err != nil {
+		return err
+	}
+	if config.Name == "" {
+		config.Name = stage.Name
+	}
+
+	run, err := job.RunJob(ctx, config)
+	if run != nil {
+		r.lock.Lock()
+		result.Run = run
+		r.lock.Unlock()
+
+		r.setVariable(stage.Name+".run_id", run.Id)
+		r.setVariable(stage.Name+".success_write", run.Stats.SuccessWrite)
+		r.setVariable(stage.Name+".failed_write", run.Stats.FailedWrite)
+	}
+	return err
+}
+
+func (r *ScenarioRun) createTarget(stage Stage, variables map[string]string) (target.Target, error) {
+	return target.CreateTarget(expandTarget(*stage.Target, variables))
+}
+
+func (r *ScenarioRun) runSQL(ctx context.Context, stage Stage) error {
+	variables := r.variables()
+	t, err := r.createTarget(stage, variables)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	for index, statement := range stage.Statements {
+		statement = expand(statement, variables)
+		if stage.Register == "" || index < len(stage.Statements)-1 {
+			if err := t.Exec(ctx, statement); err != nil {
+				return fmt.Errorf("failed to run %s : %w", statement, err)
+			}
+			continue
+		}
+
+		rows, err := t.Query(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("failed to run %s : %w", statement, err)
+		}
+		if len(rows) == 0 {
+			return fmt.Errorf("%s returns no row", statement)
+		}
+		for column, value := range rows[0] {
+			r.setVariable(stage.Register+"."+column, value)
+		}
+	}
+	return nil
+}
+
+func (r *ScenarioRun) runWait(ctx context.Context, stage Stage) error {
+	variables := r.variables()
+	t, err := r.createTarget(stage, variables)
+	if err != nil {
+		return err
+	}
+	defer t.Close()
+
+	interval := stage.Interval
+	if interval == 0 {
+		interval = DefaultWaitInterval
+	}
+
+	query := expand(stage.Query, variables)
+	for {
+		rows, err := t.Query(ctx, query)
+		if err == nil && len(rows) > 0 {
+			return nil
+		}
+		if err != nil {
+			// the condition may refer to something not created yet, keep polling
+			log.Logger().Debugf("wait query %s failed : %s", query, err)
+		}
+
+		if err := sleep(ctx, time.Duration(interval)*time.Millisecond); err != nil {
+			return err
+		}
+	}
+}
+
+func (r *ScenarioRun) runParallel(ctx context.Context, stage Stage, result *StageResult) error {
+	waiter := sync.WaitGroup{}
+	errs := make([]error, len(stage.Stages))
+	for index, child := range stage.Stages {
+		waiter.Add(1)
+		go func(index int, child Stage) {
+			defer waiter.Done()
+			if err := r.runStage(ctx, child, result.Stages[index]); err != nil && !child.ContinueOnError {
+				errs[index] = err
+			}
+		}(index, child)
+	}
+	waiter.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d stages failed", failed, len(stage.Stages))
+	}
+	return nil
+}
+
+func sleep(ctx context.Context, duration time.Duration) error {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,330 @@
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/target"
+)
+
+type StageType string
+
+const (
+	STAGE_JOB      StageType = "job"
+	STAGE_SQL      StageType = "sql"
+	STAGE_WAIT     StageType = "wait"
+	STAGE_SLEEP    StageType = "sleep"
+	STAGE_PARALLEL StageType = "parallel"
+)
+
+type Status string
+
+const (
+	STATUS_PENDING Status = "pending"
+	STATUS_RUNNING Status = "running"
+	STATUS_PASSED  Status = "passed"
+	STATUS_FAILED  Status = "failed"
+	STATUS_SKIPPED Status = "skipped"
+)
+
+const DefaultWaitInterval = 1000
+
+type Stage struct {
+	Name string    `json:"name,omitempty"`
+	Type StageType `json:"type"`
+	// timeout of the stage in seconds, the stage fails when it is reached
+	Timeout int `json:"timeout,omitempty"`
+	// run the stage even when a previous stage failed, used for teardown
+	Always bool `json:"always,omitempty"`
+	// a failure of the stage does not fail the scenario
+	ContinueOnError bool `json:"continue_on_error,omitempty"`
+
+	// job stage
+	Job *job.JobConfiguration `json:"job,omitempty"`
+
+	// sql and wait stages
+	Target     *target.Configuration `json:"target,omitempty"`
+	Statements []string              `json:"statements,omitempty"`
+	// sql stage, the columns of the first row returned by the last statement are kept as variables `<register>.<column>`
+	Register string `json:"register,omitempty"`
+	// wait stage, the query is polled every interval ms until it returns at least one row
+	Query    string `json:"query,omitempty"`
+	Interval int    `json:"interval,omitempty"`
+
+	// sleep stage, in ms
+	Duration int `json:"duration,omitempty"`
+
+	// parallel stage
+	Stages []Stage `json:"stages,omitempty"`
+}
+
+type Configuration struct {
+	Name string `json:"name"`
+	// variables referenced as ${name} in statements, queries and job configurations
+	Variables map[string]string `json:"variables,omitempty"`
+	Stages    []Stage           `json:"stages"`
+	// timeout of the whole scenario in seconds
+	Timeout int `json:"timeout,omitempty"`
+}
+
+// LoadConfig reads a json or yaml scenario file, the references to environment variables and files in its string
+// values are replaced like in a job file, see common.Interpolate, while the references to the scenario variables are
+// kept to be replaced when the stages run
+func LoadConfig(file string) (*Configuration, error) {
+	dat, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(file, ".json") && !strings.HasSuffix(file, ".yaml") && !strings.HasSuffix(file, ".yml") {
+		return nil, fmt.Errorf("configuration has to be json or yaml")
+	}
+
+	// json is valid yaml, both are read as a generic document to be interpolated
+	var document interface{}
+	if err := yaml.Unmarshal(dat, &document); err != nil {
+		return nil, err
+	}
+	if document, err = common.InterpolateAll(keepVariables(document, declaredVariables(document))); err != nil {
+		return nil, fmt.Errorf("failed to interpolate %s : %w", file, err)
+	}
+	if dat, err = json.Marshal(document); err != nil {
+		return nil, err
+	}
+
+	var payload Configuration
+	if err := json.NewDecoder(bytes.NewBuffer(dat)).Decode(&payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// declaredVariables returns the names of the variables of a scenario document
+func declaredVariables(document interface{}) map[string]bool {
+	declared := make(map[string]bool)
+	if doc, ok := document.(map[string]interface{}); ok {
+		if variables, ok := doc["variables"].(map[string]interface{}); ok {
+			for name := range variables {
+				declared[name] = true
+			}
+		}
+	}
+	return declared
+}
+
+// matches ${name}, with the $ escaping it in $${name}
+var escapedVariableRe = regexp.MustCompile(`\$?\$\{([A-Za-z0-9_.\-]+)\}`)
+
+// keepVariables escapes the references to the scenario variables, so they are not replaced by an environment
+// variable, the scenario variables are the declared ones and the ones with a dot, like scenario.id or load.run_id
+func keepVariables(value interface{}, declared map[string]bool) interface{} {
+	switch v := value.(type) {
+	case string:
+		return escapedVariableRe.ReplaceAllStringFunc(v, func(ref string) string {
+			name := strings.TrimLeft(ref, "$")
+			name = name[1 : len(name)-1]
+			if strings.HasPrefix(ref, "$$") || (!declared[name] && !strings.Contains(name, ".")) {
+				return ref
+			}
+			return "$" + ref
+		})
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = keepVariables(item, declared)
+		}
+		return v
+	case []interface{}:
+		for index, item := range v {
+			v[index] = keepVariables(item, declared)
+		}
+		return v
+	}
+	return value
+}
+
+// Redacted returns a copy of the configuration where the secrets of the job stages and of the targets are
+// replaced, to be returned by the api
+func (c Configuration) Redacted() Configuration {
+	c.Stages = redactStages(c.Stages)
+	return c
+}
+
+func redactStages(stages []Stage) []Stage {
+	if stages == nil {
+		return nil
+	}
+
+	result := make([]Stage, len(stages))
+	for index, stage := range stages {
+		if stage.Job != nil {
+			redacted := stage.Job.Redacted()
+			stage.Job = &redacted
+		}
+		if stage.Target != nil {
+			stage.Target = &target.Configuration{Type: stage.Target.Type, Properties: common.RedactProperties(stage.Target.Properties)}
+		}
+		stage.Stages = redactStages(stage.Stages)
+		result[index] = stage
+	}
+	return result
+}
+
+func (c *Configuration) Validate() error {
+	if len(c.Stages) == 0 {
+		return fmt.Errorf("scenario %s has no stage", c.Name)
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid timeout %d", c.Timeout)
+	}
+
+	for index := range c.Stages {
+		if err := c.Stages[index].validate(fmt.Sprintf("stage-%d", index)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate checks the stage and names it after its position when it has no name
+func (s *Stage) validate(defaultName string) error {
+	if s.Name == "" {
+		s.Name = defaultName
+	}
+	if s.Timeout < 0 {
+		return fmt.Errorf("invalid timeout of stage %s", s.Name)
+	}
+
+	switch s.Type {
+	case STAGE_JOB:
+		if s.Job == nil {
+			return fmt.Errorf("stage %s has no job", s.Name)
+		}
+	case STAGE_SQL:
+		if s.Target == nil || len(s.Statements) == 0 {
+			return fmt.Errorf("stage %s requires a target and statements", s.Name)
+		}
+	case STAGE_WAIT:
+		if s.Target == nil || s.Query == "" {
+			return fmt.Errorf("stage %s requires a target and a query", s.Name)
+		}
+		if s.Interval < 0 {
+			return fmt.Errorf("invalid interval of stage %s", s.Name)
+		}
+	case STAGE_SLEEP:
+		if s.Duration <= 0 {
+			return fmt.Errorf("stage %s requires a positive duration", s.Name)
+		}
+	case STAGE_PARALLEL:
+		if len(s.Stages) == 0 {
+			return fmt.Errorf("stage %s has no stage", s.Name)
+		}
+		for index := range s.Stages {
+			if err := s.Stages[index].validate(fmt.Sprintf("%s-%d", s.Name, index)); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("stage %s has invalid type %s", s.Name, s.Type)
+	}
+	return nil
+}
+
+var variableRe = regexp.MustCompile(`\$\{([A-Za-z0-9_.\-]+)\}`)
+
+// expand replaces the known ${name} references, unknown references are kept as is
+func expand(value string, variables map[string]string) string {
+	return variableRe.ReplaceAllStringFunc(value, func(ref string) string {
+		if v, ok := variables[ref[2:len(ref)-1]]; ok {
+			return v
+		}
+		return ref
+	})
+}
+
+func expandAny(value interface{}, variables map[string]string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return expand(v, variables)
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = expandAny(item, variables)
+		}
+		return v
+	case []interface{}:
+		for index, item := range v {
+			v[index] = expandAny(item, variables)
+		}
+		return v
+	}
+	return value
+}
+
+// expandJob replaces the variables referenced in all string values of a job configuration
+func expandJob(config job.JobConfiguration, variables map[string]string) (job.JobConfiguration, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return config, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(data, &generic); err != nil {
+		return config, err
+	}
+
+	if data, err = json.Marshal(expandAny(generic, variables)); err != nil {
+		return config, err
+	}
+
+	result := job.JobConfiguration{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return config, err
+	}
+	return result, nil
+}
+
+func expandTarget(config target.Configuration, variables map[string]string) target.Configuration {
+	properties := make(map[string]interface{}, len(config.Properties))
+	for key, value := range config.Properties {
+		if s, ok := value.(string); ok {
+			properties[key] = expand(s, variables)
+		} else {
+			properties[key] = value
+		}
+	}
+	return target.Configuration{Type: config.Type, Properties: properties}
+}
+
+type StageResult struct {
+	Name       string         `json:"name"`
+	Type       StageType      `json:"type"`
+	Status     Status         `json:"status"`
+	Error      string         `json:"error,omitempty"`
+	StartedAt  *time.Time     `json:"started_at,omitempty"`
+	FinishedAt *time.Time     `json:"finished_at,omitempty"`
+	Run        *job.Run       `json:"run,omitempty"`
+	Stages     []*StageResult `json:"stages,omitempty"`
+}
+
+func newStageResults(stages []Stage) []*StageResult {
+	results := make([]*StageResult, len(stages))
+	for index, stage := range stages {
+		results[index] = &StageResult{
+			Name:   stage.Name,
+			Type:   stage.Type,
+			Status: STATUS_PENDING,
+		}
+		if stage.Type == STAGE_PARALLEL {
+			results[index].Stages = newStageResults(stage.Stages)
+		}
+	}
+	return results
+}
@@ -0,0 +1,257 @@
+package schema
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+)
+
+// Type is the type of the value of a property, named after the json schema types
+type Type string
+
+const (
+	TYPE_STRING  Type = "string"
+	TYPE_INTEGER Type = "integer"
+	TYPE_NUMBER  Type = "number"
+	TYPE_BOOLEAN Type = "boolean"
+	TYPE_ARRAY   Type = "array"
+)
+
+// Property describes a property of a sink, an observer or a target
+type Property struct {
+	Name     string      `json:"name"`
+	Type     Type        `json:"type"`
+	Default  interface{} `json:"default,omitempty"`
+	Required bool        `json:"required,omitempty"`
+	// the value is redacted when the configuration is returned by the api or logged
+	Secret      bool   `json:"secret,omitempty"`
+	Description string `json:"description,omitempty"`
+	// the allowed values of a string property
+	Enum []string `json:"enum,omitempty"`
+}
+
+// Properties is the schema of the properties of a plugin, a nil schema accepts any property
+type Properties []Property
+
+// Values are the properties of a plugin checked against its schema, with the defaults of the missing properties
+type Values map[string]interface{}
+
+func (p Properties) property(name string) *Property {
+	for index := range p {
+		if p[index].Name == name {
+			return &p[index]
+		}
+	}
+	return nil
+}
+
+func (p Properties) names() []string {
+	names := make([]string, len(p))
+	for index, property := range p {
+		names[index] = property.Name
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Parse rejects the unknown properties, the missing required properties and the values which do not have the type
+// of their property. the strings of integer, number and boolean properties are converted, so they can come from
+// environment variables
+func (p Properties) Parse(properties map[string]interface{}) (Values, error) {
+	values := make(Values, len(p))
+	if p == nil {
+		for name, value := range properties {
+			values[name] = value
+		}
+		return values, nil
+	}
+
+	unknown := make([]string, 0)
+	for name := range properties {
+		if p.property(name) == nil {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		if len(p) == 0 {
+			return nil, fmt.Errorf("unknown properties %s, it has no property", strings.Join(unknown, ", "))
+		}
+		return nil, fmt.Errorf("unknown properties %s, the properties are %s", strings.Join(unknown, ", "), strings.Join(p.names(), ", "))
+	}
+
+	for _, property := range p {
+		value, ok := properties[property.Name]
+		if !ok || value == nil {
+			if property.Required {
+				return nil, fmt.Errorf("property %s is required", property.Name)
+			}
+			if property.Default != nil {
+				values[property.Name] = property.Default
+			}
+			continue
+		}
+
+		converted, err := property.convert(value)
+		if err != nil {
+			return nil, fmt.Errorf("property %s : %w", property.Name, err)
+		}
+		values[property.Name] = converted
+	}
+	return values, nil
+}
+
+func (p Property) convert(value interface{}) (interface{}, error) {
+	switch p.Type {
+	case TYPE_STRING:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%v is not a string", value)
+		}
+		if len(p.Enum) > 0 && !contains(p.Enum, s) {
+			return nil, fmt.Errorf("%s is not one of %s", s, strings.Join(p.Enum, ", "))
+		}
+		return s, nil
+	case TYPE_INTEGER:
+		switch v := value.(type) {
+		case int:
+			return v, nil
+		case int64:
+			return int(v), nil
+		case float64:
+			if v == math.Trunc(v) {
+				return int(v), nil
+			}
+		case string:
+			if i, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+				return i, nil
+			}
+		}
+		return nil, fmt.Errorf("%v is not an integer", value)
+	case TYPE_NUMBER:
+		switch v := value.(type) {
+		case int:
+			return float64(v), nil
+		case int64:
+			return float64(v), nil
+		case float64:
+			return v, nil
+		case string:
+			if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("%v is not a number", value)
+	case TYPE_BOOLEAN:
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			if b, err := strconv.ParseBool(strings.TrimSpace(v)); err == nil {
+				return b, nil
+			}
+		}
+		return nil, fmt.Errorf("%v is not a boolean", value)
+	case TYPE_ARRAY:
+		if list, ok := value.([]interface{}); ok {
+			return list, nil
+		}
+		return nil, fmt.Errorf("%v is not a list", value)
+	}
+	return value, nil
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
+
+// Redact returns a copy of the properties where the values of the secret properties, and of the properties named
+// like secrets, are replaced
+func (p Properties) Redact(properties map[string]interface{}) map[string]interface{} {
+	result := common.RedactProperties(properties)
+	for _, property := range p {
+		if value, ok := result[property.Name]; property.Secret && ok && value != nil && value != "" {
+			result[property.Name] = common.REDACTED
+		}
+	}
+	return result
+}
+
+// JSONSchema returns the json schema of the properties, a nil schema accepts any property
+func (p Properties) JSONSchema() map[string]interface{} {
+	if p == nil {
+		return map[string]interface{}{"type": "object"}
+	}
+
+	properties := make(map[string]interface{}, len(p))
+	required := make([]string, 0)
+	for _, property := range p {
+		item := map[string]interface{}{"type": property.Type}
+		if property.Description != "" {
+			item["description"] = property.Description
+		}
+		if property.Default != nil {
+			item["default"] = property.Default
+		}
+		if len(property.Enum) > 0 {
+			item["enum"] = property.Enum
+		}
+		if property.Secret {
+			item["writeOnly"] = true
+		}
+		properties[property.Name] = item
+		if property.Required {
+			required = append(required, property.Name)
+		}
+	}
+
+	result := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		result["required"] = required
+	}
+	return result
+}
+
+func (v Values) String(name string) string {
+	s, _ := v[name].(string)
+	return s
+}
+
+func (v Values) Int(name string) int {
+	i, _ := v[name].(int)
+	return i
+}
+
+func (v Values) Float(name string) float64 {
+	f, _ := v[name].(float64)
+	return f
+}
+
+func (v Values) Bool(name string) bool {
+	b, _ := v[name].(bool)
+	return b
+}
+
+func (v Values) List(name string) []interface{} {
+	l, _ := v[name].([]interface{})
+	return l
+}
+
+// Has tells whether the property is set or has a default
+func (v Values) Has(name string) bool {
+	_, ok := v[name]
+	return ok
+}
@@ -3,19 +3,26 @@ package server
 import (
 	"context"
 	"fmt"
+	"math"
 
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/profile"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 
+	"github.com/timeplus-io/chameleon/generator/internal/auth"
+	"github.com/timeplus-io/chameleon/generator/internal/cluster"
 	"github.com/timeplus-io/chameleon/generator/internal/handlers"
 	"github.com/timeplus-io/chameleon/generator/internal/job"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
@@ -30,6 +37,8 @@ import (
 	"github.com/timeplus-io/chameleon/generator/internal/plugins/rocketmq"
 	"github.com/timeplus-io/chameleon/generator/internal/plugins/splunk"
 	"github.com/timeplus-io/chameleon/generator/internal/plugins/timeplus"
+	"github.com/timeplus-io/chameleon/generator/internal/scenario"
+	"github.com/timeplus-io/chameleon/generator/internal/templates"
 
 	_ "github.com/timeplus-io/chameleon/generator/docs"
 )
@@ -55,13 +64,33 @@ func InfoMiddleware() gin.HandlerFunc {
 	}
 }
 
+// CORSMiddleware allows the configured origins to call the api from a browser
 func CORSMiddleware() gin.HandlerFunc {
-	allowedOrigin := viper.GetString("allow-origin")
+	allowedOrigins := make(map[string]struct{})
+	for _, origin := range strings.Split(viper.GetString("allow-origin"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			allowedOrigins[origin] = struct{}{}
+		}
+	}
+	_, allowAny := allowedOrigins["*"]
 
 	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", allowedOrigin)
-		c.Header("Access-Control-Allow-Credentials", "true")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		origin := c.Request.Header.Get("Origin")
+		_, ok := allowedOrigins[origin]
+		if origin == "" || (!ok && !allowAny) {
+			c.Next()
+			return
+		}
+
+		if ok {
+			// only the origins listed explicitly are trusted with the credentials of the browser
+			c.Header("Access-Control-Allow-Origin", origin)
+			c.Header("Vary", "Origin")
+			c.Header("Access-Control-Allow-Credentials", "true")
+		} else {
+			c.Header("Access-Control-Allow-Origin", "*")
+		}
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, X-Api-Key")
 		c.Header("Access-Control-Allow-Methods", "POST, HEAD,PATCH, DELETE, OPTIONS, GET, PUT")
 
 		if c.Request.Method == "OPTIONS" {
@@ -73,27 +102,25 @@ func CORSMiddleware() gin.HandlerFunc {
 	}
 }
 
+// Run runs the flags of the root command, it serves the api with -S, then runs the job of -f and the
+// scenario of --scenario-file
 func Run(_ *cobra.Command, _ []string) error {
-	initPlugins()
+	InitPlugins()
 
 	if viper.GetBool("enable-profile") {
 		defer profile.Start(profile.ProfilePath(".")).Stop()
 	}
 
 	if viper.GetBool("run-web-server") {
-		server := startServer()
-		shutdown(server)
-	}
-
-	if viper.GetBool("wait-service-ready") {
-		waitTime := viper.GetDuration("wait-service-time")
-		time.Sleep(waitTime)
+		if err := serve(); err != nil {
+			return err
+		}
 	}
 
 	if testConfigFile := viper.GetString("test-config-file"); testConfigFile != "" {
 		log.Logger().Infof("run test case from file %s", testConfigFile)
-		if job, err := job.NewJobManager().CreateJobFromFile(testConfigFile); err != nil {
-			log.Logger().Infof("failed to create job : %w", err)
+		if job, err := createJobFromFile(testConfigFile); err != nil {
+			log.Logger().Infof("failed to create job : %s", err)
 		} else {
 			log.Logger().Info("start job")
 			job.Start()
@@ -101,10 +128,114 @@ func Run(_ *cobra.Command, _ []string) error {
 		}
 	}
 
+	if scenarioFile := viper.GetString("scenario-file"); scenarioFile != "" {
+		log.Logger().Infof("run scenario from file %s", scenarioFile)
+		return runScenario(scenarioFile)
+	}
+
+	return nil
+}
+
+// Serve starts the api server and blocks until the process is interrupted
+func Serve(_ *cobra.Command, _ []string) error {
+	InitPlugins()
+
+	if viper.GetBool("enable-profile") {
+		defer profile.Start(profile.ProfilePath(".")).Stop()
+	}
+	return serve()
+}
+
+func serve() error {
+	store, err := job.NewJobStore(viper.GetString("job-store"), viper.GetString("job-store-path"))
+	if err != nil {
+		return err
+	}
+
+	manager, err := job.NewJobManagerWithStore(store, job.ResumePolicy(viper.GetString("job-resume-policy")))
+	if err != nil {
+		store.Close()
+		return err
+	}
+
+	authenticator, err := newAuthenticator()
+	if err != nil {
+		store.Close()
+		return err
+	}
+	catalogue, err := templates.NewCatalogue(viper.GetString("template-dir"))
+	if err != nil {
+		store.Close()
+		return err
+	}
+	if (viper.GetString("tls-cert-file") == "") != (viper.GetString("tls-key-file") == "") {
+		store.Close()
+		return fmt.Errorf("tls-cert-file and tls-key-file have to be set together")
+	}
+
+	var registry *cluster.Registry
+	if viper.GetBool("coordinator") {
+		log.Logger().Info("run as coordinator")
+		registry = cluster.NewRegistry()
+		manager.SetDispatcher(cluster.NewCoordinator(registry, viper.GetString("cluster-api-key")))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	agentDone := make(chan struct{})
+	if coordinator := viper.GetString("coordinator-address"); coordinator != "" {
+		go func() {
+			defer close(agentDone)
+			cluster.RunAgent(ctx, coordinator, viper.GetString("cluster-api-key"), cluster.RegisterRequest{Address: workerAddress()})
+		}()
+	} else {
+		close(agentDone)
+	}
+
+	server := startServer(NewRouter(manager, registry, catalogue, authenticator))
+	shutdown(server)
+	cancel()
+	<-agentDone
+
+	if err := store.Close(); err != nil {
+		log.Logger().WithError(err).Warn("failed to close job store")
+	}
+	return nil
+}
+
+func runScenario(file string) error {
+	config, err := scenario.LoadConfig(file)
+	if err != nil {
+		return fmt.Errorf("failed to load scenario : %w", err)
+	}
+
+	run, err := scenario.NewScenarioRun(*config)
+	if err != nil {
+		return fmt.Errorf("invalid scenario : %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// interrupting a scenario still runs its teardown stages
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt)
+	defer signal.Stop(quit)
+	go func() {
+		select {
+		case <-quit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if status := run.Run(ctx); status != scenario.STATUS_PASSED {
+		return fmt.Errorf("scenario %s %s", config.Name, status)
+	}
 	return nil
 }
 
-func initPlugins() {
+// InitPlugins makes sure the sink and observer plugins are registered
+func InitPlugins() {
 	timeplus.Init()
 	splunk.Init()
 	materialize.Init()
@@ -118,35 +249,147 @@ func initPlugins() {
 	aerospike.Init()
 }
 
-func startServer() *http.Server {
+// newAuthenticator reads the api keys from the keys file and the environment
+func newAuthenticator() (*auth.Authenticator, error) {
+	keys := make([]auth.Key, 0)
+	if file := viper.GetString("auth-keys-file"); file != "" {
+		fileKeys, err := auth.LoadKeys(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load api keys : %w", err)
+		}
+		if len(fileKeys) == 0 {
+			return nil, fmt.Errorf("no api key in %s", file)
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	envKeys, err := auth.ParseKeys(os.Getenv(auth.API_KEYS_ENV))
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s : %w", auth.API_KEYS_ENV, err)
+	}
+	keys = append(keys, envKeys...)
+
+	authenticator, err := auth.NewAuthenticator(keys)
+	if err != nil {
+		return nil, err
+	}
+	if authenticator.Enabled() {
+		log.Logger().Infof("api requires a key, %d keys are configured", len(keys))
+	} else {
+		log.Logger().Warn("api keys are not configured, anyone reaching the server can call the api")
+	}
+	return authenticator, nil
+}
+
+// workerAddress is the url the coordinator reaches this server on
+func workerAddress() string {
+	if address := viper.GetString("worker-address"); address != "" {
+		return address
+	}
+	return fmt.Sprintf("http://localhost:%d", viper.GetInt("server-port"))
+}
+
+// ServiceReadiness is the readiness of the jobs which have none, with --wait-service-ready the services of
+// their sinks and observers are waited for up to --wait-service-time
+func ServiceReadiness() *job.ReadinessConfiguration {
+	if !viper.GetBool("wait-service-ready") {
+		return nil
+	}
+	timeout := int(math.Ceil(viper.GetDuration("wait-service-time").Seconds()))
+	return &job.ReadinessConfiguration{Timeout: timeout}
+}
+
+func createJobFromFile(file string) (*job.Job, error) {
+	config, err := job.LoadConfig(file)
+	if err != nil {
+		return nil, err
+	}
+	if config.Readiness == nil {
+		config.Readiness = ServiceReadiness()
+	}
+
+	log.Logger().Infof("using configuration : %v", config.Redacted())
+	return job.NewJobManager().CreateJob(*config)
+}
+
+// NewRouter creates the routes of the api server, the worker routes are only added to a coordinator, the calls
+// require a key with the role of the route when the authenticator has keys
+func NewRouter(manager *job.JobManager, registry *cluster.Registry, catalogue *templates.Catalogue, authenticator *auth.Authenticator) *gin.Engine {
 	router := gin.New()
 
 	router.Use(log.LoggerHandler(), gin.Recovery())
 	router.Use(InfoMiddleware())
 	router.Use(CORSMiddleware())
+	router.Use(auth.Audit())
+
+	viewer := authenticator.Require(auth.ROLE_VIEWER)
+	operator := authenticator.Require(auth.ROLE_OPERATOR)
+	admin := authenticator.Require(auth.ROLE_ADMIN)
 
 	// Routes
 	router.GET("/health", handlers.HealthCheck)
+	router.GET("/metrics", viewer, gin.WrapH(metricsHandler(manager)))
 
 	v1beta1 := router.Group("/api")
-	jobHandler := handlers.NewJobHandler()
+	jobHandler := handlers.NewJobHandler(manager)
 	previewHandler := handlers.NewPreviewHandler()
+	scenarioHandler := handlers.NewScenarioHandler(scenario.NewScenarioManager())
+	templateHandler := handlers.NewTemplateHandler(catalogue, manager)
+	pluginHandler := handlers.NewPluginHandler()
+	runHandler := handlers.NewRunHandler(manager)
 
 	{
-		v1beta1.POST("/jobs", jobHandler.CreateJob)
-		v1beta1.GET("/jobs", jobHandler.ListJob)
-		v1beta1.GET("/jobs/:id", jobHandler.GetJob)
-		v1beta1.DELETE("/jobs/:id", jobHandler.DeleteJob)
-
-		v1beta1.POST("/jobs/:id/start", jobHandler.StartJob)
-		v1beta1.POST("/jobs/:id/stop", jobHandler.StopJob)
+		v1beta1.POST("/jobs", admin, jobHandler.CreateJob)
+		v1beta1.GET("/jobs", viewer, jobHandler.ListJob)
+		v1beta1.GET("/jobs/:id", viewer, jobHandler.GetJob)
+		v1beta1.PATCH("/jobs/:id", operator, jobHandler.PatchJob)
+		v1beta1.DELETE("/jobs/:id", admin, jobHandler.DeleteJob)
+
+		v1beta1.POST("/jobs/:id/start", operator, jobHandler.StartJob)
+		v1beta1.POST("/jobs/:id/stop", operator, jobHandler.StopJob)
+		v1beta1.POST("/jobs/:id/restart", operator, jobHandler.RestartJob)
+		v1beta1.POST("/jobs/:id/clone", admin, jobHandler.CloneJob)
+		v1beta1.POST("/jobs/:id/schedule", operator, jobHandler.ScheduleJob)
+		v1beta1.GET("/jobs/:id/events", viewer, jobHandler.JobEvents)
+
+		// the finished runs, kept when their job is deleted
+		v1beta1.GET("/runs", viewer, runHandler.ListRun)
+		v1beta1.GET("/runs/:id", viewer, runHandler.GetRun)
+		v1beta1.GET("/runs/:id/export", viewer, runHandler.ExportRun)
+
+		// previews only generate events, nothing is written to the sinks
+		v1beta1.POST("/previews", viewer, previewHandler.Preview)
+
+		v1beta1.GET("/templates", viewer, templateHandler.ListTemplate)
+		v1beta1.GET("/templates/:name", viewer, templateHandler.GetTemplate)
+		v1beta1.POST("/templates/:name/jobs", admin, templateHandler.CreateJobFromTemplate)
+
+		v1beta1.GET("/plugins", viewer, pluginHandler.ListPlugin)
+
+		v1beta1.POST("/scenarios", admin, scenarioHandler.RunScenario)
+		v1beta1.GET("/scenarios", viewer, scenarioHandler.ListScenario)
+		v1beta1.GET("/scenarios/:id", viewer, scenarioHandler.GetScenario)
+		v1beta1.POST("/scenarios/:id/stop", operator, scenarioHandler.StopScenario)
+	}
 
-		v1beta1.POST("/previews", previewHandler.Preview)
+	if registry != nil {
+		workerHandler := handlers.NewWorkerHandler(registry)
+		v1beta1.POST("/workers", operator, workerHandler.RegisterWorker)
+		v1beta1.GET("/workers", viewer, workerHandler.ListWorker)
+		v1beta1.DELETE("/workers/:id", operator, workerHandler.UnregisterWorker)
 	}
 
+	return router
+}
+
+func startServer(router *gin.Engine) *http.Server {
 	address := viper.GetString("server-addr")
 	port := viper.GetInt("server-port")
+	certFile, keyFile := viper.GetString("tls-cert-file"), viper.GetString("tls-key-file")
 	schema := "http"
+	if certFile != "" {
+		schema = "https"
+	}
 	url := ginSwagger.URL(fmt.Sprintf("%s://%s:%d/swagger/doc.json", schema, address, port)) // The url pointing to API definition
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler, url))
 
@@ -155,16 +398,34 @@ func startServer() *http.Server {
 		Handler: router,
 	}
 
-	log.Logger().Infof("listen on %s %d ", address, port)
+	log.Logger().Infof("listen on %s %d with %s", address, port, schema)
 	go func() {
-		if err := srv.ListenAndServe(); err != nil {
-			log.Logger().Infof("listen: %s\n", err)
+		var err error
+		if certFile != "" {
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			err = srv.ListenAndServe()
+		}
+		// a server which cannot listen, like with an invalid certificate, exits instead of running without api
+		if err != nil && err != http.ErrServerClosed {
+			log.Logger().WithError(err).Fatalf("failed to listen on %s %d with %s", address, port, schema)
 		}
 	}()
 
 	return srv
 }
 
+// metricsHandler exposes the metrics of the jobs and of the generator process in prometheus format
+func metricsHandler(manager *job.JobManager) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		job.NewJobCollector(manager),
+	)
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
 func shutdown(server *http.Server) {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt)
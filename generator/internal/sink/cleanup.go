@@ -0,0 +1,50 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+)
+
+// CleanupPolicy decides when the objects created by a sink, like a topic, a stream or a table, are dropped
+type CleanupPolicy string
+
+const (
+	CLEANUP_POLICY_NEVER CleanupPolicy = "never"
+	// dropped when the run is not failed and no row failed to be written, so a failed run can be looked into
+	CLEANUP_POLICY_ON_SUCCESS CleanupPolicy = "on_success"
+	CLEANUP_POLICY_ALWAYS     CleanupPolicy = "always"
+)
+
+type CleanupConfiguration struct {
+	Policy CleanupPolicy `json:"policy,omitempty"`
+	// drop the object named after the job before the sink creates it, so each run starts from an empty one
+	DropExisting bool `json:"drop_existing,omitempty"`
+}
+
+func (c *CleanupConfiguration) Validate() error {
+	switch c.Policy {
+	case "", CLEANUP_POLICY_NEVER, CLEANUP_POLICY_ON_SUCCESS, CLEANUP_POLICY_ALWAYS:
+	default:
+		return fmt.Errorf("invalid cleanup policy %s", c.Policy)
+	}
+	return nil
+}
+
+// Drop tells whether the objects created by the sink are dropped at the end of a run
+func (c *CleanupConfiguration) Drop(succeeded bool) bool {
+	switch c.Policy {
+	case CLEANUP_POLICY_ALWAYS:
+		return true
+	case CLEANUP_POLICY_ON_SUCCESS:
+		return succeeded
+	}
+	return false
+}
+
+// Cleaner is implemented by the sinks which create the object they write to in Init
+type Cleaner interface {
+	// DropExisting drops the object the sink would write to for the name, it is called before Init
+	DropExisting(ctx context.Context, name string) error
+	// Cleanup drops the object created by Init, an object which existed before Init is kept
+	Cleanup(ctx context.Context) error
+}
@@ -0,0 +1,145 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+)
+
+// DEAD_LETTER_SUFFIX is appended to the job name to name the stream or topic of a dead letter sink
+const DEAD_LETTER_SUFFIX = "_dead_letter"
+
+// DeadLetterConfiguration defines where the rows which cannot be written go, either a JSONL file or another sink
+type DeadLetterConfiguration struct {
+	File string         `json:"file,omitempty"`
+	Sink *Configuration `json:"sink,omitempty"`
+}
+
+// DeadLetter keeps the rows of the batches which fail after all retries
+type DeadLetter interface {
+	Init(ctx context.Context, name string, fields []common.Field) error
+	Write(headers []string, rows [][]interface{}, cause error) error
+	Flush(ctx context.Context) error
+	Close() error
+}
+
+func NewDeadLetter(config DeadLetterConfiguration, sinkType string) (DeadLetter, error) {
+	if (config.File == "") == (config.Sink == nil) {
+		return nil, fmt.Errorf("either file or sink is required")
+	}
+
+	if config.File != "" {
+		return &fileDeadLetter{path: config.File, sinkType: sinkType}, nil
+	}
+
+	s, err := CreateSink(*config.Sink)
+	if err != nil {
+		return nil, err
+	}
+	return &sinkDeadLetter{sink: s}, nil
+}
+
+// fileDeadLetter appends one JSON line per row, with the sink type and the error of the failed write
+type fileDeadLetter struct {
+	path     string
+	sinkType string
+	file     *os.File
+	writer   *bufio.Writer
+	lock     sync.Mutex
+}
+
+type deadLetterRecord struct {
+	Time  time.Time    `json:"time"`
+	Sink  string       `json:"sink"`
+	Error string       `json:"error"`
+	Row   common.Event `json:"row"`
+}
+
+func (d *fileDeadLetter) Init(ctx context.Context, name string, fields []common.Field) error {
+	file, err := os.OpenFile(d.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	d.file = file
+	d.writer = bufio.NewWriter(file)
+	return nil
+}
+
+func (d *fileDeadLetter) Write(headers []string, rows [][]interface{}, cause error) error {
+	now := time.Now().UTC()
+	message := ""
+	if cause != nil {
+		message = cause.Error()
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	encoder := json.NewEncoder(d.writer)
+	for _, event := range common.ToEvents(headers, rows) {
+		record := deadLetterRecord{Time: now, Sink: d.sinkType, Error: message, Row: event}
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *fileDeadLetter) Flush(ctx context.Context) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.writer == nil {
+		return nil
+	}
+	return d.writer.Flush()
+}
+
+func (d *fileDeadLetter) Close() error {
+	if err := d.Flush(context.Background()); err != nil {
+		return err
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	d.writer = nil
+	return err
+}
+
+// sinkDeadLetter writes the rows to another sink, named after the job with DEAD_LETTER_SUFFIX
+type sinkDeadLetter struct {
+	sink Sink
+}
+
+func (d *sinkDeadLetter) Init(ctx context.Context, name string, fields []common.Field) error {
+	return d.sink.Init(ctx, name+DEAD_LETTER_SUFFIX, fields)
+}
+
+func (d *sinkDeadLetter) Write(headers []string, rows [][]interface{}, cause error) error {
+	return d.sink.Write(context.Background(), headers, rows, 0)
+}
+
+func (d *sinkDeadLetter) Flush(ctx context.Context) error {
+	return d.sink.Flush(ctx)
+}
+
+func (d *sinkDeadLetter) Ready(ctx context.Context) error {
+	if probe, ok := d.sink.(common.ReadinessProbe); ok {
+		return probe.Ready(ctx)
+	}
+	return nil
+}
+
+func (d *sinkDeadLetter) Close() error {
+	return d.sink.Close()
+}
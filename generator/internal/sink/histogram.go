@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// HISTOGRAM_GROWTH is the ratio between the bounds of two consecutive buckets, quantiles are precise to it
+const HISTOGRAM_GROWTH = 1.05
+
+// LatencySummary is a snapshot of a latency histogram, durations are in ms
+type LatencySummary struct {
+	Count int64   `json:"count"`
+	P50   float64 `json:"p50_ms"`
+	P90   float64 `json:"p90_ms"`
+	P99   float64 `json:"p99_ms"`
+	Max   float64 `json:"max_ms"`
+}
+
+// Merge combines the summaries of several histograms, quantiles cannot be merged so the highest ones are kept
+func (l LatencySummary) Merge(other LatencySummary) LatencySummary {
+	return LatencySummary{
+		Count: l.Count + other.Count,
+		P50:   math.Max(l.P50, other.P50),
+		P90:   math.Max(l.P90, other.P90),
+		P99:   math.Max(l.P99, other.P99),
+		Max:   math.Max(l.Max, other.Max),
+	}
+}
+
+// LatencyHistogram counts durations in exponential buckets of microseconds, so its size does not grow with the
+// number of observed values
+type LatencyHistogram struct {
+	counts []int64
+	count  int64
+	sum    time.Duration
+	max    time.Duration
+	lock   sync.Mutex
+}
+
+func NewLatencyHistogram() *LatencyHistogram {
+	return &LatencyHistogram{}
+}
+
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	bucket := 0
+	if us := float64(d.Microseconds()); us > 1 {
+		bucket = int(math.Log(us)/math.Log(HISTOGRAM_GROWTH)) + 1
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if bucket >= len(h.counts) {
+		counts := make([]int64, bucket+1)
+		copy(counts, h.counts)
+		h.counts = counts
+	}
+	h.counts[bucket]++
+	h.count++
+	h.sum += d
+	if d > h.max {
+		h.max = d
+	}
+}
+
+func (h *LatencyHistogram) Summary() LatencySummary {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return LatencySummary{
+		Count: h.count,
+		P50:   h.quantile(0.5),
+		P90:   h.quantile(0.9),
+		P99:   h.quantile(0.99),
+		Max:   toMs(h.max),
+	}
+}
+
+// Cumulative returns the number of observed values up to each bound in seconds, with the count of the values
+// and their sum in seconds, a value is counted up to a bound when the upper bound of its bucket is below it
+func (h *LatencyHistogram) Cumulative(bounds []float64) (uint64, float64, map[float64]uint64) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	buckets := make(map[float64]uint64, len(bounds))
+	for _, bound := range bounds {
+		count := uint64(0)
+		for bucket, bucketCount := range h.counts {
+			if math.Pow(HISTOGRAM_GROWTH, float64(bucket)) > bound*1e6 {
+				break
+			}
+			count += uint64(bucketCount)
+		}
+		buckets[bound] = count
+	}
+	return uint64(h.count), h.sum.Seconds(), buckets
+}
+
+// quantile returns the upper bound of the bucket holding the quantile, capped by the max observed value
+func (h *LatencyHistogram) quantile(q float64) float64 {
+	if h.count == 0 {
+		return 0
+	}
+
+	rank := int64(math.Ceil(q * float64(h.count)))
+	seen := int64(0)
+	for bucket, count := range h.counts {
+		seen += count
+		if seen < rank {
+			continue
+		}
+
+		bound := time.Duration(math.Pow(HISTOGRAM_GROWTH, float64(bucket))) * time.Microsecond
+		if bound > h.max {
+			bound = h.max
+		}
+		return toMs(bound)
+	}
+	return toMs(h.max)
+}
+
+func toMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
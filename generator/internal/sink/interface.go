@@ -1,19 +1,44 @@
 package sink
 
-import "github.com/timeplus-io/chameleon/generator/internal/common"
+import (
+	"context"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+)
 
 type Stats struct {
 	SuccessWrite int
 	FailedWrite  int
+	// number of Write calls, including the retried ones, and the ones returning an error
+	Writes int
+	Errors int
+	// number of retried writes and rows sent to the dead letter destination
+	Retries        int
+	DeadLetterRows int
+	Latency        LatencySummary
 }
 
 type Sink interface {
-	Write(headers []string, rows [][]interface{}, index int) error
-	Init(name string, fields []common.Field) error
+	Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error
+	Init(ctx context.Context, name string, fields []common.Field) error
+	// Flush blocks until the rows buffered by the sink are written or the context is done
+	Flush(ctx context.Context) error
+	// Close releases the connections of the sink, the sink cannot be written after it is closed
+	Close() error
 	GetStats() *Stats
 }
 
 type Configuration struct {
 	Type       string                 `json:"type"`
 	Properties map[string]interface{} `json:"properties"`
+	// the queue of batches waiting to be written to the sink
+	Queue *QueueConfiguration `json:"queue,omitempty"`
+	// how failed writes are retried
+	Retry *RetryConfiguration `json:"retry,omitempty"`
+	// where the rows go when the retries are exhausted
+	DeadLetter *DeadLetterConfiguration `json:"dead_letter,omitempty"`
+	// a disabled sink is created but no rows are written to it, until it is enabled
+	Disabled bool `json:"disabled,omitempty"`
+	// when the objects created by the sink are dropped
+	Cleanup *CleanupConfiguration `json:"cleanup,omitempty"`
 }
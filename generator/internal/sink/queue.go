@@ -0,0 +1,494 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+// QueuePolicy decides what happens when a batch is written to a full sink queue
+type QueuePolicy string
+
+const (
+	QUEUE_POLICY_BLOCK       QueuePolicy = "block"
+	QUEUE_POLICY_DROP_NEWEST QueuePolicy = "drop_newest"
+	QUEUE_POLICY_DROP_OLDEST QueuePolicy = "drop_oldest"
+	QUEUE_POLICY_SPILL       QueuePolicy = "spill"
+)
+
+const DefaultQueueSize = 64
+
+type QueueConfiguration struct {
+	// max number of batches waiting to be written
+	Size int `json:"size,omitempty"`
+	// number of go routines writing to the sink, default to the concurrency of the source
+	Workers int         `json:"workers,omitempty"`
+	Policy  QueuePolicy `json:"policy,omitempty"`
+	// directory of the spill files, default to the system temp directory
+	SpillDir string `json:"spill_dir,omitempty"`
+}
+
+func (c *QueueConfiguration) Validate() error {
+	if c.Size < 0 || c.Workers < 0 {
+		return fmt.Errorf("size and workers cannot be negative")
+	}
+
+	switch c.Policy {
+	case "", QUEUE_POLICY_BLOCK, QUEUE_POLICY_DROP_NEWEST, QUEUE_POLICY_DROP_OLDEST, QUEUE_POLICY_SPILL:
+	default:
+		return fmt.Errorf("invalid queue policy %s", c.Policy)
+	}
+	return nil
+}
+
+// Batch is a set of rows generated by one stream of the source
+type Batch struct {
+	Headers  []string
+	Rows     [][]interface{}
+	Index    int
+	Size     int64
+	Enqueued time.Time
+	// when a worker takes the batch out of the queue to write it
+	Dequeued time.Time
+
+	delivery *Delivery
+}
+
+// Delivery is shared by the batches of the same rows pushed to the queues of several sinks
+type Delivery struct {
+	pending int32
+	failed  int32
+}
+
+func NewDelivery(sinks int) *Delivery {
+	return &Delivery{pending: int32(sinks)}
+}
+
+// WithDelivery shares the delivery of the rows of the batch with the batches pushed to the other sinks
+func (b *Batch) WithDelivery(delivery *Delivery) *Batch {
+	b.delivery = delivery
+	return b
+}
+
+// Delivered records the outcome of the write of the batch, done is true once all the sinks sharing
+// its delivery are done with the rows, and failed is true when one of them could not write them
+func (b *Batch) Delivered(err error) (done bool, failed bool) {
+	if b.delivery == nil {
+		return true, err != nil
+	}
+	if err != nil {
+		atomic.StoreInt32(&b.delivery.failed, 1)
+	}
+	done = atomic.AddInt32(&b.delivery.pending, -1) == 0
+	return done, atomic.LoadInt32(&b.delivery.failed) == 1
+}
+
+// ErrDropped is given to the write callback for the batches dropped by the queue
+var ErrDropped = errors.New("batch dropped by the queue")
+
+// WriteCallback is called by the queue workers once a batch is written, err is the error returned by the sink,
+// or ErrDropped when the queue dropped the batch without writing it
+type WriteCallback func(batch *Batch, err error)
+
+func init() {
+	// the concrete types of the generated values, so they survive a round trip through a spill file
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(time.Time{})
+	gob.Register(float32(0))
+}
+
+// Queue buffers the batches written to one sink, so a slow sink does not stall the source and the other sinks
+type Queue struct {
+	sink     Sink
+	config   QueueConfiguration
+	batches  chan *Batch
+	callback WriteCallback
+	spill    *spillFile
+	// closed once the spilled batches are moved back to the queue or dropped
+	unspilled chan struct{}
+
+	dropped int
+	lock    sync.Mutex
+	waiter  sync.WaitGroup
+	closing chan struct{}
+	once    sync.Once
+}
+
+func NewQueue(sink Sink, config QueueConfiguration, workers int, callback WriteCallback) (*Queue, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	if config.Size == 0 {
+		config.Size = DefaultQueueSize
+	}
+	if config.Workers == 0 {
+		config.Workers = workers
+	}
+	if config.Workers <= 0 {
+		config.Workers = 1
+	}
+	if config.Policy == "" {
+		config.Policy = QUEUE_POLICY_BLOCK
+	}
+
+	q := &Queue{
+		sink:      sink,
+		config:    config,
+		batches:   make(chan *Batch, config.Size),
+		callback:  callback,
+		closing:   make(chan struct{}),
+		unspilled: make(chan struct{}),
+	}
+
+	if config.Policy == QUEUE_POLICY_SPILL {
+		spill, err := newSpillFile(config.SpillDir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create spill file : %w", err)
+		}
+		q.spill = spill
+	}
+	return q, nil
+}
+
+// Start starts the workers, writes are done with the given context
+func (q *Queue) Start(ctx context.Context) {
+	q.waiter.Add(q.config.Workers)
+	for i := 0; i < q.config.Workers; i++ {
+		go q.work(ctx)
+	}
+
+	if q.spill != nil {
+		go q.unspill(ctx)
+		go func() {
+			// the batches left in the spill file are dropped once the writes are aborted
+			<-ctx.Done()
+			q.spill.close()
+		}()
+	} else {
+		close(q.unspilled)
+	}
+}
+
+func (q *Queue) work(ctx context.Context) {
+	defer q.waiter.Done()
+	for {
+		select {
+		case batch := <-q.batches:
+			q.write(ctx, batch)
+		case <-q.closing:
+			// write what is left in the queue
+			for {
+				select {
+				case batch := <-q.batches:
+					q.write(ctx, batch)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (q *Queue) write(ctx context.Context, batch *Batch) {
+	batch.Dequeued = time.Now()
+	err := q.sink.Write(ctx, batch.Headers, batch.Rows, batch.Index)
+	q.callback(batch, err)
+}
+
+// Push adds a batch to the queue and applies the queue policy when the queue is full,
+// batches pushed after the queue is closed are dropped
+func (q *Queue) Push(batch *Batch) {
+	batch.Enqueued = time.Now()
+
+	select {
+	case <-q.closing:
+		q.drop(batch)
+		return
+	default:
+	}
+
+	switch q.config.Policy {
+	case QUEUE_POLICY_DROP_NEWEST:
+		select {
+		case q.batches <- batch:
+		default:
+			q.drop(batch)
+		}
+	case QUEUE_POLICY_DROP_OLDEST:
+		for {
+			select {
+			case q.batches <- batch:
+				return
+			default:
+			}
+
+			select {
+			case oldest := <-q.batches:
+				q.drop(oldest)
+			default:
+			}
+		}
+	case QUEUE_POLICY_SPILL:
+		// once spilling, batches keep going to the spill file until it is drained to keep them in order
+		if q.spill.pending() == 0 {
+			select {
+			case q.batches <- batch:
+				return
+			default:
+			}
+		}
+		if err := q.spill.write(batch); err != nil {
+			log.Logger().WithError(err).Errorf("failed to spill batch, drop it")
+			q.drop(batch)
+		}
+	default:
+		select {
+		case q.batches <- batch:
+		case <-q.closing:
+			q.drop(batch)
+		}
+	}
+}
+
+// unspill moves the spilled batches back to the queue as soon as there is room, once the spill file
+// is closed the batches left in it are dropped
+func (q *Queue) unspill(ctx context.Context) {
+	defer close(q.unspilled)
+	defer q.spill.remove()
+	for {
+		batch, err := q.spill.read()
+		if err != nil {
+			if err != io.EOF {
+				left := q.spill.discard()
+				log.Logger().WithError(err).Errorf("failed to read spilled batch, drop the %d spilled batches left", left)
+				q.lock.Lock()
+				q.dropped += left
+				q.lock.Unlock()
+			}
+			return
+		}
+
+		select {
+		case q.batches <- batch:
+		case <-q.spill.closing:
+			q.drop(batch)
+		}
+		q.spill.done()
+	}
+}
+
+// drop counts the batch as dropped and reports it to the callback, so its rows are counted as failed
+func (q *Queue) drop(batch *Batch) {
+	q.lock.Lock()
+	q.dropped++
+	q.lock.Unlock()
+	q.callback(batch, ErrDropped)
+}
+
+// Depth returns the number of batches waiting to be written, including the spilled ones
+func (q *Queue) Depth() int {
+	depth := len(q.batches)
+	if q.spill != nil {
+		depth += q.spill.pending()
+	}
+	return depth
+}
+
+func (q *Queue) Dropped() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return q.dropped
+}
+
+// Close waits until the queued batches are written or the timeout is reached, no batch can be pushed after it
+func (q *Queue) Close(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	if q.spill != nil {
+		// let the spilled batches go back to the queue first
+		for q.spill.pending() > 0 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+
+		// the batches still spilled are dropped before the workers stop
+		q.spill.close()
+		<-q.unspilled
+	}
+
+	q.once.Do(func() {
+		close(q.closing)
+	})
+
+	done := make(chan struct{})
+	go func() {
+		q.waiter.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(time.Until(deadline)):
+		return false
+	}
+}
+
+// Wait blocks until the workers exit, the writes in progress are over once it returns
+func (q *Queue) Wait() {
+	q.waiter.Wait()
+}
+
+// spillFile keeps the batches which do not fit in a full queue, each batch is a length prefixed gob message
+type spillFile struct {
+	writer *os.File
+	reader *os.File
+	// the deliveries of the spilled batches in the order they are spilled, they are not encoded
+	deliveries []*Delivery
+	count      int
+	closed     bool
+	closing    chan struct{}
+	lock       sync.Mutex
+	cond       *sync.Cond
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	writer, err := os.CreateTemp(dir, "chameleon-spill-*")
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := os.Open(writer.Name())
+	if err != nil {
+		writer.Close()
+		os.Remove(writer.Name())
+		return nil, err
+	}
+
+	spill := &spillFile{writer: writer, reader: reader, closing: make(chan struct{})}
+	spill.cond = sync.NewCond(&spill.lock)
+	return spill, nil
+}
+
+func (f *spillFile) write(batch *Batch) error {
+	buf := bytes.Buffer{}
+	if err := gob.NewEncoder(&buf).Encode(batch); err != nil {
+		return err
+	}
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.closed {
+		return fmt.Errorf("spill file is closed")
+	}
+
+	header := make([]byte, 4)
+	binary.BigEndian.PutUint32(header, uint32(buf.Len()))
+	if _, err := f.writer.Write(append(header, buf.Bytes()...)); err != nil {
+		return err
+	}
+
+	f.deliveries = append(f.deliveries, batch.delivery)
+	f.count++
+	f.cond.Signal()
+	return nil
+}
+
+// read blocks until a spilled batch is available, it returns io.EOF once the spill file is closed and
+// all the batches in it are read
+func (f *spillFile) read() (*Batch, error) {
+	f.lock.Lock()
+	for f.count == 0 && !f.closed {
+		f.cond.Wait()
+	}
+	if f.count == 0 {
+		f.lock.Unlock()
+		return nil, io.EOF
+	}
+	delivery := f.deliveries[0]
+	f.deliveries = f.deliveries[1:]
+	f.lock.Unlock()
+
+	// the batches before count are completely written, reading them does not race with the writer
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(f.reader, header); err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(header))
+	if _, err := io.ReadFull(f.reader, data); err != nil {
+		return nil, err
+	}
+
+	var batch Batch
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&batch); err != nil {
+		return nil, err
+	}
+	batch.delivery = delivery
+	return &batch, nil
+}
+
+// done marks the last read batch as moved back to the queue, the file is truncated once it is drained
+func (f *spillFile) done() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.count--
+	if f.count == 0 && !f.closed {
+		f.writer.Truncate(0)
+		f.writer.Seek(0, io.SeekStart)
+		f.reader.Seek(0, io.SeekStart)
+	}
+}
+
+func (f *spillFile) pending() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.count
+}
+
+// close stops the spilling, the batches already spilled can still be read
+func (f *spillFile) close() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.stop()
+}
+
+func (f *spillFile) stop() {
+	if f.closed {
+		return
+	}
+
+	f.closed = true
+	close(f.closing)
+	f.cond.Broadcast()
+}
+
+// discard forgets the batches which cannot be read anymore and returns their number
+func (f *spillFile) discard() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	count := f.count
+	f.count = 0
+	f.deliveries = nil
+	return count
+}
+
+// remove deletes the spill file, it is called by the reader once it is done with the file
+func (f *spillFile) remove() {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.stop()
+	f.writer.Close()
+	f.reader.Close()
+	os.Remove(f.writer.Name())
+}
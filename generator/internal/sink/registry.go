@@ -2,6 +2,8 @@ package sink
 
 import (
 	"fmt"
+
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
 )
 
 type SinkConstructor func(properties map[string]interface{}) (Sink, error)
@@ -9,6 +11,8 @@ type SinkConstructor func(properties map[string]interface{}) (Sink, error)
 type SinkRegItem struct {
 	Name        string
 	Constructor SinkConstructor
+	// the properties of the sink, any property is accepted without schema
+	Properties schema.Properties
 }
 
 var (
@@ -46,3 +50,9 @@ func ListRegisteredSinkTypes() []string {
 	}
 	return keys
 }
+
+// GetProperties returns the schema of the properties of the sink, it is false when the sink does not exist
+func GetProperties(name string) (schema.Properties, bool) {
+	item, exist := sinkRegistry[name]
+	return item.Properties, exist
+}
@@ -0,0 +1,280 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = 100
+	DefaultRetryMaxBackoff     = 5000
+	DefaultRetryMultiplier     = 2.0
+)
+
+type RetryConfiguration struct {
+	// max number of writes of a batch, including the first one
+	MaxAttempts int `json:"max_attempts,omitempty"`
+	// backoff before the first retry in ms, it is multiplied by multiplier after each retry
+	InitialBackoff int     `json:"initial_backoff,omitempty"`
+	MaxBackoff     int     `json:"max_backoff,omitempty"`
+	Multiplier     float64 `json:"multiplier,omitempty"`
+}
+
+func (c *RetryConfiguration) Validate() error {
+	if c.MaxAttempts < 0 || c.InitialBackoff < 0 || c.MaxBackoff < 0 {
+		return fmt.Errorf("max_attempts, initial_backoff and max_backoff cannot be negative")
+	}
+	if c.Multiplier != 0 && c.Multiplier < 1 {
+		return fmt.Errorf("multiplier cannot be less than 1")
+	}
+	return nil
+}
+
+func (c RetryConfiguration) withDefaults() RetryConfiguration {
+	if c.MaxAttempts == 0 {
+		c.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if c.InitialBackoff == 0 {
+		c.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if c.MaxBackoff == 0 {
+		c.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if c.Multiplier == 0 {
+		c.Multiplier = DefaultRetryMultiplier
+	}
+	return c
+}
+
+// PermanentError is an error that fails again whatever the number of retries, like invalid data
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks an error returned by a sink as not retryable
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsRetryable tells whether a failed write may succeed when retried
+func IsRetryable(err error) bool {
+	var permanent *PermanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// RetrySink retries the failed writes of a sink, the rows of a batch which still fails are sent
+// to the dead letter destination when there is one
+type RetrySink struct {
+	Sink
+	config     RetryConfiguration
+	deadLetter DeadLetter
+
+	stats   Stats
+	latency *LatencyHistogram
+	lock    sync.Mutex
+}
+
+// NewRetrySink wraps the sink created from the configuration, without retry configuration a batch is written once
+func NewRetrySink(s Sink, config Configuration) (*RetrySink, error) {
+	retry := RetryConfiguration{MaxAttempts: 1}
+	if config.Retry != nil {
+		if err := config.Retry.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid retry : %w", err)
+		}
+		retry = *config.Retry
+	}
+
+	var deadLetter DeadLetter
+	if config.DeadLetter != nil {
+		dl, err := NewDeadLetter(*config.DeadLetter, config.Type)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dead letter : %w", err)
+		}
+		deadLetter = dl
+	}
+
+	return &RetrySink{
+		Sink:       s,
+		config:     retry.withDefaults(),
+		deadLetter: deadLetter,
+		latency:    NewLatencyHistogram(),
+	}, nil
+}
+
+func (s *RetrySink) Init(ctx context.Context, name string, fields []common.Field) error {
+	if err := s.Sink.Init(ctx, name, fields); err != nil {
+		return err
+	}
+	if s.deadLetter != nil {
+		return s.deadLetter.Init(ctx, name, fields)
+	}
+	return nil
+}
+
+func (s *RetrySink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
+	backoff := time.Duration(s.config.InitialBackoff) * time.Millisecond
+	maxBackoff := time.Duration(s.config.MaxBackoff) * time.Millisecond
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		start := time.Now()
+		err = s.Sink.Write(ctx, headers, rows, index)
+		s.latency.Observe(time.Since(start))
+		s.count(err)
+		if err == nil {
+			s.lock.Lock()
+			s.stats.SuccessWrite += len(rows)
+			s.lock.Unlock()
+			return nil
+		}
+		if attempt >= s.config.MaxAttempts || !IsRetryable(err) || ctx.Err() != nil {
+			break
+		}
+
+		log.Logger().WithError(err).Debugf("write failed, retry in %s", backoff)
+		s.lock.Lock()
+		s.stats.Retries++
+		s.lock.Unlock()
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+		if ctx.Err() != nil {
+			// the write is aborted during the backoff, the last error is the outcome of the write
+			break
+		}
+
+		backoff = time.Duration(float64(backoff) * s.config.Multiplier)
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+
+	s.lock.Lock()
+	s.stats.FailedWrite += len(rows)
+	s.lock.Unlock()
+
+	if s.deadLetter != nil {
+		if dlErr := s.deadLetter.Write(headers, rows, err); dlErr != nil {
+			log.Logger().WithError(dlErr).Errorf("failed to write %d rows to dead letter", len(rows))
+		} else {
+			s.lock.Lock()
+			s.stats.DeadLetterRows += len(rows)
+			s.lock.Unlock()
+		}
+	}
+	return err
+}
+
+func (s *RetrySink) count(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.stats.Writes++
+	if err != nil {
+		s.stats.Errors++
+	}
+}
+
+func (s *RetrySink) Flush(ctx context.Context) error {
+	err := s.Sink.Flush(ctx)
+	if s.deadLetter != nil {
+		if dlErr := s.deadLetter.Flush(ctx); err == nil {
+			err = dlErr
+		}
+	}
+	return err
+}
+
+// Ready probes the wrapped sink and the dead letter sink, a sink without readiness probe is ready
+func (s *RetrySink) Ready(ctx context.Context) error {
+	if probe, ok := s.Sink.(common.ReadinessProbe); ok {
+		if err := probe.Ready(ctx); err != nil {
+			return err
+		}
+	}
+	if probe, ok := s.deadLetter.(common.ReadinessProbe); ok {
+		if err := probe.Ready(ctx); err != nil {
+			return fmt.Errorf("dead letter sink : %w", err)
+		}
+	}
+	return nil
+}
+
+// DropExisting drops the object of the wrapped sink, the dead letter sink is never dropped
+func (s *RetrySink) DropExisting(ctx context.Context, name string) error {
+	if cleaner, ok := s.Sink.(Cleaner); ok {
+		return cleaner.DropExisting(ctx, name)
+	}
+	return nil
+}
+
+// Cleanup drops the object created by the wrapped sink, the rows of the dead letter sink are kept
+func (s *RetrySink) Cleanup(ctx context.Context) error {
+	if cleaner, ok := s.Sink.(Cleaner); ok {
+		return cleaner.Cleanup(ctx)
+	}
+	return nil
+}
+
+func (s *RetrySink) Close() error {
+	err := s.Sink.Close()
+	if s.deadLetter != nil {
+		if dlErr := s.deadLetter.Close(); err == nil {
+			err = dlErr
+		}
+	}
+	return err
+}
+
+// GetStats returns the writes counted by the wrapper, the wrapped sink does not have to count them
+func (s *RetrySink) GetStats() *Stats {
+	s.lock.Lock()
+	stats := s.stats
+	s.lock.Unlock()
+
+	stats.Latency = s.latency.Summary()
+	return &stats
+}
+
+// Latency returns the histogram of the latency of the Write calls of the wrapped sink
+func (s *RetrySink) Latency() *LatencyHistogram {
+	return s.latency
+}
+
+// Retries returns the number of retried writes
+func (s *RetrySink) Retries() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.stats.Retries
+}
+
+// DeadLetterRows returns the number of rows sent to the dead letter destination
+func (s *RetrySink) DeadLetterRows() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.stats.DeadLetterRows
+}
@@ -1,6 +1,8 @@
 package source
 
 import (
+	"context"
+	"fmt"
 	"math"
 	"math/rand"
 	"sync"
@@ -16,9 +18,17 @@ import (
 const MaxUint = ^uint(0)
 const MaxInt = int(MaxUint >> 1)
 
+type SourceMode string
 type FieldType string
 type TimestampFormatType string
 
+const (
+	SOURCE_MODE_FIELDS  SourceMode = "fields"
+	SOURCE_MODE_TRACE   SourceMode = "trace"
+	SOURCE_MODE_MARKET  SourceMode = "market"
+	SOURCE_MODE_METRICS SourceMode = "metrics"
+)
+
 const (
 	FIELDTYPE_TIMESTAMP     FieldType = "timestamp"
 	FIELDTYPE_TIMESTAMP_INT FieldType = "timestamp_int"
@@ -30,18 +40,22 @@ const (
 	FIELDTYPE_ARRAY         FieldType = "array"
 	FIELDTYPE_GENERATE      FieldType = "generate"
 	FIELDTYPE_REGEX         FieldType = "regex"
+	// an increasing int64 from limit[0], default to 0, wrapping at limit[1] when it is set
+	FIELDTYPE_SEQUENCE FieldType = "sequence"
 )
 
 type Field struct {
-	Name              string        `json:"name"`
-	Type              FieldType     `json:"type"`
-	Range             []interface{} `json:"range,omitempty"`
-	Limit             []interface{} `json:"limit,omitempty"`
-	TimestampFormat   string        `json:"timestamp_format,omitempty"`
-	TimestampDelayMin int           `json:"timestamp_delay_min,omitempty"`
-	TimestampDelayMax int           `json:"timestamp_delay_max,omitempty"`
-	TimestampLocale   string        `json:"timestamp_locale,omitempty"`
-	Rule              string        `json:"rule,omitempty"`
+	Name               string                   `json:"name"`
+	Type               FieldType                `json:"type"`
+	Range              []interface{}            `json:"range,omitempty"`
+	Limit              []interface{}            `json:"limit,omitempty"`
+	TimestampFormat    string                   `json:"timestamp_format,omitempty"`
+	TimestampDelayMin  int                      `json:"timestamp_delay_min,omitempty"`
+	TimestampDelayMax  int                      `json:"timestamp_delay_max,omitempty"`
+	TimestampLocale    string                   `json:"timestamp_locale,omitempty"`
+	TimestampEncoding  common.TimestampEncoding `json:"timestamp_encoding,omitempty"`
+	TimestampPrecision string                   `json:"timestamp_precision,omitempty"`
+	Rule               string                   `json:"rule,omitempty"`
 }
 
 type Configuration struct {
@@ -52,22 +66,119 @@ type Configuration struct {
 	BatchNumber   int     `json:"batch_number"`
 	Fields        []Field `json:"fields"`
 	RandomEvent   bool    `json:"random_event"`
+	// max number of events generated per second by all streams, it replaces interval when it is set
+	Rate int `json:"rate,omitempty"`
+	// seed of the random values, random values are different on each run when it is not set
+	Seed int64 `json:"seed,omitempty"`
+
+	Mode        SourceMode            `json:"mode,omitempty"`
+	Trace       *TraceConfiguration   `json:"trace,omitempty"`
+	Market      *MarketConfiguration  `json:"market,omitempty"`
+	Metrics     *MetricsConfiguration `json:"metrics,omitempty"`
+	PayloadSize *PayloadSize          `json:"payload_size,omitempty"`
+}
+
+// Settings are the parameters of a source which can be changed while it generates, the unset ones are kept
+type Settings struct {
+	BatchSize *int `json:"batch_size,omitempty"`
+	Interval  *int `json:"interval,omitempty"`
+	Rate      *int `json:"rate,omitempty"`
+	// number of generating routines, the routines share the streams of the source
+	Concurrency *int `json:"concurency,omitempty"`
+}
+
+func (s Settings) Validate() error {
+	if s.BatchSize != nil && *s.BatchSize <= 0 {
+		return fmt.Errorf("batch size has to be positive")
+	}
+	if s.Interval != nil && *s.Interval < 0 {
+		return fmt.Errorf("interval cannot be negative")
+	}
+	if s.Rate != nil && *s.Rate < 0 {
+		return fmt.Errorf("rate cannot be negative")
+	}
+	if s.Concurrency != nil && *s.Concurrency <= 0 {
+		return fmt.Errorf("concurency has to be positive")
+	}
+	return nil
+}
+
+// modeGenerator generates the events of the source modes other than the field based one
+type modeGenerator interface {
+	generateBatch(size int) []common.Event
+	fields() []common.Field
 }
 
 type GeneratorEngine struct {
-	Config   Configuration
-	Finished bool
+	Config Configuration
+	faker  *fake.Faker
 
 	streamChannels []chan rxgo.Item
 	streams        []rxgo.Observable
 
 	waiter *sync.WaitGroup
 	lock   sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	cache           common.Event
+	padder          *payloadPadder
+	timestampCodecs []*common.TimestampCodec
+	modeGenerator   modeGenerator
+	sequences       []*sequence
+
+	// cancels each generating routine, the last ones are stopped first when the concurrency is reduced
+	routines []context.CancelFunc
+	// number of routines still generating, the streams are closed once it is back to 0
+	running int
+	closed  bool
+}
 
-	cache common.Event
+// sequence generates the values of a sequence field, it is shared by the streams of the source
+type sequence struct {
+	start int64
+	end   int64
+	next  int64
+	lock  sync.Mutex
 }
 
-var faker *fake.Faker
+func newSequence(f Field) (*sequence, error) {
+	limits := make([]int64, len(f.Limit))
+	for index, limit := range f.Limit {
+		value, ok := limit.(float64)
+		if !ok {
+			return nil, fmt.Errorf("invalid sequence field %s : limit has to be numbers", f.Name)
+		}
+		limits[index] = int64(value)
+	}
+
+	seq := &sequence{end: math.MaxInt64}
+	if len(limits) > 0 {
+		seq.start = limits[0]
+	}
+	if len(limits) > 1 {
+		seq.end = limits[1]
+	}
+	if seq.end <= seq.start {
+		return nil, fmt.Errorf("invalid sequence field %s : end of limit has to be greater than start", f.Name)
+	}
+	seq.next = seq.start
+	return seq, nil
+}
+
+func (q *sequence) value() int64 {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	value := q.next
+	q.next++
+	if q.next >= q.end {
+		q.next = q.start
+	}
+	return value
+}
+
+var mapTimeCodec, _ = common.NewTimestampCodec(common.TIMESTAMP_ENCODING_TIME, "", "", "")
+var mapStringTimeCodec, _ = common.NewTimestampCodec(common.TIMESTAMP_ENCODING_LAYOUT, "2006-01-02 15:04:05.000", "", "")
 
 func init() {
 	fake.AddFuncLookup("byear", fake.Info{
@@ -76,10 +187,9 @@ func init() {
 		Example:     "1950",
 		Output:      "int",
 		Generate: func(r *rand.Rand, m *fake.MapParams, info *fake.Info) (interface{}, error) {
-			return fake.IntRange(1925, 2002), nil
+			return 1925 + r.Intn(2002-1925+1), nil
 		},
 	})
-	faker = fake.New(0)
 }
 
 func NewGenarator(config Configuration) (*GeneratorEngine, error) {
@@ -99,17 +209,103 @@ func NewGenarator(config Configuration) (*GeneratorEngine, error) {
 		config.BatchNumber = MaxInt
 	}
 
+	timestampCodecs, err := newTimestampCodecs(config.Fields)
+	if err != nil {
+		return nil, err
+	}
+
+	sequences := make([]*sequence, len(config.Fields))
+	for index, f := range config.Fields {
+		if f.Type == FIELDTYPE_SEQUENCE {
+			if sequences[index], err = newSequence(f); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if config.Rate < 0 {
+		return nil, fmt.Errorf("rate cannot be negative")
+	}
+
+	// each source has its own random generator, so a seeded source does not change the values of the others
+	faker := fake.New(config.Seed)
+
+	var modeGenerator modeGenerator
+	switch config.Mode {
+	case "", SOURCE_MODE_FIELDS:
+	case SOURCE_MODE_TRACE:
+		if modeGenerator, err = newTraceGenerator(config.Trace, faker); err != nil {
+			return nil, fmt.Errorf("invalid trace configuration : %w", err)
+		}
+	case SOURCE_MODE_MARKET:
+		if modeGenerator, err = newMarketGenerator(config.Market, faker); err != nil {
+			return nil, fmt.Errorf("invalid market configuration : %w", err)
+		}
+	case SOURCE_MODE_METRICS:
+		if modeGenerator, err = newMetricsGenerator(config.Metrics, faker); err != nil {
+			return nil, fmt.Errorf("invalid metrics configuration : %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported source mode %s", config.Mode)
+	}
+
+	var padder *payloadPadder
+	if config.PayloadSize != nil {
+		p, err := newPayloadPadder(*config.PayloadSize, config.Fields, faker)
+		if err != nil {
+			return nil, fmt.Errorf("invalid payload size : %w", err)
+		}
+		padder = p
+	}
+
 	return &GeneratorEngine{
-		Config:         config,
-		Finished:       false,
-		streamChannels: streamChannels,
-		streams:        streams,
-		waiter:         waiter,
-		lock:           sync.Mutex{},
-		cache:          nil,
+		Config:          config,
+		faker:           faker,
+		streamChannels:  streamChannels,
+		streams:         streams,
+		waiter:          waiter,
+		lock:            sync.Mutex{},
+		cache:           nil,
+		padder:          padder,
+		timestampCodecs: timestampCodecs,
+		modeGenerator:   modeGenerator,
+		sequences:       sequences,
 	}, nil
 }
 
+// newTimestampCodecs creates the codec of each timestamp field, so invalid encodings or time zones
+// are reported when the job is created instead of failing in the generating go routines
+func newTimestampCodecs(fields []Field) ([]*common.TimestampCodec, error) {
+	codecs := make([]*common.TimestampCodec, len(fields))
+	for index, f := range fields {
+		if f.Type != FIELDTYPE_TIMESTAMP && f.Type != FIELDTYPE_TIMESTAMP_INT {
+			continue
+		}
+
+		encoding := f.TimestampEncoding
+		if encoding == "" {
+			if f.Type == FIELDTYPE_TIMESTAMP_INT {
+				encoding = common.TIMESTAMP_ENCODING_EPOCH_MS
+			} else if f.TimestampFormat != "" {
+				encoding = common.TIMESTAMP_ENCODING_LAYOUT
+			} else {
+				encoding = common.TIMESTAMP_ENCODING_TIME
+			}
+		}
+
+		codec, err := common.NewTimestampCodec(encoding, f.TimestampFormat, f.TimestampLocale, f.TimestampPrecision)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timestamp field %s : %w", f.Name, err)
+		}
+
+		if f.Type == FIELDTYPE_TIMESTAMP_INT && (!codec.IsEpoch() || codec.IsFloat()) {
+			return nil, fmt.Errorf("invalid timestamp field %s : timestamp_int only support integer epoch encodings", f.Name)
+		}
+		codecs[index] = codec
+	}
+	return codecs, nil
+}
+
 func DefaultConfiguration() Configuration {
 	defaultConfiguration := Configuration{
 		BatchSize:   3,
@@ -132,18 +328,80 @@ func DefaultConfiguration() Configuration {
 	return defaultConfiguration
 }
 
-func (s *GeneratorEngine) Start() {
-	s.Finished = false // lock?
+func (s *GeneratorEngine) Start(ctx context.Context) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.ctx, s.cancel = context.WithCancel(ctx)
 	for i := 0; i < s.Config.Concurrency; i++ {
-		go func(index int) {
-			s.run(index)
-		}(i)
+		s.startRoutine()
+	}
+}
+
+// startRoutine starts a generating routine, it has to be called with the lock held
+func (s *GeneratorEngine) startRoutine() {
+	ctx, cancel := context.WithCancel(s.ctx)
+	index := len(s.routines)
+	s.routines = append(s.routines, cancel)
+	s.running++
+	go s.run(ctx, index)
+}
+
+// routineDone closes the streams once no routine generates anymore
+func (s *GeneratorEngine) routineDone() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.running--
+	if s.running == 0 && !s.closed {
+		s.closed = true
+		for _, streamChannel := range s.streamChannels {
+			close(streamChannel)
+		}
 	}
 }
 
-func (s *GeneratorEngine) run(index int) error {
+// Update changes the settings of the source, the routines are started or stopped to match the concurrency
+func (s *GeneratorEngine) Update(settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if settings.Concurrency != nil && s.ctx != nil {
+		if s.closed {
+			return fmt.Errorf("source is finished")
+		}
+		for len(s.routines) < *settings.Concurrency {
+			s.startRoutine()
+		}
+		for len(s.routines) > *settings.Concurrency {
+			last := len(s.routines) - 1
+			s.routines[last]()
+			s.routines = s.routines[:last]
+		}
+	}
+
+	if settings.BatchSize != nil {
+		s.Config.BatchSize = *settings.BatchSize
+	}
+	if settings.Interval != nil {
+		s.Config.Interval = *settings.Interval
+	}
+	if settings.Rate != nil {
+		s.Config.Rate = *settings.Rate
+	}
+	if settings.Concurrency != nil {
+		s.Config.Concurrency = *settings.Concurrency
+	}
+	return nil
+}
+
+// run generates batches into one of the streams, the routines share the streams when there are more
+// routines than streams
+func (s *GeneratorEngine) run(ctx context.Context, index int) error {
 	log.Logger().Infof("start generate routine with index %d, batch number %d ", index, s.Config.BatchNumber)
-	streamChannel := s.streamChannels[index]
+	streamChannel := s.streamChannels[index%len(s.streamChannels)]
+	defer s.routineDone()
 	number := s.Config.BatchNumber
 
 	if number == 0 {
@@ -151,25 +409,53 @@ func (s *GeneratorEngine) run(index int) error {
 	}
 
 	for i := 0; i < number; i++ {
-		if s.Finished {
+		if ctx.Err() != nil {
 			log.Logger().Warnf("run generator finished %d", index)
 			break
 		}
 		events := s.generateBatchEvent()
-		streamChannel <- rxgo.Of(events)
-		if s.Config.IntervalDelta > 0 {
-			interval := faker.IntRange(s.Config.Interval-s.Config.IntervalDelta, s.Config.Interval+s.Config.IntervalDelta)
-			time.Sleep(time.Duration(interval) * time.Millisecond)
-		} else {
-			time.Sleep(time.Duration(s.Config.Interval) * time.Millisecond)
+		select {
+		case streamChannel <- rxgo.Of(events):
+		case <-ctx.Done():
+			return nil
+		}
+
+		timer := time.NewTimer(s.interval())
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
 		}
 	}
-	close(streamChannel)
 	return nil
 }
 
+// interval returns the time to wait between two batches of a stream
+func (s *GeneratorEngine) interval() time.Duration {
+	s.lock.Lock()
+	config := s.Config
+	s.lock.Unlock()
+
+	if config.Rate > 0 {
+		// each routine generates its share of the rate
+		events := float64(config.BatchSize * config.Concurrency)
+		return time.Duration(events / float64(config.Rate) * float64(time.Second))
+	}
+
+	interval := config.Interval
+	if config.IntervalDelta > 0 {
+		interval = s.faker.IntRange(config.Interval-config.IntervalDelta, config.Interval+config.IntervalDelta)
+	}
+	return time.Duration(interval) * time.Millisecond
+}
+
+// Stop stops generating, the batches already sent to the streams are still delivered
 func (s *GeneratorEngine) Stop() {
-	s.Finished = true
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cancel != nil {
+		s.cancel()
+	}
 }
 
 func (s *GeneratorEngine) GetStreams() []rxgo.Observable {
@@ -178,10 +464,10 @@ func (s *GeneratorEngine) GetStreams() []rxgo.Observable {
 
 func (s *GeneratorEngine) Read() []common.Event {
 	result := make([]common.Event, 0)
-	for i := 0; i < s.Config.Concurrency; i++ {
-		observable := s.streams[i].Take(1) // must after generate start
+	for _, stream := range s.streams {
+		observable := stream.Take(1) // must after generate start
 		for item := range observable.Observe() {
-			result = append(result, item.V.(common.Event))
+			result = append(result, item.V.([]common.Event)...)
 		}
 	}
 
@@ -189,7 +475,9 @@ func (s *GeneratorEngine) Read() []common.Event {
 }
 
 func (s *GeneratorEngine) IsFinished() bool {
-	return s.Finished
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.ctx != nil && s.ctx.Err() != nil
 }
 
 func (s *GeneratorEngine) GetFields() []common.Field {
@@ -202,43 +490,30 @@ func (s *GeneratorEngine) GetFields() []common.Field {
 		}
 	}
 
-	return fields
-}
+	if s.modeGenerator != nil {
+		fields = s.modeGenerator.fields()
+	}
 
-func makeTimestampInt(timestampDeleyMin int, timestampDeleyMax int) int64 {
-	now := time.Now().UTC()
-	// delay := faker.Number(timestampDeleyMin, timestampDeleyMax)
-	nsec := now.UnixMilli()
-	//t := nsec - int64(delay)
-	return nsec
+	if s.padder != nil {
+		if filler := s.padder.field(); filler != nil {
+			fields = append(fields, *filler)
+		}
+	}
+
+	return fields
 }
 
+// the delay settings are kept for compatibility, the generated timestamp is always now
 func makeTimestamp(timestampDeleyMin int, timestampDeleyMax int) time.Time {
-	t := makeTimestampInt(timestampDeleyMin, timestampDeleyMax)
-	tm := time.UnixMilli(int64(t)).UTC()
-	return tm
+	// delay := faker.Number(timestampDeleyMin, timestampDeleyMax)
+	return time.Now().UTC()
 }
 
-func makeTimestampString(format string, timestampDeleyMin int, timestampDeleyMax int, locale string) string {
-	t := makeTimestampInt(timestampDeleyMin, timestampDeleyMax)
-
-	// location: "America/Los_Angeles"
-	if locale != "" {
-		location, err := time.LoadLocation(locale)
-		if err != nil {
-			panic(err)
-		}
-
-		timestamp := time.UnixMilli(int64(t)).In(location)
-		return timestamp.Format(format)
-	}
-
-	timestamp := time.UnixMilli(int64(t)).UTC()
-	result := timestamp.Format(format)
-	return result
+func makeTimestampValue(codec *common.TimestampCodec, timestampDeleyMin int, timestampDeleyMax int) interface{} {
+	return codec.Encode(makeTimestamp(timestampDeleyMin, timestampDeleyMax))
 }
 
-func makeInt(ranges []int, limits []int) int {
+func makeInt(faker *fake.Faker, ranges []int, limits []int) int {
 	range_length := len(ranges)
 	limit_length := len(limits)
 
@@ -252,7 +527,7 @@ func makeInt(ranges []int, limits []int) int {
 	return 0
 }
 
-func makeFloat(ranges []float32, limits []float32) float32 {
+func makeFloat(faker *fake.Faker, ranges []float32, limits []float32) float32 {
 	range_length := len(ranges)
 	limit_length := len(limits)
 
@@ -266,11 +541,11 @@ func makeFloat(ranges []float32, limits []float32) float32 {
 	return 0.0
 }
 
-func makeBool() bool {
+func makeBool(faker *fake.Faker) bool {
 	return faker.Bool()
 }
 
-func makeString(ranges []string) string {
+func makeString(faker *fake.Faker, ranges []string) string {
 	range_length := len(ranges)
 
 	if range_length > 0 {
@@ -280,46 +555,39 @@ func makeString(ranges []string) string {
 	return faker.LetterN(8)
 }
 
-func makeMap() map[string]interface{} {
+func makeMap(faker *fake.Faker) map[string]interface{} {
 	result := make(map[string]interface{})
-	result["key1"] = makeBool()
-	result["key2"] = makeInt([]int{}, []int{0, 10})
-	result["key3"] = makeString([]string{})
-	result["key4"] = makeTimestamp(0, 0)
-	result["key5"] = makeTimestampString("2006-01-02 15:04:05.000", 0, 0, "")
+	result["key1"] = makeBool(faker)
+	result["key2"] = makeInt(faker, []int{}, []int{0, 10})
+	result["key3"] = makeString(faker, []string{})
+	result["key4"] = makeTimestampValue(mapTimeCodec, 0, 0)
+	result["key5"] = makeTimestampValue(mapStringTimeCodec, 0, 0)
 
 	return result
 }
 
-func makeArray() []interface{} {
+func makeArray(faker *fake.Faker) []interface{} {
 	result := make([]interface{}, 3)
 	for i := 0; i < 3; i++ {
-		result[i] = makeInt([]int{}, []int{0, 10})
+		result[i] = makeInt(faker, []int{}, []int{0, 10})
 	}
 
 	return result
 }
 
-func makeGenerate(rule string) string {
+func makeGenerate(faker *fake.Faker, rule string) string {
 	return faker.Generate(rule)
 }
 
-func makeRegex(rule string) string {
+func makeRegex(faker *fake.Faker, rule string) string {
 	return faker.Regex(rule)
 }
 
-func makeValue(sourceType FieldType, sourceRange []interface{}, sourceLimit []interface{},
-	timestampFormat string, timestampDelayMin int, timestampDelayMax int, timestampLocale string, rule string) interface{} {
+func makeValue(faker *fake.Faker, sourceType FieldType, sourceRange []interface{}, sourceLimit []interface{},
+	timestampCodec *common.TimestampCodec, timestampDelayMin int, timestampDelayMax int, rule string) interface{} {
 	switch s := sourceType; s {
-	case FIELDTYPE_TIMESTAMP:
-		if timestampFormat == "" {
-			return makeTimestamp(timestampDelayMin, timestampDelayMax)
-		} else {
-			return makeTimestampString(timestampFormat, timestampDelayMin, timestampDelayMax, timestampLocale)
-		}
-
-	case FIELDTYPE_TIMESTAMP_INT:
-		return makeTimestampInt(timestampDelayMin, timestampDelayMax)
+	case FIELDTYPE_TIMESTAMP, FIELDTYPE_TIMESTAMP_INT:
+		return makeTimestampValue(timestampCodec, timestampDelayMin, timestampDelayMax)
 
 	case FIELDTYPE_STRING:
 		ranges := make([]string, len(sourceRange))
@@ -327,7 +595,7 @@ func makeValue(sourceType FieldType, sourceRange []interface{}, sourceLimit []in
 			ranges[i] = sourceRange[i].(string)
 		}
 
-		return makeString(ranges)
+		return makeString(faker, ranges)
 	case FIELDTYPE_INT:
 		ranges := make([]int, len(sourceRange))
 		for i := 0; i < len(sourceRange); i++ {
@@ -338,7 +606,7 @@ func makeValue(sourceType FieldType, sourceRange []interface{}, sourceLimit []in
 		for i := 0; i < len(sourceLimit); i++ {
 			limits[i] = int(sourceLimit[i].(float64))
 		}
-		return makeInt(ranges, limits)
+		return makeInt(faker, ranges, limits)
 	case FIELDTYPE_FLOAT:
 		ranges := make([]float32, len(sourceRange))
 		for i := 0; i < len(sourceRange); i++ {
@@ -349,34 +617,39 @@ func makeValue(sourceType FieldType, sourceRange []interface{}, sourceLimit []in
 		for i := 0; i < len(sourceLimit); i++ {
 			limits[i] = float32(sourceLimit[i].(float64))
 		}
-		return makeFloat(ranges, limits)
+		return makeFloat(faker, ranges, limits)
 	case FIELDTYPE_BOOL:
-		return makeBool()
+		return makeBool(faker)
 	case FIELDTYPE_MAP:
-		return makeMap()
+		return makeMap(faker)
 	case FIELDTYPE_ARRAY:
-		return makeArray()
+		return makeArray(faker)
 	case FIELDTYPE_GENERATE:
-		return makeGenerate(rule)
+		return makeGenerate(faker, rule)
 	case FIELDTYPE_REGEX:
-		return makeRegex(rule)
+		return makeRegex(faker, rule)
 	default:
 		return nil
 	}
 }
 
 func (s *GeneratorEngine) generateEvent() common.Event {
-	// cache event expect time fields
-	if !s.Config.RandomEvent && s.cache != nil {
+	// cache event expect time fields, the cache is shared by the generating routines
+	s.lock.Lock()
+	cache := s.cache
+	s.lock.Unlock()
+	if !s.Config.RandomEvent && cache != nil {
 		event := make(common.Event)
-		for k, v := range s.cache {
+		for k, v := range cache {
 			event[k] = v
 		}
 
 		// keep time and value random as these are critical for latency caculation
-		for _, f := range s.Config.Fields {
-			if f.Name == "time" || f.Name == "value" {
-				event[f.Name] = makeValue(f.Type, f.Range, f.Limit, f.TimestampFormat, f.TimestampDelayMin, f.TimestampDelayMax, f.TimestampLocale, f.Rule)
+		for i, f := range s.Config.Fields {
+			if f.Type == FIELDTYPE_SEQUENCE {
+				event[f.Name] = s.sequences[i].value()
+			} else if f.Name == "time" || f.Name == "value" {
+				event[f.Name] = makeValue(s.faker, f.Type, f.Range, f.Limit, s.timestampCodecs[i], f.TimestampDelayMin, f.TimestampDelayMax, f.Rule)
 			}
 		}
 		return event
@@ -385,20 +658,48 @@ func (s *GeneratorEngine) generateEvent() common.Event {
 	value := make(common.Event)
 	fields := s.Config.Fields
 
-	for _, f := range fields {
-		value[f.Name] = makeValue(f.Type, f.Range, f.Limit, f.TimestampFormat, f.TimestampDelayMin, f.TimestampDelayMax, f.TimestampLocale, f.Rule)
+	for i, f := range fields {
+		if f.Type == FIELDTYPE_SEQUENCE {
+			value[f.Name] = s.sequences[i].value()
+			continue
+		}
+		value[f.Name] = makeValue(s.faker, f.Type, f.Range, f.Limit, s.timestampCodecs[i], f.TimestampDelayMin, f.TimestampDelayMax, f.Rule)
 	}
 
-	s.cache = value
+	// padding is applied to the returned event, keep the cached one untouched
+	cache = make(common.Event, len(value))
+	for k, v := range value {
+		cache[k] = v
+	}
+	s.lock.Lock()
+	s.cache = cache
+	s.lock.Unlock()
 	return value
 }
 
 func (s *GeneratorEngine) generateBatchEvent() []common.Event {
+	s.lock.Lock()
 	batchSize := s.Config.BatchSize
+	s.lock.Unlock()
+
+	if s.modeGenerator != nil {
+		events := s.modeGenerator.generateBatch(batchSize)
+		if s.padder != nil {
+			for _, event := range events {
+				s.padder.pad(event)
+			}
+		}
+		return events
+	}
+
 	events := make([]common.Event, batchSize)
 
 	for i := 0; i < batchSize; i++ {
-		events[i] = s.generateEvent()
+		event := s.generateEvent()
+		if s.padder != nil {
+			s.padder.pad(event)
+		}
+		events[i] = event
 	}
 	return events
 }
@@ -1,15 +1,20 @@
 package source
 
 import (
+	"context"
+
 	rxgo "github.com/reactivex/rxgo/v2"
 	"github.com/timeplus-io/chameleon/generator/internal/common"
 )
 
 type Source interface {
-	Start()
+	// Start starts generating, generating stops when the context is done or Stop is called
+	Start(ctx context.Context)
 	Stop()
 	Read() []common.Event
 	GetStreams() []rxgo.Observable
 	IsFinished() bool
 	GetFields() []common.Field
+	// Update changes the settings of the source, also while it generates
+	Update(settings Settings) error
 }
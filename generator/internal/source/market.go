@@ -0,0 +1,353 @@
+package source
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	fake "github.com/brianvoe/gofakeit/v6"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+)
+
+// fields of the events generated in market mode, trades, quotes and book updates share one schema
+const (
+	MARKET_FIELD_EVENT_TYPE = "event_type"
+	MARKET_FIELD_SYMBOL     = "symbol"
+	MARKET_FIELD_TIME       = "time"
+	MARKET_FIELD_SEQUENCE   = "sequence"
+	MARKET_FIELD_PRICE      = "price"
+	MARKET_FIELD_SIZE       = "size"
+	MARKET_FIELD_SIDE       = "side"
+	MARKET_FIELD_LEVEL      = "level"
+	MARKET_FIELD_BID_PRICE  = "bid_price"
+	MARKET_FIELD_BID_SIZE   = "bid_size"
+	MARKET_FIELD_ASK_PRICE  = "ask_price"
+	MARKET_FIELD_ASK_SIZE   = "ask_size"
+)
+
+const (
+	MARKET_EVENT_TRADE = "trade"
+	MARKET_EVENT_QUOTE = "quote"
+	MARKET_EVENT_BOOK  = "book"
+
+	MARKET_SIDE_BUY  = "buy"
+	MARKET_SIDE_SELL = "sell"
+	MARKET_SIDE_BID  = "bid"
+	MARKET_SIDE_ASK  = "ask"
+)
+
+// seconds of a trading year, 252 days of 6.5 hours, used to scale the annualized drift and volatility
+const tradingSecondsPerYear = 252 * 6.5 * 3600
+
+type MarketSession struct {
+	// open and close time of the session as HH:MM in the session time zone
+	Open     string `json:"open"`
+	Close    string `json:"close"`
+	TimeZone string `json:"timezone,omitempty"`
+	// whether saturday and sunday are trading days
+	Weekends bool `json:"weekends,omitempty"`
+}
+
+type MarketConfiguration struct {
+	Symbols      []string `json:"symbols,omitempty"`
+	SymbolCount  int      `json:"symbol_count,omitempty"`
+	InitialPrice float64  `json:"initial_price,omitempty"`
+	// annualized drift and volatility of the geometric brownian motion
+	Drift      float64 `json:"drift,omitempty"`
+	Volatility float64 `json:"volatility,omitempty"`
+	// speed up the simulated clock, e.g. 60 makes one real second a simulated minute
+	TimeScale    float64 `json:"time_scale,omitempty"`
+	TickSize     float64 `json:"tick_size,omitempty"`
+	SpreadBps    float64 `json:"spread_bps,omitempty"`
+	TradeSizeMin int     `json:"trade_size_min,omitempty"`
+	TradeSizeMax int     `json:"trade_size_max,omitempty"`
+	BookDepth    int     `json:"book_depth,omitempty"`
+	// probability of each event type, quotes take the rest
+	TradeRatio float64        `json:"trade_ratio,omitempty"`
+	BookRatio  float64        `json:"book_ratio,omitempty"`
+	Session    *MarketSession `json:"session,omitempty"`
+}
+
+type symbolState struct {
+	symbol   string
+	price    float64
+	bid      float64
+	ask      float64
+	bidSize  int
+	askSize  int
+	sequence int
+	updated  time.Time
+}
+
+type marketSession struct {
+	open     int // minutes of the day
+	close    int
+	location *time.Location
+	weekends bool
+}
+
+type marketGenerator struct {
+	faker   *fake.Faker
+	config  MarketConfiguration
+	symbols []*symbolState
+	session *marketSession
+	lock    sync.Mutex
+}
+
+func parseSessionTime(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid session time %s, expect HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func newMarketGenerator(config *MarketConfiguration, faker *fake.Faker) (*marketGenerator, error) {
+	c := MarketConfiguration{}
+	if config != nil {
+		c = *config
+	}
+
+	if c.SymbolCount <= 0 {
+		c.SymbolCount = 10
+	}
+	if c.Volatility == 0 {
+		c.Volatility = 0.2
+	}
+	if c.TimeScale == 0 {
+		c.TimeScale = 1
+	}
+	if c.TickSize == 0 {
+		c.TickSize = 0.01
+	}
+	if c.SpreadBps == 0 {
+		c.SpreadBps = 5
+	}
+	if c.TradeSizeMin == 0 {
+		c.TradeSizeMin = 1
+	}
+	if c.TradeSizeMax == 0 {
+		c.TradeSizeMax = 500
+	}
+	if c.BookDepth == 0 {
+		c.BookDepth = 5
+	}
+	if c.TradeRatio == 0 && c.BookRatio == 0 {
+		c.TradeRatio = 0.3
+		c.BookRatio = 0.3
+	}
+
+	if c.Volatility < 0 || c.TimeScale < 0 || c.TickSize < 0 || c.SpreadBps < 0 || c.InitialPrice < 0 {
+		return nil, fmt.Errorf("volatility, time scale, tick size, spread and initial price cannot be negative")
+	}
+	if c.TradeSizeMin < 0 || c.TradeSizeMax < c.TradeSizeMin {
+		return nil, fmt.Errorf("invalid trade size range [%d, %d]", c.TradeSizeMin, c.TradeSizeMax)
+	}
+	if c.TradeRatio < 0 || c.BookRatio < 0 || c.TradeRatio+c.BookRatio > 1 {
+		return nil, fmt.Errorf("trade ratio and book ratio must be positive and add up to at most 1")
+	}
+
+	var session *marketSession
+	if c.Session != nil {
+		open, err := parseSessionTime(c.Session.Open)
+		if err != nil {
+			return nil, err
+		}
+		closeTime, err := parseSessionTime(c.Session.Close)
+		if err != nil {
+			return nil, err
+		}
+		if closeTime <= open {
+			return nil, fmt.Errorf("session close %s must be after open %s", c.Session.Close, c.Session.Open)
+		}
+
+		location := time.UTC
+		if c.Session.TimeZone != "" {
+			if location, err = time.LoadLocation(c.Session.TimeZone); err != nil {
+				return nil, fmt.Errorf("invalid session time zone %s : %w", c.Session.TimeZone, err)
+			}
+		}
+		session = &marketSession{open: open, close: closeTime, location: location, weekends: c.Session.Weekends}
+	}
+
+	names := c.Symbols
+	if len(names) == 0 {
+		names = makeSymbols(faker, c.SymbolCount)
+	}
+
+	now := time.Now().UTC()
+	symbols := make([]*symbolState, len(names))
+	for index, name := range names {
+		price := c.InitialPrice
+		if price == 0 {
+			price = faker.Float64Range(10, 500)
+		}
+		state := &symbolState{symbol: name, price: price, updated: now}
+		symbols[index] = state
+	}
+
+	g := &marketGenerator{
+		faker:   faker,
+		config:  c,
+		symbols: symbols,
+		session: session,
+	}
+
+	for _, state := range symbols {
+		g.updateQuote(state)
+	}
+	return g, nil
+}
+
+// makeSymbols generates unique tickers of 3 or 4 upper case letters
+func makeSymbols(faker *fake.Faker, count int) []string {
+	symbols := make([]string, 0, count)
+	seen := make(map[string]bool)
+	for len(symbols) < count {
+		symbol := strings.ToUpper(faker.LetterN(uint(faker.IntRange(3, 4))))
+		if seen[symbol] {
+			continue
+		}
+		seen[symbol] = true
+		symbols = append(symbols, symbol)
+	}
+	return symbols
+}
+
+func (g *marketGenerator) isOpen(t time.Time) bool {
+	if g.session == nil {
+		return true
+	}
+
+	local := t.In(g.session.location)
+	if !g.session.weekends && (local.Weekday() == time.Saturday || local.Weekday() == time.Sunday) {
+		return false
+	}
+
+	minutes := local.Hour()*60 + local.Minute()
+	return minutes >= g.session.open && minutes < g.session.close
+}
+
+func (g *marketGenerator) roundToTick(price float64) float64 {
+	if g.config.TickSize <= 0 {
+		return price
+	}
+	return math.Round(price/g.config.TickSize) * g.config.TickSize
+}
+
+// advance moves the price of the symbol along a geometric brownian motion for the elapsed time
+func (g *marketGenerator) advance(state *symbolState, now time.Time) {
+	dt := now.Sub(state.updated).Seconds() * g.config.TimeScale / tradingSecondsPerYear
+	state.updated = now
+	if dt <= 0 {
+		return
+	}
+
+	sigma := g.config.Volatility
+	z := g.faker.Rand.NormFloat64()
+	state.price *= math.Exp((g.config.Drift-sigma*sigma/2)*dt + sigma*math.Sqrt(dt)*z)
+}
+
+// updateQuote sets the best bid and ask around the current price, the spread is at least one tick
+func (g *marketGenerator) updateQuote(state *symbolState) {
+	halfSpread := state.price * g.config.SpreadBps / 10000 / 2 * g.faker.Float64Range(0.5, 1.5)
+	state.bid = g.roundToTick(state.price - halfSpread)
+	state.ask = g.roundToTick(state.price + halfSpread)
+	if state.ask <= state.bid {
+		state.ask = g.roundToTick(state.bid + g.config.TickSize)
+	}
+	state.bidSize = g.faker.IntRange(1, 100) * 100
+	state.askSize = g.faker.IntRange(1, 100) * 100
+}
+
+func (g *marketGenerator) newEvent(state *symbolState, eventType string, now time.Time) common.Event {
+	state.sequence++
+	return common.Event{
+		MARKET_FIELD_EVENT_TYPE: eventType,
+		MARKET_FIELD_SYMBOL:     state.symbol,
+		MARKET_FIELD_TIME:       now,
+		MARKET_FIELD_SEQUENCE:   state.sequence,
+		MARKET_FIELD_PRICE:      0.0,
+		MARKET_FIELD_SIZE:       0,
+		MARKET_FIELD_SIDE:       "",
+		MARKET_FIELD_LEVEL:      0,
+		MARKET_FIELD_BID_PRICE:  state.bid,
+		MARKET_FIELD_BID_SIZE:   state.bidSize,
+		MARKET_FIELD_ASK_PRICE:  state.ask,
+		MARKET_FIELD_ASK_SIZE:   state.askSize,
+	}
+}
+
+func (g *marketGenerator) tick(state *symbolState, now time.Time) common.Event {
+	g.advance(state, now)
+
+	dice := g.faker.Float64()
+	switch {
+	case dice < g.config.TradeRatio:
+		// trades execute against the last published quote, buys lift the ask and sells hit the bid
+		event := g.newEvent(state, MARKET_EVENT_TRADE, now)
+		event[MARKET_FIELD_SIZE] = g.faker.IntRange(g.config.TradeSizeMin, g.config.TradeSizeMax)
+		if g.faker.Bool() {
+			event[MARKET_FIELD_SIDE] = MARKET_SIDE_BUY
+			event[MARKET_FIELD_PRICE] = state.ask
+		} else {
+			event[MARKET_FIELD_SIDE] = MARKET_SIDE_SELL
+			event[MARKET_FIELD_PRICE] = state.bid
+		}
+		return event
+	case dice < g.config.TradeRatio+g.config.BookRatio:
+		level := g.faker.IntRange(1, g.config.BookDepth)
+		event := g.newEvent(state, MARKET_EVENT_BOOK, now)
+		event[MARKET_FIELD_LEVEL] = level
+		event[MARKET_FIELD_SIZE] = g.faker.IntRange(0, 100) * 100
+		if g.faker.Bool() {
+			event[MARKET_FIELD_SIDE] = MARKET_SIDE_BID
+			event[MARKET_FIELD_PRICE] = g.roundToTick(state.bid - float64(level-1)*g.config.TickSize)
+		} else {
+			event[MARKET_FIELD_SIDE] = MARKET_SIDE_ASK
+			event[MARKET_FIELD_PRICE] = g.roundToTick(state.ask + float64(level-1)*g.config.TickSize)
+		}
+		return event
+	default:
+		g.updateQuote(state)
+		return g.newEvent(state, MARKET_EVENT_QUOTE, now)
+	}
+}
+
+// generateBatch generates the given number of ticks on random symbols, nothing is generated out of the session
+func (g *marketGenerator) generateBatch(size int) []common.Event {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	now := time.Now().UTC()
+	if !g.isOpen(now) {
+		return []common.Event{}
+	}
+
+	events := make([]common.Event, size)
+	for i := 0; i < size; i++ {
+		state := g.symbols[g.faker.IntRange(0, len(g.symbols)-1)]
+		events[i] = g.tick(state, now)
+	}
+	return events
+}
+
+func (g *marketGenerator) fields() []common.Field {
+	return []common.Field{
+		{Name: MARKET_FIELD_EVENT_TYPE, Type: string(FIELDTYPE_STRING)},
+		{Name: MARKET_FIELD_SYMBOL, Type: string(FIELDTYPE_STRING)},
+		{Name: MARKET_FIELD_TIME, Type: string(FIELDTYPE_TIMESTAMP)},
+		{Name: MARKET_FIELD_SEQUENCE, Type: string(FIELDTYPE_INT)},
+		{Name: MARKET_FIELD_PRICE, Type: string(FIELDTYPE_FLOAT)},
+		{Name: MARKET_FIELD_SIZE, Type: string(FIELDTYPE_INT)},
+		{Name: MARKET_FIELD_SIDE, Type: string(FIELDTYPE_STRING)},
+		{Name: MARKET_FIELD_LEVEL, Type: string(FIELDTYPE_INT)},
+		{Name: MARKET_FIELD_BID_PRICE, Type: string(FIELDTYPE_FLOAT)},
+		{Name: MARKET_FIELD_BID_SIZE, Type: string(FIELDTYPE_INT)},
+		{Name: MARKET_FIELD_ASK_PRICE, Type: string(FIELDTYPE_FLOAT)},
+		{Name: MARKET_FIELD_ASK_SIZE, Type: string(FIELDTYPE_INT)},
+	}
+}
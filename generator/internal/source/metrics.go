@@ -0,0 +1,401 @@
+package source
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	fake "github.com/brianvoe/gofakeit/v6"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+// fields of the samples generated in metrics mode
+const (
+	METRICS_FIELD_NAME   = "name"
+	METRICS_FIELD_TYPE   = "type"
+	METRICS_FIELD_LABELS = "labels"
+	METRICS_FIELD_VALUE  = "value"
+	METRICS_FIELD_TIME   = "time"
+
+	METRICS_LABEL_LE = "le"
+)
+
+type MetricType string
+
+const (
+	METRIC_TYPE_COUNTER   MetricType = "counter"
+	METRIC_TYPE_GAUGE     MetricType = "gauge"
+	METRIC_TYPE_HISTOGRAM MetricType = "histogram"
+)
+
+// upper limit of the number of series, mainly to catch a cardinality typo before allocating the state
+const maxMetricSeries = 100_000_000
+
+var defaultHistogramBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type MetricDimension struct {
+	Name string `json:"name"`
+	// label values, `<name>-<index>` values are generated when only the cardinality is set
+	Values      []string `json:"values,omitempty"`
+	Cardinality int      `json:"cardinality,omitempty"`
+}
+
+type MetricFamily struct {
+	Name string     `json:"name"`
+	Type MetricType `json:"type"`
+	// names of the dimensions used as labels of the family, default to all dimensions
+	Labels []string `json:"labels,omitempty"`
+	// counter increase per second of each series, histogram observations per second of each series
+	RateMin float64 `json:"rate_min,omitempty"`
+	RateMax float64 `json:"rate_max,omitempty"`
+	// gauge value range
+	Min float64 `json:"min,omitempty"`
+	Max float64 `json:"max,omitempty"`
+	// histogram bucket upper bounds, the +Inf bucket is always added
+	Buckets []float64 `json:"buckets,omitempty"`
+}
+
+type MetricsConfiguration struct {
+	Dimensions []MetricDimension `json:"dimensions,omitempty"`
+	Families   []MetricFamily    `json:"families,omitempty"`
+	// interval in ms between two scrapes, every series has one sample per scrape
+	ScrapeInterval int `json:"scrape_interval,omitempty"`
+	// add each dimension as a column besides the labels map
+	FlattenLabels bool `json:"flatten_labels,omitempty"`
+}
+
+func DefaultMetricsConfiguration() MetricsConfiguration {
+	return MetricsConfiguration{
+		Dimensions: []MetricDimension{
+			{Name: "instance", Cardinality: 10},
+			{Name: "method", Values: []string{"GET", "POST", "PUT", "DELETE"}},
+			{Name: "status", Values: []string{"200", "404", "500"}},
+		},
+		Families: []MetricFamily{
+			{Name: "http_requests_total", Type: METRIC_TYPE_COUNTER},
+			{Name: "http_request_duration_seconds", Type: METRIC_TYPE_HISTOGRAM, Labels: []string{"instance", "method"}},
+			{Name: "process_resident_memory_bytes", Type: METRIC_TYPE_GAUGE, Labels: []string{"instance"}, Min: 1e8, Max: 1e9},
+		},
+	}
+}
+
+type metricFamily struct {
+	MetricFamily
+	dimensions []*MetricDimension
+	series     int
+	// number of samples of each series per scrape, buckets plus sum and count for histograms
+	samples int
+	// last value of counters and gauges, cumulative bucket counts followed by sum and count for histograms
+	state [][]float64
+}
+
+type metricsGenerator struct {
+	faker     *fake.Faker
+	config    MetricsConfiguration
+	families  []*metricFamily
+	interval  time.Duration
+	histogram bool
+
+	// position of the next sample
+	family int
+	series int
+	sample int
+
+	// whether all series of the current scrape have been generated
+	scraped        bool
+	scrapeTime     time.Time
+	lastScrapeTime time.Time
+	lock           sync.Mutex
+}
+
+func newMetricsGenerator(config *MetricsConfiguration, faker *fake.Faker) (*metricsGenerator, error) {
+	c := DefaultMetricsConfiguration()
+	if config != nil {
+		c = *config
+	}
+
+	if c.ScrapeInterval == 0 {
+		c.ScrapeInterval = 10000
+	}
+	if c.ScrapeInterval < 0 {
+		return nil, fmt.Errorf("scrape interval cannot be negative")
+	}
+
+	if len(c.Families) == 0 {
+		return nil, fmt.Errorf("at least one metric family is required")
+	}
+
+	reserved := map[string]bool{
+		METRICS_FIELD_NAME: true, METRICS_FIELD_TYPE: true, METRICS_FIELD_LABELS: true,
+		METRICS_FIELD_VALUE: true, METRICS_FIELD_TIME: true, METRICS_LABEL_LE: true,
+	}
+	dimensions := make(map[string]*MetricDimension)
+	for i := range c.Dimensions {
+		dimension := &c.Dimensions[i]
+		if len(dimension.Values) > 0 {
+			dimension.Cardinality = len(dimension.Values)
+		}
+		if dimension.Cardinality <= 0 {
+			return nil, fmt.Errorf("dimension %s requires values or a positive cardinality", dimension.Name)
+		}
+		if _, ok := dimensions[dimension.Name]; ok {
+			return nil, fmt.Errorf("dimension %s is defined more than once", dimension.Name)
+		}
+		if c.FlattenLabels && reserved[dimension.Name] {
+			return nil, fmt.Errorf("dimension %s conflicts with a metric field", dimension.Name)
+		}
+		dimensions[dimension.Name] = dimension
+	}
+
+	families := make([]*metricFamily, len(c.Families))
+	histogram := false
+	total := 0
+	for index, f := range c.Families {
+		family := &metricFamily{MetricFamily: f, series: 1}
+
+		labels := f.Labels
+		if len(labels) == 0 {
+			for _, dimension := range c.Dimensions {
+				labels = append(labels, dimension.Name)
+			}
+		}
+		for _, label := range labels {
+			dimension, ok := dimensions[label]
+			if !ok {
+				return nil, fmt.Errorf("metric %s uses undefined dimension %s", f.Name, label)
+			}
+			family.dimensions = append(family.dimensions, dimension)
+			family.series *= dimension.Cardinality
+			if family.series > maxMetricSeries {
+				return nil, fmt.Errorf("metric %s has more than %d series", f.Name, maxMetricSeries)
+			}
+		}
+
+		switch f.Type {
+		case METRIC_TYPE_COUNTER:
+			if family.RateMax == 0 {
+				family.RateMax = 10
+			}
+			family.samples = 1
+		case METRIC_TYPE_GAUGE:
+			if family.Max == 0 && family.Min == 0 {
+				family.Max = 100
+			}
+			family.samples = 1
+		case METRIC_TYPE_HISTOGRAM:
+			if family.RateMax == 0 {
+				family.RateMax = 5
+			}
+			if len(family.Buckets) == 0 {
+				family.Buckets = defaultHistogramBuckets
+			}
+			for i := 1; i < len(family.Buckets); i++ {
+				if family.Buckets[i] <= family.Buckets[i-1] {
+					return nil, fmt.Errorf("buckets of metric %s must be increasing", f.Name)
+				}
+			}
+			family.samples = len(family.Buckets) + 3
+			histogram = true
+		default:
+			return nil, fmt.Errorf("metric %s has unsupported type %s", f.Name, f.Type)
+		}
+
+		if family.RateMin < 0 || family.RateMax < family.RateMin || family.Max < family.Min {
+			return nil, fmt.Errorf("metric %s has invalid rate or value range", f.Name)
+		}
+
+		total += family.series
+		if total > maxMetricSeries {
+			return nil, fmt.Errorf("metrics have more than %d series", maxMetricSeries)
+		}
+
+		family.state = make([][]float64, family.series)
+		families[index] = family
+	}
+
+	now := time.Now().UTC()
+	return &metricsGenerator{
+		faker:          faker,
+		config:         c,
+		families:       families,
+		interval:       time.Duration(c.ScrapeInterval) * time.Millisecond,
+		histogram:      histogram,
+		scrapeTime:     now,
+		lastScrapeTime: now,
+	}, nil
+}
+
+// labels returns the label values of the series, the series index is decomposed over the cardinalities
+func (f *metricFamily) labels(series int) map[string]string {
+	labels := make(map[string]string, len(f.dimensions)+1)
+	for i := len(f.dimensions) - 1; i >= 0; i-- {
+		dimension := f.dimensions[i]
+		index := series % dimension.Cardinality
+		series /= dimension.Cardinality
+		if len(dimension.Values) > 0 {
+			labels[dimension.Name] = dimension.Values[index]
+		} else {
+			labels[dimension.Name] = dimension.Name + "-" + strconv.Itoa(index)
+		}
+	}
+	return labels
+}
+
+// scrape updates the state of the series for the time elapsed since the previous scrape
+func (f *metricFamily) scrape(faker *fake.Faker, series int, elapsed float64) {
+	state := f.state[series]
+
+	switch f.Type {
+	case METRIC_TYPE_COUNTER:
+		if state == nil {
+			state = []float64{0}
+		}
+		// counters only go up
+		state[0] += faker.Float64Range(f.RateMin, f.RateMax) * elapsed
+	case METRIC_TYPE_GAUGE:
+		if state == nil {
+			state = []float64{faker.Float64Range(f.Min, f.Max)}
+		}
+		// random walk within the range
+		step := (f.Max - f.Min) * 0.05 * faker.Rand.NormFloat64()
+		state[0] = math.Max(f.Min, math.Min(f.Max, state[0]+step))
+	case METRIC_TYPE_HISTOGRAM:
+		buckets := len(f.Buckets)
+		if state == nil {
+			// buckets, +Inf, sum and count
+			state = make([]float64, buckets+3)
+		}
+
+		mean := f.Buckets[buckets/2]
+		observations := int(math.Round(faker.Float64Range(f.RateMin, f.RateMax) * elapsed))
+		for i := 0; i < observations; i++ {
+			value := -mean * math.Log(1-faker.Float64())
+			for b := 0; b < buckets; b++ {
+				if value <= f.Buckets[b] {
+					state[b]++
+				}
+			}
+			state[buckets]++
+			state[buckets+1] += value
+			state[buckets+2]++
+		}
+	}
+
+	f.state[series] = state
+}
+
+func (f *metricFamily) sample(series int, sample int, labels map[string]string) (string, float64) {
+	state := f.state[series]
+	if f.Type != METRIC_TYPE_HISTOGRAM {
+		return f.Name, state[0]
+	}
+
+	buckets := len(f.Buckets)
+	switch {
+	case sample < buckets:
+		labels[METRICS_LABEL_LE] = strconv.FormatFloat(f.Buckets[sample], 'g', -1, 64)
+		return f.Name + "_bucket", state[sample]
+	case sample == buckets:
+		labels[METRICS_LABEL_LE] = "+Inf"
+		return f.Name + "_bucket", state[sample]
+	case sample == buckets+1:
+		return f.Name + "_sum", state[sample]
+	default:
+		return f.Name + "_count", state[sample]
+	}
+}
+
+func (g *metricsGenerator) newEvent(family *metricFamily, name string, labels map[string]string, value float64) common.Event {
+	event := common.Event{
+		METRICS_FIELD_NAME:   name,
+		METRICS_FIELD_TYPE:   string(family.Type),
+		METRICS_FIELD_LABELS: labels,
+		METRICS_FIELD_VALUE:  value,
+		METRICS_FIELD_TIME:   g.scrapeTime,
+	}
+
+	if g.config.FlattenLabels {
+		for _, dimension := range g.config.Dimensions {
+			event[dimension.Name] = labels[dimension.Name]
+		}
+		if g.histogram {
+			event[METRICS_LABEL_LE] = labels[METRICS_LABEL_LE]
+		}
+	}
+	return event
+}
+
+// generateBatch returns the next samples of the current scrape, once all series are scraped
+// nothing is returned until the next scrape is due
+func (g *metricsGenerator) generateBatch(size int) []common.Event {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	events := make([]common.Event, 0, size)
+	for len(events) < size {
+		if g.scraped {
+			// the previous scrape is done, wait for the next one
+			now := time.Now().UTC()
+			next := g.scrapeTime.Add(g.interval)
+			if now.Before(next) {
+				break
+			}
+			if now.Sub(next) > g.interval {
+				log.Logger().Warnf("metrics generation is behind the scrape interval, skip to now")
+				next = now
+			}
+			g.lastScrapeTime = g.scrapeTime
+			g.scrapeTime = next
+			g.scraped = false
+		}
+
+		family := g.families[g.family]
+		if g.sample == 0 {
+			elapsed := g.scrapeTime.Sub(g.lastScrapeTime).Seconds()
+			family.scrape(g.faker, g.series, elapsed)
+		}
+
+		labels := family.labels(g.series)
+		name, value := family.sample(g.series, g.sample, labels)
+		events = append(events, g.newEvent(family, name, labels, value))
+
+		g.sample++
+		if g.sample == family.samples {
+			g.sample = 0
+			g.series++
+		}
+		if g.series == family.series {
+			g.series = 0
+			g.family++
+		}
+		if g.family == len(g.families) {
+			g.family = 0
+			g.scraped = true
+		}
+	}
+	return events
+}
+
+func (g *metricsGenerator) fields() []common.Field {
+	fields := []common.Field{
+		{Name: METRICS_FIELD_NAME, Type: string(FIELDTYPE_STRING)},
+		{Name: METRICS_FIELD_TYPE, Type: string(FIELDTYPE_STRING)},
+		{Name: METRICS_FIELD_LABELS, Type: string(FIELDTYPE_MAP)},
+		{Name: METRICS_FIELD_VALUE, Type: string(FIELDTYPE_FLOAT)},
+		{Name: METRICS_FIELD_TIME, Type: string(FIELDTYPE_TIMESTAMP)},
+	}
+
+	if g.config.FlattenLabels {
+		for _, dimension := range g.config.Dimensions {
+			fields = append(fields, common.Field{Name: dimension.Name, Type: string(FIELDTYPE_STRING)})
+		}
+		if g.histogram {
+			fields = append(fields, common.Field{Name: METRICS_LABEL_LE, Type: string(FIELDTYPE_STRING)})
+		}
+	}
+	return fields
+}
@@ -0,0 +1,182 @@
+package source
+
+import (
+	"fmt"
+	"math"
+
+	fake "github.com/brianvoe/gofakeit/v6"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+)
+
+type PayloadSizeDistribution string
+type PayloadPaddingMode string
+
+const (
+	PAYLOAD_SIZE_FIXED   PayloadSizeDistribution = "fixed"
+	PAYLOAD_SIZE_UNIFORM PayloadSizeDistribution = "uniform"
+	PAYLOAD_SIZE_NORMAL  PayloadSizeDistribution = "normal"
+)
+
+const (
+	PAYLOAD_PADDING_FILLER  PayloadPaddingMode = "filler"
+	PAYLOAD_PADDING_STRINGS PayloadPaddingMode = "strings"
+)
+
+const DefaultPayloadFillerField = "padding"
+
+// size of the pre-generated random letters used to pad events
+const payloadPoolSize = 64 * 1024
+
+// PayloadSize controls the serialised (json) size of each generated event.
+// events smaller than the target are padded, events already larger than the target are left as they are
+type PayloadSize struct {
+	Distribution PayloadSizeDistribution `json:"distribution,omitempty"`
+	Size         int                     `json:"size,omitempty"`
+	SizeMin      int                     `json:"size_min,omitempty"`
+	SizeMax      int                     `json:"size_max,omitempty"`
+	SizeStddev   int                     `json:"size_stddev,omitempty"`
+	Padding      PayloadPaddingMode      `json:"padding,omitempty"`
+	FillerField  string                  `json:"filler_field,omitempty"`
+}
+
+type payloadPadder struct {
+	faker        *fake.Faker
+	config       PayloadSize
+	stringFields []string
+	pool         string
+}
+
+func newPayloadPadder(config PayloadSize, fields []Field, faker *fake.Faker) (*payloadPadder, error) {
+	if config.Distribution == "" {
+		config.Distribution = PAYLOAD_SIZE_FIXED
+	}
+
+	if config.Padding == "" {
+		config.Padding = PAYLOAD_PADDING_FILLER
+	}
+
+	if config.FillerField == "" {
+		config.FillerField = DefaultPayloadFillerField
+	}
+
+	switch config.Distribution {
+	case PAYLOAD_SIZE_FIXED, PAYLOAD_SIZE_NORMAL:
+		if config.Size <= 0 {
+			return nil, fmt.Errorf("payload size must be positive, got %d", config.Size)
+		}
+		if config.SizeStddev < 0 {
+			return nil, fmt.Errorf("payload size stddev cannot be negative, got %d", config.SizeStddev)
+		}
+	case PAYLOAD_SIZE_UNIFORM:
+		if config.SizeMin <= 0 || config.SizeMax < config.SizeMin {
+			return nil, fmt.Errorf("invalid uniform payload size range [%d, %d]", config.SizeMin, config.SizeMax)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported payload size distribution %s", config.Distribution)
+	}
+
+	stringFields := make([]string, 0)
+	for _, f := range fields {
+		if f.Name == config.FillerField && config.Padding == PAYLOAD_PADDING_FILLER {
+			return nil, fmt.Errorf("payload filler field %s conflicts with an existing field", f.Name)
+		}
+		if f.Type == FIELDTYPE_STRING || f.Type == FIELDTYPE_GENERATE || f.Type == FIELDTYPE_REGEX {
+			stringFields = append(stringFields, f.Name)
+		}
+	}
+
+	switch config.Padding {
+	case PAYLOAD_PADDING_FILLER:
+	case PAYLOAD_PADDING_STRINGS:
+		if len(stringFields) == 0 {
+			return nil, fmt.Errorf("payload padding by strings requires at least one string field")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported payload padding mode %s", config.Padding)
+	}
+
+	return &payloadPadder{
+		faker:        faker,
+		config:       config,
+		stringFields: stringFields,
+		pool:         faker.LetterN(payloadPoolSize),
+	}, nil
+}
+
+func (p *payloadPadder) targetSize() int {
+	switch p.config.Distribution {
+	case PAYLOAD_SIZE_UNIFORM:
+		return p.faker.IntRange(p.config.SizeMin, p.config.SizeMax)
+	case PAYLOAD_SIZE_NORMAL:
+		size := int(math.Round(p.faker.Rand.NormFloat64()*float64(p.config.SizeStddev))) + p.config.Size
+		if size < 1 {
+			return 1
+		}
+		return size
+	default:
+		return p.config.Size
+	}
+}
+
+// letters returns n random letters, letters only so json encoding does not change the length
+func (p *payloadPadder) letters(n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	if n > len(p.pool) {
+		result := make([]byte, 0, n)
+		for len(result) < n {
+			result = append(result, p.pool[:min(len(p.pool), n-len(result))]...)
+		}
+		return string(result)
+	}
+
+	offset := p.faker.IntRange(0, len(p.pool)-n)
+	return p.pool[offset : offset+n]
+}
+
+func (p *payloadPadder) pad(event common.Event) {
+	target := p.targetSize()
+
+	if p.config.Padding == PAYLOAD_PADDING_FILLER {
+		// the filler field is always set so the schema of the events does not change
+		event[p.config.FillerField] = ""
+	}
+
+	missing := target - event.Size()
+	if missing <= 0 {
+		return
+	}
+
+	switch p.config.Padding {
+	case PAYLOAD_PADDING_FILLER:
+		event[p.config.FillerField] = p.letters(missing)
+	case PAYLOAD_PADDING_STRINGS:
+		share := missing / len(p.stringFields)
+		for index, name := range p.stringFields {
+			extra := share
+			if index == 0 {
+				extra += missing % len(p.stringFields)
+			}
+
+			value, ok := event[name].(string)
+			if !ok {
+				continue
+			}
+			event[name] = value + p.letters(extra)
+		}
+	}
+}
+
+func (p *payloadPadder) field() *common.Field {
+	if p.config.Padding != PAYLOAD_PADDING_FILLER {
+		return nil
+	}
+
+	return &common.Field{
+		Name: p.config.FillerField,
+		Type: string(FIELDTYPE_STRING),
+	}
+}
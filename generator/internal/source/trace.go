@@ -0,0 +1,303 @@
+package source
+
+import (
+	"fmt"
+	"time"
+
+	fake "github.com/brianvoe/gofakeit/v6"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+)
+
+// fields of the span events generated in trace mode
+const (
+	TRACE_FIELD_TRACE_ID       = "trace_id"
+	TRACE_FIELD_SPAN_ID        = "span_id"
+	TRACE_FIELD_PARENT_SPAN_ID = "parent_span_id"
+	TRACE_FIELD_SERVICE_NAME   = "service_name"
+	TRACE_FIELD_OPERATION_NAME = "operation_name"
+	TRACE_FIELD_START_TIME     = "start_time"
+	TRACE_FIELD_END_TIME       = "end_time"
+	TRACE_FIELD_DURATION_MS    = "duration_ms"
+	TRACE_FIELD_STATUS_CODE    = "status_code"
+	TRACE_FIELD_ERROR          = "error"
+)
+
+const (
+	TRACE_STATUS_OK    = "OK"
+	TRACE_STATUS_ERROR = "ERROR"
+)
+
+// the maximum depth of a call tree, deeper graphs are considered as a configuration mistake
+const maxTraceDepth = 32
+
+type TraceCall struct {
+	Service   string `json:"service"`
+	Operation string `json:"operation"`
+}
+
+type TraceOperation struct {
+	Name string `json:"name"`
+	// self time of the operation in ms, the duration of a span is its self time plus the duration of its calls
+	LatencyMin int         `json:"latency_min,omitempty"`
+	LatencyMax int         `json:"latency_max,omitempty"`
+	ErrorRate  float64     `json:"error_rate,omitempty"`
+	Calls      []TraceCall `json:"calls,omitempty"`
+}
+
+type TraceService struct {
+	Name       string           `json:"name"`
+	Operations []TraceOperation `json:"operations"`
+}
+
+type TraceConfiguration struct {
+	// entry points of the traces, default to all operations of the first service
+	Roots    []TraceCall    `json:"roots,omitempty"`
+	Services []TraceService `json:"services,omitempty"`
+	// probability that an error of a call is propagated to the caller span
+	ErrorPropagation *float64 `json:"error_propagation,omitempty"`
+}
+
+func DefaultTraceConfiguration() TraceConfiguration {
+	return TraceConfiguration{
+		Services: []TraceService{
+			{
+				Name: "frontend",
+				Operations: []TraceOperation{
+					{Name: "GET /checkout", LatencyMin: 2, LatencyMax: 10, ErrorRate: 0.001, Calls: []TraceCall{
+						{Service: "cart", Operation: "GetCart"},
+						{Service: "checkout", Operation: "PlaceOrder"},
+					}},
+					{Name: "GET /product", LatencyMin: 1, LatencyMax: 5, Calls: []TraceCall{
+						{Service: "catalog", Operation: "GetProduct"},
+					}},
+				},
+			},
+			{
+				Name: "cart",
+				Operations: []TraceOperation{
+					{Name: "GetCart", LatencyMin: 1, LatencyMax: 8, ErrorRate: 0.005},
+				},
+			},
+			{
+				Name: "catalog",
+				Operations: []TraceOperation{
+					{Name: "GetProduct", LatencyMin: 1, LatencyMax: 20, ErrorRate: 0.001},
+				},
+			},
+			{
+				Name: "checkout",
+				Operations: []TraceOperation{
+					{Name: "PlaceOrder", LatencyMin: 5, LatencyMax: 30, ErrorRate: 0.005, Calls: []TraceCall{
+						{Service: "payment", Operation: "Charge"},
+						{Service: "shipping", Operation: "ShipOrder"},
+					}},
+				},
+			},
+			{
+				Name: "payment",
+				Operations: []TraceOperation{
+					{Name: "Charge", LatencyMin: 20, LatencyMax: 200, ErrorRate: 0.02},
+				},
+			},
+			{
+				Name: "shipping",
+				Operations: []TraceOperation{
+					{Name: "ShipOrder", LatencyMin: 5, LatencyMax: 50, ErrorRate: 0.01},
+				},
+			},
+		},
+	}
+}
+
+type span struct {
+	service   string
+	operation string
+	spanId    string
+	parentId  string
+	start     time.Time
+	duration  time.Duration
+	isError   bool
+}
+
+type traceGenerator struct {
+	faker      *fake.Faker
+	config     TraceConfiguration
+	operations map[TraceCall]*TraceOperation
+	roots      []TraceCall
+	propagate  float64
+}
+
+func newTraceGenerator(config *TraceConfiguration, faker *fake.Faker) (*traceGenerator, error) {
+	if config == nil || len(config.Services) == 0 {
+		defaultConfig := DefaultTraceConfiguration()
+		config = &defaultConfig
+	}
+
+	operations := make(map[TraceCall]*TraceOperation)
+	for i := range config.Services {
+		service := &config.Services[i]
+		for j := range service.Operations {
+			operation := &service.Operations[j]
+			if operation.LatencyMin < 0 || operation.LatencyMax < operation.LatencyMin {
+				return nil, fmt.Errorf("invalid latency range of operation %s.%s", service.Name, operation.Name)
+			}
+			if operation.ErrorRate < 0 || operation.ErrorRate > 1 {
+				return nil, fmt.Errorf("invalid error rate of operation %s.%s", service.Name, operation.Name)
+			}
+			operations[TraceCall{Service: service.Name, Operation: operation.Name}] = operation
+		}
+	}
+
+	roots := config.Roots
+	if len(roots) == 0 {
+		if len(config.Services[0].Operations) == 0 {
+			return nil, fmt.Errorf("service %s has no operation", config.Services[0].Name)
+		}
+		for _, operation := range config.Services[0].Operations {
+			roots = append(roots, TraceCall{Service: config.Services[0].Name, Operation: operation.Name})
+		}
+	}
+
+	for _, root := range roots {
+		if err := checkTraceGraph(operations, root, map[TraceCall]bool{}, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	propagate := 1.0
+	if config.ErrorPropagation != nil {
+		propagate = *config.ErrorPropagation
+	}
+
+	return &traceGenerator{
+		faker:      faker,
+		config:     *config,
+		operations: operations,
+		roots:      roots,
+		propagate:  propagate,
+	}, nil
+}
+
+// checkTraceGraph makes sure all calls refer to defined operations and the graph has no cycle
+func checkTraceGraph(operations map[TraceCall]*TraceOperation, call TraceCall, visiting map[TraceCall]bool, depth int) error {
+	operation, ok := operations[call]
+	if !ok {
+		return fmt.Errorf("operation %s.%s is not defined", call.Service, call.Operation)
+	}
+
+	if visiting[call] {
+		return fmt.Errorf("operation %s.%s calls itself", call.Service, call.Operation)
+	}
+
+	if depth > maxTraceDepth {
+		return fmt.Errorf("call tree is deeper than %d", maxTraceDepth)
+	}
+
+	visiting[call] = true
+	for _, child := range operation.Calls {
+		if err := checkTraceGraph(operations, child, visiting, depth+1); err != nil {
+			return err
+		}
+	}
+	delete(visiting, call)
+	return nil
+}
+
+func (g *traceGenerator) newSpanId() string {
+	return fmt.Sprintf("%016x", g.faker.Rand.Uint64())
+}
+
+func (g *traceGenerator) newTraceId() string {
+	return fmt.Sprintf("%016x%016x", g.faker.Rand.Uint64(), g.faker.Rand.Uint64())
+}
+
+// buildSpan creates the span of the call started at the given time and the spans of its sub calls,
+// the calls are made sequentially after the self time so the latencies add up through the tree
+func (g *traceGenerator) buildSpan(call TraceCall, parentId string, start time.Time, spans []*span) []*span {
+	operation := g.operations[call]
+	current := &span{
+		service:   call.Service,
+		operation: call.Operation,
+		spanId:    g.newSpanId(),
+		parentId:  parentId,
+		start:     start,
+	}
+	spans = append(spans, current)
+
+	selfTime := time.Duration(g.faker.IntRange(operation.LatencyMin*1000, operation.LatencyMax*1000)) * time.Microsecond
+	// half of the self time is spent before calling others, the other half after
+	cursor := start.Add(selfTime / 2)
+	for _, child := range operation.Calls {
+		index := len(spans)
+		spans = g.buildSpan(child, current.spanId, cursor, spans)
+		childSpan := spans[index]
+		cursor = cursor.Add(childSpan.duration)
+
+		if childSpan.isError && g.faker.Float64() < g.propagate {
+			current.isError = true
+		}
+	}
+
+	current.duration = cursor.Sub(start) + selfTime - selfTime/2
+	if operation.ErrorRate > 0 && g.faker.Float64() < operation.ErrorRate {
+		current.isError = true
+	}
+	return spans
+}
+
+func (g *traceGenerator) generateTrace() []common.Event {
+	root := g.roots[g.faker.IntRange(0, len(g.roots)-1)]
+	traceId := g.newTraceId()
+
+	// build the tree starting now and shift it back so the root span ends now
+	spans := g.buildSpan(root, "", time.Now().UTC(), make([]*span, 0))
+	shift := -spans[0].duration
+
+	events := make([]common.Event, len(spans))
+	for index, s := range spans {
+		start := s.start.Add(shift)
+		status := TRACE_STATUS_OK
+		if s.isError {
+			status = TRACE_STATUS_ERROR
+		}
+
+		events[index] = common.Event{
+			TRACE_FIELD_TRACE_ID:       traceId,
+			TRACE_FIELD_SPAN_ID:        s.spanId,
+			TRACE_FIELD_PARENT_SPAN_ID: s.parentId,
+			TRACE_FIELD_SERVICE_NAME:   s.service,
+			TRACE_FIELD_OPERATION_NAME: s.operation,
+			TRACE_FIELD_START_TIME:     start,
+			TRACE_FIELD_END_TIME:       start.Add(s.duration),
+			TRACE_FIELD_DURATION_MS:    float64(s.duration.Microseconds()) / 1000.0,
+			TRACE_FIELD_STATUS_CODE:    status,
+			TRACE_FIELD_ERROR:          s.isError,
+		}
+	}
+	return events
+}
+
+// generateBatch generates the given number of traces, all their spans are returned as flat events
+func (g *traceGenerator) generateBatch(size int) []common.Event {
+	events := make([]common.Event, 0, size)
+	for i := 0; i < size; i++ {
+		events = append(events, g.generateTrace()...)
+	}
+	return events
+}
+
+func (g *traceGenerator) fields() []common.Field {
+	return []common.Field{
+		{Name: TRACE_FIELD_TRACE_ID, Type: string(FIELDTYPE_STRING)},
+		{Name: TRACE_FIELD_SPAN_ID, Type: string(FIELDTYPE_STRING)},
+		{Name: TRACE_FIELD_PARENT_SPAN_ID, Type: string(FIELDTYPE_STRING)},
+		{Name: TRACE_FIELD_SERVICE_NAME, Type: string(FIELDTYPE_STRING)},
+		{Name: TRACE_FIELD_OPERATION_NAME, Type: string(FIELDTYPE_STRING)},
+		{Name: TRACE_FIELD_START_TIME, Type: string(FIELDTYPE_TIMESTAMP)},
+		{Name: TRACE_FIELD_END_TIME, Type: string(FIELDTYPE_TIMESTAMP)},
+		{Name: TRACE_FIELD_DURATION_MS, Type: string(FIELDTYPE_FLOAT)},
+		{Name: TRACE_FIELD_STATUS_CODE, Type: string(FIELDTYPE_STRING)},
+		{Name: TRACE_FIELD_ERROR, Type: string(FIELDTYPE_BOOL)},
+	}
+}
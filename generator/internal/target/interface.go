@@ -0,0 +1,16 @@
+package target
+
+import "context"
+
+// Target is a data processing system which can run statements, used by scenarios to prepare,
+// check and clean up what is benchmarked
+type Target interface {
+	Exec(ctx context.Context, statement string) error
+	Query(ctx context.Context, statement string) ([]map[string]interface{}, error)
+	Close() error
+}
+
+type Configuration struct {
+	Type       string                 `json:"type"`
+	Properties map[string]interface{} `json:"properties"`
+}
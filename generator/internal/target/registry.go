@@ -0,0 +1,45 @@
+package target
+
+import (
+	"fmt"
+)
+
+type TargetConstructor func(properties map[string]interface{}) (Target, error)
+
+type TargetRegItem struct {
+	Name        string
+	Constructor TargetConstructor
+}
+
+var (
+	targetRegistry map[string]TargetRegItem
+)
+
+func init() {
+	targetRegistry = make(map[string]TargetRegItem)
+}
+
+// The target will register itself in `init`. During that the the logger may not be inited. So here we'd better not log anything
+func Register(item TargetRegItem) {
+	if _, exist := targetRegistry[item.Name]; exist {
+		panic(fmt.Errorf("item has already been registered"))
+	}
+
+	targetRegistry[item.Name] = item
+}
+
+func CreateTarget(config Configuration) (Target, error) {
+	if _, exist := targetRegistry[config.Type]; !exist {
+		return nil, fmt.Errorf("the target %s doesnot exist", config.Type)
+	}
+	constructor := targetRegistry[config.Type].Constructor
+	return constructor(config.Properties)
+}
+
+func ListRegisteredTargetTypes() []string {
+	keys := make([]string, 0)
+	for k := range targetRegistry {
+		keys = append(keys, k)
+	}
+	return keys
+}
@@ -0,0 +1,251 @@
+package templates
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/log"
+)
+
+//go:embed catalogue/*.yaml
+var catalogue embed.FS
+
+// COMMON_PARAMETERS are the parameters of every template
+var COMMON_PARAMETERS = []Parameter{
+	{Name: "name", Description: "name of the job", Path: "name"},
+	{Name: "rate", Description: "max number of events generated per second, replaces the interval of the template", Path: "source.rate"},
+	{Name: "duration", Description: "how long the job runs in seconds, until it is stopped by default", Path: "timeout"},
+	{Name: "sinks", Description: "the sinks the events are written to, a console sink by default", Path: "sinks"},
+}
+
+// Parameter is a value of a template which can be set when a job is created from the template
+type Parameter struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	// where the value goes in the job configuration, map keys and list indexes separated by dots,
+	// like source.fields.1.limit
+	Path    string      `json:"path"`
+	Default interface{} `json:"default,omitempty"`
+}
+
+// Template is a job configuration with parameters
+type Template struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  []Parameter            `json:"parameters,omitempty"`
+	Job         map[string]interface{} `json:"job"`
+	// where the template comes from, builtin or the path of its file
+	Origin string `json:"origin"`
+}
+
+// AllParameters returns the parameters of the template followed by the common parameters
+func (t *Template) AllParameters() []Parameter {
+	parameters := append([]Parameter{}, t.Parameters...)
+	for _, common := range COMMON_PARAMETERS {
+		if t.parameter(common.Name) == nil {
+			parameters = append(parameters, common)
+		}
+	}
+	return parameters
+}
+
+func (t *Template) parameter(name string) *Parameter {
+	for index := range t.Parameters {
+		if t.Parameters[index].Name == name {
+			return &t.Parameters[index]
+		}
+	}
+	return nil
+}
+
+// Render returns the job configuration of the template with the values of the parameters, the parameters
+// without value keep the value of the template, or get their default
+func (t *Template) Render(values map[string]interface{}) (map[string]interface{}, error) {
+	parameters := t.AllParameters()
+	known := make(map[string]struct{}, len(parameters))
+	for _, parameter := range parameters {
+		known[parameter.Name] = struct{}{}
+	}
+	for name := range values {
+		if _, ok := known[name]; !ok {
+			return nil, fmt.Errorf("template %s has no parameter %s", t.Name, name)
+		}
+	}
+
+	// the job of the template is copied, so rendering does not change it
+	data, err := json.Marshal(t.Job)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]interface{})
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+
+	for _, parameter := range parameters {
+		value, ok := values[parameter.Name]
+		if !ok {
+			value = parameter.Default
+		}
+		if value == nil {
+			continue
+		}
+		if err := setPath(result, strings.Split(parameter.Path, "."), value); err != nil {
+			return nil, fmt.Errorf("invalid parameter %s : %w", parameter.Name, err)
+		}
+	}
+	return result, nil
+}
+
+// Instantiate returns the job configuration of the template with the values of the parameters
+func (t *Template) Instantiate(values map[string]interface{}) (job.JobConfiguration, error) {
+	config := job.JobConfiguration{}
+	rendered, err := t.Render(values)
+	if err != nil {
+		return config, err
+	}
+
+	data, err := json.Marshal(rendered)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("invalid job of template %s : %w", t.Name, err)
+	}
+	return config, nil
+}
+
+// setPath sets the value at the path, the maps on the path are created when they are missing
+func setPath(node interface{}, path []string, value interface{}) error {
+	key := path[0]
+	last := len(path) == 1
+
+	switch current := node.(type) {
+	case map[string]interface{}:
+		if last {
+			current[key] = value
+			return nil
+		}
+		if _, ok := current[key]; !ok {
+			current[key] = make(map[string]interface{})
+		}
+		return setPath(current[key], path[1:], value)
+	case []interface{}:
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 || index >= len(current) {
+			return fmt.Errorf("invalid list index %s", key)
+		}
+		if last {
+			current[index] = value
+			return nil
+		}
+		return setPath(current[index], path[1:], value)
+	default:
+		return fmt.Errorf("%s is not in a map or a list", key)
+	}
+}
+
+// Catalogue holds the builtin templates and the templates of a user directory
+type Catalogue struct {
+	templates map[string]*Template
+}
+
+// NewCatalogue loads the builtin templates, then the yaml and json templates of the directory when it is set,
+// a template of the directory replaces the builtin template with the same name
+func NewCatalogue(dir string) (*Catalogue, error) {
+	c := &Catalogue{templates: make(map[string]*Template)}
+
+	files, err := fs.Glob(catalogue, "catalogue/*.yaml")
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := catalogue.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.add(data, "builtin"); err != nil {
+			return nil, fmt.Errorf("invalid builtin template %s : %w", file, err)
+		}
+	}
+
+	if dir == "" {
+		return c, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template directory : %w", err)
+	}
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if entry.IsDir() || (ext != ".yaml" && ext != ".yml" && ext != ".json") {
+			continue
+		}
+
+		file := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.add(data, file); err != nil {
+			return nil, fmt.Errorf("invalid template %s : %w", file, err)
+		}
+	}
+	return c, nil
+}
+
+func (c *Catalogue) add(data []byte, origin string) error {
+	t := &Template{}
+	if err := yaml.Unmarshal(data, t); err != nil {
+		return err
+	}
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if t.Job == nil {
+		return fmt.Errorf("job is required")
+	}
+	for _, parameter := range t.Parameters {
+		if parameter.Name == "" || parameter.Path == "" {
+			return fmt.Errorf("parameters require a name and a path")
+		}
+	}
+
+	t.Origin = origin
+	if previous, ok := c.templates[t.Name]; ok {
+		log.Logger().Infof("template %s of %s replaces the one of %s", t.Name, origin, previous.Origin)
+	}
+	c.templates[t.Name] = t
+	return nil
+}
+
+// List returns the templates sorted by name
+func (c *Catalogue) List() []*Template {
+	result := make([]*Template, 0, len(c.templates))
+	for _, t := range c.templates {
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func (c *Catalogue) Get(name string) (*Template, error) {
+	t, ok := c.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%s template does not exist", name)
+	}
+	return t, nil
+}
@@ -0,0 +1,268 @@
+package test_test
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/timeplus-io/chameleon/generator/internal/auth"
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/metrics"
+	"github.com/timeplus-io/chameleon/generator/internal/observer"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+	"github.com/timeplus-io/chameleon/generator/internal/templates"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// countingObserver observes the latencies 1 to 10 when it starts
+type countingObserver struct {
+	metricsManager metrics.Metrics
+}
+
+func init() {
+	observer.Register(observer.ObRegItem{
+		Name: "counting",
+		Constructor: func(properties map[string]interface{}) (observer.Observer, error) {
+			return &countingObserver{metricsManager: metrics.NewMemoryMetricManager()}, nil
+		},
+	})
+}
+
+func (o *countingObserver) Observe(ctx context.Context) error {
+	o.metricsManager.Add("latency")
+	for i := 1; i <= 10; i++ {
+		o.metricsManager.Observe("latency", float64(i), nil)
+	}
+	return nil
+}
+
+func (o *countingObserver) Stop()        {}
+func (o *countingObserver) Wait()        {}
+func (o *countingObserver) Close() error { return nil }
+
+func (o *countingObserver) Measurements() map[string]float64 {
+	return o.metricsManager.Latest()
+}
+
+func (o *countingObserver) Series() map[string][]metrics.Point {
+	return o.metricsManager.Series()
+}
+
+var _ = Describe("Test Run Archive", func() {
+
+	BeforeEach(func() {
+		console.Init()
+	})
+
+	newConfig := func() job.JobConfiguration {
+		return job.JobConfiguration{
+			Name:    "archived",
+			Source:  source.DefaultConfiguration(),
+			Timeout: 1,
+			Sinks: []sink.Configuration{
+				{Type: "console", Properties: map[string]interface{}{}},
+			},
+			Observers: []observer.Configuration{
+				{Type: "counting", Properties: map[string]interface{}{}},
+			},
+		}
+	}
+
+	It("summarize the metric series", func() {
+		points := make([]metrics.Point, 0)
+		for i := 100; i >= 1; i-- {
+			points = append(points, metrics.Point{Time: int64(i), Value: float64(i)})
+		}
+		summary := metrics.Summarize(points)
+		Expect(summary).Should(Equal(metrics.Summary{Count: 100, Min: 1, Max: 100, Mean: 50.5, P50: 50, P90: 90, P99: 99}))
+		Expect(metrics.Summarize(nil)).Should(Equal(metrics.Summary{}))
+	})
+
+	It("keep the last values of a metric only", func() {
+		viper.Set("metrics-max-points", 5)
+		defer viper.Set("metrics-max-points", 0)
+
+		manager := metrics.NewMemoryMetricManager()
+		manager.Add("latency")
+		for i := 1; i <= 12; i++ {
+			manager.Observe("latency", float64(i), nil)
+		}
+		values := make([]float64, 0)
+		for _, point := range manager.Series()["latency"] {
+			values = append(values, point.Value)
+		}
+		Expect(values).Should(Equal([]float64{8, 9, 10, 11, 12}))
+		Expect(manager.Latest()["latency"]).Should(Equal(12.0))
+	})
+
+	It("write the csv reports of the metrics when configured", func() {
+		dir, err := os.MkdirTemp("", "reports")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		Expect(metrics.NewLocalMetricManager()).Should(BeAssignableToTypeOf(&metrics.MemoryManager{}))
+
+		viper.Set("metrics-report", "csv")
+		viper.Set("metrics-report-dir", dir)
+		defer viper.Set("metrics-report", "")
+		defer viper.Set("metrics-report-dir", "")
+
+		manager := metrics.NewLocalMetricManager()
+		manager.Add("latency")
+		manager.Observe("latency", 1.5, nil)
+		manager.Observe("latency", 2.5, nil)
+		manager.Save("counting")
+
+		reports, err := filepath.Glob(filepath.Join(dir, "counting_latency_report_*.csv"))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(reports).Should(HaveLen(1))
+		content, err := os.ReadFile(reports[0])
+		Expect(err).ShouldNot(HaveOccurred())
+		lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+		Expect(lines).Should(HaveLen(3))
+		Expect(lines[0]).Should(Equal("time,latency"))
+		Expect(lines[2]).Should(HaveSuffix(",2.500000"))
+		// the values are archived with the run as well
+		Expect(manager.Series()["latency"]).Should(HaveLen(2))
+	})
+
+	It("archive the finished runs with the metrics of the observers", func() {
+		manager := job.NewJobManager()
+		ajob, err := manager.CreateJob(newConfig())
+		Expect(err).ShouldNot(HaveOccurred())
+		ajob.Start()
+
+		runs, err := manager.ListRuns()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(runs).Should(HaveLen(1))
+		Expect(runs[0].JobId).Should(Equal(ajob.Id))
+		Expect(runs[0].Status).Should(Equal(job.STATUS_STOPPED))
+		Expect(runs[0].Observers[0].Metrics["latency"].Summary.P90).Should(Equal(9.0))
+		Expect(runs[0].Observers[0].Metrics["latency"].Series).Should(BeEmpty())
+
+		run, err := manager.GetRun(runs[0].Id)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(run.Config.Sinks).Should(HaveLen(1))
+		Expect(run.StoppedAt).ShouldNot(BeNil())
+		Expect(run.Stats.SuccessWrite).Should(BeNumerically(">", 0))
+		Expect(run.Observers[0].Type).Should(Equal("counting"))
+		Expect(run.Observers[0].Metrics["latency"].Series).Should(HaveLen(10))
+
+		// the runs are kept when the job is deleted
+		Expect(manager.DeleteJob(ajob.Id)).Should(Succeed())
+		_, err = manager.GetRun(run.Id)
+		Expect(err).ShouldNot(HaveOccurred())
+		_, err = manager.GetRun("unknown")
+		Expect(err).Should(MatchError(ContainSubstring("run does not exist")))
+	})
+
+	It("archive nothing when an observer cannot be created", func() {
+		manager := job.NewJobManager()
+		config := newConfig()
+		config.Observers = append(config.Observers, observer.Configuration{Type: "broken", Properties: map[string]interface{}{}})
+		_, err := manager.CreateJob(config)
+		Expect(err).Should(MatchError(ContainSubstring("invalid observer 1")))
+
+		runs, err := manager.ListRuns()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(runs).Should(BeEmpty())
+
+		// the results are in the order of the observer configurations
+		config = newConfig()
+		config.Observers = append(config.Observers, observer.Configuration{Type: "counting", Properties: map[string]interface{}{}, Disabled: true})
+		ajob, err := manager.CreateJob(config)
+		Expect(err).ShouldNot(HaveOccurred())
+		ajob.Start()
+
+		result := ajob.Result()
+		Expect(result.Observers).Should(HaveLen(2))
+		Expect(result.Observers[0].Type).Should(Equal("counting"))
+		Expect(result.Observers[0].Metrics).Should(HaveKey("latency"))
+		Expect(result.Observers[1].Type).Should(Equal("counting"))
+		Expect(result.Observers[1].Metrics).Should(BeEmpty())
+	})
+
+	It("keep the runs in the file job store", func() {
+		dir, err := os.MkdirTemp("", "runstore")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "jobs.db")
+		store, err := job.NewJobStore(job.JOB_STORE_FILE, path)
+		Expect(err).ShouldNot(HaveOccurred())
+		manager, err := job.NewJobManagerWithStore(store, job.RESUME_POLICY_FAIL)
+		Expect(err).ShouldNot(HaveOccurred())
+		ajob, err := manager.CreateJob(newConfig())
+		Expect(err).ShouldNot(HaveOccurred())
+		ajob.Start()
+		Expect(store.Close()).Should(Succeed())
+
+		store, err = job.NewJobStore(job.JOB_STORE_FILE, path)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer store.Close()
+		run, err := store.GetRun(ajob.CurrentRun().Id)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(run).ShouldNot(BeNil())
+		Expect(run.JobName).Should(Equal("archived"))
+		Expect(run.Observers[0].Metrics["latency"].Series).Should(HaveLen(10))
+
+		missing, err := store.GetRun("unknown")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(missing).Should(BeNil())
+	})
+
+	It("serve and export the runs", func() {
+		manager := job.NewJobManager()
+		ajob, err := manager.CreateJob(newConfig())
+		Expect(err).ShouldNot(HaveOccurred())
+		ajob.Start()
+
+		authenticator, err := auth.NewAuthenticator(nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		catalogue, err := templates.NewCatalogue("")
+		Expect(err).ShouldNot(HaveOccurred())
+		router := server.NewRouter(manager, nil, catalogue, authenticator)
+		get := func(url string) *httptest.ResponseRecorder {
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, url, nil))
+			return recorder
+		}
+
+		recorder := get("/api/runs")
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		var runs []job.RunRecord
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &runs)).Should(Succeed())
+		Expect(runs).Should(HaveLen(1))
+		runId := runs[0].Id
+
+		recorder = get("/api/runs/" + runId)
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		Expect(get("/api/runs/unknown").Code).Should(Equal(http.StatusNotFound))
+
+		recorder = get("/api/runs/" + runId + "/export")
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		Expect(recorder.Header().Get("Content-Disposition")).Should(ContainSubstring("archived_" + runId + ".json"))
+
+		recorder = get("/api/runs/" + runId + "/export?format=csv")
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		rows, err := csv.NewReader(strings.NewReader(recorder.Body.String())).ReadAll()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(rows).Should(HaveLen(11))
+		Expect(rows[0]).Should(Equal([]string{"observer", "type", "metric", "time", "value"}))
+		Expect(rows[10][1:3]).Should(Equal([]string{"counting", "latency"}))
+		Expect(rows[10][4]).Should(Equal("10"))
+
+		Expect(get("/api/runs/" + runId + "/export?format=xml").Code).Should(Equal(http.StatusBadRequest))
+	})
+})
@@ -0,0 +1,191 @@
+package test_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/viper"
+
+	"github.com/timeplus-io/chameleon/generator/internal/auth"
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+	"github.com/timeplus-io/chameleon/generator/internal/templates"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test Auth", func() {
+
+	BeforeEach(func() {
+		console.Init()
+	})
+
+	It("load api keys", func() {
+		dir, err := os.MkdirTemp("", "auth")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		file := filepath.Join(dir, "keys.yaml")
+		content := "keys:\n- name: ci\n  key: secret\n  role: operator\n"
+		Expect(os.WriteFile(file, []byte(content), 0600)).Should(Succeed())
+
+		keys, err := auth.LoadKeys(file)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(keys).Should(Equal([]auth.Key{{Name: "ci", Key: "secret", Role: auth.ROLE_OPERATOR}}))
+
+		keys, err = auth.ParseKeys("dashboard:viewer:abc, ops:admin:d:e")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(keys).Should(HaveLen(2))
+		Expect(keys[1]).Should(Equal(auth.Key{Name: "ops", Key: "d:e", Role: auth.ROLE_ADMIN}))
+
+		_, err = auth.ParseKeys("secret")
+		Expect(err).Should(HaveOccurred())
+		Expect(err.Error()).ShouldNot(ContainSubstring("secret"))
+
+		_, err = auth.NewAuthenticator([]auth.Key{{Name: "ci", Key: "secret", Role: "root"}})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("require the role of each route", func() {
+		authenticator, err := auth.NewAuthenticator([]auth.Key{
+			{Name: "dashboard", Key: "viewer-key", Role: auth.ROLE_VIEWER},
+			{Name: "ci", Key: "operator-key", Role: auth.ROLE_OPERATOR},
+			{Name: "ops", Key: "admin-key", Role: auth.ROLE_ADMIN},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		catalogue, err := templates.NewCatalogue("")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		manager := job.NewJobManager()
+		router := server.NewRouter(manager, nil, catalogue, authenticator)
+		call := func(method string, path string, key string, payload interface{}) *httptest.ResponseRecorder {
+			var body bytes.Buffer
+			if payload != nil {
+				Expect(json.NewEncoder(&body).Encode(payload)).Should(Succeed())
+			}
+			req := httptest.NewRequest(method, path, &body)
+			req.Header.Set("Content-Type", "application/json")
+			if key != "" {
+				req.Header.Set("Authorization", "Bearer "+key)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			return recorder
+		}
+
+		Expect(call(http.MethodGet, "/health", "", nil).Code).Should(Equal(http.StatusOK))
+		Expect(call(http.MethodGet, "/api/jobs", "", nil).Code).Should(Equal(http.StatusUnauthorized))
+		Expect(call(http.MethodGet, "/api/jobs", "wrong-key", nil).Code).Should(Equal(http.StatusUnauthorized))
+		Expect(call(http.MethodGet, "/api/jobs", "viewer-key", nil).Code).Should(Equal(http.StatusOK))
+
+		config := job.JobConfiguration{
+			Name:   "test job",
+			Source: source.DefaultConfiguration(),
+			Sinks: []sink.Configuration{
+				{
+					Type:       "console",
+					Properties: map[string]interface{}{},
+				},
+			},
+		}
+		Expect(call(http.MethodPost, "/api/jobs", "viewer-key", config).Code).Should(Equal(http.StatusForbidden))
+		Expect(call(http.MethodPost, "/api/jobs", "operator-key", config).Code).Should(Equal(http.StatusForbidden))
+
+		invalid := call(http.MethodPost, "/api/jobs", "admin-key", job.JobConfiguration{
+			Name:   "invalid job",
+			Source: source.DefaultConfiguration(),
+			Sinks:  []sink.Configuration{{Type: "unknown", Properties: map[string]interface{}{}}},
+		})
+		Expect(invalid.Code).Should(Equal(http.StatusBadRequest))
+		Expect(invalid.Body.String()).Should(ContainSubstring("the sink unknown does not exist"))
+
+		created := call(http.MethodPost, "/api/jobs", "admin-key", config)
+		Expect(created.Code).Should(Equal(http.StatusCreated))
+		response := map[string]interface{}{}
+		Expect(json.Unmarshal(created.Body.Bytes(), &response)).Should(Succeed())
+		id := response["id"].(string)
+
+		// the api key header is accepted too
+		req := httptest.NewRequest(http.MethodGet, "/api/jobs/"+id, nil)
+		req.Header.Set(auth.API_KEY_HEADER, "viewer-key")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+		Expect(call(http.MethodGet, "/api/jobs/"+id+"/events?interval=1ns", "viewer-key", nil).Code).Should(Equal(http.StatusBadRequest))
+
+		Expect(call(http.MethodPost, "/api/jobs/"+id+"/stop", "viewer-key", nil).Code).Should(Equal(http.StatusForbidden))
+		Expect(call(http.MethodPost, "/api/jobs/"+id+"/stop", "operator-key", nil).Code).Should(Equal(http.StatusNoContent))
+		Expect(call(http.MethodDelete, "/api/jobs/"+id, "operator-key", nil).Code).Should(Equal(http.StatusForbidden))
+		Expect(call(http.MethodDelete, "/api/jobs/"+id, "admin-key", nil).Code).Should(Equal(http.StatusNoContent))
+
+		Expect(call(http.MethodGet, "/api/plugins", "viewer-key", nil).Code).Should(Equal(http.StatusOK))
+		Expect(call(http.MethodGet, "/api/templates", "viewer-key", nil).Code).Should(Equal(http.StatusOK))
+		Expect(call(http.MethodPost, "/api/templates/iot/jobs", "operator-key", nil).Code).Should(Equal(http.StatusForbidden))
+		fromTemplate := call(http.MethodPost, "/api/templates/iot/jobs", "admin-key", map[string]interface{}{
+			"parameters": map[string]interface{}{"name": "sensors", "rate": 100},
+		})
+		Expect(fromTemplate.Code).Should(Equal(http.StatusCreated))
+		Expect(json.Unmarshal(fromTemplate.Body.Bytes(), &response)).Should(Succeed())
+		Expect(response["name"]).Should(Equal("sensors"))
+		Expect(call(http.MethodPost, "/api/jobs/"+response["id"].(string)+"/stop", "operator-key", nil).Code).Should(Equal(http.StatusNoContent))
+		Expect(call(http.MethodPost, "/api/templates/missing/jobs", "admin-key", nil).Code).Should(Equal(http.StatusNotFound))
+	})
+
+	It("allow the api key header in cross origin requests", func() {
+		viper.Set("allow-origin", "http://dashboard")
+		defer viper.Set("allow-origin", "")
+
+		authenticator, err := auth.NewAuthenticator([]auth.Key{{Name: "dashboard", Key: "viewer-key", Role: auth.ROLE_VIEWER}})
+		Expect(err).ShouldNot(HaveOccurred())
+		catalogue, err := templates.NewCatalogue("")
+		Expect(err).ShouldNot(HaveOccurred())
+		router := server.NewRouter(job.NewJobManager(), nil, catalogue, authenticator)
+
+		req := httptest.NewRequest(http.MethodOptions, "/api/jobs", nil)
+		req.Header.Set("Origin", "http://dashboard")
+		req.Header.Set("Access-Control-Request-Headers", auth.API_KEY_HEADER)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		Expect(recorder.Code).Should(Equal(http.StatusNoContent))
+		Expect(recorder.Header().Get("Access-Control-Allow-Headers")).Should(ContainSubstring(auth.API_KEY_HEADER))
+		Expect(recorder.Header().Get("Access-Control-Allow-Origin")).Should(Equal("http://dashboard"))
+		Expect(recorder.Header().Get("Access-Control-Allow-Credentials")).Should(Equal("true"))
+	})
+
+	It("allow any origin without credentials", func() {
+		viper.Set("allow-origin", "*, http://dashboard")
+		defer viper.Set("allow-origin", "")
+
+		authenticator, err := auth.NewAuthenticator(nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		catalogue, err := templates.NewCatalogue("")
+		Expect(err).ShouldNot(HaveOccurred())
+		router := server.NewRouter(job.NewJobManager(), nil, catalogue, authenticator)
+
+		preflight := func(origin string) http.Header {
+			req := httptest.NewRequest(http.MethodOptions, "/api/jobs", nil)
+			req.Header.Set("Origin", origin)
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, req)
+			Expect(recorder.Code).Should(Equal(http.StatusNoContent))
+			return recorder.Header()
+		}
+
+		header := preflight("http://elsewhere")
+		Expect(header.Get("Access-Control-Allow-Origin")).Should(Equal("*"))
+		Expect(header.Get("Access-Control-Allow-Credentials")).Should(BeEmpty())
+
+		header = preflight("http://dashboard")
+		Expect(header.Get("Access-Control-Allow-Origin")).Should(Equal("http://dashboard"))
+		Expect(header.Get("Access-Control-Allow-Credentials")).Should(Equal("true"))
+	})
+})
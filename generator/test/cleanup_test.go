@@ -0,0 +1,192 @@
+package test_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// disposableSink records the objects it drops and its closes, it fails all its writes when `fail` is set
+// and its initialization when `fail_init` is set
+type disposableSink struct {
+	sink.Sink
+	fail     bool
+	failInit bool
+}
+
+var disposed = struct {
+	sync.Mutex
+	calls  []string
+	closes int
+}{}
+
+func init() {
+	sink.Register(sink.SinkRegItem{
+		Name: "disposable",
+		Constructor: func(properties map[string]interface{}) (sink.Sink, error) {
+			console, _ := console.NewConsoleSink(nil)
+			fail, _ := properties["fail"].(bool)
+			failInit, _ := properties["fail_init"].(bool)
+			return &disposableSink{Sink: console, fail: fail, failInit: failInit}, nil
+		},
+	})
+}
+
+func dispose(call string) {
+	disposed.Lock()
+	defer disposed.Unlock()
+	disposed.calls = append(disposed.calls, call)
+}
+
+func resetDisposed() {
+	disposed.Lock()
+	defer disposed.Unlock()
+	disposed.calls = nil
+	disposed.closes = 0
+}
+
+func disposedCalls() []string {
+	disposed.Lock()
+	defer disposed.Unlock()
+	return append([]string{}, disposed.calls...)
+}
+
+func closedSinks() int {
+	disposed.Lock()
+	defer disposed.Unlock()
+	return disposed.closes
+}
+
+func (s *disposableSink) Init(ctx context.Context, name string, fields []common.Field) error {
+	if s.failInit {
+		return fmt.Errorf("unreachable")
+	}
+	return s.Sink.Init(ctx, name, fields)
+}
+
+func (s *disposableSink) Close() error {
+	disposed.Lock()
+	disposed.closes++
+	disposed.Unlock()
+	return s.Sink.Close()
+}
+
+func (s *disposableSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
+	if s.fail {
+		return sink.Permanent(fmt.Errorf("rejected"))
+	}
+	return s.Sink.Write(ctx, headers, rows, index)
+}
+
+func (s *disposableSink) DropExisting(ctx context.Context, name string) error {
+	dispose("drop " + name)
+	return nil
+}
+
+func (s *disposableSink) Cleanup(ctx context.Context) error {
+	dispose("cleanup")
+	return nil
+}
+
+var _ = Describe("Test Cleanup", func() {
+
+	BeforeEach(func() {
+		console.Init()
+		resetDisposed()
+	})
+
+	run := func(fail bool, cleanup *sink.CleanupConfiguration) *job.Run {
+		config := job.JobConfiguration{
+			Name:    "disposable",
+			Source:  source.DefaultConfiguration(),
+			Timeout: 1,
+			Sinks: []sink.Configuration{
+				{Type: "disposable", Properties: map[string]interface{}{"fail": fail}, Cleanup: cleanup},
+			},
+		}
+		ajob, err := job.NewRunJob(config)
+		Expect(err).ShouldNot(HaveOccurred())
+		ajob.RunToEnd()
+		return ajob.CurrentRun()
+	}
+
+	It("drop the objects of the sinks according to the cleanup policy", func() {
+		run(false, nil)
+		Expect(disposedCalls()).Should(BeEmpty())
+
+		run(false, &sink.CleanupConfiguration{Policy: sink.CLEANUP_POLICY_NEVER})
+		Expect(disposedCalls()).Should(BeEmpty())
+
+		run(false, &sink.CleanupConfiguration{Policy: sink.CLEANUP_POLICY_ALWAYS})
+		Expect(disposedCalls()).Should(Equal([]string{"cleanup"}))
+	})
+
+	It("keep the objects of a run with failed writes on success policy", func() {
+		stats := run(true, &sink.CleanupConfiguration{Policy: sink.CLEANUP_POLICY_ON_SUCCESS}).Stats
+		Expect(stats.FailedWrite).Should(BeNumerically(">", 0))
+		Expect(disposedCalls()).Should(BeEmpty())
+
+		run(false, &sink.CleanupConfiguration{Policy: sink.CLEANUP_POLICY_ON_SUCCESS})
+		Expect(disposedCalls()).Should(Equal([]string{"cleanup"}))
+
+		resetDisposed()
+		run(true, &sink.CleanupConfiguration{Policy: sink.CLEANUP_POLICY_ALWAYS})
+		Expect(disposedCalls()).Should(Equal([]string{"cleanup"}))
+	})
+
+	It("drop the existing objects before the sinks are initialized", func() {
+		run(false, &sink.CleanupConfiguration{DropExisting: true, Policy: sink.CLEANUP_POLICY_ALWAYS})
+		Expect(disposedCalls()).Should(Equal([]string{"drop disposable", "cleanup"}))
+	})
+
+	It("create no sinks for the scheduled job itself", func() {
+		config := job.JobConfiguration{
+			Name:   "disposable",
+			Source: source.DefaultConfiguration(),
+			Sinks: []sink.Configuration{
+				{Type: "disposable", Properties: map[string]interface{}{}, Cleanup: &sink.CleanupConfiguration{DropExisting: true, Policy: sink.CLEANUP_POLICY_ALWAYS}},
+			},
+			Schedule: &job.Schedule{Interval: 3600},
+		}
+		manager := job.NewJobManager()
+		ajob, err := manager.CreateJob(config)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(manager.StartJob(ajob.Id)).Should(Succeed())
+		Expect(manager.StopJob(ajob.Id)).Should(Succeed())
+		Expect(disposedCalls()).Should(BeEmpty())
+	})
+
+	It("release the sinks of a job failing to initialize them", func() {
+		_, err := job.NewRunJob(job.JobConfiguration{
+			Name:   "disposable",
+			Source: source.DefaultConfiguration(),
+			Sinks: []sink.Configuration{
+				{Type: "disposable", Properties: map[string]interface{}{}, Cleanup: &sink.CleanupConfiguration{Policy: sink.CLEANUP_POLICY_ALWAYS}},
+				{Type: "disposable", Properties: map[string]interface{}{"fail_init": true}},
+			},
+		})
+		Expect(err).Should(MatchError("unreachable"))
+		Expect(disposedCalls()).Should(Equal([]string{"cleanup"}))
+		Expect(closedSinks()).Should(Equal(2))
+	})
+
+	It("reject an unknown cleanup policy", func() {
+		config := job.JobConfiguration{
+			Name:   "disposable",
+			Source: source.DefaultConfiguration(),
+			Sinks: []sink.Configuration{
+				{Type: "disposable", Properties: map[string]interface{}{}, Cleanup: &sink.CleanupConfiguration{Policy: "sometimes"}},
+			},
+		}
+		Expect(config.Validate()).Should(MatchError(ContainSubstring("invalid cleanup policy sometimes")))
+	})
+})
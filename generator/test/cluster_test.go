@@ -0,0 +1,133 @@
+package test_test
+
+import (
+	"net/http/httptest"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/auth"
+	"github.com/timeplus-io/chameleon/generator/internal/cluster"
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+	"github.com/timeplus-io/chameleon/generator/internal/templates"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func distributedConfiguration(distribution *job.Distribution) job.JobConfiguration {
+	sourceConfig := source.DefaultConfiguration()
+	sourceConfig.Concurrency = 5
+	sourceConfig.Rate = 100
+	sourceConfig.Seed = 42
+	sourceConfig.Fields = append(sourceConfig.Fields, source.Field{
+		Name:  "id",
+		Type:  source.FIELDTYPE_SEQUENCE,
+		Limit: []interface{}{float64(0), float64(1000)},
+	})
+
+	return job.JobConfiguration{
+		Name:   "distributed job",
+		Source: sourceConfig,
+		Sinks: []sink.Configuration{
+			{
+				Type:       "console",
+				Properties: map[string]interface{}{},
+			},
+		},
+		Distribution: distribution,
+	}
+}
+
+var _ = Describe("Test Cluster", func() {
+
+	BeforeEach(func() {
+		console.Init()
+	})
+
+	It("partition a distributed job", func() {
+		config := distributedConfiguration(&job.Distribution{})
+
+		first, err := job.Partition(config, 0, 2)
+		Expect(err).ShouldNot(HaveOccurred())
+		second, err := job.Partition(config, 1, 2)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(first.Distribution).Should(BeNil())
+		Expect(first.Source.Concurrency).Should(Equal(3))
+		Expect(second.Source.Concurrency).Should(Equal(2))
+		Expect(first.Source.Rate).Should(Equal(60))
+		Expect(second.Source.Rate).Should(Equal(40))
+		Expect(first.Source.Seed).Should(Equal(int64(42)))
+		Expect(second.Source.Seed).Should(Equal(int64(43)))
+		Expect(first.Source.Fields[2].Limit).Should(Equal([]interface{}{float64(0), float64(500)}))
+		Expect(second.Source.Fields[2].Limit).Should(Equal([]interface{}{float64(500), float64(1000)}))
+		// the configuration of the job is not changed
+		Expect(config.Source.Fields[2].Limit).Should(Equal([]interface{}{float64(0), float64(1000)}))
+
+		config.Distribution = &job.Distribution{Strategy: job.PARTITION_STRATEGY_KEY_RANGE, KeyField: "number"}
+		first, err = job.Partition(config, 0, 2)
+		Expect(err).ShouldNot(HaveOccurred())
+		second, err = job.Partition(config, 1, 2)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(first.Source.Concurrency).Should(Equal(5))
+		Expect(first.Source.Fields[0].Limit).Should(Equal([]interface{}{float64(0), float64(5)}))
+		Expect(second.Source.Fields[0].Limit).Should(Equal([]interface{}{float64(6), float64(10)}))
+
+		config.Distribution = &job.Distribution{Strategy: job.PARTITION_STRATEGY_KEY_RANGE, KeyField: "time"}
+		Expect(config.Distribution.Validate(config)).ShouldNot(Succeed())
+	})
+
+	It("run a distributed job on workers", func() {
+		authenticator, err := auth.NewAuthenticator(nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		catalogue, err := templates.NewCatalogue("")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		workerManagers := []*job.JobManager{job.NewJobManager(), job.NewJobManager()}
+		registry := cluster.NewRegistry()
+		for _, manager := range workerManagers {
+			worker := httptest.NewServer(server.NewRouter(manager, nil, catalogue, authenticator))
+			defer worker.Close()
+			_, err := registry.Register("", worker.URL)
+			Expect(err).ShouldNot(HaveOccurred())
+		}
+		Expect(registry.Alive()).Should(HaveLen(2))
+
+		manager := job.NewJobManager()
+		_, err = manager.CreateJob(distributedConfiguration(&job.Distribution{}))
+		Expect(err).Should(HaveOccurred())
+
+		manager.SetDispatcher(cluster.NewCoordinator(registry, ""))
+		ajob, err := manager.CreateJob(distributedConfiguration(&job.Distribution{}))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(manager.StartJob(ajob.Id)).Should(Succeed())
+
+		Eventually(func() int {
+			return len(workerManagers[0].ListJob()) + len(workerManagers[1].ListJob())
+		}, 5*time.Second).Should(Equal(2))
+		Eventually(func() int {
+			got, _ := manager.GetJob(ajob.Id)
+			return got.CurrentRun().Stats.SuccessWrite
+		}, 5*time.Second).Should(BeNumerically(">", 0))
+
+		Expect(manager.StopJob(ajob.Id)).Should(Succeed())
+		run := ajob.CurrentRun()
+		Expect(run.Status).Should(Equal(job.STATUS_STOPPED))
+		states := ajob.Parts()
+		Expect(states).Should(HaveLen(2))
+
+		generated := 0
+		for index, manager := range workerManagers {
+			parts := manager.ListJob()
+			Expect(parts).Should(HaveLen(1))
+			part := parts[0].CurrentRun()
+			Expect(part.Status).Should(Equal(job.STATUS_STOPPED))
+			Expect(states[index].Status).Should(Equal(job.STATUS_STOPPED))
+			generated += part.Stats.Generated
+		}
+		Expect(run.Stats.Generated).Should(Equal(generated))
+	})
+})
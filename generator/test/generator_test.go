@@ -1,8 +1,10 @@
 package test_test
 
 import (
+	"context"
 	"time"
 
+	"github.com/timeplus-io/chameleon/generator/internal/common"
 	"github.com/timeplus-io/chameleon/generator/internal/log"
 	"github.com/timeplus-io/chameleon/generator/internal/source"
 
@@ -23,7 +25,7 @@ var _ = Describe("Test Generator", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 			Expect(generator).ShouldNot(BeNil())
 
-			generator.Start()
+			generator.Start(context.Background())
 
 			time.Sleep(3 * time.Second)
 			events := generator.Read()
@@ -40,5 +42,309 @@ var _ = Describe("Test Generator", func() {
 
 			generator.Stop()
 		})
+
+		It("generate the same values from the same seed whatever the other sources", func() {
+			config := source.DefaultConfiguration()
+			config.RandomEvent = true
+			config.BatchSize = 20
+			config.Fields[0].Limit = []interface{}{float64(0), float64(1000000)}
+			config.Seed = 42
+
+			numbers := func(generator *source.GeneratorEngine) []interface{} {
+				result := make([]interface{}, 0)
+				for _, event := range generator.Read() {
+					result = append(result, event["number"])
+				}
+				return result
+			}
+
+			first, err := source.NewGenarator(config)
+			Expect(err).ShouldNot(HaveOccurred())
+			first.Start(context.Background())
+			defer first.Stop()
+			expected := numbers(first)
+
+			// another seeded source does not change the values of the seeded one
+			other := config
+			other.Seed = 7
+			second, err := source.NewGenarator(config)
+			Expect(err).ShouldNot(HaveOccurred())
+			third, err := source.NewGenarator(other)
+			Expect(err).ShouldNot(HaveOccurred())
+			third.Start(context.Background())
+			defer third.Stop()
+			numbers(third)
+			second.Start(context.Background())
+			defer second.Stop()
+			Expect(numbers(second)).Should(Equal(expected))
+		})
+
+		It("generate events with target payload size", func() {
+			config := source.DefaultConfiguration()
+			config.PayloadSize = &source.PayloadSize{
+				Size: 1024,
+			}
+			generator, err := source.NewGenarator(config)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			generator.Start(context.Background())
+			events := generator.Read()
+			Expect(events).ShouldNot(BeEmpty())
+			for _, event := range events {
+				Expect(event.Size()).Should(Equal(1024))
+				Expect(event).Should(HaveKey(source.DefaultPayloadFillerField))
+			}
+			generator.Stop()
+		})
+
+		It("pad string fields to a uniform payload size", func() {
+			config := source.DefaultConfiguration()
+			config.Fields = append(config.Fields, source.Field{
+				Name: "name",
+				Type: source.FIELDTYPE_STRING,
+			})
+			config.PayloadSize = &source.PayloadSize{
+				Distribution: source.PAYLOAD_SIZE_UNIFORM,
+				SizeMin:      512,
+				SizeMax:      2048,
+				Padding:      source.PAYLOAD_PADDING_STRINGS,
+			}
+			generator, err := source.NewGenarator(config)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			generator.Start(context.Background())
+			events := generator.Read()
+			Expect(events).ShouldNot(BeEmpty())
+			for _, event := range events {
+				Expect(event.Size()).Should(BeNumerically(">=", 512))
+				Expect(event.Size()).Should(BeNumerically("<=", 2048))
+				Expect(event).ShouldNot(HaveKey(source.DefaultPayloadFillerField))
+			}
+			generator.Stop()
+		})
+
+		It("generate timestamps with explicit encodings", func() {
+			config := source.DefaultConfiguration()
+			config.Fields = []source.Field{
+				{Name: "ts_us", Type: source.FIELDTYPE_TIMESTAMP, TimestampEncoding: common.TIMESTAMP_ENCODING_EPOCH_US},
+				{Name: "ts_float", Type: source.FIELDTYPE_TIMESTAMP, TimestampEncoding: common.TIMESTAMP_ENCODING_EPOCH_S_FLOAT, TimestampPrecision: "ms"},
+				{Name: "ts_nano", Type: source.FIELDTYPE_TIMESTAMP, TimestampEncoding: common.TIMESTAMP_ENCODING_RFC3339NANO, TimestampLocale: "America/Vancouver"},
+				{Name: "ts_int", Type: source.FIELDTYPE_TIMESTAMP_INT},
+			}
+			generator, err := source.NewGenarator(config)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			generator.Start(context.Background())
+			events := generator.Read()
+			Expect(events).ShouldNot(BeEmpty())
+			generator.Stop()
+
+			event := events[0]
+			Expect(event["ts_us"]).Should(BeAssignableToTypeOf(int64(0)))
+			Expect(event["ts_float"]).Should(BeAssignableToTypeOf(float64(0)))
+			Expect(event["ts_nano"]).Should(BeAssignableToTypeOf(""))
+			Expect(event["ts_int"]).Should(BeAssignableToTypeOf(int64(0)))
+
+			codec, err := common.NewTimestampCodec(common.TIMESTAMP_ENCODING_EPOCH_US, "", "", "")
+			Expect(err).ShouldNot(HaveOccurred())
+			t, err := codec.Decode(event["ts_us"])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(time.Since(t)).Should(BeNumerically("<", time.Minute))
+
+			codec, err = common.NewTimestampCodec(common.TIMESTAMP_ENCODING_EPOCH_S_FLOAT, "", "", "")
+			Expect(err).ShouldNot(HaveOccurred())
+			tf, err := codec.Decode(event["ts_float"])
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(tf.Sub(t).Abs()).Should(BeNumerically("<", 10*time.Millisecond))
+		})
+
+		It("generate traces in trace mode", func() {
+			config := source.DefaultConfiguration()
+			config.Mode = source.SOURCE_MODE_TRACE
+			generator, err := source.NewGenarator(config)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(generator.GetFields()).Should(HaveLen(10))
+
+			generator.Start(context.Background())
+			events := generator.Read()
+			generator.Stop()
+			Expect(len(events)).Should(BeNumerically(">=", config.BatchSize))
+
+			spans := make(map[string]common.Event)
+			for _, event := range events {
+				spans[event[source.TRACE_FIELD_SPAN_ID].(string)] = event
+			}
+
+			roots := 0
+			for _, event := range events {
+				parentId := event[source.TRACE_FIELD_PARENT_SPAN_ID].(string)
+				if parentId == "" {
+					roots++
+					continue
+				}
+
+				parent, ok := spans[parentId]
+				Expect(ok).Should(BeTrue())
+				Expect(parent[source.TRACE_FIELD_TRACE_ID]).Should(Equal(event[source.TRACE_FIELD_TRACE_ID]))
+				Expect(event[source.TRACE_FIELD_START_TIME].(time.Time)).ShouldNot(BeTemporally("<", parent[source.TRACE_FIELD_START_TIME].(time.Time)))
+				Expect(event[source.TRACE_FIELD_END_TIME].(time.Time)).ShouldNot(BeTemporally(">", parent[source.TRACE_FIELD_END_TIME].(time.Time)))
+				if event[source.TRACE_FIELD_ERROR].(bool) {
+					Expect(parent[source.TRACE_FIELD_ERROR]).Should(BeTrue())
+				}
+			}
+			Expect(roots).Should(Equal(config.BatchSize))
+		})
+
+		It("reject trace graph with cycle", func() {
+			config := source.DefaultConfiguration()
+			config.Mode = source.SOURCE_MODE_TRACE
+			config.Trace = &source.TraceConfiguration{
+				Services: []source.TraceService{{
+					Name: "a",
+					Operations: []source.TraceOperation{{
+						Name:  "op",
+						Calls: []source.TraceCall{{Service: "a", Operation: "op"}},
+					}},
+				}},
+			}
+			_, err := source.NewGenarator(config)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("generate consistent trades and quotes in market mode", func() {
+			config := source.DefaultConfiguration()
+			config.Mode = source.SOURCE_MODE_MARKET
+			config.BatchSize = 200
+			config.Interval = 10
+			config.Market = &source.MarketConfiguration{
+				Symbols:    []string{"AAPL", "MSFT"},
+				Volatility: 0.5,
+				TimeScale:  3600,
+			}
+			generator, err := source.NewGenarator(config)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			generator.Start(context.Background())
+			events := generator.Read()
+			generator.Stop()
+			Expect(events).Should(HaveLen(config.BatchSize))
+
+			quotes := make(map[string]common.Event)
+			sequences := make(map[string]int)
+			for _, event := range events {
+				symbol := event[source.MARKET_FIELD_SYMBOL].(string)
+				Expect(symbol).Should(BeElementOf("AAPL", "MSFT"))
+				Expect(event[source.MARKET_FIELD_SEQUENCE].(int)).Should(BeNumerically(">", sequences[symbol]))
+				sequences[symbol] = event[source.MARKET_FIELD_SEQUENCE].(int)
+				Expect(event[source.MARKET_FIELD_ASK_PRICE].(float64)).Should(BeNumerically(">", event[source.MARKET_FIELD_BID_PRICE].(float64)))
+
+				switch event[source.MARKET_FIELD_EVENT_TYPE] {
+				case source.MARKET_EVENT_QUOTE:
+					quotes[symbol] = event
+				case source.MARKET_EVENT_TRADE:
+					quote, ok := quotes[symbol]
+					if !ok {
+						continue
+					}
+					// trades execute on the last quote of the symbol
+					Expect(event[source.MARKET_FIELD_PRICE]).Should(BeElementOf(quote[source.MARKET_FIELD_BID_PRICE], quote[source.MARKET_FIELD_ASK_PRICE]))
+				}
+			}
+		})
+
+		It("generate nothing out of the market session", func() {
+			now := time.Now().UTC()
+			config := source.DefaultConfiguration()
+			config.Mode = source.SOURCE_MODE_MARKET
+			config.Market = &source.MarketConfiguration{
+				Session: &source.MarketSession{
+					Open:     now.Add(2 * time.Hour).Format("15:04"),
+					Close:    now.Add(3 * time.Hour).Format("15:04"),
+					Weekends: true,
+				},
+			}
+			generator, err := source.NewGenarator(config)
+			if now.Hour() >= 21 {
+				// the session would wrap around midnight
+				Expect(err).Should(HaveOccurred())
+				return
+			}
+			Expect(err).ShouldNot(HaveOccurred())
+
+			generator.Start(context.Background())
+			events := generator.Read()
+			generator.Stop()
+			Expect(events).Should(BeEmpty())
+		})
+
+		It("generate metric series in metrics mode", func() {
+			config := source.DefaultConfiguration()
+			config.Mode = source.SOURCE_MODE_METRICS
+			config.BatchSize = 100
+			config.Interval = 10
+			config.Metrics = &source.MetricsConfiguration{
+				Dimensions: []source.MetricDimension{
+					{Name: "instance", Cardinality: 5},
+					{Name: "method", Values: []string{"GET", "POST"}},
+				},
+				Families: []source.MetricFamily{
+					{Name: "requests_total", Type: source.METRIC_TYPE_COUNTER},
+					{Name: "latency_seconds", Type: source.METRIC_TYPE_HISTOGRAM, Labels: []string{"method"}, Buckets: []float64{0.1, 1}},
+				},
+				ScrapeInterval: 200,
+				FlattenLabels:  true,
+			}
+			generator, err := source.NewGenarator(config)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(generator.GetFields()).Should(HaveLen(8))
+
+			generator.Start(context.Background())
+			defer generator.Stop()
+
+			// 10 counter series and 2 histogram series of 5 samples per scrape
+			samples := make([]common.Event, 0)
+			for len(samples) < 3*20 {
+				samples = append(samples, generator.Read()...)
+			}
+
+			counters := make(map[string]float64)
+			buckets := make(map[string]float64)
+			for _, sample := range samples {
+				labels := sample[source.METRICS_FIELD_LABELS].(map[string]string)
+				value := sample[source.METRICS_FIELD_VALUE].(float64)
+				switch sample[source.METRICS_FIELD_NAME] {
+				case "requests_total":
+					key := labels["instance"] + labels["method"]
+					Expect(value).Should(BeNumerically(">=", counters[key]))
+					counters[key] = value
+				case "latency_seconds_bucket":
+					key := labels["method"]
+					if labels[source.METRICS_LABEL_LE] == "0.1" {
+						buckets[key] = value
+					} else {
+						// buckets are cumulative
+						Expect(value).Should(BeNumerically(">=", buckets[key]))
+					}
+				}
+			}
+			Expect(counters).Should(HaveLen(10))
+		})
+
+		It("reject invalid timestamp locale", func() {
+			config := source.DefaultConfiguration()
+			config.Fields[1].TimestampLocale = "Mars/Olympus_Mons"
+			_, err := source.NewGenarator(config)
+			Expect(err).Should(HaveOccurred())
+		})
+
+		It("reject invalid payload size", func() {
+			config := source.DefaultConfiguration()
+			config.PayloadSize = &source.PayloadSize{
+				Padding: source.PAYLOAD_PADDING_STRINGS,
+				Size:    1024,
+			}
+			_, err := source.NewGenarator(config)
+			Expect(err).Should(HaveOccurred())
+		})
 	})
 })
@@ -0,0 +1,198 @@
+package test_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+func init() {
+	// a sink without property schema, it accepts any property
+	sink.Register(sink.SinkRegItem{
+		Name:        "any",
+		Constructor: console.NewConsoleSink,
+	})
+}
+
+var _ = Describe("Test Interpolation", func() {
+
+	BeforeEach(func() {
+		console.Init()
+	})
+
+	It("interpolate environment variables and files", func() {
+		os.Setenv("CHAMELEON_TEST_USER", "admin")
+		os.Setenv("CHAMELEON_TEST_EMPTY", "")
+		defer os.Unsetenv("CHAMELEON_TEST_USER")
+		defer os.Unsetenv("CHAMELEON_TEST_EMPTY")
+
+		dir, err := os.MkdirTemp("", "secrets")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		secret := filepath.Join(dir, "password")
+		Expect(os.WriteFile(secret, []byte("s3cret\n"), 0600)).Should(Succeed())
+
+		value, err := common.Interpolate("${CHAMELEON_TEST_USER}:${file:" + secret + "}")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(value).Should(Equal("admin:s3cret"))
+
+		value, err = common.Interpolate("${CHAMELEON_TEST_MISSING:-localhost:9092},${CHAMELEON_TEST_EMPTY:-default},$${CHAMELEON_TEST_USER}")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(value).Should(Equal("localhost:9092,default,${CHAMELEON_TEST_USER}"))
+
+		value, err = common.Interpolate("${CHAMELEON_TEST_EMPTY}")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(value).Should(BeEmpty())
+
+		_, err = common.Interpolate("${CHAMELEON_TEST_MISSING}")
+		Expect(err).Should(MatchError(ContainSubstring("CHAMELEON_TEST_MISSING is not set")))
+
+		_, err = common.Interpolate("${file:" + filepath.Join(dir, "missing") + "}")
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("load a job configuration with secrets", func() {
+		os.Setenv("CHAMELEON_TEST_PASSWORD", "s3cret")
+		defer os.Unsetenv("CHAMELEON_TEST_PASSWORD")
+
+		dir, err := os.MkdirTemp("", "jobs")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		content := `
+name: ${CHAMELEON_TEST_NAME:-secret job}
+source:
+  batch_size: 1
+  concurency: 1
+  interval: 100
+  fields:
+  - name: id
+    type: int
+sinks:
+- type: console
+  properties:
+    brokers: localhost:9092
+    sasl_password: ${CHAMELEON_TEST_PASSWORD}
+    options:
+      hec_token: ${CHAMELEON_TEST_PASSWORD}
+`
+		file := filepath.Join(dir, "job.yaml")
+		Expect(os.WriteFile(file, []byte(content), 0644)).Should(Succeed())
+
+		config, err := job.LoadConfig(file)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(config.Name).Should(Equal("secret job"))
+		Expect(config.Sinks[0].Properties["sasl_password"]).Should(Equal("s3cret"))
+
+		redacted := config.Redacted()
+		Expect(redacted.Sinks[0].Properties["sasl_password"]).Should(Equal(common.REDACTED))
+		Expect(redacted.Sinks[0].Properties["options"]).Should(HaveKeyWithValue("hec_token", common.REDACTED))
+		Expect(redacted.Sinks[0].Properties["brokers"]).Should(Equal("localhost:9092"))
+		// the configuration itself keeps the secrets
+		Expect(config.Sinks[0].Properties["sasl_password"]).Should(Equal("s3cret"))
+
+		os.Unsetenv("CHAMELEON_TEST_PASSWORD")
+		_, err = job.LoadConfig(file)
+		Expect(err).Should(MatchError(ContainSubstring("CHAMELEON_TEST_PASSWORD is not set")))
+	})
+
+	It("redact the secrets of a job returned by the api", func() {
+		manager := job.NewJobManager()
+		ajob, err := manager.CreateJob(job.JobConfiguration{
+			Name:   "secret job",
+			Source: source.DefaultConfiguration(),
+			Sinks: []sink.Configuration{
+				{
+					Type:       "any",
+					Properties: map[string]interface{}{"apiKey": "s3cret", "address": "http://localhost:8000"},
+				},
+			},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		data, err := json.Marshal(manager.ListJob())
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).ShouldNot(ContainSubstring("s3cret"))
+		Expect(string(data)).Should(ContainSubstring("http://localhost:8000"))
+		Expect(string(data)).Should(ContainSubstring(`"id":"` + ajob.Id + `"`))
+		Expect(ajob.Config.Sinks[0].Properties["apiKey"]).Should(Equal("s3cret"))
+	})
+
+	It("keep the references of the secrets in the job store", func() {
+		os.Setenv("CHAMELEON_TEST_PASSWORD", "s3cret")
+		defer os.Unsetenv("CHAMELEON_TEST_PASSWORD")
+
+		dir, err := os.MkdirTemp("", "jobs")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		content := `
+name: secret job
+source:
+  fields:
+  - name: id
+    type: int
+sinks:
+- type: any
+  properties:
+    password: ${CHAMELEON_TEST_PASSWORD}
+    escaped: $${CHAMELEON_TEST_PASSWORD}
+`
+		file := filepath.Join(dir, "job.yaml")
+		Expect(os.WriteFile(file, []byte(content), 0644)).Should(Succeed())
+		config, err := job.LoadConfig(file)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		path := filepath.Join(dir, "jobs.db")
+		store, err := job.NewJobStore(job.JOB_STORE_FILE, path)
+		Expect(err).ShouldNot(HaveOccurred())
+		manager, err := job.NewJobManagerWithStore(store, job.RESUME_POLICY_FAIL)
+		Expect(err).ShouldNot(HaveOccurred())
+		created, err := manager.CreateJob(*config)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		records, err := store.List()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(records).Should(HaveLen(1))
+		Expect(records[0].Interpolated).Should(BeTrue())
+		Expect(records[0].Config.Sinks[0].Properties["password"]).Should(Equal("${CHAMELEON_TEST_PASSWORD}"))
+		Expect(records[0].Config.Sinks[0].Properties["escaped"]).Should(Equal("$${CHAMELEON_TEST_PASSWORD}"))
+
+		// a clone keeps the references of the cloned job
+		cloned, err := manager.CloneJob(created.Id, map[string]interface{}{"name": "cloned secret job"})
+		Expect(err).ShouldNot(HaveOccurred())
+		records, err = store.List()
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(records).Should(HaveLen(2))
+		for _, record := range records {
+			Expect(record.Interpolated).Should(BeTrue())
+			Expect(record.Config.Sinks[0].Properties["password"]).Should(Equal("${CHAMELEON_TEST_PASSWORD}"))
+		}
+		Expect(cloned.Config.Sinks[0].Properties["password"]).Should(Equal("s3cret"))
+		Expect(store.Close()).Should(Succeed())
+
+		data, err := os.ReadFile(path)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).ShouldNot(ContainSubstring("s3cret"))
+
+		// the references are resolved again when the job is restored
+		store, err = job.NewJobStore(job.JOB_STORE_FILE, path)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer store.Close()
+		restored, err := job.NewJobManagerWithStore(store, job.RESUME_POLICY_FAIL)
+		Expect(err).ShouldNot(HaveOccurred())
+		restoredJob, err := restored.GetJob(created.Id)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(restoredJob.Config.Sinks[0].Properties["password"]).Should(Equal("s3cret"))
+		Expect(restoredJob.Config.Sinks[0].Properties["escaped"]).Should(Equal("${CHAMELEON_TEST_PASSWORD}"))
+	})
+})
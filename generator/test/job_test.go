@@ -1,9 +1,18 @@
 package test_test
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+
 	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/metrics"
 	"github.com/timeplus-io/chameleon/generator/internal/observer"
 	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
 	"github.com/timeplus-io/chameleon/generator/internal/plugins/timeplus"
@@ -14,6 +23,66 @@ import (
 	. "github.com/onsi/gomega"
 )
 
+func init() {
+	// an observer which cannot reach its target
+	observer.Register(observer.ObRegItem{
+		Name: "broken",
+		Constructor: func(properties map[string]interface{}) (observer.Observer, error) {
+			return nil, fmt.Errorf("target is unreachable")
+		},
+	})
+	// an observer which cannot observe again once it is stopped
+	observer.Register(observer.ObRegItem{
+		Name: "once",
+		Constructor: func(properties map[string]interface{}) (observer.Observer, error) {
+			onceLock.Lock()
+			defer onceLock.Unlock()
+			ob := &onceObserver{}
+			onceObservers = append(onceObservers, ob)
+			return ob, nil
+		},
+	})
+}
+
+var (
+	onceLock      sync.Mutex
+	onceObservers []*onceObserver
+)
+
+type onceObserver struct {
+	observed int
+	stopped  bool
+	closed   bool
+}
+
+func (o *onceObserver) Observe(ctx context.Context) error {
+	onceLock.Lock()
+	defer onceLock.Unlock()
+	o.observed++
+	if o.stopped {
+		return fmt.Errorf("observer is stopped")
+	}
+	return nil
+}
+
+func (o *onceObserver) Stop() {
+	onceLock.Lock()
+	defer onceLock.Unlock()
+	o.stopped = true
+}
+
+func (o *onceObserver) Wait() {}
+
+func (o *onceObserver) Close() error {
+	onceLock.Lock()
+	defer onceLock.Unlock()
+	o.closed = true
+	return nil
+}
+
+func (o *onceObserver) Measurements() map[string]float64   { return nil }
+func (o *onceObserver) Series() map[string][]metrics.Point { return nil }
+
 var _ = Describe("Test Job", func() {
 
 	BeforeEach(func() {
@@ -45,7 +114,8 @@ var _ = Describe("Test Job", func() {
 				},
 			}
 
-			job := job.CreateJob("test job", generator, []sink.Sink{console}, nil, 0, jobConfig)
+			job, err := job.CreateJob("test job", generator, []sink.Sink{console}, nil, 0, jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
 
 			job.Start()
 			time.Sleep(3 * time.Second)
@@ -119,5 +189,402 @@ var _ = Describe("Test Job", func() {
 			manager.StopJob(ajob.Id)
 			Expect(ajob.Status).Should(Equal(job.STATUS_STOPPED))
 		})
+
+		It("fail to create a job when an observer cannot be created", func() {
+			resetDisposed()
+			jobConfig := job.JobConfiguration{
+				Name:   "test job",
+				Source: source.DefaultConfiguration(),
+				Sinks: []sink.Configuration{{
+					Type:       "disposable",
+					Properties: map[string]interface{}{},
+				}},
+				Observers: []observer.Configuration{
+					{Type: "counting", Properties: map[string]interface{}{}},
+					{Type: "broken", Properties: map[string]interface{}{}},
+				},
+			}
+
+			_, err := job.NewJob(jobConfig)
+			Expect(err).Should(MatchError(ContainSubstring("invalid observer 1")))
+			// the sink created before the observer is closed
+			Expect(closedSinks()).Should(Equal(1))
+		})
+
+		It("restart and clone job", func() {
+			jobConfig := job.JobConfiguration{
+				Name:   "test job",
+				Source: source.DefaultConfiguration(),
+				Sinks: []sink.Configuration{
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+				},
+			}
+
+			manager := job.NewJobManager()
+			ajob, err := manager.CreateJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			ajob.Start()
+			Expect(manager.RestartJob(ajob.Id)).Should(HaveOccurred())
+			ajob.Stop()
+			Expect(manager.StartJob(ajob.Id)).Should(HaveOccurred())
+
+			firstRun := ajob.CurrentRun().Id
+			Expect(manager.RestartJob(ajob.Id)).ShouldNot(HaveOccurred())
+			Eventually(func() job.JobStatus {
+				bjob, _ := manager.GetJob(ajob.Id)
+				return bjob.CurrentRun().Status
+			}, 3*time.Second).Should(Equal(job.STATUS_RUNNING))
+			manager.StopJob(ajob.Id)
+
+			Expect(ajob.CurrentRun().Id).ShouldNot(Equal(firstRun))
+			runs := ajob.PreviousRuns()
+			Expect(len(runs)).Should(Equal(1))
+			Expect(runs[0].Id).Should(Equal(firstRun))
+			Expect(runs[0].Status).Should(Equal(job.STATUS_STOPPED))
+
+			cjob, err := manager.CloneJob(ajob.Id, map[string]interface{}{
+				"name":   "cloned job",
+				"source": map[string]interface{}{"batch_size": 7},
+			})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(cjob.Id).ShouldNot(Equal(ajob.Id))
+			Expect(cjob.Name).Should(Equal("cloned job"))
+			Expect(cjob.Config.Source.BatchSize).Should(Equal(7))
+			Expect(cjob.Config.Source.Concurrency).Should(Equal(ajob.Config.Source.Concurrency))
+			Expect(len(cjob.Config.Sinks)).Should(Equal(1))
+			Expect(len(manager.ListJob())).Should(Equal(2))
+		})
+
+		It("stream job telemetry", func() {
+			jobConfig := job.JobConfiguration{
+				Name:   "test job",
+				Source: source.DefaultConfiguration(),
+				Sinks: []sink.Configuration{
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+				},
+			}
+
+			manager := job.NewJobManager()
+			ajob, err := manager.CreateJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			transitions, unsubscribe := ajob.Subscribe()
+			defer unsubscribe()
+			telemetry := job.NewTelemetry(ajob)
+
+			ajob.Start()
+			Eventually(transitions).Should(Receive(HaveField("Status", job.STATUS_RUNNING)))
+
+			time.Sleep(2 * time.Second)
+			snapshot := telemetry.Next()
+			Expect(snapshot.Status).Should(Equal(job.STATUS_RUNNING))
+			Expect(snapshot.Generated).Should(BeNumerically(">", 0))
+			Expect(snapshot.GeneratedEps).Should(BeNumerically(">", 0))
+			Expect(len(snapshot.Sinks)).Should(Equal(1))
+			Expect(snapshot.Sinks[0].Type).Should(Equal("console"))
+
+			ajob.Stop()
+			Eventually(transitions).Should(Receive(HaveField("Status", job.STATUS_STOPPED)))
+			Expect(telemetry.Next().Sinks[0].SuccessWrite).Should(BeNumerically(">", 0))
+		})
+
+		It("patch a running job", func() {
+			jobConfig := job.JobConfiguration{
+				Name:   "test job",
+				Source: source.DefaultConfiguration(),
+				Sinks: []sink.Configuration{
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+				},
+			}
+			jobConfig.Source.Interval = 100
+
+			manager := job.NewJobManager()
+			ajob, err := manager.CreateJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			batchSize, concurrency := 10, 3
+			patch := job.JobPatch{Source: &source.Settings{BatchSize: &batchSize}}
+			_, err = manager.PatchJob(ajob.Id, patch)
+			Expect(err).Should(HaveOccurred())
+
+			telemetry := job.NewTelemetry(ajob)
+			ajob.Start()
+			defer ajob.Stop()
+			time.Sleep(500 * time.Millisecond)
+
+			patch = job.JobPatch{
+				Source: &source.Settings{BatchSize: &batchSize, Concurrency: &concurrency},
+				Sinks:  []job.ComponentPatch{{Index: 1, Enabled: false}},
+			}
+			annotation, err := manager.PatchJob(ajob.Id, patch)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(annotation.Message).Should(Equal("batch_size 3 -> 10, concurency 1 -> 3, sink 1 (console) disabled"))
+			Expect(ajob.Config.Source.BatchSize).Should(Equal(10))
+			Expect(ajob.Config.Sinks[1].Disabled).Should(BeTrue())
+
+			_, err = manager.PatchJob(ajob.Id, job.JobPatch{Sinks: []job.ComponentPatch{{Index: 2, Enabled: false}}})
+			Expect(err).Should(HaveOccurred())
+
+			snapshot := telemetry.Next()
+			Expect(snapshot.Annotations).Should(Equal([]*job.Annotation{annotation}))
+
+			time.Sleep(time.Second)
+			snapshot = telemetry.Next()
+			Expect(snapshot.Annotations).Should(BeEmpty())
+			// three routines generating batches of 10 rows every 100ms
+			Expect(snapshot.GeneratedEps).Should(BeNumerically(">", 150))
+			Expect(snapshot.Sinks[0].WriteRate).Should(BeNumerically(">", 150))
+			// the disabled sink only writes the batches queued before the patch
+			Expect(snapshot.Sinks[1].SuccessWrite).Should(BeNumerically("<", snapshot.Sinks[0].SuccessWrite/2))
+		})
+
+		It("create an observer again when it is enabled again", func() {
+			jobConfig := job.JobConfiguration{
+				Name:   "test job",
+				Source: source.DefaultConfiguration(),
+				Sinks: []sink.Configuration{
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+				},
+				Observers: []observer.Configuration{
+					{
+						Type:       "once",
+						Properties: map[string]interface{}{},
+					},
+				},
+			}
+			jobConfig.Source.Interval = 100
+
+			onceLock.Lock()
+			onceObservers = nil
+			onceLock.Unlock()
+
+			manager := job.NewJobManager()
+			ajob, err := manager.CreateJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+			ajob.Start()
+			defer ajob.Stop()
+			time.Sleep(200 * time.Millisecond)
+
+			_, err = manager.PatchJob(ajob.Id, job.JobPatch{Observers: []job.ComponentPatch{{Index: 0, Enabled: false}, {Index: 0, Enabled: true}}})
+			Expect(err).Should(HaveOccurred())
+
+			annotation, err := manager.PatchJob(ajob.Id, job.JobPatch{Observers: []job.ComponentPatch{{Index: 0, Enabled: false}}})
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(annotation.Message).Should(Equal("observer 0 (once) disabled"))
+			_, err = manager.PatchJob(ajob.Id, job.JobPatch{Observers: []job.ComponentPatch{{Index: 0, Enabled: true}}})
+			Expect(err).ShouldNot(HaveOccurred())
+
+			onceLock.Lock()
+			defer onceLock.Unlock()
+			Expect(onceObservers).Should(HaveLen(2))
+			// the stopped observer is closed and never observes again
+			Expect(onceObservers[0].observed).Should(Equal(1))
+			Expect(onceObservers[0].closed).Should(BeTrue())
+			Expect(onceObservers[1].observed).Should(Equal(1))
+			Expect(onceObservers[1].stopped).Should(BeFalse())
+		})
+
+		It("expose job metrics", func() {
+			jobConfig := job.JobConfiguration{
+				Name:   "test job",
+				Source: source.DefaultConfiguration(),
+				Sinks: []sink.Configuration{
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+				},
+			}
+
+			manager := job.NewJobManager()
+			ajob, err := manager.CreateJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			ajob.Start()
+			time.Sleep(2 * time.Second)
+			ajob.Stop()
+
+			registry := prometheus.NewPedanticRegistry()
+			registry.MustRegister(job.NewJobCollector(manager))
+			families, err := registry.Gather()
+			Expect(err).ShouldNot(HaveOccurred())
+
+			metrics := map[string]*dto.MetricFamily{}
+			for _, family := range families {
+				metrics[family.GetName()] = family
+			}
+			Expect(metrics).Should(HaveKey("chameleon_job_status"))
+			Expect(metrics).Should(HaveKey("chameleon_sink_write_duration_seconds"))
+
+			written := metrics["chameleon_sink_rows_written_total"].GetMetric()
+			Expect(written).Should(HaveLen(1))
+			Expect(written[0].GetCounter().GetValue()).Should(BeNumerically(">", 0))
+
+			latency := metrics["chameleon_sink_write_duration_seconds"].GetMetric()[0].GetHistogram()
+			Expect(latency.GetSampleCount()).Should(BeNumerically(">", 0))
+		})
+
+		It("validate and run a job configuration", func() {
+			jobConfig := job.JobConfiguration{
+				Name:    "test job",
+				Source:  source.DefaultConfiguration(),
+				Timeout: 2,
+				Sinks: []sink.Configuration{
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+				},
+			}
+			Expect(jobConfig.Validate()).Should(Succeed())
+
+			invalid := jobConfig
+			invalid.Sinks = []sink.Configuration{{Type: "unknown"}}
+			Expect(invalid.Validate()).Should(MatchError(ContainSubstring("the sink unknown does not exist")))
+
+			invalid = jobConfig
+			invalid.Observers = []observer.Configuration{{Type: "unknown"}}
+			Expect(invalid.Validate()).Should(HaveOccurred())
+
+			invalid = jobConfig
+			invalid.Schedule = &job.Schedule{Cron: "not a cron"}
+			Expect(invalid.Validate()).Should(HaveOccurred())
+
+			invalid = jobConfig
+			invalid.Distribution = &job.Distribution{Strategy: "unknown"}
+			Expect(invalid.Validate()).Should(HaveOccurred())
+			_, err := job.NewRunJob(invalid)
+			Expect(err).Should(HaveOccurred())
+
+			ajob, err := job.NewRunJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+			ajob.RunToEnd()
+
+			run := ajob.CurrentRun()
+			Expect(run.Status).Should(Equal(job.STATUS_STOPPED))
+			Expect(run.StartedAt).ShouldNot(BeNil())
+			Expect(run.StoppedAt).ShouldNot(BeNil())
+			Expect(run.Stats.SuccessWrite).Should(BeNumerically(">", 0))
+		})
+
+		It("scheduled job", func() {
+			jobConfig := job.JobConfiguration{
+				Name:   "test job",
+				Source: source.DefaultConfiguration(),
+				Sinks: []sink.Configuration{
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+				},
+				Schedule: &job.Schedule{Cron: "not a cron"},
+			}
+
+			manager := job.NewJobManager()
+			_, err := manager.CreateJob(jobConfig)
+			Expect(err).Should(HaveOccurred())
+
+			jobConfig.Schedule = &job.Schedule{Interval: 1, Duration: 2, Overlap: job.OVERLAP_SKIP}
+			ajob, err := manager.CreateJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			Expect(manager.StartJob(ajob.Id)).ShouldNot(HaveOccurred())
+			Expect(ajob.CurrentRun().Status).Should(Equal(job.STATUS_SCHEDULED))
+			Expect(manager.StartJob(ajob.Id)).Should(HaveOccurred())
+
+			Eventually(func() int {
+				bjob, _ := manager.GetJob(ajob.Id)
+				return len(bjob.PreviousRuns())
+			}, 6*time.Second, 100*time.Millisecond).Should(BeNumerically(">=", 1))
+
+			manager.StopJob(ajob.Id)
+			Expect(ajob.CurrentRun().Status).Should(Equal(job.STATUS_STOPPED))
+			state := ajob.Scheduling()
+			Expect(state.LastRun).ShouldNot(BeNil())
+			Expect(state.LastRunStatus).Should(Equal(job.STATUS_STOPPED))
+			Expect(state.SkippedRuns).Should(BeNumerically(">=", 1))
+			Expect(state.ActiveRuns).Should(Equal(0))
+			Expect(state.NextRun).Should(BeNil())
+			Expect(ajob.PreviousRuns()[0].Stats.SuccessWrite).Should(BeNumerically(">", 0))
+		})
+
+		It("restore jobs from file job store", func() {
+			dir, err := os.MkdirTemp("", "jobstore")
+			Expect(err).ShouldNot(HaveOccurred())
+			defer os.RemoveAll(dir)
+			path := filepath.Join(dir, "jobs.db")
+
+			jobConfig := job.JobConfiguration{
+				Name:   "test job",
+				Source: source.DefaultConfiguration(),
+				Sinks: []sink.Configuration{
+					{
+						Type:       "console",
+						Properties: map[string]interface{}{},
+					},
+				},
+			}
+
+			store, err := job.NewJobStore(job.JOB_STORE_FILE, path)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			manager, err := job.NewJobManagerWithStore(store, job.RESUME_POLICY_FAIL)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			stopped, err := manager.CreateJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+			stopped.Start()
+			stopped.Stop()
+
+			running, err := manager.CreateJob(jobConfig)
+			Expect(err).ShouldNot(HaveOccurred())
+			running.Start()
+			Expect(store.Close()).ShouldNot(HaveOccurred())
+			defer running.Stop()
+
+			store, err = job.NewJobStore(job.JOB_STORE_FILE, path)
+			Expect(err).ShouldNot(HaveOccurred())
+			defer store.Close()
+
+			restored, err := job.NewJobManagerWithStore(store, job.RESUME_POLICY_FAIL)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(restored.ListJob())).Should(Equal(2))
+
+			stoppedJob, err := restored.GetJob(stopped.Id)
+			Expect(err).ShouldNot(HaveOccurred())
+			stoppedRun := stoppedJob.CurrentRun()
+			Expect(stoppedRun.Status).Should(Equal(job.STATUS_STOPPED))
+			Expect(stoppedRun.StartedAt).ShouldNot(BeNil())
+			Expect(stoppedRun.StoppedAt).ShouldNot(BeNil())
+			Expect(len(stoppedJob.History)).Should(Equal(3))
+			Expect(stoppedJob.Config.Name).Should(Equal("test job"))
+
+			runningJob, err := restored.GetJob(running.Id)
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(runningJob.CurrentRun().Status).Should(Equal(job.STATUS_FAILED))
+			Expect(restored.StartJob(running.Id)).Should(HaveOccurred())
+
+			Expect(restored.DeleteJob(stopped.Id)).ShouldNot(HaveOccurred())
+			records, err := store.List()
+			Expect(err).ShouldNot(HaveOccurred())
+			Expect(len(records)).Should(Equal(1))
+		})
 	})
 })
@@ -1,6 +1,7 @@
 package test_test
 
 import (
+	"context"
 	"time"
 
 	"github.com/timeplus-io/chameleon/generator/internal/job"
@@ -15,6 +16,7 @@ import (
 var _ = Describe("Test Kafka", func() {
 
 	BeforeEach(func() {
+		requireServices("localhost:9092")
 		kafka.Init()
 	})
 
@@ -52,7 +54,7 @@ var _ = Describe("Test Kafka", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			go func() {
-				err = kafkaOb.Observe()
+				err = kafkaOb.Observe(context.Background())
 				Expect(err).ShouldNot(HaveOccurred())
 			}()
 
@@ -69,7 +71,7 @@ var _ = Describe("Test Kafka", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			go func() {
-				err = kafkaOb.Observe()
+				err = kafkaOb.Observe(context.Background())
 				Expect(err).ShouldNot(HaveOccurred())
 			}()
 
@@ -1,6 +1,7 @@
 package test_test
 
 import (
+	"context"
 	"time"
 
 	"github.com/timeplus-io/chameleon/generator/internal/job"
@@ -14,6 +15,7 @@ import (
 
 var _ = Describe("Test KSQL", func() {
 	BeforeEach(func() {
+		requireServices("localhost:8088", "localhost:9092")
 		ksql.Init()
 	})
 
@@ -81,7 +83,7 @@ var _ = Describe("Test KSQL", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			go func() {
-				err = ob.Observe()
+				err = ob.Observe(context.Background())
 				Expect(err).ShouldNot(HaveOccurred())
 			}()
 
@@ -89,7 +91,7 @@ var _ = Describe("Test KSQL", func() {
 			ob.Stop()
 		})
 
-		FIt("create ksql throughput observer", func() {
+		It("create ksql throughput observer", func() {
 			properties := map[string]interface{}{
 				"metric": "throughput",
 				"query":  `SELECT count(*), g FROM test GROUP BY g`,
@@ -98,7 +100,7 @@ var _ = Describe("Test KSQL", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			go func() {
-				err = ob.Observe()
+				err = ob.Observe(context.Background())
 				Expect(err).ShouldNot(HaveOccurred())
 			}()
 
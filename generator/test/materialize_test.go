@@ -1,6 +1,7 @@
 package test_test
 
 import (
+	"context"
 	"time"
 
 	"github.com/timeplus-io/chameleon/generator/internal/job"
@@ -15,6 +16,7 @@ import (
 var _ = Describe("Test Materialize", func() {
 
 	BeforeEach(func() {
+		requireServices("localhost:6875")
 		materialize.Init()
 	})
 
@@ -26,10 +28,8 @@ var _ = Describe("Test Materialize", func() {
 				Source: source.DefaultConfiguration(),
 				Sinks: []sink.Configuration{
 					{
-						Type: materialize.MATERIALIZE_SINK_TYPE,
-						Properties: map[string]interface{}{
-							"hec_token": "abcd1234",
-						},
+						Type:       materialize.MATERIALIZE_SINK_TYPE,
+						Properties: map[string]interface{}{},
 					},
 				},
 			}
@@ -55,7 +55,7 @@ var _ = Describe("Test Materialize", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			go func() {
-				err = ob.Observe()
+				err = ob.Observe(context.Background())
 				Expect(err).ShouldNot(HaveOccurred())
 			}()
 
@@ -72,7 +72,7 @@ var _ = Describe("Test Materialize", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			go func() {
-				err = ob.Observe()
+				err = ob.Observe(context.Background())
 				Expect(err).ShouldNot(HaveOccurred())
 			}()
 
@@ -89,7 +89,7 @@ var _ = Describe("Test Materialize", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			go func() {
-				err = ob.Observe()
+				err = ob.Observe(context.Background())
 				Expect(err).ShouldNot(HaveOccurred())
 			}()
 
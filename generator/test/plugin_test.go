@@ -0,0 +1,126 @@
+package test_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/timeplus-io/chameleon/generator/internal/auth"
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/observer"
+	"github.com/timeplus-io/chameleon/generator/internal/schema"
+	"github.com/timeplus-io/chameleon/generator/internal/server"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+	"github.com/timeplus-io/chameleon/generator/internal/templates"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test Plugin Properties", func() {
+
+	BeforeEach(func() {
+		server.InitPlugins()
+	})
+
+	It("parse properties against their schema", func() {
+		properties := schema.Properties{
+			{Name: "host", Type: schema.TYPE_STRING, Required: true},
+			{Name: "port", Type: schema.TYPE_INTEGER, Default: 8123},
+			{Name: "tls", Type: schema.TYPE_BOOLEAN, Default: false},
+			{Name: "mode", Type: schema.TYPE_STRING, Default: "json", Enum: []string{"json", "otlp"}},
+		}
+
+		values, err := properties.Parse(map[string]interface{}{"host": "localhost", "port": "9000", "tls": "true"})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(values.String("host")).Should(Equal("localhost"))
+		Expect(values.Int("port")).Should(Equal(9000))
+		Expect(values.Bool("tls")).Should(BeTrue())
+		Expect(values.String("mode")).Should(Equal("json"))
+
+		values, err = properties.Parse(map[string]interface{}{"host": "localhost", "port": float64(8124)})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(values.Int("port")).Should(Equal(8124))
+
+		_, err = properties.Parse(map[string]interface{}{"host": "localhost", "hots": "localhost"})
+		Expect(err).Should(MatchError(ContainSubstring("unknown properties hots")))
+		_, err = properties.Parse(map[string]interface{}{"port": 8123})
+		Expect(err).Should(MatchError(ContainSubstring("property host is required")))
+		_, err = properties.Parse(map[string]interface{}{"host": "localhost", "port": "eighty"})
+		Expect(err).Should(MatchError(ContainSubstring("property port : eighty is not an integer")))
+		_, err = properties.Parse(map[string]interface{}{"host": "localhost", "mode": "xml"})
+		Expect(err).Should(MatchError(ContainSubstring("xml is not one of json, otlp")))
+
+		// without schema any property is accepted
+		values, err = schema.Properties(nil).Parse(map[string]interface{}{"anything": 1})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(values.Has("anything")).Should(BeTrue())
+	})
+
+	It("reject unknown properties of the sinks and observers of a job", func() {
+		config := job.JobConfiguration{
+			Name:   "misspelled",
+			Source: source.DefaultConfiguration(),
+			Sinks: []sink.Configuration{
+				{Type: "console", Properties: map[string]interface{}{}},
+			},
+			Observers: []observer.Configuration{
+				{Type: "splunk", Properties: map[string]interface{}{"host": "localhost", "port": "8089"}},
+			},
+		}
+		Expect(config.Validate()).Should(Succeed())
+
+		config.Sinks[0].Properties = map[string]interface{}{"brokers": "localhost:9092"}
+		Expect(config.Validate()).Should(MatchError(ContainSubstring("sink 0 : invalid properties of console : unknown properties brokers")))
+		_, err := job.NewJobManager().CreateJob(config)
+		Expect(err).Should(MatchError(ContainSubstring("unknown properties brokers")))
+
+		config.Sinks[0].Properties = map[string]interface{}{}
+		config.Observers[0].Properties = map[string]interface{}{"metric": "jitter"}
+		Expect(config.Validate()).Should(MatchError(ContainSubstring("observer 0 : invalid properties of splunk")))
+	})
+
+	It("redact the secret properties", func() {
+		config := job.JobConfiguration{
+			Sinks: []sink.Configuration{
+				{Type: "splunk", Properties: map[string]interface{}{"hec_token": "t0ken", "index": "main"}},
+			},
+			Observers: []observer.Configuration{
+				{Type: "kafka", Properties: map[string]interface{}{"password": "s3cret", "topic": "test"}},
+			},
+		}
+		redacted := config.Redacted()
+		Expect(redacted.Sinks[0].Properties["hec_token"]).Should(Equal(common.REDACTED))
+		Expect(redacted.Sinks[0].Properties["index"]).Should(Equal("main"))
+		Expect(redacted.Observers[0].Properties["password"]).Should(Equal(common.REDACTED))
+		Expect(redacted.Observers[0].Properties["topic"]).Should(Equal("test"))
+	})
+
+	It("serve the schemas of the plugins", func() {
+		authenticator, err := auth.NewAuthenticator(nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		catalogue, err := templates.NewCatalogue("")
+		Expect(err).ShouldNot(HaveOccurred())
+		router := server.NewRouter(job.NewJobManager(), nil, catalogue, authenticator)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/plugins", nil))
+		Expect(recorder.Code).Should(Equal(http.StatusOK))
+
+		var response struct {
+			Sinks     map[string]map[string]interface{} `json:"sinks"`
+			Observers map[string]map[string]interface{} `json:"observers"`
+		}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &response)).Should(Succeed())
+
+		timeplus := response.Sinks["timeplus"]
+		Expect(timeplus["type"]).Should(Equal("object"))
+		Expect(timeplus["additionalProperties"]).Should(BeFalse())
+		Expect(timeplus["properties"]).Should(HaveKeyWithValue("maxConnsPerHost", HaveKeyWithValue("type", "integer")))
+		Expect(timeplus["properties"]).Should(HaveKeyWithValue("apikey", HaveKeyWithValue("writeOnly", true)))
+		Expect(response.Sinks["console"]["properties"]).Should(BeEmpty())
+		Expect(response.Observers["splunk"]["properties"]).Should(HaveKeyWithValue("port", HaveKeyWithValue("default", float64(8089))))
+	})
+})
@@ -0,0 +1,202 @@
+package test_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// slowSink records the index of the written batches, each write waits until the gate is opened
+type slowSink struct {
+	gate    chan struct{}
+	written []int
+	rows    [][]interface{}
+	lock    sync.Mutex
+}
+
+func newSlowSink() *slowSink {
+	return &slowSink{gate: make(chan struct{})}
+}
+
+func (s *slowSink) Init(ctx context.Context, name string, fields []common.Field) error {
+	return nil
+}
+
+func (s *slowSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
+	<-s.gate
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.written = append(s.written, index)
+	s.rows = append(s.rows, rows...)
+	return nil
+}
+
+func (s *slowSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *slowSink) Close() error {
+	return nil
+}
+
+func (s *slowSink) GetStats() *sink.Stats {
+	return &sink.Stats{}
+}
+
+func (s *slowSink) result() []int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]int{}, s.written...)
+}
+
+func pushBatches(queue *sink.Queue, count int) {
+	for i := 0; i < count; i++ {
+		queue.Push(&sink.Batch{
+			Headers: []string{"value", "time"},
+			Rows:    [][]interface{}{{i, time.Now().UTC()}},
+			Index:   i,
+		})
+	}
+}
+
+var _ = Describe("Test Sink Queue", func() {
+
+	It("reject invalid policy", func() {
+		_, err := sink.NewQueue(newSlowSink(), sink.QueueConfiguration{Policy: "unknown"}, 1, nil)
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("drop newest batches when full", func() {
+		s := newSlowSink()
+		queue, err := sink.NewQueue(s, sink.QueueConfiguration{Size: 2, Workers: 1, Policy: sink.QUEUE_POLICY_DROP_NEWEST}, 1, func(batch *sink.Batch, err error) {})
+		Expect(err).ShouldNot(HaveOccurred())
+		queue.Start(context.Background())
+
+		// the worker holds the first batch, two wait in the queue and the others are dropped
+		pushBatches(queue, 1)
+		Eventually(queue.Depth).Should(Equal(0))
+		pushBatches(queue, 5)
+		Expect(queue.Depth()).Should(Equal(2))
+		Expect(queue.Dropped()).Should(Equal(3))
+
+		close(s.gate)
+		Expect(queue.Close(5 * time.Second)).Should(BeTrue())
+		Expect(s.result()).Should(Equal([]int{0, 0, 1}))
+	})
+
+	It("drop oldest batches when full", func() {
+		s := newSlowSink()
+		queue, err := sink.NewQueue(s, sink.QueueConfiguration{Size: 2, Workers: 1, Policy: sink.QUEUE_POLICY_DROP_OLDEST}, 1, func(batch *sink.Batch, err error) {})
+		Expect(err).ShouldNot(HaveOccurred())
+		queue.Start(context.Background())
+
+		pushBatches(queue, 1)
+		Eventually(queue.Depth).Should(Equal(0))
+		pushBatches(queue, 5)
+		Expect(queue.Dropped()).Should(Equal(3))
+
+		close(s.gate)
+		Expect(queue.Close(5 * time.Second)).Should(BeTrue())
+		Expect(s.result()).Should(Equal([]int{0, 3, 4}))
+	})
+
+	It("report the dropped batches as failed to the callback", func() {
+		s := newSlowSink()
+		lock := sync.Mutex{}
+		written, failed := 0, 0
+		queue, err := sink.NewQueue(s, sink.QueueConfiguration{Size: 2, Workers: 1, Policy: sink.QUEUE_POLICY_SPILL}, 1, func(batch *sink.Batch, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			if done, batchFailed := batch.Delivered(err); done && batchFailed {
+				Expect(err).Should(MatchError(sink.ErrDropped))
+				failed++
+			} else if done {
+				written++
+			}
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		queue.Start(ctx)
+
+		// the worker holds the first batch, two wait in the queue and the others are spilled
+		pushBatches(queue, 1)
+		Eventually(queue.Depth).Should(Equal(0))
+		pushBatches(queue, 5)
+		Expect(queue.Close(50 * time.Millisecond)).Should(BeFalse())
+		pushBatches(queue, 1)
+
+		close(s.gate)
+		queue.Wait()
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(queue.Dropped()).Should(Equal(4))
+		Expect(failed).Should(Equal(4))
+		Expect(written).Should(Equal(3))
+	})
+
+	It("spill batches to disk and keep them in order", func() {
+		s := newSlowSink()
+		written := 0
+		lock := sync.Mutex{}
+		queue, err := sink.NewQueue(s, sink.QueueConfiguration{Size: 2, Workers: 1, Policy: sink.QUEUE_POLICY_SPILL, SpillDir: GinkgoT().TempDir()}, 1, func(batch *sink.Batch, err error) {
+			lock.Lock()
+			defer lock.Unlock()
+			written++
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		queue.Start(ctx)
+
+		pushBatches(queue, 10)
+		Expect(queue.Depth()).Should(BeNumerically(">", 2))
+		Expect(queue.Dropped()).Should(Equal(0))
+
+		close(s.gate)
+		Expect(queue.Close(5 * time.Second)).Should(BeTrue())
+		Expect(s.result()).Should(Equal([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}))
+		Expect(written).Should(Equal(10))
+
+		// the values keep their type after being spilled
+		for _, row := range s.rows {
+			Expect(row[0]).Should(BeAssignableToTypeOf(0))
+			Expect(row[1]).Should(BeAssignableToTypeOf(time.Time{}))
+		}
+	})
+
+	It("wait for the writes in progress after a timed out close", func() {
+		s := newSlowSink()
+		batches := make(chan *sink.Batch, 1)
+		queue, err := sink.NewQueue(s, sink.QueueConfiguration{Size: 2, Workers: 1}, 1, func(batch *sink.Batch, err error) {
+			batches <- batch
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		queue.Start(context.Background())
+
+		pushBatches(queue, 1)
+		Expect(queue.Close(50 * time.Millisecond)).Should(BeFalse())
+
+		waited := make(chan struct{})
+		go func() {
+			queue.Wait()
+			close(waited)
+		}()
+		Consistently(waited, 100*time.Millisecond).ShouldNot(BeClosed())
+
+		close(s.gate)
+		Eventually(waited).Should(BeClosed())
+		Expect(s.result()).Should(Equal([]int{0}))
+
+		// the lag is the time spent in the queue, without the time spent writing
+		batch := <-batches
+		Expect(batch.Dequeued).ShouldNot(BeTemporally("<", batch.Enqueued))
+		Expect(batch.Dequeued.Sub(batch.Enqueued)).Should(BeNumerically("<", 50*time.Millisecond))
+	})
+})
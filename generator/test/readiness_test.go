@@ -0,0 +1,114 @@
+package test_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/splunk"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// unreadySink is not ready for its first `failures` probes
+type unreadySink struct {
+	sink.Sink
+	failures int
+	probes   int
+}
+
+func init() {
+	sink.Register(sink.SinkRegItem{
+		Name: "unready",
+		Constructor: func(properties map[string]interface{}) (sink.Sink, error) {
+			console, _ := console.NewConsoleSink(nil)
+			return &unreadySink{Sink: console, failures: int(properties["failures"].(float64))}, nil
+		},
+	})
+}
+
+func (s *unreadySink) Ready(ctx context.Context) error {
+	s.probes++
+	if s.probes <= s.failures {
+		return fmt.Errorf("connection refused")
+	}
+	return nil
+}
+
+var _ = Describe("Test Readiness", func() {
+
+	BeforeEach(func() {
+		console.Init()
+	})
+
+	newConfig := func(failures int, readiness *job.ReadinessConfiguration) job.JobConfiguration {
+		return job.JobConfiguration{
+			Name:   "readiness",
+			Source: source.DefaultConfiguration(),
+			Sinks: []sink.Configuration{
+				{Type: "console", Properties: map[string]interface{}{}},
+				{Type: "unready", Properties: map[string]interface{}{"failures": float64(failures)}},
+			},
+			Readiness: readiness,
+		}
+	}
+
+	It("wait for the sinks to be ready", func() {
+		created, err := job.NewJobManager().CreateJob(newConfig(3, &job.ReadinessConfiguration{Timeout: 5, InitialBackoff: 10}))
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(created.CurrentRun().Status).Should(Equal(job.STATUS_INIT))
+	})
+
+	It("return the readiness failure of a job created from its components", func() {
+		config := newConfig(1000, &job.ReadinessConfiguration{Timeout: 1, InitialBackoff: 10, MaxBackoff: 100})
+		generator, err := source.NewGenarator(config.Source)
+		Expect(err).ShouldNot(HaveOccurred())
+		consoleSink, err := console.NewConsoleSink(nil)
+		Expect(err).ShouldNot(HaveOccurred())
+
+		_, err = job.CreateJob(config.Name, generator, []sink.Sink{consoleSink, &unreadySink{Sink: consoleSink, failures: 1000}}, nil, 0, config)
+		Expect(err).Should(MatchError(ContainSubstring("sink 1 (unready)")))
+	})
+
+	It("fail with the sinks which are not ready", func() {
+		_, err := job.NewJobManager().CreateJob(newConfig(1000, &job.ReadinessConfiguration{Timeout: 1, InitialBackoff: 10, MaxBackoff: 100}))
+		Expect(err).Should(MatchError(ContainSubstring("not ready after 1s, sink 1 (unready) : ")))
+		Expect(err).Should(MatchError(ContainSubstring("probes failed, the last one with connection refused")))
+		Expect(err.Error()).ShouldNot(ContainSubstring("sink 0"))
+
+		// without readiness the sinks are not probed
+		_, err = job.NewJobManager().CreateJob(newConfig(1000, nil))
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(newConfig(0, &job.ReadinessConfiguration{Timeout: -1}).Validate()).Should(MatchError(ContainSubstring("invalid readiness")))
+	})
+
+	It("probe the health of the splunk http event collector", func() {
+		var calls int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Expect(r.URL.Path).Should(Equal(splunk.HEC_HEALTH_PATH))
+			if atomic.AddInt32(&calls, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte(`{"text":"HEC is healthy","code":17}`))
+		}))
+		defer server.Close()
+
+		hec, err := splunk.NewSplunkSink(map[string]interface{}{"hec_address": server.URL + "/services/collector/event"})
+		Expect(err).ShouldNot(HaveOccurred())
+		probe := hec.(common.ReadinessProbe)
+
+		Expect(probe.Ready(context.Background())).Should(MatchError(ContainSubstring("503")))
+		Expect(probe.Ready(context.Background())).Should(HaveOccurred())
+		Expect(probe.Ready(context.Background())).Should(Succeed())
+	})
+})
@@ -0,0 +1,134 @@
+package test_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/common"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// failingSink fails the first failures writes with err, then accepts the rows
+type failingSink struct {
+	failures int
+	err      error
+	writes   int
+	lock     sync.Mutex
+}
+
+func (s *failingSink) Init(ctx context.Context, name string, fields []common.Field) error {
+	return nil
+}
+
+func (s *failingSink) Write(ctx context.Context, headers []string, rows [][]interface{}, index int) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.writes++
+	if s.writes <= s.failures {
+		return s.err
+	}
+	return nil
+}
+
+func (s *failingSink) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (s *failingSink) Close() error {
+	return nil
+}
+
+func (s *failingSink) GetStats() *sink.Stats {
+	return &sink.Stats{}
+}
+
+var _ = Describe("Test Sink Retry", func() {
+	headers := []string{"value"}
+	rows := [][]interface{}{{1}, {2}}
+	retry := &sink.RetryConfiguration{MaxAttempts: 3, InitialBackoff: 1, MaxBackoff: 5}
+
+	It("retry failed writes until they succeed", func() {
+		s := &failingSink{failures: 2, err: fmt.Errorf("unavailable")}
+		retrySink, err := sink.NewRetrySink(s, sink.Configuration{Type: "test", Retry: retry})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(retrySink.Write(context.Background(), headers, rows, 0)).Should(Succeed())
+		Expect(s.writes).Should(Equal(3))
+		Expect(retrySink.Retries()).Should(Equal(2))
+	})
+
+	It("do not retry permanent errors", func() {
+		s := &failingSink{failures: 1, err: sink.Permanent(fmt.Errorf("bad row"))}
+		retrySink, err := sink.NewRetrySink(s, sink.Configuration{Type: "test", Retry: retry})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(retrySink.Write(context.Background(), headers, rows, 0)).Should(HaveOccurred())
+		Expect(s.writes).Should(Equal(1))
+		Expect(retrySink.Retries()).Should(Equal(0))
+	})
+
+	It("stop retrying once the write is aborted", func() {
+		s := &failingSink{failures: 3, err: fmt.Errorf("unavailable")}
+		retrySink, err := sink.NewRetrySink(s, sink.Configuration{
+			Type:  "test",
+			Retry: &sink.RetryConfiguration{MaxAttempts: 3, InitialBackoff: 60000, MaxBackoff: 60000},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		Expect(retrySink.Write(ctx, headers, rows, 0)).Should(MatchError("unavailable"))
+		Expect(s.writes).Should(Equal(1))
+	})
+
+	It("write exhausted rows to dead letter file", func() {
+		dir, err := os.MkdirTemp("", "dead-letter")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "dead_letter.jsonl")
+
+		s := &failingSink{failures: 10, err: fmt.Errorf("unavailable")}
+		retrySink, err := sink.NewRetrySink(s, sink.Configuration{
+			Type:       "test",
+			Retry:      retry,
+			DeadLetter: &sink.DeadLetterConfiguration{File: path},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(retrySink.Init(context.Background(), "test", nil)).Should(Succeed())
+
+		Expect(retrySink.Write(context.Background(), headers, rows, 0)).Should(HaveOccurred())
+		Expect(s.writes).Should(Equal(3))
+		Expect(retrySink.DeadLetterRows()).Should(Equal(2))
+		Expect(retrySink.Close()).Should(Succeed())
+
+		file, err := os.Open(path)
+		Expect(err).ShouldNot(HaveOccurred())
+		defer file.Close()
+
+		var records []map[string]interface{}
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			var record map[string]interface{}
+			Expect(json.Unmarshal(scanner.Bytes(), &record)).Should(Succeed())
+			records = append(records, record)
+		}
+		Expect(records).Should(HaveLen(2))
+		Expect(records[0]["sink"]).Should(Equal("test"))
+		Expect(records[0]["error"]).Should(Equal("unavailable"))
+		Expect(records[1]["row"]).Should(Equal(map[string]interface{}{"value": 2.0}))
+	})
+
+	It("reject dead letter without destination", func() {
+		_, err := sink.NewRetrySink(&failingSink{}, sink.Configuration{DeadLetter: &sink.DeadLetterConfiguration{}})
+		Expect(err).Should(HaveOccurred())
+	})
+})
@@ -0,0 +1,244 @@
+package test_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
+	"github.com/timeplus-io/chameleon/generator/internal/scenario"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+	"github.com/timeplus-io/chameleon/generator/internal/target"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// fakeTarget records the statements, `select ready` returns a row after the third call
+type fakeTarget struct {
+	statements []string
+	readyCalls int
+	lock       sync.Mutex
+}
+
+var fakeTargetInstance = &fakeTarget{}
+
+func init() {
+	target.Register(target.TargetRegItem{
+		Name: "fake",
+		Constructor: func(properties map[string]interface{}) (target.Target, error) {
+			return fakeTargetInstance, nil
+		},
+	})
+}
+
+func (t *fakeTarget) Exec(ctx context.Context, statement string) error {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if strings.HasPrefix(statement, "fail") {
+		return fmt.Errorf("failed")
+	}
+	t.statements = append(t.statements, statement)
+	return nil
+}
+
+func (t *fakeTarget) Query(ctx context.Context, statement string) ([]map[string]interface{}, error) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.statements = append(t.statements, statement)
+	if statement == "select ready" {
+		t.readyCalls++
+		if t.readyCalls < 3 {
+			return nil, nil
+		}
+	}
+	return []map[string]interface{}{{"count": 42}}, nil
+}
+
+func (t *fakeTarget) Close() error {
+	return nil
+}
+
+func (t *fakeTarget) reset() []string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	statements := t.statements
+	t.statements = nil
+	t.readyCalls = 0
+	return statements
+}
+
+var _ = Describe("Test Scenario", func() {
+
+	BeforeEach(func() {
+		console.Init()
+		fakeTargetInstance.reset()
+	})
+
+	fake := &target.Configuration{Type: "fake", Properties: map[string]interface{}{}}
+	loadJob := &job.JobConfiguration{
+		Source: source.DefaultConfiguration(),
+		Sinks: []sink.Configuration{
+			{
+				Type:       "console",
+				Properties: map[string]interface{}{},
+			},
+		},
+		Timeout: 1,
+	}
+
+	It("validate scenario", func() {
+		_, err := scenario.NewScenarioRun(scenario.Configuration{Name: "empty"})
+		Expect(err).Should(HaveOccurred())
+
+		_, err = scenario.NewScenarioRun(scenario.Configuration{
+			Name:   "invalid",
+			Stages: []scenario.Stage{{Type: scenario.STAGE_SQL}},
+		})
+		Expect(err).Should(HaveOccurred())
+
+		_, err = scenario.NewScenarioRun(scenario.Configuration{
+			Name:   "invalid",
+			Stages: []scenario.Stage{{Type: "unknown"}},
+		})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("run stages in order with shared variables", func() {
+		run, err := scenario.NewScenarioRun(scenario.Configuration{
+			Name:      "bench",
+			Variables: map[string]string{"stream": "s1"},
+			Stages: []scenario.Stage{
+				{Name: "setup", Type: scenario.STAGE_SQL, Target: fake, Register: "setup",
+					Statements: []string{"create stream ${stream}", "select count() from ${stream}"}},
+				{Name: "load", Type: scenario.STAGE_PARALLEL, Stages: []scenario.Stage{
+					{Name: "ingest", Type: scenario.STAGE_JOB, Job: loadJob},
+					{Name: "warmup", Type: scenario.STAGE_SLEEP, Duration: 100},
+				}},
+				{Name: "check", Type: scenario.STAGE_WAIT, Target: fake, Query: "select ready", Interval: 10},
+				{Name: "teardown", Type: scenario.STAGE_SQL, Target: fake, Always: true,
+					Statements: []string{"drop stream ${stream} -- ${setup.count} ${unknown}"}},
+			},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(run.Run(context.Background())).Should(Equal(scenario.STATUS_PASSED))
+		for _, stage := range run.Stages {
+			Expect(stage.Status).Should(Equal(scenario.STATUS_PASSED))
+		}
+
+		ingest := run.Stages[1].Stages[0]
+		Expect(ingest.Run).ShouldNot(BeNil())
+		Expect(ingest.Run.Stats.SuccessWrite).Should(BeNumerically(">", 0))
+		Expect(run.Variables["ingest.run_id"]).Should(Equal(ingest.Run.Id))
+
+		statements := fakeTargetInstance.reset()
+		Expect(statements[0]).Should(Equal("create stream s1"))
+		Expect(statements[1]).Should(Equal("select count() from s1"))
+		Expect(statements[len(statements)-1]).Should(Equal("drop stream s1 -- 42 ${unknown}"))
+		Expect(len(statements)).Should(Equal(6))
+	})
+
+	It("skip stages after a failure except teardown", func() {
+		run, err := scenario.NewScenarioRun(scenario.Configuration{
+			Name: "bench",
+			Stages: []scenario.Stage{
+				{Name: "optional", Type: scenario.STAGE_SQL, Target: fake, Statements: []string{"fail soft"}, ContinueOnError: true},
+				{Name: "slow", Type: scenario.STAGE_SLEEP, Duration: 5000, Timeout: 1},
+				{Name: "load", Type: scenario.STAGE_SLEEP, Duration: 10},
+				{Name: "teardown", Type: scenario.STAGE_SQL, Target: fake, Always: true, Statements: []string{"drop"}},
+			},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		Expect(run.Run(context.Background())).Should(Equal(scenario.STATUS_FAILED))
+		Expect(run.Stages[0].Status).Should(Equal(scenario.STATUS_FAILED))
+		Expect(run.Stages[1].Status).Should(Equal(scenario.STATUS_FAILED))
+		Expect(run.Stages[1].Error).Should(ContainSubstring("timed out"))
+		Expect(run.Stages[2].Status).Should(Equal(scenario.STATUS_SKIPPED))
+		Expect(run.Stages[3].Status).Should(Equal(scenario.STATUS_PASSED))
+		Expect(fakeTargetInstance.reset()).Should(Equal([]string{"drop"}))
+	})
+
+	It("interpolate the scenario file and keep the scenario variables", func() {
+		os.Setenv("CHAMELEON_TEST_USER", "admin")
+		os.Setenv("CHAMELEON_TEST_PASSWORD", "s3cret")
+		defer os.Unsetenv("CHAMELEON_TEST_USER")
+		defer os.Unsetenv("CHAMELEON_TEST_PASSWORD")
+
+		dir, err := os.MkdirTemp("", "scenario")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+		file := filepath.Join(dir, "scenario.yaml")
+		Expect(os.WriteFile(file, []byte(`
+name: bench
+variables:
+  stream: s1
+stages:
+  - name: setup
+    type: sql
+    target:
+      type: fake
+      properties:
+        user: ${CHAMELEON_TEST_USER}
+    statements:
+      - create stream ${stream} -- ${scenario.id} $${literal}
+  - name: ingest
+    type: job
+    job:
+      source:
+        batch_size: 1
+      sinks:
+        - type: any
+          properties:
+            topic: ${stream}
+            password: ${CHAMELEON_TEST_PASSWORD}
+`), 0600)).Should(Succeed())
+
+		config, err := scenario.LoadConfig(file)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(config.Stages[0].Target.Properties["user"]).Should(Equal("admin"))
+		Expect(config.Stages[0].Statements[0]).Should(Equal("create stream ${stream} -- ${scenario.id} ${literal}"))
+		Expect(config.Stages[1].Job.Sinks[0].Properties["topic"]).Should(Equal("${stream}"))
+		Expect(config.Stages[1].Job.Sinks[0].Properties["password"]).Should(Equal("s3cret"))
+
+		Expect(os.WriteFile(file, []byte("name: bench\nstages:\n  - type: sleep\n    duration: ${CHAMELEON_TEST_MISSING}\n"), 0600)).Should(Succeed())
+		_, err = scenario.LoadConfig(file)
+		Expect(err).Should(MatchError(ContainSubstring("CHAMELEON_TEST_MISSING is not set")))
+	})
+
+	It("redact the secrets of the stages", func() {
+		secretJob := *loadJob
+		secretJob.Sinks = []sink.Configuration{{Type: "any", Properties: map[string]interface{}{"password": "s3cret"}}}
+		run, err := scenario.NewScenarioRun(scenario.Configuration{
+			Name: "bench",
+			Stages: []scenario.Stage{
+				{Name: "setup", Type: scenario.STAGE_SQL, Statements: []string{"create stream s1"},
+					Target: &target.Configuration{Type: "fake", Properties: map[string]interface{}{"password": "s3cret"}}},
+				{Name: "load", Type: scenario.STAGE_PARALLEL, Stages: []scenario.Stage{
+					{Name: "ingest", Type: scenario.STAGE_JOB, Job: &secretJob},
+				}},
+			},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		data, err := json.Marshal(run)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(string(data)).ShouldNot(ContainSubstring("s3cret"))
+		Expect(string(data)).Should(ContainSubstring(`"id":"` + run.Id + `"`))
+
+		var result map[string]interface{}
+		Expect(json.Unmarshal(data, &result)).Should(Succeed())
+		stages := result["config"].(map[string]interface{})["stages"].([]interface{})
+		Expect(stages).Should(HaveLen(2))
+
+		// the run keeps the secrets to run the stages
+		Expect(run.Config.Stages[1].Stages[0].Job.Sinks[0].Properties["password"]).Should(Equal("s3cret"))
+	})
+})
@@ -1,6 +1,7 @@
 package test_test
 
 import (
+	"context"
 	"time"
 
 	"github.com/timeplus-io/chameleon/generator/internal/job"
@@ -15,6 +16,7 @@ import (
 var _ = Describe("Test Splunk", func() {
 
 	BeforeEach(func() {
+		requireServices("localhost:8088")
 		//console.Init()
 	})
 
@@ -55,7 +57,7 @@ var _ = Describe("Test Splunk", func() {
 			Expect(err).ShouldNot(HaveOccurred())
 
 			go func() {
-				err = splunkOb.Observe()
+				err = splunkOb.Observe(context.Background())
 				Expect(err).ShouldNot(HaveOccurred())
 			}()
 
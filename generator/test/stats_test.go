@@ -0,0 +1,88 @@
+package test_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/timeplus-io/chameleon/generator/internal/job"
+	"github.com/timeplus-io/chameleon/generator/internal/plugins/console"
+	"github.com/timeplus-io/chameleon/generator/internal/sink"
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test Sink Stats", func() {
+
+	It("summarize latency histogram", func() {
+		histogram := sink.NewLatencyHistogram()
+		Expect(histogram.Summary()).Should(Equal(sink.LatencySummary{}))
+
+		for i := 1; i <= 100; i++ {
+			histogram.Observe(time.Duration(i) * time.Millisecond)
+		}
+
+		summary := histogram.Summary()
+		Expect(summary.Count).Should(Equal(int64(100)))
+		Expect(summary.P50).Should(BeNumerically("~", 50, 50*0.05))
+		Expect(summary.P90).Should(BeNumerically("~", 90, 90*0.05))
+		Expect(summary.P99).Should(BeNumerically("~", 99, 99*0.05))
+		Expect(summary.Max).Should(Equal(100.0))
+	})
+
+	It("count the writes of each sink", func() {
+		s := &failingSink{failures: 1, err: fmt.Errorf("unavailable")}
+		retrySink, err := sink.NewRetrySink(s, sink.Configuration{
+			Type:  "test",
+			Retry: &sink.RetryConfiguration{MaxAttempts: 2, InitialBackoff: 1},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+
+		headers := []string{"value"}
+		Expect(retrySink.Write(context.Background(), headers, [][]interface{}{{1}, {2}}, 0)).Should(Succeed())
+		Expect(retrySink.Write(context.Background(), headers, [][]interface{}{{3}}, 0)).Should(Succeed())
+
+		stats := retrySink.GetStats()
+		Expect(stats.SuccessWrite).Should(Equal(3))
+		Expect(stats.FailedWrite).Should(Equal(0))
+		Expect(stats.Writes).Should(Equal(3))
+		Expect(stats.Errors).Should(Equal(1))
+		Expect(stats.Retries).Should(Equal(1))
+		Expect(stats.Latency.Count).Should(Equal(int64(3)))
+	})
+
+	It("count the rows shared by several sinks once", func() {
+		delivery := sink.NewDelivery(2)
+		rows := [][]interface{}{{1}}
+		done, _ := (&sink.Batch{Rows: rows}).WithDelivery(delivery).Delivered(fmt.Errorf("unavailable"))
+		Expect(done).Should(BeFalse())
+		done, failed := (&sink.Batch{Rows: rows}).WithDelivery(delivery).Delivered(nil)
+		Expect(done).Should(BeTrue())
+		Expect(failed).Should(BeTrue())
+
+		console.Init()
+		ajob, err := job.NewJob(job.JobConfiguration{
+			Name:    "shared",
+			Source:  source.DefaultConfiguration(),
+			Timeout: 1,
+			Sinks: []sink.Configuration{
+				{Type: "console", Properties: map[string]interface{}{}},
+				{Type: "console", Properties: map[string]interface{}{}},
+			},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		ajob.Start()
+
+		stats := ajob.CurrentRun().Stats
+		Expect(stats.Generated).Should(BeNumerically(">", 0))
+		Expect(stats.SuccessWrite).Should(Equal(stats.Generated))
+		Expect(stats.FailedWrite).Should(Equal(0))
+		Expect(stats.BytesWritten).Should(BeNil())
+		for _, sinkStats := range stats.Sinks {
+			Expect(sinkStats.SuccessWrite).Should(Equal(stats.Generated))
+			Expect(sinkStats.BytesWritten).Should(BeNil())
+		}
+	})
+})
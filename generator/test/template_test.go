@@ -0,0 +1,105 @@
+package test_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/timeplus-io/chameleon/generator/internal/source"
+	"github.com/timeplus-io/chameleon/generator/internal/templates"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Test Template", func() {
+
+	It("generate events from each builtin template", func() {
+		catalogue, err := templates.NewCatalogue("")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(len(catalogue.List())).Should(BeNumerically(">=", 6))
+
+		for _, t := range catalogue.List() {
+			config, err := t.Instantiate(nil)
+			Expect(err).ShouldNot(HaveOccurred(), t.Name)
+			Expect(config.Sinks).ShouldNot(BeEmpty(), t.Name)
+
+			generator, err := source.NewGenarator(config.Source)
+			Expect(err).ShouldNot(HaveOccurred(), t.Name)
+			generator.Start(context.Background())
+			Expect(generator.Read()).ShouldNot(BeEmpty(), t.Name)
+			generator.Stop()
+		}
+	})
+
+	It("set the parameters of a template", func() {
+		catalogue, err := templates.NewCatalogue("")
+		Expect(err).ShouldNot(HaveOccurred())
+		iot, err := catalogue.Get("iot")
+		Expect(err).ShouldNot(HaveOccurred())
+
+		config, err := iot.Instantiate(map[string]interface{}{
+			"devices":  10,
+			"rate":     500,
+			"duration": 60,
+			"sinks":    []interface{}{map[string]interface{}{"type": "kafka", "properties": map[string]interface{}{"brokers": "localhost:9092"}}},
+		})
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(config.Name).Should(Equal("iot"))
+		Expect(config.Source.Rate).Should(Equal(500))
+		Expect(config.Timeout).Should(Equal(60))
+		Expect(config.Source.Fields[1].Limit).Should(Equal([]interface{}{float64(1), float64(10)}))
+		Expect(config.Sinks[0].Type).Should(Equal("kafka"))
+
+		// the template itself is not changed
+		config, err = iot.Instantiate(nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(config.Source.Fields[1].Limit).Should(Equal([]interface{}{float64(1), float64(1000)}))
+		Expect(config.Sinks[0].Type).Should(Equal("console"))
+
+		_, err = iot.Instantiate(map[string]interface{}{"unknown": 1})
+		Expect(err).Should(HaveOccurred())
+	})
+
+	It("load the templates of a directory", func() {
+		dir, err := os.MkdirTemp("", "templates")
+		Expect(err).ShouldNot(HaveOccurred())
+		defer os.RemoveAll(dir)
+
+		content := `
+name: orders
+description: orders of a shop
+parameters:
+- name: batch_size
+  path: source.batch_size
+  default: 5
+job:
+  name: orders
+  source:
+    batch_size: 1
+    concurency: 1
+    interval: 100
+    random_event: true
+    fields:
+    - name: id
+      type: sequence
+  sinks:
+  - type: console
+    properties: {}
+`
+		Expect(os.WriteFile(filepath.Join(dir, "orders.yaml"), []byte(content), 0644)).Should(Succeed())
+
+		catalogue, err := templates.NewCatalogue(dir)
+		Expect(err).ShouldNot(HaveOccurred())
+		orders, err := catalogue.Get("orders")
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(orders.Origin).Should(Equal(filepath.Join(dir, "orders.yaml")))
+
+		config, err := orders.Instantiate(nil)
+		Expect(err).ShouldNot(HaveOccurred())
+		Expect(config.Source.BatchSize).Should(Equal(5))
+
+		_, err = catalogue.Get("iot")
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+})
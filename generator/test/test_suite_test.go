@@ -1,7 +1,10 @@
 package test_test
 
 import (
+	"fmt"
+	"net"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -11,3 +14,15 @@ func TestTest(t *testing.T) {
 	RegisterFailHandler(Fail)
 	RunSpecs(t, "Test Suite")
 }
+
+// requireServices skips the spec when one of the services it writes to or observes is not reachable, so the
+// specs of the external targets only run where the targets are started
+func requireServices(addresses ...string) {
+	for _, address := range addresses {
+		conn, err := net.DialTimeout("tcp", address, time.Second)
+		if err != nil {
+			Skip(fmt.Sprintf("%s is not reachable", address))
+		}
+		conn.Close()
+	}
+}